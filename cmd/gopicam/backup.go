@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"go.etcd.io/bbolt"
+	"golang.org/x/term"
+
+	"github.com/jempe/gopicam/pkg/backup"
+	"github.com/jempe/gopicam/pkg/db"
+)
+
+const passphraseEnvVariable = "GOPICAM_BACKUP_PASSPHRASE"
+
+// backupFiles are the DBs saved in the backups, the sessions are included so
+// the users don't have to log in again after a restore
+func backupFiles(database *db.DB, sessionsDB *bbolt.DB) []backup.File {
+	files := restoreFiles(database.Path, sessionsDB.Path())
+
+	files[0].View = backup.BoltView(database.Store.View)
+	files[1].View = backup.BoltView(sessionsDB.View)
+
+	return files
+}
+
+func restoreFiles(dbPath string, sessionsDBPath string) []backup.File {
+	return []backup.File{
+		{
+			Name: "gopicam.db",
+			Path: dbPath,
+			Validate: func(path string) error {
+				_, err := db.CheckFile(path)
+				return err
+			},
+		},
+		{
+			Name:     "sessions.db",
+			Path:     sessionsDBPath,
+			Validate: backup.CheckBolt,
+		},
+	}
+}
+
+// checkNotInUse makes sure that the server isn't running, Bolt locks the DB
+// file while it's open
+func checkNotInUse(dbPath string) error {
+	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+		return nil
+	}
+
+	boltDB, err := bbolt.Open(dbPath, 0600, &bbolt.Options{ReadOnly: true, Timeout: time.Second})
+	if err != nil {
+		return errors.New("Error: " + dbPath + " is in use, stop gopicam first")
+	}
+
+	return boltDB.Close()
+}
+
+// readPassphrase gets the passphrase of the backups from the passphrase file of
+// the settings, the environment variable or asks it in the terminal
+func readPassphrase(configPath string, confirm bool) (passphrase string, err error) {
+	if settings.Backups.PassphraseFile != "" {
+		passphraseContent, readErr := ioutil.ReadFile(configFile(configPath, settings.Backups.PassphraseFile))
+		if readErr != nil {
+			return "", readErr
+		}
+
+		passphrase = strings.TrimRight(string(passphraseContent), "\r\n")
+	} else if envPassphrase, ok := os.LookupEnv(passphraseEnvVariable); ok {
+		passphrase = envPassphrase
+	} else if term.IsTerminal(int(os.Stdin.Fd())) {
+		passphrase, err = askPassword("Backup passphrase")
+		if err != nil {
+			return
+		}
+
+		if confirm {
+			confirmation, confirmErr := askPassword("Repeat the passphrase")
+			if confirmErr != nil {
+				return "", confirmErr
+			}
+
+			if passphrase != confirmation {
+				return "", errors.New("Error: the passphrases don't match")
+			}
+		}
+	} else {
+		return "", fmt.Errorf("Error: set the passphrase file with the backups.passphrase_file setting, the -backup-passphrase-file flag or the passphrase with the %s environment variable", passphraseEnvVariable)
+	}
+
+	if passphrase == "" {
+		err = errors.New("Error: the passphrase can't be empty")
+	}
+
+	return
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"go.etcd.io/bbolt"
+
+	"github.com/jempe/gopicam/pkg/backup"
+	"github.com/jempe/gopicam/pkg/db"
+)
+
+const dbCommandUsage = `Usage: gopicam [flags] db <command>
+
+Commands:
+  status                      Show the version of the DB and the applied and pending migrations
+  migrate [-dry-run]          Apply the pending migrations, the DB is copied to
+                              gopicam.db.v<version>-<time>.bak before migrating. With
+                              -dry-run the migrations are rolled back
+  backup [-gzip] [-encrypt] [file]
+                              Save a backup of gopicam.db and sessions.db to the file, to
+                              the backups folder without file or to the output with -
+  restore <file>              Replace the DBs with the DBs of a backup, - reads the backup
+                              from the input
+  check [-repair]             Check the DB files and every record: records that can't be
+                              decoded or don't pass the validators, locations of unknown
+                              devices, sessions of unknown users, media records without
+                              file and wrong index entries. -repair deletes the records
+                              that can't be fixed by hand and rebuilds the indexes
+  compact                     Rewrite gopicam.db and sessions.db without the free pages
+
+The DB commands can't run while the server is running, the backups of a running
+server are downloaded from /api/backup. The passphrase of the encrypted backups is
+read from the file of the backups.passphrase_file setting, the ` + passphraseEnvVariable + `
+environment variable or asked in the terminal.`
+
+// runDBCommand runs the DB maintenance commands, they open the DB without
+// migrating it
+func runDBCommand(configPath string, args []string) error {
+	if len(args) == 0 {
+		return errors.New(dbCommandUsage)
+	}
+
+	dbPath := configPath + "/gopicam.db"
+	sessionsDBPath := configPath + "/sessions.db"
+
+	err := checkNotInUse(dbPath)
+	if err != nil {
+		return err
+	}
+
+	err = checkNotInUse(sessionsDBPath)
+	if err != nil {
+		return err
+	}
+
+	if args[0] == "restore" {
+		if len(args) != 2 {
+			return errors.New(dbCommandUsage)
+		}
+
+		// the DB is replaced, its settings are not used
+		err = loadSettings(nil)
+		if err != nil {
+			return errors.New("Error: " + err.Error())
+		}
+
+		return restoreBackup(configPath, args[1])
+	}
+
+	if args[0] == "compact" {
+		if len(args) != 1 {
+			return errors.New(dbCommandUsage)
+		}
+
+		return compactDBs(dbPath, sessionsDBPath)
+	}
+
+	if args[0] == "check" {
+		err = checkDBFiles(dbPath, sessionsDBPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	database := &db.DB{Path: dbPath, Timeout: *dbTimeout}
+
+	err = database.Open()
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	// the settings of the backups are saved in the DB
+	err = loadSettings(database)
+	if err != nil {
+		return errors.New("Error: " + err.Error())
+	}
+
+	switch args[0] {
+	case "status":
+		return migrationStatus(database)
+	case "migrate":
+		flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+		dryRun := flags.Bool("dry-run", false, "Run the migrations without saving the changes")
+
+		err := flags.Parse(args[1:])
+		if err != nil {
+			return errors.New(dbCommandUsage)
+		}
+
+		if *dryRun {
+			pending, err := database.MigrateDryRun()
+			if err != nil {
+				return err
+			}
+
+			if len(pending) == 0 {
+				fmt.Println("The DB is up to date")
+			}
+
+			for _, migration := range pending {
+				fmt.Println("Migration", migration.Version, "can be applied:", migration.Description)
+			}
+
+			return nil
+		}
+
+		applied, err := database.Migrate()
+
+		for _, record := range applied {
+			fmt.Println("Applied migration", record.Version, record.Description)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if len(applied) == 0 {
+			fmt.Println("The DB is up to date")
+		} else if applied[0].Backup != "" {
+			fmt.Println("Backup of the DB before migrating:", applied[0].Backup)
+		}
+	case "backup":
+		flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+		compress := flags.Bool("gzip", false, "Compress the backup")
+		encrypt := flags.Bool("encrypt", false, "Encrypt the backup with a passphrase")
+
+		err := flags.Parse(args[1:])
+		if err != nil || flags.NArg() > 1 {
+			return errors.New(dbCommandUsage)
+		}
+
+		return saveBackup(database, configPath, flags.Arg(0), *compress, *encrypt)
+	case "check":
+		flags := flag.NewFlagSet("check", flag.ContinueOnError)
+		repair := flags.Bool("repair", false, "Repair the problems")
+
+		err := flags.Parse(args[1:])
+		if err != nil || flags.NArg() > 0 {
+			return errors.New(dbCommandUsage)
+		}
+
+		return checkDB(database, configPath+"/media", *repair)
+	default:
+		return errors.New(dbCommandUsage)
+	}
+
+	return nil
+}
+
+func migrationStatus(database *db.DB) error {
+	status, err := database.MigrationStatus()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("DB version %d, latest version %d\n\n", status.Version, status.Latest)
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "VERSION\tSTATUS\tAPPLIED\tDESCRIPTION")
+
+	for _, record := range status.Applied {
+		fmt.Fprintf(writer, "%d\tapplied\t%s\t%s\n", record.Version, record.Applied.Format("2006-01-02 15:04"), record.Description)
+	}
+
+	for _, migration := range status.Pending {
+		fmt.Fprintf(writer, "%d\tpending\t\t%s\n", migration.Version, migration.Description)
+	}
+
+	return writer.Flush()
+}
+
+// checkDBFiles checks the pages of the DB files, it runs before the DB is opened
+func checkDBFiles(dbPath string, sessionsDBPath string) error {
+	_, err := db.CheckFile(dbPath)
+	if err != nil {
+		return errors.New("Error: " + dbPath + " is corrupt, restore a backup: " + err.Error())
+	}
+
+	if _, statErr := os.Stat(sessionsDBPath); statErr == nil {
+		err = backup.CheckBolt(sessionsDBPath)
+		if err != nil {
+			return errors.New("Error: " + sessionsDBPath + " is corrupt, delete it to log out all the users: " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+// checkDB checks the records, they are only checked when the DB is migrated to
+// the last version
+func checkDB(database *db.DB, mediaFolder string, repair bool) error {
+	status, err := database.MigrationStatus()
+	if err != nil {
+		return err
+	}
+
+	if len(status.Pending) > 0 {
+		return errors.New("Error: the DB has pending migrations, run gopicam db migrate before checking it")
+	}
+
+	// without media folder every media record would be deleted by the repair
+	if _, statErr := os.Stat(mediaFolder); statErr != nil {
+		fmt.Println("The media folder", mediaFolder, "doesn't exist, the media files are not checked")
+		mediaFolder = ""
+	}
+
+	report, err := database.Check(db.CheckOptions{MediaFolder: mediaFolder, Repair: repair})
+	if err != nil {
+		return err
+	}
+
+	var records int64
+	for _, count := range report.Records {
+		records += count
+	}
+
+	fmt.Printf("Checked %d records in %d buckets\n", records, len(report.Records))
+
+	if len(report.Issues) == 0 {
+		fmt.Println("No problems found")
+		return nil
+	}
+
+	fmt.Println()
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "BUCKET\tKEY\tPROBLEM\tREPAIRED\tERROR")
+
+	for _, issue := range report.Issues {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%t\t%s\n", issue.Bucket, issue.Key, issue.Problem, issue.Repaired, issue.Error)
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+
+	unrepaired := report.Unrepaired()
+
+	if unrepaired == 0 {
+		fmt.Println("Repaired", len(report.Issues), "problems")
+		return nil
+	} else if repair {
+		return fmt.Errorf("Error: %d problems were not repaired, the invalid records have to be fixed by hand", unrepaired)
+	}
+
+	return fmt.Errorf("Error: found %d problems, run gopicam db check -repair to repair them, a backup with gopicam db backup is recommended before", unrepaired)
+}
+
+// compactDBs compacts the DB files, they are locked by CompactFile
+func compactDBs(paths ...string) error {
+	for _, path := range paths {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+
+		before, after, err := db.CompactFile(path, *dbTimeout)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Compacted %s from %d to %d KB\n", path, before/1024, after/1024)
+	}
+
+	return nil
+}
+
+// saveBackup writes the backup to the file, the output when the file is - or a
+// new file in the backups folder when it's empty
+func saveBackup(database *db.DB, configPath string, backupPath string, compress bool, encrypt bool) error {
+	sessionsDB, err := bbolt.Open(configPath+"/sessions.db", 0600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+	defer sessionsDB.Close()
+
+	options := backup.Options{Compress: compress}
+
+	if encrypt || settings.Backups.PassphraseFile != "" {
+		options.Passphrase, err = readPassphrase(configPath, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	files := backupFiles(database, sessionsDB)
+
+	switch backupPath {
+	case "":
+		backupPath, err = backup.Save(backupsFolder(configPath), files, options)
+		if err != nil {
+			return err
+		}
+	case "-":
+		return backup.Write(os.Stdout, files, options)
+	default:
+		backupFile, err := os.OpenFile(backupPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return err
+		}
+
+		err = backup.Write(backupFile, files, options)
+
+		closeErr := backupFile.Close()
+		if err == nil {
+			err = closeErr
+		}
+
+		if err != nil {
+			os.Remove(backupPath)
+			return err
+		}
+	}
+
+	fmt.Fprintln(os.Stderr, "Backup saved in", backupPath)
+
+	return nil
+}
+
+func restoreBackup(configPath string, backupPath string) error {
+	var input io.Reader = os.Stdin
+
+	if backupPath != "-" {
+		backupFile, err := os.Open(backupPath)
+		if err != nil {
+			return err
+		}
+		defer backupFile.Close()
+
+		input = backupFile
+	}
+
+	var version int
+
+	files := restoreFiles(configPath+"/gopicam.db", configPath+"/sessions.db")
+
+	files[0].Validate = func(path string) (err error) {
+		version, err = db.CheckFile(path)
+		return
+	}
+
+	err := backup.Restore(input, files, func() (string, error) {
+		return readPassphrase(configPath, false)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Restored the backup of the DB version", version, "- the previous DBs were renamed to gopicam.db.pre-restore and sessions.db.pre-restore")
+
+	if version < db.DB_VERSION {
+		fmt.Println("The DB will be migrated to the version", db.DB_VERSION, "when gopicam starts")
+	}
+
+	return nil
+}
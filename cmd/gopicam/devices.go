@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jempe/gopicam/pkg/db"
+)
+
+const deviceCommandUsage = `Usage: gopicam [flags] device <command>
+
+Commands:
+  add <name>          Enroll a device, the key and the secret that sign its
+                      requests to /api/ingest are shown once
+  delete <id>         Remove a device, its requests are rejected
+  list                List the devices`
+
+// runDeviceCommand manages the devices that send their locations from the command line
+func runDeviceCommand(database *db.DB, args []string) error {
+	if len(args) == 0 {
+		return errors.New(deviceCommandUsage)
+	}
+
+	switch args[0] {
+	case "list":
+		if len(args) != 1 {
+			return errors.New(deviceCommandUsage)
+		}
+
+		return listDevices(database)
+	case "add":
+		if len(args) < 2 {
+			return errors.New(deviceCommandUsage)
+		}
+
+		device, err := database.EnrollDevice(strings.Join(args[1:], " "))
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Device", device.Name, "enrolled, save the secret now, it isn't shown again")
+		fmt.Println()
+		fmt.Println("ID:    ", device.ID)
+		fmt.Println("Key:   ", device.Key)
+		fmt.Println("Secret:", device.Secret)
+	case "delete":
+		if len(args) != 2 {
+			return errors.New(deviceCommandUsage)
+		}
+
+		rowsAffected, err := database.DeleteDevice(args[1])
+		if err != nil {
+			return err
+		}
+
+		if rowsAffected == 0 {
+			return errors.New("Error: the device " + args[1] + " doesn't exist")
+		}
+
+		fmt.Println("Device", args[1], "deleted")
+	default:
+		return errors.New(deviceCommandUsage)
+	}
+
+	return nil
+}
+
+func listDevices(database *db.DB) error {
+	devices, _, err := database.GetDeviceList(0, 1000, db.Filters{Operator: "AND"}, []string{}, db.SortBy{Field: "Name", Direction: "ASC"})
+	if err != nil {
+		return err
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "ID\tNAME\tKEY\tCREATED")
+
+	for _, device := range devices {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", device.ID, device.Name, device.Key, device.Created.Format("2006-01-02 15:04"))
+	}
+
+	return writer.Flush()
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jempe/gopicam/pkg/db"
+)
+
+var exportCommandUsage = `Usage: gopicam [flags] export -bucket <bucket> [-format jsonl|csv] [-filter <expression>] [-o <file>]
+       gopicam [flags] import -bucket <bucket> [-format jsonl|csv] [-dry-run] <file>
+
+The buckets are ` + strings.Join(db.ExportBuckets, ", ") + `. The export is written to the
+output without -o and the import reads the input when the file is -. The import
+validates every row, saves the valid ones and shows the errors of the others.`
+
+// runExportCommand writes the items of a bucket as JSON Lines or CSV
+func runExportCommand(database *db.DB, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	bucket := flags.String("bucket", "", "Bucket to export")
+	format := flags.String("format", db.FormatJSONL, "Format of the export: jsonl or csv")
+	filter := flags.String("filter", "", "Export the items that meet the filter expression")
+	outputPath := flags.String("o", "", "Write the export to this file")
+
+	err := flags.Parse(args)
+	if err != nil || *bucket == "" || flags.NArg() > 0 {
+		return errors.New(exportCommandUsage)
+	}
+
+	repo, err := database.Exportable(*bucket)
+	if err != nil {
+		return err
+	}
+
+	filters, err := db.ParseFilter(*filter)
+	if err != nil {
+		return err
+	}
+
+	var output io.Writer = os.Stdout
+
+	if *outputPath != "" {
+		outputFile, err := os.OpenFile(*outputPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+		defer outputFile.Close()
+
+		output = outputFile
+	}
+
+	count, err := repo.Export(output, *format, filters)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr, "Exported", count, *bucket)
+
+	return nil
+}
+
+// runImportCommand saves the items of a JSON Lines or CSV file in a bucket
+func runImportCommand(database *db.DB, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	bucket := flags.String("bucket", "", "Bucket of the items")
+	format := flags.String("format", db.FormatJSONL, "Format of the file: jsonl or csv")
+	dryRun := flags.Bool("dry-run", false, "Validate the rows without saving them")
+
+	err := flags.Parse(args)
+	if err != nil || *bucket == "" || flags.NArg() != 1 {
+		return errors.New(exportCommandUsage)
+	}
+
+	repo, err := database.Exportable(*bucket)
+	if err != nil {
+		return err
+	}
+
+	var input io.Reader = os.Stdin
+
+	if flags.Arg(0) != "-" {
+		inputFile, err := os.Open(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer inputFile.Close()
+
+		input = inputFile
+	}
+
+	result, err := repo.Import(input, *format, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	for _, rowError := range result.Errors {
+		fmt.Printf("row %d: %s\n", rowError.Row, rowError.Error)
+	}
+
+	if int64(len(result.Errors)) < result.Failed {
+		fmt.Println("...", result.Failed-int64(len(result.Errors)), "more errors")
+	}
+
+	if *dryRun {
+		fmt.Println(result.Imported, "valid rows,", result.Failed, "errors, nothing was saved")
+	} else {
+		fmt.Println("Imported", result.Imported, *bucket+",", result.Failed, "errors")
+	}
+
+	if result.Failed > 0 {
+		return errors.New("Error: some rows were not imported")
+	}
+
+	return nil
+}
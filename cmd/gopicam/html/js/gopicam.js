@@ -80,6 +80,59 @@ function populate_form_data(form, form_data, csrf_token)
 
 /*--includeend--*/
 
+// CSRF token of the session, it's sent with every POST and DELETE request
+let csrf_token = "";
+
+// get the CSRF token of the session
+function get_csrf_token()
+{
+	if(csrf_token != "")
+	{
+		return Promise.resolve(csrf_token);
+	}
+
+	let csrfRequest = Object.assign({}, requestInit);
+	csrfRequest["method"] = "GET";
+
+	return fetch("/api/csrf", csrfRequest).then(handleResponse).then(handleJson).then(function(data)
+	{
+		csrf_token = data.csrf_token;
+
+		return csrf_token;
+	});
+}
+
+// send a request to the API adding the CSRF token to state changing requests,
+// if the token is rejected it's requested again and the request is retried once
+function api_fetch(url, method, retry)
+{
+	let apiRequest = Object.assign({}, requestInit);
+	apiRequest["method"] = method;
+	apiRequest["headers"] = Object.assign({}, requestInit.headers);
+
+	if(method == "GET")
+	{
+		return fetch(url, apiRequest);
+	}
+
+	return get_csrf_token().then(function(token)
+	{
+		apiRequest.headers["X-CSRF-Token"] = token;
+
+		return fetch(url, apiRequest);
+	}).then(function(response)
+	{
+		if(response.status == 403 && retry !== false)
+		{
+			csrf_token = "";
+
+			return api_fetch(url, method, false);
+		}
+
+		return response;
+	});
+}
+
 // show login form
 function show_login()
 {
@@ -121,8 +174,11 @@ function submit_login_form(form)
 		}
 		else
 		{
+			csrf_token = data.csrf_token;
+
 			hide_login();
 			get_preview();
+			check_speaker();
 		}
 
 	}).catch(function(error)
@@ -133,6 +189,117 @@ function submit_login_form(form)
 	return false;
 }
 
+// show the setup form if the admin account doesn't exist, otherwise start the preview
+function check_setup()
+{
+	let setupRequest = Object.assign({}, requestInit);
+	setupRequest["method"] = "GET";
+
+	fetch("/api/setup", setupRequest).then(handleResponse).then(handleJson).then(function(data)
+	{
+		if(data.setup_required)
+		{
+			document.body.classList.add("show_setup");
+		}
+		else
+		{
+			get_preview();
+			check_speaker();
+		}
+	}).catch(function(error)
+	{
+		log_error('Request failed' +  error);
+	});
+}
+
+//submit setup form
+function submit_setup_form()
+{
+	let setup_form = document.getElementById("setup_form");
+	let setup_container = setup_form.parentNode;
+
+	setup_container.classList.remove("error");
+
+	let setupRequest = {
+		"cache": "no-store",
+		headers: {
+			"Content-Type": "application/x-www-form-urlencoded"
+		},
+		"method" : "POST",
+		"body" : new URLSearchParams(new FormData(setup_form)).toString()
+	}
+
+	fetch("/api/setup", setupRequest).then(function(response)
+	{
+		return response.json();
+	}).then(function(data)
+	{
+		if(data.status != "success")
+		{
+			let error_message = setup_form.querySelector("span.error");
+
+			if(error_message == null)
+			{
+				setup_form.insertAdjacentHTML('afterBegin', '<span class="error"></span>');
+				error_message = setup_form.querySelector("span.error");
+			}
+
+			error_message.textContent = setup_error_message(data);
+			setup_container.classList.add("error");
+		}
+		else
+		{
+			csrf_token = data.csrf_token;
+
+			document.body.classList.remove("show_setup");
+			get_preview();
+			check_speaker();
+		}
+	}).catch(function(error)
+	{
+		log_error('Request failed' +  error);
+	});
+
+	return false;
+}
+
+// convert the error codes of the setup API to messages
+function setup_error_message(data)
+{
+	let error = data.error;
+
+	if(error == undefined)
+	{
+		return "The admin account already exists";
+	}
+	else if(error == "error:password_confirmation")
+	{
+		return "The passwords don't match";
+	}
+	else if(data.field == "username" && /^error:(min_length_error|max_length_error|alphanumdash_error)/.test(error))
+	{
+		return "The username must have between 6 and 25 lowercase letters, numbers, dashes or underscores";
+	}
+	else if(/^error:min_length_error/.test(error))
+	{
+		return "The password must have at least " + error.split("|")[1] + " characters";
+	}
+	else if(/^error:max_length_error/.test(error))
+	{
+		return "The password is too long";
+	}
+	else if(error == "error:common_password_error")
+	{
+		return "This password is too common, choose a different one";
+	}
+	else if(error == "error:password_username_error")
+	{
+		return "The password can't contain the username";
+	}
+
+	return error;
+}
+
 // preview image and camera status
 function get_preview()
 {
@@ -188,10 +355,6 @@ function get_preview()
 
 // send command to camera API
 function send_command(camera_command) {
-	// prepare request
-	let commandRequest = requestInit;
-	commandRequest["method"] = "GET";
-
 	let command_url = "";
 
 	if(camera_command == "power")
@@ -246,7 +409,7 @@ function send_command(camera_command) {
 	if(command_url != "")
 	{
 		// send command to api
-		fetch(command_url, commandRequest).then(handleResponse).then(handleJson).then(function(data)
+		api_fetch(command_url, "POST").then(handleResponse).then(handleJson).then(function(data)
 		{
 			console.log(data);
 		}).catch(function(error)
@@ -256,6 +419,242 @@ function send_command(camera_command) {
 	}
 }
 
+// show settings popup
+function show_settings()
+{
+	document.body.classList.add("show_settings");
+
+	load_sessions();
+
+	return false;
+}
+
+// hide settings popup
+function hide_settings()
+{
+	document.body.classList.remove("show_settings");
+}
+
+// list the active sessions of the user
+function load_sessions()
+{
+	let sessionsRequest = Object.assign({}, requestInit);
+	sessionsRequest["method"] = "GET";
+
+	fetch("/api/sessions", sessionsRequest).then(handleResponse).then(handleJson).then(function(data)
+	{
+		let sessions_list = document.getElementById("sessions_list");
+		sessions_list.innerHTML = "";
+
+		data.sessions.forEach(function(session)
+		{
+			let session_item = document.createElement("li");
+
+			if(session.current)
+			{
+				session_item.classList.add("current");
+			}
+
+			let session_info = document.createElement("span");
+			session_info.classList.add("session_info");
+			session_info.textContent = session.user_agent;
+
+			let session_details = document.createElement("span");
+			session_details.classList.add("session_details");
+			session_details.textContent = session.ip + " - last seen " + new Date(session.last_seen).toLocaleString();
+			session_info.appendChild(session_details);
+
+			let revoke_button = document.createElement("button");
+			revoke_button.textContent = "Revoke";
+			revoke_button.addEventListener("click", function()
+			{
+				revoke_session(session.id);
+			});
+
+			session_item.appendChild(session_info);
+			session_item.appendChild(revoke_button);
+			sessions_list.appendChild(session_item);
+		});
+	}).catch(function(error)
+	{
+		log_error('Request failed' +  error);
+	});
+}
+
+// revoke one of the sessions
+function revoke_session(session_id)
+{
+	api_fetch("/api/sessions?id=" + encodeURIComponent(session_id), "DELETE").then(handleResponse).then(handleJson).then(function(data)
+	{
+		load_sessions();
+	}).catch(function(error)
+	{
+		log_error('Request failed' +  error);
+	});
+}
+
+// log out the current session
+function logout()
+{
+	api_fetch("/api/logout", "POST").then(handleResponse).then(handleJson).then(function(data)
+	{
+		csrf_token = "";
+		hide_settings();
+		show_login();
+	}).catch(function(error)
+	{
+		log_error('Request failed' +  error);
+	});
+}
+
+// revoke every session of the user
+function logout_everywhere()
+{
+	api_fetch("/api/sessions/revoke_all", "POST").then(handleResponse).then(handleJson).then(function(data)
+	{
+		csrf_token = "";
+		hide_settings();
+		show_login();
+	}).catch(function(error)
+	{
+		log_error('Request failed' +  error);
+	});
+}
+
+// WebSocket and recorder of the push to talk
+let talk_socket = null;
+let talk_recorder = null;
+
+// show the talk button if the camera has a speaker
+function check_speaker()
+{
+	let speakerRequest = Object.assign({}, requestInit);
+	speakerRequest["method"] = "GET";
+
+	fetch("/api/speaker", speakerRequest).then(handleResponse).then(handleJson).then(function(data)
+	{
+		document.body.classList.add("speaker_enabled");
+	}).catch(function(error)
+	{
+		// the speaker is disabled
+		document.body.classList.remove("speaker_enabled");
+	});
+}
+
+// start or stop talking through the speaker of the camera
+function toggle_talk()
+{
+	if(talk_socket != null)
+	{
+		stop_talk();
+	}
+	else
+	{
+		start_talk();
+	}
+}
+
+// send the audio of the microphone to the speaker, the chunks of the recorder
+// are Opus audio that is decoded by the camera
+function start_talk()
+{
+	if(typeof MediaRecorder == "undefined")
+	{
+		log_error("The browser can't record audio");
+		return;
+	}
+
+	let mime_type = ["audio/webm;codecs=opus", "audio/ogg;codecs=opus"].find(function(type)
+	{
+		return MediaRecorder.isTypeSupported(type);
+	});
+
+	if(mime_type == undefined)
+	{
+		log_error("The browser can't record Opus audio");
+		return;
+	}
+
+	let protocol = location.protocol == "https:" ? "wss:" : "ws:";
+
+	let socket = new WebSocket(protocol + "//" + location.host + "/api/talk?codec=opus");
+	talk_socket = socket;
+
+	socket.addEventListener("close", function(event)
+	{
+		if(event.code != 1000)
+		{
+			// the handshake fails when another user is talking or the session ended
+			log_error("Talk closed " + event.code + " " + event.reason);
+		}
+
+		if(talk_socket == socket)
+		{
+			stop_talk();
+		}
+	});
+
+	navigator.mediaDevices.getUserMedia({ audio: true }).then(function(stream)
+	{
+		if(talk_socket != socket)
+		{
+			stream.getTracks().forEach(function(track) { track.stop(); });
+			return;
+		}
+
+		talk_recorder = new MediaRecorder(stream, { mimeType: mime_type });
+
+		talk_recorder.addEventListener("dataavailable", function(event)
+		{
+			if(socket.readyState == WebSocket.OPEN && event.data.size > 0)
+			{
+				socket.send(event.data);
+			}
+		});
+
+		if(socket.readyState == WebSocket.OPEN)
+		{
+			talk_recorder.start(100);
+		}
+		else
+		{
+			socket.addEventListener("open", function()
+			{
+				talk_recorder.start(100);
+			});
+		}
+
+		document.getElementById("talk_button").classList.add("talking");
+	}).catch(function(error)
+	{
+		log_error("Microphone not available " + error);
+		stop_talk();
+	});
+}
+
+// stop the recorder and close the WebSocket of the talk
+function stop_talk()
+{
+	if(talk_recorder != null)
+	{
+		if(talk_recorder.state != "inactive")
+		{
+			talk_recorder.stop();
+		}
+
+		talk_recorder.stream.getTracks().forEach(function(track) { track.stop(); });
+		talk_recorder = null;
+	}
+
+	if(talk_socket != null)
+	{
+		talk_socket.close(1000);
+		talk_socket = null;
+	}
+
+	document.getElementById("talk_button").classList.remove("talking");
+}
+
 function toggleFullScreen()
 {
 	if( ! document.fullscreenElement)
@@ -271,4 +670,4 @@ function toggleFullScreen()
 	}
 }
 
-get_preview();
+check_setup();
@@ -1,8 +1,8 @@
 package main
 
 import (
-	"bufio"
 	"embed"
+	"errors"
 	"flag"
 	"fmt"
 	"io/fs"
@@ -19,21 +19,35 @@ import (
 	"github.com/alexedwards/scs/boltstore"
 	"github.com/alexedwards/scs/v2"
 	"go.etcd.io/bbolt"
-	"golang.org/x/crypto/bcrypt"
 
+	"github.com/jempe/gopicam/pkg/audio"
+	"github.com/jempe/gopicam/pkg/backup"
 	"github.com/jempe/gopicam/pkg/camera"
 	"github.com/jempe/gopicam/pkg/db"
+	"github.com/jempe/gopicam/pkg/events"
 	"github.com/jempe/gopicam/pkg/handlers"
+	"github.com/jempe/gopicam/pkg/tracker"
 	"github.com/jempe/gopicam/pkg/utils"
-	"github.com/jempe/gopicam/pkg/validator"
 )
 
 var configPathFlag = flag.String("config", "", "Define the path of config folder")
-var resetAdmin = flag.Bool("reset", false, "Reset admin username and password")
 var showHelp = flag.Bool("help", false, "Show Help")
-var insecureServer = flag.Bool("insecure", false, "Run web server without HTTPS")
-var port = flag.Int("port", 443, "Web Server Port")
+var insecureServer = flag.Bool("insecure", defaultSettings.Server.Insecure, "Run web server without HTTPS")
+var port = flag.Int("port", defaultSettings.Server.Port, "Web Server Port")
 var debugMode = flag.Bool("debug", false, "Print all Debug messages")
+var sessionIdleTimeout = flag.Duration("session-idle", time.Duration(defaultSettings.Sessions.IdleTimeout), "Log out sessions that haven't been used during this time")
+var sessionLifetime = flag.Duration("session-lifetime", time.Duration(defaultSettings.Sessions.Lifetime), "Maximum lifetime of a session, regardless of activity")
+var passwordFile = flag.String("password-file", "", "Read the password of the user commands from this file")
+var passwordMinLength = flag.Int("password-min-length", defaultSettings.Passwords.MinLength, "Minimum length of the user passwords")
+var passwordAllowCommon = flag.Bool("password-allow-common", defaultSettings.Passwords.AllowCommon, "Allow passwords that are in the list of breached and common passwords")
+var passwordHash = flag.String("password-hash", defaultSettings.Passwords.Hash, "Algorithm of the password hashes: argon2id or bcrypt, old hashes are upgraded on login")
+var auditRetention = flag.Duration("audit-retention", time.Duration(defaultSettings.Retention.Audit), "Delete the audit log entries older than this time, 0 keeps them forever")
+var bcryptCost = flag.Int("bcrypt-cost", defaultSettings.Passwords.BcryptCost, "Cost of the bcrypt password hashes")
+var backupDir = flag.String("backup-dir", defaultSettings.Backups.Dir, "Folder of the backups, by default the backups folder of the config folder")
+var backupInterval = flag.Duration("backup-interval", time.Duration(defaultSettings.Backups.Interval), "Save a backup of the DBs to the backups folder at this interval, 0 disables the scheduled backups")
+var backupKeep = flag.Int("backup-keep", defaultSettings.Backups.Keep, "Number of scheduled backups to keep")
+var backupPassphraseFile = flag.String("backup-passphrase-file", defaultSettings.Backups.PassphraseFile, "Encrypt the backups with the passphrase of this file")
+var dbTimeout = flag.Duration("db-timeout", db.DefaultTimeout, "Time to wait for the lock of the DB files, another gopicam instance holds it while it's running")
 
 var logError *log.Logger
 var logInfo *log.Logger
@@ -60,6 +74,14 @@ func main() {
 	// if help argument is present show flag Defaults
 	if *showHelp {
 		flag.PrintDefaults()
+		fmt.Println()
+		fmt.Println(userCommandUsage)
+		fmt.Println()
+		fmt.Println(dbCommandUsage)
+		fmt.Println()
+		fmt.Println(exportCommandUsage)
+		fmt.Println()
+		fmt.Println(deviceCommandUsage)
 		os.Exit(0)
 	}
 
@@ -88,99 +110,241 @@ func main() {
 	dbPath := configPath + "/gopicam.db"
 	sessionsDBPath := configPath + "/sessions.db"
 
-	sessionsDB, sessionsDBErr := bbolt.Open(sessionsDBPath, 0600, nil)
+	database := &db.DB{Path: dbPath, Timeout: *dbTimeout}
+
+	// Run the DB commands before migrating, so the migrations can be checked
+	if flag.Arg(0) == "db" {
+		commandErr := runDBCommand(configPath, flag.Args()[1:])
+
+		if commandErr != nil {
+			logAndExit(commandErr.Error())
+		}
+
+		os.Exit(0)
+	}
+
+	err := database.InitDb()
+	if errors.Is(err, db.ErrLocked) {
+		logAndExit("Error: " + dbPath + " is in use, stop the other gopicam instance first")
+	} else if err != nil {
+		logAndExit("Couldn't create the DB")
+	}
+	defer database.Close()
+
+	err = loadSettings(database)
+	if err != nil {
+		logAndExit("Error: " + err.Error())
+	}
+
+	// Run the user account, device and export commands and exit
+	var command func(database *db.DB, args []string) error
+
+	switch flag.Arg(0) {
+	case "user":
+		command = runUserCommand
+	case "export":
+		command = runExportCommand
+	case "import":
+		command = runImportCommand
+	case "device":
+		command = runDeviceCommand
+	}
+
+	if command != nil {
+		commandErr := command(database, flag.Args()[1:])
+		database.Close()
+
+		if commandErr != nil {
+			logAndExit(commandErr.Error())
+		}
+
+		os.Exit(0)
+	} else if flag.NArg() > 0 {
+		logAndExit("Unknown command " + flag.Arg(0) + ", run gopicam -help to see the available commands")
+	}
+
+	sessionsDB, sessionsDBErr := bbolt.Open(sessionsDBPath, 0600, &bbolt.Options{Timeout: *dbTimeout})
 	if sessionsDBErr != nil {
 		logAndExit(sessionsDBErr.Error())
 	}
 	defer sessionsDB.Close()
 
-	database := &db.DB{Path: dbPath}
-
-	err := database.InitDb()
-
 	sessionManager := scs.New()
-	sessionManager.IdleTimeout = 10000 * time.Hour
+	sessionManager.IdleTimeout = time.Duration(settings.Sessions.IdleTimeout)
 	sessionManager.Cookie.HttpOnly = true
 	sessionManager.Cookie.Persist = true
 	sessionManager.Cookie.SameSite = http.SameSiteStrictMode
 	sessionManager.Cookie.Secure = true
-	sessionManager.Store = boltstore.NewWithCleanupInterval(sessionsDB, 10000*time.Hour)
-	sessionManager.Lifetime = 20000 * time.Hour
+	sessionManager.Store = boltstore.NewWithCleanupInterval(sessionsDB, time.Hour)
+	sessionManager.Lifetime = time.Duration(settings.Sessions.Lifetime)
 
-	if err != nil {
-		logAndExit("Couldn't create the DB")
+	if database.SetupRequired() {
+		logInfo.Println("There is no admin account, open GoPiCam in the browser to create it or run: gopicam user add <username>")
 	}
 
-	// Check if configuration have the admin username and password or reset argument is present
-	if database.GetConfigValue("username") == nil || database.GetConfigValue("password") == nil || *resetAdmin {
-		//Ask Username
-		var username string
-		for {
-			usernameInput, shellErr := simpleShell("Enter a username to create the admin account")
+	// declare camera controller
+	camController := &camera.CamController{
+		ConfigFolder:    configPath,
+		LogInfo:         logInfo,
+		LogError:        logError,
+		MotionRecord:    settings.Motion.Record,
+		MotionStopDelay: time.Duration(settings.Motion.StopDelay),
+		MotionWebhook:   settings.Integrations.MotionWebhook,
+	}
 
-			if shellErr != nil {
-				logAndExit(shellErr.Error())
-			}
+	// Initialize Camera Controller to create Required folders for preview
+	camError := camController.Init()
 
-			minUsernameLength := 6
-			maxUsernameLength := 25
+	if camError != nil {
+		logAndExit(camError.Error())
+	}
 
-			validUsername, usernameError := validator.ValidateUsername(usernameInput, minUsernameLength, maxUsernameLength)
+	srv := &handlers.Server{
+		Db:             database,
+		Sessions:       sessionManager,
+		LogError:       logError,
+		LogInfo:        logInfo,
+		CamController:  camController,
+		PasswordPolicy: passwordPolicy(),
+		HashPolicy:     hashPolicy(),
+		BackupFiles:    backupFiles(database, sessionsDB),
 
-			// check if username is valid to continue
-			if validUsername {
-				username = usernameInput
-				break
-			} else {
-				fmt.Println("Error:", usernameError)
-			}
-		}
+		Settings:          settings,
+		SettingsOverrides: settingsOverrides,
 
-		//Ask Password
-		password, shellErr := simpleShell("Enter the password of the admin account")
+		Events: events.NewBroker(),
+	}
 
-		if shellErr != nil {
-			logAndExit(shellErr.Error())
+	if settings.Speaker.Enabled {
+		speakerSink := audio.Aplay(settings.Speaker.Device)
+		if settings.Speaker.Device == "loopback" {
+			speakerSink = audio.WAVFile(configPath + "/talk.wav")
 		}
 
-		//Save Username
-		saveErr := database.SetConfigValue("username", []byte(username))
-		if saveErr != nil {
-			logAndExit(saveErr.Error())
-		}
+		srv.Speaker = audio.NewSpeaker(speakerSink, time.Duration(settings.Speaker.Buffer), settings.Speaker.Volume)
+	}
 
-		//Hash and Save Password
-		hashedPassword, hashedPasswordErr := bcrypt.GenerateFromPassword([]byte(password), 8)
-		if hashedPasswordErr != nil {
-			logAndExit(hashedPasswordErr.Error())
-		}
+	srv.Geofencer = &tracker.Geofencer{
+		Db:          database,
+		SendCommand: camController.SendCommand,
+		Notify:      func(event db.GeofenceEvent) { srv.Events.Publish(events.TypeGeofence, event) },
+		LogInfo:     logInfo,
+	}
 
-		saveErr = database.SetConfigValue("password", hashedPassword)
-		if saveErr != nil {
-			logAndExit(saveErr.Error())
-		}
+	srv.Monitor = &tracker.Monitor{
+		Db:       database,
+		Settings: settings.Presence,
+		Notify:   func(alert db.Alert) { srv.Events.Publish(events.TypeAlert, alert) },
+		LogInfo:  logInfo,
+		LogError: logError,
+	}
 
-		fmt.Println("Creating admin account", username, "with password", password)
+	geotagger := &tracker.Geotagger{
+		Db:          database,
+		Device:      settings.Geotag.Device,
+		Window:      time.Duration(settings.Geotag.Window),
+		MediaFolder: configPath + "/media",
+		LogInfo:     logInfo,
+		LogError:    logError,
+	}
 
-		// Save Username and password
+	audioSource := audio.Arecord(settings.Audio.Device)
+	if settings.Audio.Device == "sine" {
+		audioSource = audio.Sine{Frequency: 440, Amplitude: 0.5, Realtime: true}.Source()
 	}
 
-	// declare camera controller
-	camController := &camera.CamController{ConfigFolder: configPath, LogInfo: logInfo, LogError: logError}
+	audioFormat := audio.Format{SampleRate: settings.Audio.SampleRate, Channels: settings.Audio.Channels}
 
-	// Initialize Camera Controller to create Required folders for preview
-	camError := camController.Init()
+	audioRecorder := &audio.Recorder{
+		Db:            database,
+		Source:        audioSource,
+		Format:        audioFormat,
+		SegmentLength: time.Duration(settings.Audio.SegmentLength),
+		LogInfo:       logInfo,
+		LogError:      logError,
+	}
 
-	if camError != nil {
-		logAndExit(camError.Error())
+	if settings.Audio.Record {
+		audioRecorder.MediaFolder = configPath + "/media"
+	}
+
+	if settings.Audio.Trigger {
+		audioRecorder.Detector = &audio.Detector{
+			Format:    audioFormat,
+			Level:     settings.Audio.Level,
+			Threshold: float64(settings.Audio.Threshold),
+			Attack:    time.Duration(settings.Audio.Attack),
+			Release:   time.Duration(settings.Audio.Release),
+			Trigger: func(level audio.Level) {
+				logInfo.Println("Sound Detected,", level)
+				camController.Motion(camera.MotionSourceSound, settings.Audio.TriggerRecord)
+			},
+			LevelLog: time.Duration(settings.Audio.LevelLog),
+			LogInfo:  logInfo,
+		}
+	}
+
+	videoMuxer := &audio.VideoMuxer{
+		Db:            database,
+		MediaFolder:   configPath + "/media",
+		SegmentLength: time.Duration(settings.Audio.SegmentLength),
+		DryRun:        settings.Audio.MuxDryRun,
+		LogInfo:       logInfo,
+		LogError:      logError,
+	}
+
+	if settings.Audio.Mux {
+		videoMuxer.Muxer, err = audio.FindMuxer(settings.Audio.Muxer)
+		if err != nil {
+			logError.Println(err)
+		}
+	}
+
+	backupScheduler := &backup.Scheduler{
+		Dir:      backupsFolder(configPath),
+		Interval: time.Duration(settings.Backups.Interval),
+		Keep:     settings.Backups.Keep,
+		Files:    srv.BackupFiles,
+		Options:  backup.Options{Compress: true},
+		LogInfo:  logInfo,
+		LogError: logError,
 	}
 
-	srv := &handlers.Server{Db: database, Sessions: sessionManager, LogError: logError, LogInfo: logInfo, CamController: camController}
+	if settings.Backups.PassphraseFile != "" {
+		passphrase, passphraseErr := readPassphrase(configPath, false)
+		if passphraseErr != nil {
+			logAndExit(passphraseErr.Error())
+		}
+
+		backupScheduler.Options.Passphrase = passphrase
+	}
 
 	// Handler to serve HTML Files
 	mux := http.NewServeMux()
 	mux.Handle("/", http.FileServer(getHTMLFiles()))
+	mux.HandleFunc("/api/setup", srv.SetupHandler)
+	mux.HandleFunc("/api/csrf", srv.CSRFTokenHandler)
 	mux.HandleFunc("/api/login", srv.LoginHandler)
+	mux.HandleFunc("/api/logout", srv.LogoutHandler)
+	mux.HandleFunc("/api/sessions", srv.SessionsHandler)
+	mux.HandleFunc("/api/sessions/revoke_all", srv.RevokeAllSessionsHandler)
+	mux.HandleFunc("/api/audit", srv.AuditHandler)
+	mux.HandleFunc("/api/backup", srv.BackupHandler)
+	mux.HandleFunc("/api/export/", srv.ExportHandler)
+	mux.HandleFunc("/api/import/", srv.ImportHandler)
+	mux.HandleFunc("/api/devices", srv.DevicesHandler)
+	mux.HandleFunc("/api/devices/status", srv.DeviceStatusHandler)
+	mux.HandleFunc("/api/devices/{id}/track", srv.TrackHandler)
+	mux.HandleFunc("/api/ingest", srv.IngestHandler)
+	mux.HandleFunc("/api/ingest/owntracks", srv.OwnTracksHandler)
+	mux.HandleFunc("/api/ingest/osmand", srv.OsmAndHandler)
+	mux.HandleFunc("/api/geofences", srv.GeofencesHandler)
+	mux.HandleFunc("/api/geofences/events", srv.GeofenceEventsHandler)
+	mux.HandleFunc("/api/alerts", srv.AlertsHandler)
+	mux.HandleFunc("/api/speaker", srv.SpeakerHandler)
+	mux.HandleFunc("/api/settings", srv.SettingsHandler)
+	mux.HandleFunc("/api/settings/history", srv.SettingsHistoryHandler)
+	mux.HandleFunc("/api/settings/rollback", srv.SettingsRollbackHandler)
 	mux.HandleFunc("/api/camera/preview", srv.PreviewHandler)
 	mux.HandleFunc("/api/camera/stop", srv.CameraCommandHandler)
 	mux.HandleFunc("/api/camera/start", srv.CameraCommandHandler)
@@ -194,12 +358,12 @@ func main() {
 
 	// Setup Web Server
 
-	serverPort := strconv.Itoa(*port)
+	serverPort := strconv.Itoa(settings.Server.Port)
 	serverProtocol := "https"
-	serverCertFile := configPath + "/server.crt"
-	serverKeyFile := configPath + "/server.key"
+	serverCertFile := configFile(configPath, settings.Server.CertFile)
+	serverKeyFile := configFile(configPath, settings.Server.KeyFile)
 
-	if *insecureServer {
+	if settings.Server.Insecure {
 		serverProtocol = "http"
 	} else {
 		// Check if certificate and key files exist
@@ -257,17 +421,46 @@ func main() {
 
 	showLocalIPs(serverPort, serverProtocol)
 
+	// delete old audit log entries
+	go srv.PruneAuditLog(time.Duration(settings.Retention.Audit))
+
+	// raise the alerts of the devices that are not reporting
+	go srv.Monitor.Run()
+
+	// geotag the new photos and videos
+	go geotagger.Run(time.Duration(settings.Geotag.Interval))
+
+	// record the audio of the microphone and detect the loud sounds
+	if settings.Audio.Record || settings.Audio.Trigger {
+		go audioRecorder.Run()
+	}
+
+	// mux the recorded audio in the videos
+	if videoMuxer.Muxer != nil {
+		go videoMuxer.Run(time.Minute)
+	}
+
+	// save the scheduled backups
+	go backupScheduler.Run()
+
 	// read FIFO messages
 	go camController.ReadFIFO()
 
 	// Kill any raspimjpeg process and start raspimjpeg
 	go camController.StartRaspiMJPEG()
 
+	// the event stream and the talks are served outside of the session
+	// middleware, which buffers the responses
+	rootMux := http.NewServeMux()
+	rootMux.HandleFunc("/api/events", srv.EventsHandler)
+	rootMux.HandleFunc("/api/talk", srv.TalkHandler)
+	rootMux.Handle("/", sessionManager.LoadAndSave(srv.AuditLog(srv.CSRFProtect(mux))))
+
 	//Start Web Server
-	if *insecureServer {
-		panic(http.ListenAndServe(":"+serverPort, sessionManager.LoadAndSave(mux)))
+	if settings.Server.Insecure {
+		panic(http.ListenAndServe(":"+serverPort, rootMux))
 	} else {
-		panic(http.ListenAndServeTLS(":"+serverPort, serverCertFile, serverKeyFile, sessionManager.LoadAndSave(mux)))
+		panic(http.ListenAndServeTLS(":"+serverPort, serverCertFile, serverKeyFile, rootMux))
 	}
 }
 
@@ -279,24 +472,6 @@ func getHTMLFiles() http.FileSystem {
 	return http.FS(fsys)
 }
 
-// Shell Ask Question and return response
-//
-func simpleShell(question string) (response string, err error) {
-	reader := bufio.NewReader(os.Stdin)
-
-	fmt.Print(question, ":")
-	input, inputErr := reader.ReadString('\n')
-
-	if inputErr != nil {
-		err = inputErr
-		return
-	}
-
-	response = strings.Replace(input, "\n", "", -1)
-
-	return
-}
-
 // Print error message and exit
 func logAndExit(message string) {
 	logError.Println(message)
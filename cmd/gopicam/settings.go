@@ -0,0 +1,134 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+
+	"github.com/jempe/gopicam/pkg/auth"
+	"github.com/jempe/gopicam/pkg/db"
+	"github.com/jempe/gopicam/pkg/validator"
+)
+
+// settingFlags are the flags that override a setting, the value is the setting
+var settingFlags = map[string]string{
+	"port":                   "server.port",
+	"insecure":               "server.insecure",
+	"session-idle":           "sessions.idle_timeout",
+	"session-lifetime":       "sessions.lifetime",
+	"password-min-length":    "passwords.min_length",
+	"password-allow-common":  "passwords.allow_common",
+	"password-hash":          "passwords.hash",
+	"bcrypt-cost":            "passwords.bcrypt_cost",
+	"audit-retention":        "retention.audit",
+	"backup-dir":             "backups.dir",
+	"backup-interval":        "backups.interval",
+	"backup-keep":            "backups.keep",
+	"backup-passphrase-file": "backups.passphrase_file",
+}
+
+// pathFlags are the flags of the settings that have a path
+var pathFlags = map[string]bool{"backup-dir": true, "backup-passphrase-file": true}
+
+// defaultSettings are the defaults of the flags of the settings
+var defaultSettings = db.DefaultSettings()
+
+// settings are the effective settings, the saved settings with the overrides of
+// the environment variables and the flags
+var settings = db.DefaultSettings()
+
+// settingsOverrides has the settings set by an environment variable or a flag,
+// the value is the variable or the flag
+var settingsOverrides = map[string]string{}
+
+// loadSettings reads the saved settings and applies the environment variables
+// and the flags that are set in the command line, the flags have priority.
+// Without database the defaults are used instead of the saved settings
+func loadSettings(database *db.DB) (err error) {
+	saved := db.DefaultSettings()
+
+	if database != nil {
+		saved, _, err = database.GetSettings()
+		if err != nil {
+			return err
+		}
+	}
+
+	applied, err := saved.ApplyEnv(os.LookupEnv)
+	if err != nil {
+		return err
+	}
+
+	for _, setting := range applied {
+		settingsOverrides[setting] = db.EnvName(setting)
+	}
+
+	flag.Visit(func(f *flag.Flag) {
+		setting, ok := settingFlags[f.Name]
+		if !ok || err != nil {
+			return
+		}
+
+		value := f.Value.String()
+
+		// the paths of the flags are relative to the working directory
+		if pathFlags[f.Name] && value != "" {
+			value, err = filepath.Abs(value)
+			if err != nil {
+				return
+			}
+		}
+
+		err = saved.Set(setting, value)
+		settingsOverrides[setting] = "-" + f.Name
+	})
+	if err != nil {
+		return err
+	}
+
+	err = saved.Validate()
+	if err != nil {
+		return err
+	}
+
+	settings = saved
+
+	return nil
+}
+
+// configFile returns the path of a file of the settings, the relative paths
+// are in the config folder
+func configFile(configPath string, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(configPath, path)
+}
+
+// backupsFolder is the folder of the backups of the settings
+func backupsFolder(configPath string) string {
+	if settings.Backups.Dir == "" {
+		return configPath + "/backups"
+	}
+
+	return configFile(configPath, settings.Backups.Dir)
+}
+
+// Password policy configured by the settings
+func passwordPolicy() validator.PasswordPolicy {
+	policy := validator.DefaultPasswordPolicy
+	policy.MinLength = settings.Passwords.MinLength
+	policy.RejectCommon = !settings.Passwords.AllowCommon
+
+	return policy
+}
+
+// Hash policy configured by the settings
+func hashPolicy() auth.HashPolicy {
+	policy := auth.DefaultHashPolicy
+	policy.Algorithm = settings.Passwords.Hash
+	policy.BcryptCost = settings.Passwords.BcryptCost
+
+	return policy
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"golang.org/x/term"
+
+	"github.com/jempe/gopicam/pkg/db"
+	"github.com/jempe/gopicam/pkg/validator"
+)
+
+const passwordEnvVariable = "GOPICAM_PASSWORD"
+
+const userCommandUsage = `Usage: gopicam [flags] user <command> [username]
+
+Commands:
+  add <username>      Create a user account
+  passwd <username>   Change the password of a user
+  delete <username>   Delete a user account and log out its sessions
+  list                List the user accounts
+
+The password is read from the file of the -password-file flag, the ` + passwordEnvVariable + `
+environment variable or asked in the terminal.`
+
+// runUserCommand manages the user accounts from the command line
+func runUserCommand(database *db.DB, args []string) error {
+	if len(args) == 0 {
+		return errors.New(userCommandUsage)
+	}
+
+	command := args[0]
+
+	if command == "list" {
+		return listUsers(database)
+	}
+
+	if len(args) != 2 {
+		return errors.New(userCommandUsage)
+	}
+
+	username := args[1]
+
+	switch command {
+	case "add":
+		password, err := readPassword("Password for " + username)
+		if err != nil {
+			return err
+		}
+
+		validPassword, passwordErr := validator.ValidatePassword(password, username, passwordPolicy())
+		if !validPassword {
+			return errors.New("Error: the password doesn't meet the password policy " + passwordErr.Error())
+		}
+
+		hashedPassword, err := hashPolicy().Hash(password)
+		if err != nil {
+			return err
+		}
+
+		_, err = database.InsertUser(db.User{Username: username, Password: hashedPassword})
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("User", username, "created")
+	case "passwd":
+		if _, err := database.GetUserByUsername(username); err != nil {
+			return err
+		}
+
+		password, err := readPassword("New password for " + username)
+		if err != nil {
+			return err
+		}
+
+		validPassword, passwordErr := validator.ValidatePassword(password, username, passwordPolicy())
+		if !validPassword {
+			return errors.New("Error: the password doesn't meet the password policy " + passwordErr.Error())
+		}
+
+		hashedPassword, err := hashPolicy().Hash(password)
+		if err != nil {
+			return err
+		}
+
+		_, err = database.UpdateUserPassword(username, hashedPassword)
+		if err != nil {
+			return err
+		}
+
+		// log out the sessions that were opened with the old password
+		_, err = database.DeleteUserSessions(username)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Password of", username, "changed")
+	case "delete":
+		_, err := database.DeleteUser(username)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("User", username, "deleted")
+	default:
+		return errors.New(userCommandUsage)
+	}
+
+	return nil
+}
+
+func listUsers(database *db.DB) error {
+	userList, err := database.GetUserList()
+	if err != nil {
+		return err
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "USERNAME\tCREATED\tUPDATED")
+
+	for _, user := range userList {
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", user.Username, user.Created.Format("2006-01-02 15:04"), user.Updated.Format("2006-01-02 15:04"))
+	}
+
+	return writer.Flush()
+}
+
+// readPassword gets the password from the password file, the environment
+// variable or asks it in the terminal without echo
+func readPassword(question string) (password string, err error) {
+	if *passwordFile != "" {
+		passwordContent, readErr := ioutil.ReadFile(*passwordFile)
+		if readErr != nil {
+			return "", readErr
+		}
+
+		password = strings.TrimRight(string(passwordContent), "\r\n")
+	} else if envPassword, ok := os.LookupEnv(passwordEnvVariable); ok {
+		password = envPassword
+	} else if term.IsTerminal(int(os.Stdin.Fd())) {
+		password, err = askPassword(question)
+		if err != nil {
+			return
+		}
+
+		confirmation, confirmErr := askPassword("Repeat the password")
+		if confirmErr != nil {
+			return "", confirmErr
+		}
+
+		if password != confirmation {
+			return "", errors.New("Error: the passwords don't match")
+		}
+	} else {
+		// password piped to stdin
+		input, inputErr := bufio.NewReader(os.Stdin).ReadString('\n')
+		if inputErr != nil && input == "" {
+			return "", inputErr
+		}
+
+		password = strings.TrimRight(input, "\r\n")
+	}
+
+	if password == "" {
+		err = errors.New("Error: the password can't be empty")
+	}
+
+	return
+}
+
+// askPassword reads the password from the terminal with echo turned off
+func askPassword(question string) (string, error) {
+	fmt.Print(question, ": ")
+
+	password, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+
+	return string(password), err
+}
@@ -5,10 +5,10 @@ go 1.24.0
 require (
 	github.com/alexedwards/scs/boltstore v0.0.0-20210724084017-7da169695f20
 	github.com/alexedwards/scs/v2 v2.3.1
-	github.com/boltdb/bolt v1.3.1
 	github.com/google/uuid v1.1.1
 	go.etcd.io/bbolt v1.3.6
 	golang.org/x/crypto v0.45.0
+	golang.org/x/term v0.37.0
 )
 
 require golang.org/x/sys v0.38.0 // indirect
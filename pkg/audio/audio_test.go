@@ -0,0 +1,220 @@
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jempe/gopicam/pkg/db"
+)
+
+var testFormat = Format{SampleRate: 8000, Channels: 1}
+
+func TestWAV(t *testing.T) {
+	samples := []int16{0, 1000, -1000, math.MaxInt16, math.MinInt16, 42}
+
+	file, err := os.Create(filepath.Join(t.TempDir(), "test.wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	format := Format{SampleRate: 44100, Channels: 2}
+
+	writer, err := NewWAVWriter(file, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, chunk := range [][]int16{samples[:2], samples[2:]} {
+		_, err = writer.Write(PCM(chunk))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	readFormat, pcm, err := ReadWAV(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if readFormat != format {
+		t.Errorf("want format %+v; got %+v", format, readFormat)
+	}
+
+	read := Samples(pcm)
+	if len(read) != len(samples) {
+		t.Fatalf("want %d samples; got %d", len(samples), len(read))
+	}
+
+	for i := range samples {
+		if read[i] != samples[i] {
+			t.Errorf("sample %d: want %d; got %d", i, samples[i], read[i])
+		}
+	}
+
+	if _, _, err := ReadWAV(bytes.NewReader([]byte("RIFF\x00\x00\x00\x00AVI "))); !errors.Is(err, ErrInvalidWAV) {
+		t.Errorf("want ErrInvalidWAV; got %v", err)
+	}
+}
+
+func TestSine(t *testing.T) {
+	source, err := Sine{Frequency: 440, Amplitude: 0.5, Duration: time.Second}.Source()(testFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	pcm, err := io.ReadAll(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	samples := Samples(pcm)
+	if len(samples) != testFormat.SampleRate {
+		t.Fatalf("want %d samples; got %d", testFormat.SampleRate, len(samples))
+	}
+
+	crossings := 0
+	peak := 0
+
+	for i, sample := range samples {
+		if i > 0 && (samples[i-1] < 0) != (sample < 0) {
+			crossings++
+		}
+
+		peak = max(peak, int(sample))
+	}
+
+	// two zero crossings by cycle
+	if crossings < 878 || crossings > 882 {
+		t.Errorf("want 880 zero crossings; got %d", crossings)
+	}
+
+	if peak != int(math.Round(0.5*math.MaxInt16)) {
+		t.Errorf("want the peak at half the amplitude; got %d", peak)
+	}
+}
+
+func newTestDB(t *testing.T) *db.DB {
+	t.Helper()
+
+	database := &db.DB{Store: db.NewMemoryStore()}
+
+	err := database.InitDb()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { database.Close() })
+
+	return database
+}
+
+func TestRecorder(t *testing.T) {
+	database := newTestDB(t)
+	mediaFolder := t.TempDir()
+
+	recorder := &Recorder{
+		Db:            database,
+		Source:        Sine{Frequency: 440, Amplitude: 0.5, Duration: 25 * time.Second}.Source(),
+		Format:        testFormat,
+		SegmentLength: 10 * time.Second,
+		MediaFolder:   mediaFolder,
+	}
+
+	audios, err := recorder.Record(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantLengths := []int{10, 10, 5}
+
+	if len(audios) != len(wantLengths) {
+		t.Fatalf("want %d audios; got %+v", len(wantLengths), audios)
+	}
+
+	for i, audio := range audios {
+		saved, err := database.GetAudio(audio.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if saved.Length != wantLengths[i] || saved.FileType != "wav" {
+			t.Errorf("audio %d: want a WAV file of %d seconds; got %+v", i, wantLengths[i], saved)
+		}
+
+		if saved.DeviceTime != audios[0].DeviceTime+int64(i*10) {
+			t.Errorf("audio %d: want the device time %d; got %d", i, audios[0].DeviceTime+int64(i*10), saved.DeviceTime)
+		}
+
+		data, err := os.ReadFile(filepath.Join(mediaFolder, saved.FileName()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(data) != saved.Size {
+			t.Errorf("audio %d: want the size %d of the file; got %d", i, len(data), saved.Size)
+		}
+
+		format, pcm, err := ReadWAV(bytes.NewReader(data))
+		if err != nil || format != testFormat || len(pcm) != wantLengths[i]*testFormat.BytesPerSecond() {
+			t.Errorf("audio %d: want %d seconds of audio; got %+v %d %v", i, wantLengths[i], format, len(pcm), err)
+		}
+	}
+
+	entries, err := os.ReadDir(mediaFolder)
+	if err != nil || len(entries) != len(wantLengths) {
+		t.Errorf("want only the audio files in the media folder; got %v %v", entries, err)
+	}
+}
+
+func TestRecorderStop(t *testing.T) {
+	database := newTestDB(t)
+	mediaFolder := t.TempDir()
+
+	recorder := &Recorder{
+		Db:            database,
+		Source:        Sine{Frequency: 440, Amplitude: 0.5, Realtime: true}.Source(),
+		Format:        testFormat,
+		SegmentLength: time.Minute,
+		MediaFolder:   mediaFolder,
+	}
+
+	stop := make(chan struct{})
+	time.AfterFunc(300*time.Millisecond, func() { close(stop) })
+
+	audios, err := recorder.Record(stop)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(audios) != 1 {
+		t.Fatalf("want the partial segment saved; got %+v", audios)
+	}
+
+	data, err := os.ReadFile(filepath.Join(mediaFolder, audios[0].FileName()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, pcm, err := ReadWAV(bytes.NewReader(data))
+	if err != nil || len(pcm) == 0 || len(pcm) > testFormat.BytesPerSecond() {
+		t.Errorf("want less than a second of audio; got %d bytes %v", len(pcm), err)
+	}
+}
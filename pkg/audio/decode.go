@@ -0,0 +1,70 @@
+package audio
+
+import (
+	"errors"
+	"io"
+	"os/exec"
+	"strconv"
+)
+
+var ErrNoDecoder = errors.New("audio: ffmpeg must be installed to decode Opus")
+
+// DecodeOpus returns a writer that decodes the Opus audio in a WebM or Ogg
+// container, like the chunks of MediaRecorder, with ffmpeg. The PCM audio in
+// the format is written to output, closing the writer waits until the audio
+// is decoded
+func DecodeOpus(output io.Writer, format Format) (io.WriteCloser, error) {
+	path, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil, ErrNoDecoder
+	}
+
+	cmd := exec.Command(path, "-v", "error", "-i", "pipe:0", "-f", "s16le", "-ar", strconv.Itoa(format.SampleRate), "-ac", strconv.Itoa(format.Channels), "pipe:1")
+
+	input, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := &opusDecoder{WriteCloser: input, cmd: cmd, copied: make(chan error, 1)}
+
+	go func() {
+		_, copyErr := io.Copy(output, decoded)
+		// ffmpeg stops when the output can't be written
+		io.Copy(io.Discard, decoded)
+
+		decoder.copied <- copyErr
+	}()
+
+	return decoder, nil
+}
+
+type opusDecoder struct {
+	io.WriteCloser
+	cmd    *exec.Cmd
+	copied chan error
+}
+
+func (decoder *opusDecoder) Close() error {
+	err := decoder.WriteCloser.Close()
+
+	if copyErr := <-decoder.copied; err == nil {
+		err = copyErr
+	}
+
+	if waitErr := decoder.cmd.Wait(); err == nil {
+		err = waitErr
+	}
+
+	return err
+}
@@ -0,0 +1,184 @@
+package audio
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+// MinLevel is the level of the silence, the quietest sound of the 16 bit
+// samples is about -90 dBFS
+const MinLevel = -96.0
+
+// defaultBlockLength is the duration of the blocks whose level is measured
+const defaultBlockLength = 50 * time.Millisecond
+
+// The levels compared with the threshold
+const (
+	LevelRMS  = "rms"
+	LevelPeak = "peak"
+)
+
+// Level is the RMS and the peak level in dBFS of a block of audio, Position is
+// the time of the end of the block from the start of the audio
+type Level struct {
+	RMS      float64
+	Peak     float64
+	Position time.Duration
+}
+
+func (level Level) String() string {
+	return fmt.Sprintf("rms %.1f dBFS, peak %.1f dBFS", level.RMS, level.Peak)
+}
+
+// Detector measures the level of the audio written to it and calls Trigger
+// when it's louder than Threshold during Attack. It's triggered again once
+// the level was below the threshold during Release
+type Detector struct {
+	Format Format
+	// Level is LevelRMS or LevelPeak
+	Level string
+	// Threshold is in dBFS, 0 is the loudest sound
+	Threshold float64
+	Attack    time.Duration
+	Release   time.Duration
+	// BlockLength is 50ms when it's 0
+	BlockLength time.Duration
+	Trigger     func(level Level)
+	// LevelLog is the interval of the log of the loudest levels, 0 disables it
+	LevelLog time.Duration
+	LogInfo  *log.Logger
+
+	pending   []byte
+	processed int
+	triggered bool
+	// above and below are the time the level has been above and below the
+	// threshold
+	above time.Duration
+	below time.Duration
+	// loudest is the loudest level since the last log
+	loudest   Level
+	lastLog   time.Duration
+	hasLevels bool
+}
+
+// Write measures the level of the PCM audio, the blocks are completed with
+// the next writes
+func (detector *Detector) Write(pcm []byte) (int, error) {
+	blockLength := detector.BlockLength
+	if blockLength <= 0 {
+		blockLength = defaultBlockLength
+	}
+
+	frameSize := detector.Format.Channels * bytesPerSample
+
+	blockSize := int(blockLength*time.Duration(detector.Format.BytesPerSecond())/time.Second) / frameSize * frameSize
+	if blockSize <= 0 {
+		return 0, fmt.Errorf("audio: invalid format %+v", detector.Format)
+	}
+
+	detector.pending = append(detector.pending, pcm...)
+
+	for len(detector.pending) >= blockSize {
+		detector.process(detector.pending[:blockSize])
+		detector.pending = detector.pending[blockSize:]
+	}
+
+	// keep the partial block in a new array so the old ones can be freed
+	detector.pending = append([]byte(nil), detector.pending...)
+
+	return len(pcm), nil
+}
+
+func (detector *Detector) process(block []byte) {
+	detector.processed += len(block)
+
+	level := Measure(block)
+	level.Position = detector.Format.Duration(detector.processed)
+
+	duration := detector.Format.Duration(len(block))
+
+	value := level.RMS
+	if detector.Level == LevelPeak {
+		value = level.Peak
+	}
+
+	if value >= detector.Threshold {
+		detector.above += duration
+		detector.below = 0
+	} else {
+		detector.below += duration
+		if !detector.triggered {
+			detector.above = 0
+		}
+	}
+
+	if !detector.triggered && detector.above > 0 && detector.above >= detector.Attack {
+		detector.triggered = true
+
+		if detector.Trigger != nil {
+			detector.Trigger(level)
+		}
+	} else if detector.triggered && detector.below > 0 && detector.below >= detector.Release {
+		detector.triggered = false
+		detector.above = 0
+	}
+
+	detector.logLevel(level)
+}
+
+// logLevel logs the loudest levels of every LevelLog to choose the threshold
+func (detector *Detector) logLevel(level Level) {
+	if detector.LevelLog <= 0 || detector.LogInfo == nil {
+		return
+	}
+
+	if !detector.hasLevels {
+		detector.loudest = level
+		detector.hasLevels = true
+	} else {
+		detector.loudest.RMS = math.Max(detector.loudest.RMS, level.RMS)
+		detector.loudest.Peak = math.Max(detector.loudest.Peak, level.Peak)
+	}
+
+	if level.Position-detector.lastLog >= detector.LevelLog {
+		detector.LogInfo.Println("Audio level:", detector.loudest)
+
+		detector.lastLog = level.Position
+		detector.hasLevels = false
+	}
+}
+
+// Measure returns the RMS and the peak level of the PCM audio in dBFS, the
+// levels of the silence are MinLevel
+func Measure(pcm []byte) Level {
+	samples := Samples(pcm)
+
+	if len(samples) == 0 {
+		return Level{RMS: MinLevel, Peak: MinLevel}
+	}
+
+	sum := 0.0
+	peak := 0.0
+
+	for _, sample := range samples {
+		value := math.Abs(float64(sample)) / -math.MinInt16
+
+		sum += value * value
+		peak = math.Max(peak, value)
+	}
+
+	return Level{
+		RMS:  decibels(math.Sqrt(sum / float64(len(samples)))),
+		Peak: decibels(peak),
+	}
+}
+
+func decibels(value float64) float64 {
+	if value == 0 {
+		return MinLevel
+	}
+
+	return math.Max(20*math.Log10(value), MinLevel)
+}
@@ -0,0 +1,217 @@
+package audio
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func tone(t *testing.T, amplitude float64, duration time.Duration) []byte {
+	t.Helper()
+
+	source, err := Sine{Frequency: 440, Amplitude: amplitude, Duration: duration}.Source()(testFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	pcm, err := io.ReadAll(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pcm
+}
+
+func silence(duration time.Duration) []byte {
+	return make([]byte, int(duration*time.Duration(testFormat.BytesPerSecond())/time.Second))
+}
+
+// fixture saves the parts in a WAV file and returns its audio
+func fixture(t *testing.T, parts ...[]byte) []byte {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "fixture.wav")
+
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	writer, err := NewWAVWriter(file, testFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, part := range parts {
+		_, err = writer.Write(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, pcm, err := ReadWAV(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pcm
+}
+
+func TestMeasure(t *testing.T) {
+	level := Measure(tone(t, 0.5, time.Second))
+
+	if math.Abs(level.RMS+9.03) > 0.1 || math.Abs(level.Peak+6.02) > 0.1 {
+		t.Errorf("want rms -9.0 dBFS and peak -6.0 dBFS; got %v", level)
+	}
+
+	if level := Measure(silence(time.Second)); level.RMS != MinLevel || level.Peak != MinLevel {
+		t.Errorf("want the silence at %v dBFS; got %v", MinLevel, level)
+	}
+}
+
+func TestDetector(t *testing.T) {
+	tests := []struct {
+		name          string
+		level         string
+		attack        time.Duration
+		pcm           [][]byte
+		wantPositions []time.Duration
+	}{
+		{
+			name:          "Barks",
+			attack:        100 * time.Millisecond,
+			pcm:           [][]byte{silence(time.Second), tone(t, 0.5, 500*time.Millisecond), silence(time.Second), tone(t, 0.5, 300*time.Millisecond)},
+			wantPositions: []time.Duration{1100 * time.Millisecond, 2600 * time.Millisecond},
+		},
+		{
+			name:          "Pause Shorter Than Release",
+			attack:        100 * time.Millisecond,
+			pcm:           [][]byte{tone(t, 0.5, 500*time.Millisecond), silence(200 * time.Millisecond), tone(t, 0.5, 500*time.Millisecond)},
+			wantPositions: []time.Duration{100 * time.Millisecond},
+		},
+		{
+			name:   "Quiet",
+			attack: 100 * time.Millisecond,
+			pcm:    [][]byte{silence(time.Second), tone(t, 0.01, time.Second)},
+		},
+		{
+			name:   "Click Shorter Than Attack",
+			attack: 100 * time.Millisecond,
+			pcm:    [][]byte{silence(time.Second), tone(t, 0.9, 10*time.Millisecond), silence(time.Second)},
+		},
+		{
+			name:          "Glass Peak",
+			level:         LevelPeak,
+			pcm:           [][]byte{silence(time.Second), tone(t, 0.9, 10*time.Millisecond), silence(time.Second)},
+			wantPositions: []time.Duration{1050 * time.Millisecond},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var positions []time.Duration
+
+			detector := &Detector{
+				Format:    testFormat,
+				Level:     tt.level,
+				Threshold: -20,
+				Attack:    tt.attack,
+				Release:   500 * time.Millisecond,
+				Trigger:   func(level Level) { positions = append(positions, level.Position) },
+			}
+
+			pcm := fixture(t, tt.pcm...)
+
+			// the chunks don't match the blocks
+			for len(pcm) > 0 {
+				chunk := pcm[:min(len(pcm), 998)]
+
+				_, err := detector.Write(chunk)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				pcm = pcm[len(chunk):]
+			}
+
+			if len(positions) != len(tt.wantPositions) {
+				t.Fatalf("want triggers at %v; got %v", tt.wantPositions, positions)
+			}
+
+			for i := range positions {
+				if positions[i] != tt.wantPositions[i] {
+					t.Errorf("want trigger at %v; got %v", tt.wantPositions[i], positions[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDetectorLevelLog(t *testing.T) {
+	var output bytes.Buffer
+
+	detector := &Detector{
+		Format:    testFormat,
+		Threshold: -20,
+		LevelLog:  time.Second,
+		LogInfo:   log.New(&output, "", 0),
+	}
+
+	_, err := detector.Write(fixture(t, silence(time.Second), tone(t, 0.5, time.Second), silence(1500*time.Millisecond)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+
+	want := []string{
+		"Audio level: rms -96.0 dBFS, peak -96.0 dBFS",
+		"Audio level: rms -9.0 dBFS, peak -6.0 dBFS",
+		"Audio level: rms -96.0 dBFS, peak -96.0 dBFS",
+	}
+
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("want the levels logged every second %q; got %q", want, lines)
+	}
+}
+
+func TestRecorderDetector(t *testing.T) {
+	database := newTestDB(t)
+
+	triggers := 0
+
+	recorder := &Recorder{
+		Db:            database,
+		Source:        Sine{Frequency: 440, Amplitude: 0.5, Duration: time.Second}.Source(),
+		Format:        testFormat,
+		SegmentLength: 10 * time.Second,
+		Detector:      &Detector{Format: testFormat, Threshold: -20, Trigger: func(Level) { triggers++ }},
+	}
+
+	audios, err := recorder.Record(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if triggers != 1 || len(audios) != 0 {
+		t.Errorf("want 1 trigger and no audios saved without media folder; got %d and %+v", triggers, audios)
+	}
+}
@@ -0,0 +1,118 @@
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+// The muxers of FindMuxer
+const (
+	MuxerAuto   = "auto"
+	MuxerFFmpeg = "ffmpeg"
+	MuxerMP4Box = "mp4box"
+)
+
+// Muxer writes in output the MP4 video with the audio of the WAV file as its
+// audio track
+type Muxer interface {
+	Mux(video string, audio string, output string) error
+}
+
+// MuxerFunc is a function used as a Muxer
+type MuxerFunc func(video string, audio string, output string) error
+
+func (muxer MuxerFunc) Mux(video string, audio string, output string) error {
+	return muxer(video, audio, output)
+}
+
+// FFmpeg muxes with ffmpeg, the audio is encoded in AAC and the video is
+// copied. Path is ffmpeg when it's empty
+type FFmpeg struct {
+	Path string
+}
+
+// Command returns the ffmpeg command of the mux
+func (muxer FFmpeg) Command(video string, audio string, output string) *exec.Cmd {
+	path := muxer.Path
+	if path == "" {
+		path = "ffmpeg"
+	}
+
+	return exec.Command(path, "-y", "-v", "error", "-i", video, "-i", audio, "-map", "0:v:0", "-map", "1:a:0", "-c:v", "copy", "-c:a", "aac", "-f", "mp4", output)
+}
+
+func (muxer FFmpeg) Mux(video string, audio string, output string) error {
+	return runMuxer(muxer.Command(video, audio, output))
+}
+
+// MP4Box muxes with MP4Box of GPAC, the audio is added as a PCM track. Path
+// is MP4Box when it's empty
+type MP4Box struct {
+	Path string
+}
+
+// Command returns the MP4Box command of the mux
+func (muxer MP4Box) Command(video string, audio string, output string) *exec.Cmd {
+	path := muxer.Path
+	if path == "" {
+		path = "MP4Box"
+	}
+
+	return exec.Command(path, "-quiet", "-add", video+"#video", "-add", audio+"#audio", "-new", output)
+}
+
+func (muxer MP4Box) Mux(video string, audio string, output string) error {
+	return runMuxer(muxer.Command(video, audio, output))
+}
+
+// runMuxer runs the command, the error has the output of the command
+func runMuxer(cmd *exec.Cmd) error {
+	var output bytes.Buffer
+
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	err := cmd.Run()
+	if err != nil {
+		message := strings.TrimSpace(output.String())
+		if message != "" {
+			return errors.New(cmd.Args[0] + ": " + err.Error() + ": " + message)
+		}
+
+		return errors.New(cmd.Args[0] + ": " + err.Error())
+	}
+
+	return nil
+}
+
+// FindMuxer returns the muxer of the name, auto returns ffmpeg or MP4Box,
+// the first that is installed
+func FindMuxer(name string) (Muxer, error) {
+	switch name {
+	case MuxerFFmpeg, MuxerMP4Box, MuxerAuto:
+	default:
+		return nil, errors.New("audio: unknown muxer " + name)
+	}
+
+	if name == MuxerFFmpeg || name == MuxerAuto {
+		path, err := exec.LookPath("ffmpeg")
+		if err == nil {
+			return FFmpeg{Path: path}, nil
+		}
+	}
+
+	if name == MuxerMP4Box || name == MuxerAuto {
+		path, err := exec.LookPath("MP4Box")
+		if err == nil {
+			return MP4Box{Path: path}, nil
+		}
+	}
+
+	if name == MuxerAuto {
+		return nil, errors.New("audio: ffmpeg or MP4Box must be installed to mux the audio")
+	}
+
+	return nil, errors.New("audio: " + name + " isn't installed")
+}
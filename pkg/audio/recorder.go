@@ -0,0 +1,229 @@
+package audio
+
+import (
+	"errors"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/jempe/gopicam/pkg/db"
+)
+
+// restartDelay is the time before the capture is started again after an error
+const restartDelay = 10 * time.Second
+
+// readInterval is the duration of the audio read at a time
+const readInterval = 100 * time.Millisecond
+
+// Recorder records the audio of a source in WAV files of SegmentLength in the
+// media folder and saves them in the audios bucket
+type Recorder struct {
+	Db            *db.DB
+	Source        Source
+	Format        Format
+	SegmentLength time.Duration
+	// MediaFolder is empty when the audio is only listened by the Detector
+	MediaFolder string
+	// Detector receives the audio as it's captured
+	Detector *Detector
+	LogInfo  *log.Logger
+	LogError *log.Logger
+}
+
+// segment is the WAV file being recorded, it's saved with a temporary name
+// until it's complete
+type segment struct {
+	audio  db.Audio
+	file   *os.File
+	writer *WAVWriter
+}
+
+// Record records the audio until the source ends or stop is closed and
+// returns the saved audios. The DeviceTime of the audios is the time of their
+// first sample, counted from the start of the capture
+func (recorder *Recorder) Record(stop <-chan struct{}) (audios []db.Audio, err error) {
+	frameSize := recorder.Format.Channels * bytesPerSample
+
+	segmentSize := int(recorder.SegmentLength*time.Duration(recorder.Format.BytesPerSecond())/time.Second) / frameSize * frameSize
+	if segmentSize <= 0 {
+		return nil, errors.New("audio: invalid format or segment length")
+	}
+
+	source, err := recorder.Source(recorder.Format)
+	if err != nil {
+		return
+	}
+
+	started := time.Now()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	stopped := make(chan struct{})
+
+	go func() {
+		select {
+		case <-stop:
+			close(stopped)
+		case <-done:
+		}
+
+		source.Close()
+	}()
+
+	buffer := make([]byte, max(int(readInterval*time.Duration(recorder.Format.BytesPerSecond())/time.Second)/frameSize*frameSize, frameSize))
+
+	// recorded is the size of the audio read from the source
+	recorded := 0
+
+	var current *segment
+
+	for {
+		n, readErr := source.Read(buffer)
+
+		data := buffer[:n]
+
+		if recorder.Detector != nil {
+			recorder.Detector.Write(data)
+		}
+
+		if recorder.MediaFolder == "" {
+			data = nil
+		}
+
+		for len(data) > 0 {
+			if current == nil {
+				current, err = recorder.startSegment(started.Add(recorder.Format.Duration(recorded)))
+				if err != nil {
+					return
+				}
+			}
+
+			chunk := data[:min(len(data), segmentSize-current.writer.Size())]
+
+			_, err = current.writer.Write(chunk)
+			if err != nil {
+				recorder.discardSegment(current)
+				return
+			}
+
+			data = data[len(chunk):]
+			recorded += len(chunk)
+
+			if current.writer.Size() == segmentSize {
+				var audio db.Audio
+
+				audio, err = recorder.saveSegment(current)
+				if err != nil {
+					return
+				}
+
+				audios = append(audios, audio)
+				current = nil
+			}
+		}
+
+		if readErr == nil {
+			continue
+		}
+
+		// the partial segment is saved when the capture ends
+		if current != nil && current.writer.Size() > 0 {
+			audio, saveErr := recorder.saveSegment(current)
+			if saveErr != nil {
+				return audios, saveErr
+			}
+
+			audios = append(audios, audio)
+		} else if current != nil {
+			recorder.discardSegment(current)
+		}
+
+		select {
+		case <-stopped:
+			return audios, nil
+		default:
+		}
+
+		if readErr == io.EOF {
+			return audios, nil
+		}
+
+		return audios, readErr
+	}
+}
+
+func (recorder *Recorder) startSegment(deviceTime time.Time) (*segment, error) {
+	current := &segment{audio: db.Audio{ID: uuid.New().String(), FileType: "wav", DeviceTime: deviceTime.Unix()}}
+
+	file, err := os.Create(filepath.Join(recorder.MediaFolder, "."+current.audio.FileName()+".part"))
+	if err != nil {
+		return nil, err
+	}
+
+	current.file = file
+
+	current.writer, err = NewWAVWriter(file, recorder.Format)
+	if err != nil {
+		recorder.discardSegment(current)
+		return nil, err
+	}
+
+	return current, nil
+}
+
+// saveSegment completes the WAV file, renames it and saves the audio
+func (recorder *Recorder) saveSegment(current *segment) (db.Audio, error) {
+	err := current.writer.Close()
+	if closeErr := current.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(current.file.Name())
+		return db.Audio{}, err
+	}
+
+	current.audio.Size = wavHeaderSize + current.writer.Size()
+	current.audio.Length = int(math.Round(recorder.Format.Duration(current.writer.Size()).Seconds()))
+
+	err = os.Rename(current.file.Name(), filepath.Join(recorder.MediaFolder, current.audio.FileName()))
+	if err != nil {
+		os.Remove(current.file.Name())
+		return db.Audio{}, err
+	}
+
+	_, err = recorder.Db.InsertAudio(current.audio, []string{})
+	if err != nil {
+		return db.Audio{}, err
+	}
+
+	if recorder.LogInfo != nil {
+		recorder.LogInfo.Println("Audio: saved", current.audio.FileName(), current.audio.Length, "seconds")
+	}
+
+	return current.audio, nil
+}
+
+func (recorder *Recorder) discardSegment(current *segment) {
+	current.file.Close()
+	os.Remove(current.file.Name())
+}
+
+// Run records the audio until the program exits, the capture is started again
+// after the errors
+func (recorder *Recorder) Run() {
+	for {
+		_, err := recorder.Record(nil)
+		if err != nil && recorder.LogError != nil {
+			recorder.LogError.Println("Audio:", err)
+		}
+
+		time.Sleep(restartDelay)
+	}
+}
@@ -0,0 +1,146 @@
+package audio
+
+import (
+	"errors"
+	"io"
+	"math"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Source starts the capture of signed 16 bit little endian PCM audio in the
+// format, closing the reader stops it
+type Source func(format Format) (io.ReadCloser, error)
+
+// Arecord returns the source of the ALSA device, like default or plughw:1,0,
+// captured with arecord
+func Arecord(device string) Source {
+	return func(format Format) (io.ReadCloser, error) {
+		cmd := exec.Command("arecord", "-q", "-D", device, "-t", "raw", "-f", "S16_LE", "-r", strconv.Itoa(format.SampleRate), "-c", strconv.Itoa(format.Channels))
+
+		output, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+
+		err = cmd.Start()
+		if err != nil {
+			return nil, err
+		}
+
+		return &commandReader{ReadCloser: output, cmd: cmd}, nil
+	}
+}
+
+// commandReader reads the output of a command and stops it when it's closed
+type commandReader struct {
+	io.ReadCloser
+	cmd  *exec.Cmd
+	once sync.Once
+}
+
+func (reader *commandReader) Close() (err error) {
+	reader.once.Do(func() {
+		reader.cmd.Process.Kill()
+		reader.ReadCloser.Close()
+		reader.cmd.Wait()
+	})
+
+	return
+}
+
+// Sine is a source of a sine tone, it replaces the microphone in the tests and
+// in the computers without one. A zero Duration doesn't end, Realtime returns
+// the audio at the speed it would be captured
+type Sine struct {
+	Frequency float64
+	// Amplitude is between 0 and 1
+	Amplitude float64
+	Duration  time.Duration
+	Realtime  bool
+}
+
+// Source returns the source of the tone
+func (sine Sine) Source() Source {
+	return func(format Format) (io.ReadCloser, error) {
+		if format.SampleRate <= 0 || format.Channels <= 0 {
+			return nil, errors.New("audio: invalid format")
+		}
+
+		reader := &sineReader{sine: sine, format: format, started: time.Now(), closed: make(chan struct{})}
+
+		if sine.Duration > 0 {
+			reader.total = int64(sine.Duration * time.Duration(format.SampleRate) / time.Second)
+		}
+
+		return reader, nil
+	}
+}
+
+type sineReader struct {
+	sine    Sine
+	format  Format
+	started time.Time
+	// frame is the number of the next frame, total the number of frames or 0
+	frame  int64
+	total  int64
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (reader *sineReader) Read(p []byte) (int, error) {
+	select {
+	case <-reader.closed:
+		return 0, io.ErrClosedPipe
+	default:
+	}
+
+	frameSize := reader.format.Channels * bytesPerSample
+
+	frames := int64(len(p) / frameSize)
+	if reader.total > 0 && reader.frame+frames > reader.total {
+		frames = reader.total - reader.frame
+	}
+
+	if frames <= 0 {
+		if len(p) < frameSize {
+			return 0, io.ErrShortBuffer
+		}
+
+		return 0, io.EOF
+	}
+
+	if reader.sine.Realtime {
+		ready := time.NewTimer(time.Until(reader.started.Add(time.Duration(reader.frame+frames) * time.Second / time.Duration(reader.format.SampleRate))))
+		defer ready.Stop()
+
+		select {
+		case <-reader.closed:
+			return 0, io.ErrClosedPipe
+		case <-ready.C:
+		}
+	}
+
+	samples := make([]int16, 0, frames*int64(reader.format.Channels))
+
+	for i := int64(0); i < frames; i++ {
+		phase := 2 * math.Pi * reader.sine.Frequency * float64(reader.frame+i) / float64(reader.format.SampleRate)
+		sample := int16(math.Round(reader.sine.Amplitude * math.MaxInt16 * math.Sin(phase)))
+
+		for channel := 0; channel < reader.format.Channels; channel++ {
+			samples = append(samples, sample)
+		}
+	}
+
+	reader.frame += frames
+
+	return copy(p, PCM(samples)), nil
+}
+
+func (reader *sineReader) Close() error {
+	reader.once.Do(func() { close(reader.closed) })
+
+	return nil
+}
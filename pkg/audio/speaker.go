@@ -0,0 +1,361 @@
+package audio
+
+import (
+	"errors"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxTalkDelay is the audio waiting after the buffer before the oldest audio is
+// dropped, so the delay doesn't grow when the network sends the audio late
+const maxTalkDelay = time.Second
+
+// talkChunk is the duration of the audio played at a time
+const talkChunk = 20 * time.Millisecond
+
+var ErrSpeakerBusy = errors.New("audio: the speaker is used by another talk")
+var ErrTalkClosed = errors.New("audio: the talk is closed")
+
+// Sink starts the playback of signed 16 bit little endian PCM audio in the
+// format, closing the writer waits until the audio is played
+type Sink func(format Format) (io.WriteCloser, error)
+
+// Aplay returns the sink of the ALSA device, like default or plughw:1,0,
+// played with aplay
+func Aplay(device string) Sink {
+	return func(format Format) (io.WriteCloser, error) {
+		cmd := exec.Command("aplay", "-q", "-D", device, "-t", "raw", "-f", "S16_LE", "-r", strconv.Itoa(format.SampleRate), "-c", strconv.Itoa(format.Channels))
+
+		input, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+
+		err = cmd.Start()
+		if err != nil {
+			return nil, err
+		}
+
+		return &commandWriter{WriteCloser: input, cmd: cmd}, nil
+	}
+}
+
+// commandWriter writes in the input of a command, closing it waits for the
+// command to end
+type commandWriter struct {
+	io.WriteCloser
+	cmd *exec.Cmd
+}
+
+func (writer *commandWriter) Close() error {
+	err := writer.WriteCloser.Close()
+	if waitErr := writer.cmd.Wait(); err == nil {
+		err = waitErr
+	}
+
+	return err
+}
+
+// WAVFile returns the sink that writes the audio in the WAV file instead of
+// playing it, the file is replaced by every playback. It's the loopback that
+// tests the talks without speaker
+func WAVFile(path string) Sink {
+	return func(format Format) (io.WriteCloser, error) {
+		file, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+
+		writer, err := NewWAVWriter(file, format)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+
+		return &wavFile{file: file, writer: writer}, nil
+	}
+}
+
+type wavFile struct {
+	file   *os.File
+	writer *WAVWriter
+}
+
+func (sink *wavFile) Write(pcm []byte) (int, error) {
+	return sink.writer.Write(pcm)
+}
+
+func (sink *wavFile) Close() error {
+	err := sink.writer.Close()
+	if closeErr := sink.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// Speaker plays the talks of the users one at a time, the volume and the mute
+// apply to the talk being played
+type Speaker struct {
+	Sink Sink
+	// Buffer is the audio received before the playback starts, it absorbs the
+	// jitter of the network
+	Buffer time.Duration
+
+	mutex   sync.Mutex
+	volume  int
+	muted   bool
+	talking bool
+}
+
+// NewSpeaker returns a speaker with the volume in percent
+func NewSpeaker(sink Sink, buffer time.Duration, volume int) *Speaker {
+	return &Speaker{Sink: sink, Buffer: buffer, volume: min(max(volume, 0), 100)}
+}
+
+// Volume returns the volume in percent
+func (speaker *Speaker) Volume() int {
+	speaker.mutex.Lock()
+	defer speaker.mutex.Unlock()
+
+	return speaker.volume
+}
+
+// SetVolume changes the volume, between 0 and 100 percent
+func (speaker *Speaker) SetVolume(volume int) error {
+	if volume < 0 || volume > 100 {
+		return errors.New("audio: the volume must be between 0 and 100")
+	}
+
+	speaker.mutex.Lock()
+	defer speaker.mutex.Unlock()
+
+	speaker.volume = volume
+
+	return nil
+}
+
+func (speaker *Speaker) Muted() bool {
+	speaker.mutex.Lock()
+	defer speaker.mutex.Unlock()
+
+	return speaker.muted
+}
+
+// SetMuted mutes the speaker, the talks are played as silence
+func (speaker *Speaker) SetMuted(muted bool) {
+	speaker.mutex.Lock()
+	defer speaker.mutex.Unlock()
+
+	speaker.muted = muted
+}
+
+// Talking returns whether a talk is being played
+func (speaker *Speaker) Talking() bool {
+	speaker.mutex.Lock()
+	defer speaker.mutex.Unlock()
+
+	return speaker.talking
+}
+
+// gain returns the factor of the samples
+func (speaker *Speaker) gain() float64 {
+	speaker.mutex.Lock()
+	defer speaker.mutex.Unlock()
+
+	if speaker.muted {
+		return 0
+	}
+
+	return float64(speaker.volume) / 100
+}
+
+// Talk starts the playback of the audio in the format written to the talk,
+// it returns ErrSpeakerBusy while another talk is played
+func (speaker *Speaker) Talk(format Format) (*Talk, error) {
+	if format.SampleRate <= 0 || format.Channels <= 0 {
+		return nil, errors.New("audio: invalid format")
+	}
+
+	speaker.mutex.Lock()
+	defer speaker.mutex.Unlock()
+
+	if speaker.talking {
+		return nil, ErrSpeakerBusy
+	}
+
+	sink, err := speaker.Sink(format)
+	if err != nil {
+		return nil, err
+	}
+
+	speaker.talking = true
+
+	frameSize := format.Channels * bytesPerSample
+
+	talk := &Talk{
+		speaker:    speaker,
+		format:     format,
+		sink:       sink,
+		bufferSize: int(speaker.Buffer*time.Duration(format.BytesPerSecond())/time.Second) / frameSize * frameSize,
+		maxSize:    int((speaker.Buffer+maxTalkDelay)*time.Duration(format.BytesPerSecond())/time.Second) / frameSize * frameSize,
+		chunkSize:  max(int(talkChunk*time.Duration(format.BytesPerSecond())/time.Second)/frameSize*frameSize, frameSize),
+		frameSize:  frameSize,
+		done:       make(chan struct{}),
+	}
+
+	talk.ready = sync.NewCond(&talk.mutex)
+
+	go talk.play()
+
+	return talk, nil
+}
+
+// Talk is the audio of a user played by the speaker, the audio is buffered
+// until Speaker.Buffer is received
+type Talk struct {
+	speaker    *Speaker
+	format     Format
+	sink       io.WriteCloser
+	bufferSize int
+	maxSize    int
+	chunkSize  int
+	frameSize  int
+
+	mutex   sync.Mutex
+	ready   *sync.Cond
+	pending []byte
+	started bool
+	closed  bool
+	// received and dropped are the size of the audio written and dropped
+	received    int
+	dropped     int
+	playbackErr error
+	done        chan struct{}
+}
+
+// Write adds the PCM audio to the buffer of the talk, the oldest audio is
+// dropped when the playback is late
+func (talk *Talk) Write(pcm []byte) (int, error) {
+	talk.mutex.Lock()
+	defer talk.mutex.Unlock()
+
+	if talk.closed {
+		return 0, ErrTalkClosed
+	}
+
+	if talk.playbackErr != nil {
+		return 0, talk.playbackErr
+	}
+
+	talk.pending = append(talk.pending, pcm...)
+	talk.received += len(pcm)
+
+	if excess := len(talk.pending) - talk.maxSize; excess > 0 {
+		excess = (excess + talk.frameSize - 1) / talk.frameSize * talk.frameSize
+		talk.pending = talk.pending[excess:]
+		talk.dropped += excess
+	}
+
+	if len(talk.pending) >= talk.bufferSize {
+		talk.started = true
+	}
+
+	talk.ready.Signal()
+
+	return len(pcm), nil
+}
+
+// play writes the buffered audio in the sink until the talk is closed
+func (talk *Talk) play() {
+	defer close(talk.done)
+
+	for {
+		talk.mutex.Lock()
+
+		for !talk.closed && (!talk.started || len(talk.pending) < talk.frameSize) {
+			talk.ready.Wait()
+		}
+
+		// the partial frame at the end isn't played
+		size := min(len(talk.pending), talk.chunkSize) / talk.frameSize * talk.frameSize
+
+		if size == 0 {
+			talk.mutex.Unlock()
+			break
+		}
+
+		chunk := append([]byte(nil), talk.pending[:size]...)
+		talk.pending = talk.pending[size:]
+
+		talk.mutex.Unlock()
+
+		gain := talk.speaker.gain()
+		if gain != 1 {
+			samples := Samples(chunk)
+
+			for i, sample := range samples {
+				samples[i] = int16(math.Round(float64(sample) * gain))
+			}
+
+			chunk = PCM(samples)
+		}
+
+		_, err := talk.sink.Write(chunk)
+		if err != nil {
+			talk.mutex.Lock()
+			talk.playbackErr = err
+			talk.mutex.Unlock()
+			break
+		}
+	}
+
+	err := talk.sink.Close()
+
+	talk.mutex.Lock()
+	if talk.playbackErr == nil {
+		talk.playbackErr = err
+	}
+	talk.mutex.Unlock()
+}
+
+// Close plays the audio left in the buffer and frees the speaker
+func (talk *Talk) Close() error {
+	talk.mutex.Lock()
+
+	if talk.closed {
+		talk.mutex.Unlock()
+		<-talk.done
+
+		return nil
+	}
+
+	talk.closed = true
+	talk.ready.Signal()
+	talk.mutex.Unlock()
+
+	<-talk.done
+
+	talk.speaker.mutex.Lock()
+	talk.speaker.talking = false
+	talk.speaker.mutex.Unlock()
+
+	talk.mutex.Lock()
+	defer talk.mutex.Unlock()
+
+	return talk.playbackErr
+}
+
+// Received returns the duration of the audio written to the talk and the
+// duration of the audio dropped
+func (talk *Talk) Received() (received time.Duration, dropped time.Duration) {
+	talk.mutex.Lock()
+	defer talk.mutex.Unlock()
+
+	return talk.format.Duration(talk.received), talk.format.Duration(talk.dropped)
+}
@@ -0,0 +1,160 @@
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSpeakerLoopback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "talk.wav")
+
+	speaker := NewSpeaker(WAVFile(path), 100*time.Millisecond, 100)
+
+	pcm := tone(t, 0.5, time.Second)
+
+	tests := []struct {
+		name   string
+		volume int
+		muted  bool
+		gain   float64
+	}{
+		{"Full Volume", 100, false, 1},
+		{"Half Volume", 50, false, 0.5},
+		{"Muted", 100, true, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := speaker.SetVolume(tt.volume)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			speaker.SetMuted(tt.muted)
+
+			talk, err := speaker.Talk(testFormat)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := speaker.Talk(testFormat); !errors.Is(err, ErrSpeakerBusy) {
+				t.Errorf("want ErrSpeakerBusy during the talk; got %v", err)
+			}
+
+			// the chunks of the browser don't match the chunks played
+			for data := pcm; len(data) > 0; {
+				chunk := data[:min(len(data), 250)]
+
+				_, err = talk.Write(chunk)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				data = data[len(chunk):]
+			}
+
+			err = talk.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if speaker.Talking() {
+				t.Error("want the speaker free after the talk")
+			}
+
+			if received, dropped := talk.Received(); received != time.Second || dropped != 0 {
+				t.Errorf("want 1s received and nothing dropped; got %v %v", received, dropped)
+			}
+
+			file, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+
+			format, played, err := ReadWAV(file)
+			if err != nil || format != testFormat {
+				t.Fatalf("want the talk in the WAV file; got %+v %v", format, err)
+			}
+
+			input := Samples(pcm)
+			output := Samples(played)
+
+			if len(output) != len(input) {
+				t.Fatalf("want %d samples played; got %d", len(input), len(output))
+			}
+
+			for i := range input {
+				want := int16(float64(input[i]) * tt.gain)
+				if diff := int(output[i]) - int(want); diff < -1 || diff > 1 {
+					t.Fatalf("sample %d: want %d; got %d", i, want, output[i])
+				}
+			}
+		})
+	}
+
+	if err := speaker.SetVolume(101); err == nil {
+		t.Error("want error of the volume above 100")
+	}
+}
+
+// blockedSink plays nothing until release is closed
+type blockedSink struct {
+	release chan struct{}
+	played  bytes.Buffer
+}
+
+func (sink *blockedSink) Write(pcm []byte) (int, error) {
+	<-sink.release
+
+	return sink.played.Write(pcm)
+}
+
+func (sink *blockedSink) Close() error {
+	return nil
+}
+
+func TestSpeakerDrop(t *testing.T) {
+	sink := &blockedSink{release: make(chan struct{})}
+
+	speaker := NewSpeaker(func(Format) (io.WriteCloser, error) { return sink, nil }, 0, 100)
+
+	talk, err := speaker.Talk(testFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the speaker is stuck, only the last second of the audio is kept
+	for i := 0; i < 30; i++ {
+		_, err = talk.Write(constant(int16(i), 100*time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	received, dropped := talk.Received()
+	if received != 3*time.Second || dropped < 1900*time.Millisecond || dropped > 2*time.Second {
+		t.Errorf("want 3s received and about 2s dropped; got %v %v", received, dropped)
+	}
+
+	close(sink.release)
+
+	err = talk.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	samples := Samples(sink.played.Bytes())
+	if len(samples) == 0 || samples[len(samples)-1] != 29 {
+		t.Errorf("want the last audio played; got %d samples", len(samples))
+	}
+
+	if _, err := talk.Write([]byte{0, 0}); !errors.Is(err, ErrTalkClosed) {
+		t.Errorf("want ErrTalkClosed; got %v", err)
+	}
+}
@@ -0,0 +1,323 @@
+package audio
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jempe/gopicam/pkg/db"
+)
+
+// muxPage is the number of videos and audios read at a time by the mux
+const muxPage = 100
+
+// muxDelay is the time after the end of the audio segments before the videos
+// are muxed, so the last segment is saved
+const muxDelay = time.Minute
+
+// VideoMuxer muxes the recorded audio in the MP4 videos, the soundtrack of a
+// video is made of the audios that overlap it by DeviceTime
+type VideoMuxer struct {
+	Db          *db.DB
+	Muxer       Muxer
+	MediaFolder string
+	// SegmentLength is the maximum length of the audios, the videos are muxed
+	// once the audios that overlap them are saved
+	SegmentLength time.Duration
+	// DryRun logs the videos that would be muxed without changing them
+	DryRun   bool
+	LogInfo  *log.Logger
+	LogError *log.Logger
+
+	// dryRunLogged are the videos already logged in the dry run
+	dryRunLogged map[string]bool
+}
+
+// Soundtrack returns the audio recorded during the video, the time without
+// audio is silent. audios is empty when no audio overlaps the video
+func (muxer *VideoMuxer) Soundtrack(video db.Video) (format Format, pcm []byte, audios []db.Audio, err error) {
+	start := video.DeviceTime
+	end := video.DeviceTime + int64(video.Length)
+
+	filters := db.Filters{
+		Operator: "AND",
+		Conditions: []db.Condition{
+			{Field: "DeviceTime", Comparison: "BETWEEN", Value: []int64{start - int64(muxer.SegmentLength/time.Second), end - 1}},
+		},
+	}
+
+	cursor := ""
+
+	for {
+		var page []db.Audio
+
+		page, cursor, err = muxer.Db.GetAudioPage(cursor, muxPage, filters, []string{}, db.SortBy{Field: "DeviceTime", Direction: "ASC"})
+		if err != nil {
+			return
+		}
+
+		for _, audio := range page {
+			if strings.ToLower(audio.FileType) != "wav" {
+				continue
+			}
+
+			audioFormat, audioPCM, readErr := muxer.readAudio(audio)
+			if readErr != nil {
+				muxer.logError("Mux:", audio.FileName(), readErr)
+				continue
+			}
+
+			if pcm == nil {
+				format = audioFormat
+				pcm = make([]byte, video.Length*format.BytesPerSecond())
+			} else if audioFormat != format {
+				muxer.logError("Mux:", audio.FileName(), "has a different format than the other audios of the video", video.ID)
+				continue
+			}
+
+			// offset is the position of the audio in the soundtrack
+			offset := int(audio.DeviceTime-start) * format.BytesPerSecond()
+
+			from := max(offset, 0)
+			to := min(offset+len(audioPCM), len(pcm))
+
+			if from >= to {
+				continue
+			}
+
+			copy(pcm[from:to], audioPCM[from-offset:])
+			audios = append(audios, audio)
+		}
+
+		if cursor == "" {
+			break
+		}
+	}
+
+	if len(audios) == 0 {
+		return Format{}, nil, nil, nil
+	}
+
+	return
+}
+
+func (muxer *VideoMuxer) readAudio(audio db.Audio) (Format, []byte, error) {
+	file, err := os.Open(filepath.Join(muxer.MediaFolder, audio.FileName()))
+	if err != nil {
+		return Format{}, nil, err
+	}
+	defer file.Close()
+
+	return ReadWAV(file)
+}
+
+// MuxVideos muxes the audio in the videos that ended before the audios that
+// overlap them were saved at the time now. The videos without audio are
+// marked so they aren't read again
+func (muxer *VideoMuxer) MuxVideos(now time.Time) (muxed int, err error) {
+	saved := now.Add(-muxer.SegmentLength - muxDelay).Unix()
+
+	pending := db.Filters{
+		Operator: "AND",
+		Conditions: []db.Condition{
+			{Field: "Audio", Comparison: "=", Value: ""},
+			{Field: "DeviceTime", Comparison: "<=", Value: saved},
+		},
+	}
+
+	cursor := ""
+
+	for {
+		var page []db.Video
+
+		page, cursor, err = muxer.Db.GetVideoPage(cursor, muxPage, pending, []string{}, db.SortBy{Field: "DeviceTime", Direction: "ASC"})
+		if err != nil {
+			return
+		}
+
+		for _, video := range page {
+			if video.DeviceTime+int64(video.Length) > saved || (muxer.DryRun && muxer.dryRunLogged[video.ID]) {
+				continue
+			}
+
+			var ok bool
+
+			ok, err = muxer.muxVideo(video)
+			if err != nil {
+				return
+			}
+
+			if ok {
+				muxed++
+			}
+		}
+
+		if cursor == "" {
+			break
+		}
+	}
+
+	return
+}
+
+// muxVideo muxes the soundtrack in the video, the videos whose mux fails are
+// marked as failed and logged
+func (muxer *VideoMuxer) muxVideo(video db.Video) (bool, error) {
+	var format Format
+	var pcm []byte
+	var audios []db.Audio
+
+	var err error
+
+	if strings.ToLower(video.FileType) == "mp4" && video.Length > 0 {
+		format, pcm, audios, err = muxer.Soundtrack(video)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if muxer.DryRun {
+		if muxer.dryRunLogged == nil {
+			muxer.dryRunLogged = map[string]bool{}
+		}
+
+		muxer.dryRunLogged[video.ID] = true
+
+		if len(audios) == 0 {
+			muxer.logInfo("Mux: dry run, the video", video.FileName(), "has no audio")
+			return false, nil
+		}
+
+		ids := make([]string, len(audios))
+		for i, audio := range audios {
+			ids[i] = audio.ID
+		}
+
+		muxer.logInfo("Mux: dry run, the video", video.FileName(), "would get the audio of", strings.Join(ids, ", "))
+
+		return true, nil
+	}
+
+	if len(audios) == 0 {
+		video.Audio = db.VideoAudioNone
+
+		_, err = muxer.Db.UpdateVideo(video, []string{"Audio"})
+
+		return false, err
+	}
+
+	size, muxErr := muxer.mux(video, format, pcm)
+	if muxErr != nil {
+		muxer.logError("Mux:", video.FileName(), muxErr)
+
+		video.Audio = db.VideoAudioFailed
+
+		_, err = muxer.Db.UpdateVideo(video, []string{"Audio"})
+
+		return false, err
+	}
+
+	video.Audio = db.VideoAudioMuxed
+	video.Size = size
+
+	_, err = muxer.Db.UpdateVideo(video, []string{"Audio", "Size"})
+	if err != nil {
+		return false, err
+	}
+
+	muxer.logInfo("Mux: the audio of", len(audios), "audios muxed in", video.FileName())
+
+	return true, nil
+}
+
+// mux writes the soundtrack in a WAV file, muxes it and replaces the video
+// with the result. It returns the new size of the video
+func (muxer *VideoMuxer) mux(video db.Video, format Format, pcm []byte) (int, error) {
+	soundtrack, err := os.CreateTemp(muxer.MediaFolder, ".mux-*.wav")
+	if err != nil {
+		return 0, err
+	}
+
+	defer os.Remove(soundtrack.Name())
+
+	writer, err := NewWAVWriter(soundtrack, format)
+	if err == nil {
+		_, err = writer.Write(pcm)
+	}
+
+	if err == nil {
+		err = writer.Close()
+	}
+
+	if closeErr := soundtrack.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	output, err := os.CreateTemp(muxer.MediaFolder, ".mux-*.mp4")
+	if err != nil {
+		return 0, err
+	}
+
+	output.Close()
+	defer os.Remove(output.Name())
+
+	videoPath := filepath.Join(muxer.MediaFolder, video.FileName())
+
+	err = muxer.Muxer.Mux(videoPath, soundtrack.Name(), output.Name())
+	if err != nil {
+		return 0, err
+	}
+
+	info, err := os.Stat(videoPath)
+	if err == nil {
+		err = os.Chmod(output.Name(), info.Mode().Perm())
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	muxed, err := os.Stat(output.Name())
+	if err != nil {
+		return 0, err
+	}
+
+	// the muxed video replaces the video at once so it's never half written
+	err = os.Rename(output.Name(), videoPath)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(muxed.Size()), nil
+}
+
+func (muxer *VideoMuxer) logInfo(v ...any) {
+	if muxer.LogInfo != nil {
+		muxer.LogInfo.Println(v...)
+	}
+}
+
+func (muxer *VideoMuxer) logError(v ...any) {
+	if muxer.LogError != nil {
+		muxer.LogError.Println(v...)
+	}
+}
+
+// Run muxes the audio in the new videos every interval until the program
+// exits
+func (muxer *VideoMuxer) Run(interval time.Duration) {
+	for {
+		_, err := muxer.MuxVideos(time.Now().UTC())
+		if err != nil {
+			muxer.logError("Mux:", err)
+		}
+
+		time.Sleep(interval)
+	}
+}
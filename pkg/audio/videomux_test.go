@@ -0,0 +1,281 @@
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jempe/gopicam/pkg/db"
+)
+
+// constant returns the audio of samples of the value
+func constant(value int16, duration time.Duration) []byte {
+	samples := make([]int16, int(duration*time.Duration(testFormat.SampleRate)/time.Second))
+	for i := range samples {
+		samples[i] = value
+	}
+
+	return PCM(samples)
+}
+
+func TestFindMuxer(t *testing.T) {
+	_, err := FindMuxer("vlc")
+	if err == nil {
+		t.Error("want error of unknown muxer")
+	}
+
+	ffmpeg := FFmpeg{}.Command("video.mp4", "audio.wav", "output.mp4")
+
+	wantFFmpeg := []string{"ffmpeg", "-y", "-v", "error", "-i", "video.mp4", "-i", "audio.wav", "-map", "0:v:0", "-map", "1:a:0", "-c:v", "copy", "-c:a", "aac", "-f", "mp4", "output.mp4"}
+	if !slices.Equal(ffmpeg.Args, wantFFmpeg) {
+		t.Errorf("want %q; got %q", wantFFmpeg, ffmpeg.Args)
+	}
+
+	mp4box := MP4Box{Path: "/opt/gpac/MP4Box"}.Command("video.mp4", "audio.wav", "output.mp4")
+
+	wantMP4Box := []string{"/opt/gpac/MP4Box", "-quiet", "-add", "video.mp4#video", "-add", "audio.wav#audio", "-new", "output.mp4"}
+	if mp4box.Path != "/opt/gpac/MP4Box" || !slices.Equal(mp4box.Args, wantMP4Box) {
+		t.Errorf("want %q; got %q", wantMP4Box, mp4box.Args)
+	}
+}
+
+func TestVideoMuxer(t *testing.T) {
+	database := newTestDB(t)
+	mediaFolder := t.TempDir()
+
+	const start = 1700000000
+
+	// two segments of 10 seconds, the second starts when the first ends
+	for i, value := range []int16{1000, 2000} {
+		path := filepath.Join(mediaFolder, "segment.wav")
+
+		file, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		writer, err := NewWAVWriter(file, testFormat)
+		if err == nil {
+			_, err = writer.Write(constant(value, 10*time.Second))
+		}
+
+		if err == nil {
+			err = writer.Close()
+		}
+
+		file.Close()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		id, err := database.InsertAudio(db.Audio{FileType: "wav", Length: 10, DeviceTime: start + int64(i*10)}, []string{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = os.Rename(path, filepath.Join(mediaFolder, id+".wav"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Unix(start+3600, 0)
+
+	videos := map[string]struct {
+		video     db.Video
+		wantAudio string
+		// wantSoundtrack are the values of every second of the soundtrack
+		wantSoundtrack []int16
+	}{
+		"both segments":  {db.Video{FileType: "mp4", Length: 10, DeviceTime: start + 5}, db.VideoAudioMuxed, []int16{1000, 1000, 1000, 1000, 1000, 2000, 2000, 2000, 2000, 2000}},
+		"after the end":  {db.Video{FileType: "mp4", Length: 5, DeviceTime: start + 18}, db.VideoAudioMuxed, []int16{2000, 2000, 0, 0, 0}},
+		"without audio":  {db.Video{FileType: "mp4", Length: 5, DeviceTime: start + 100}, db.VideoAudioNone, nil},
+		"not mp4":        {db.Video{FileType: "h264", Length: 5, DeviceTime: start + 5}, db.VideoAudioNone, nil},
+		"still recorded": {db.Video{FileType: "mp4", Length: 5, DeviceTime: now.Unix() - 10}, "", nil},
+	}
+
+	ids := map[string]string{}
+
+	for name, tt := range videos {
+		id, err := database.InsertVideo(tt.video, []string{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids[name] = id
+
+		err = os.WriteFile(filepath.Join(mediaFolder, id+"."+tt.video.FileType), []byte("video "+name), 0640)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	soundtracks := map[string][]byte{}
+
+	muxer := &VideoMuxer{
+		Db: database,
+		Muxer: MuxerFunc(func(video string, audio string, output string) error {
+			videoData, err := os.ReadFile(video)
+			if err != nil {
+				return err
+			}
+
+			audioData, err := os.ReadFile(audio)
+			if err != nil {
+				return err
+			}
+
+			format, pcm, err := ReadWAV(bytes.NewReader(audioData))
+			if err != nil || format != testFormat {
+				t.Errorf("want a WAV soundtrack in %+v; got %+v %v", testFormat, format, err)
+			}
+
+			soundtracks[string(videoData)] = pcm
+
+			return os.WriteFile(output, append(videoData, audioData...), 0600)
+		}),
+		MediaFolder:   mediaFolder,
+		SegmentLength: 10 * time.Second,
+		DryRun:        true,
+	}
+
+	// the dry run doesn't change the videos and logs them once
+	for _, wantMuxed := range []int{2, 0} {
+		muxed, err := muxer.MuxVideos(now)
+		if err != nil || muxed != wantMuxed || len(soundtracks) != 0 {
+			t.Fatalf("want %d videos in the dry run and no mux; got %d %v %d", wantMuxed, muxed, err, len(soundtracks))
+		}
+	}
+
+	for name, id := range ids {
+		video, err := database.GetVideo(id)
+		if err != nil || video.Audio != "" {
+			t.Errorf("%s: want the video unchanged by the dry run; got %+v %v", name, video, err)
+		}
+	}
+
+	muxer.DryRun = false
+
+	muxed, err := muxer.MuxVideos(now)
+	if err != nil || muxed != 2 {
+		t.Fatalf("want 2 videos muxed; got %d %v", muxed, err)
+	}
+
+	for name, tt := range videos {
+		video, err := database.GetVideo(ids[name])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if video.Audio != tt.wantAudio {
+			t.Errorf("%s: want audio %q; got %q", name, tt.wantAudio, video.Audio)
+		}
+
+		data, err := os.ReadFile(filepath.Join(mediaFolder, video.FileName()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if tt.wantAudio != db.VideoAudioMuxed {
+			if string(data) != "video "+name {
+				t.Errorf("%s: want the file unchanged; got %q", name, data)
+			}
+
+			continue
+		}
+
+		info, err := os.Stat(filepath.Join(mediaFolder, video.FileName()))
+		if err != nil || info.Mode().Perm() != 0640 {
+			t.Errorf("%s: want the mode of the video kept; got %v %v", name, info, err)
+		}
+
+		if video.Size != len(data) || !bytes.HasPrefix(data, []byte("video "+name)) {
+			t.Errorf("%s: want the muxed file of %d bytes; got %d", name, len(data), video.Size)
+		}
+
+		soundtrack := Samples(soundtracks["video "+name])
+		if len(soundtrack) != len(tt.wantSoundtrack)*testFormat.SampleRate {
+			t.Fatalf("%s: want %d seconds of soundtrack; got %d samples", name, len(tt.wantSoundtrack), len(soundtrack))
+		}
+
+		for second, want := range tt.wantSoundtrack {
+			for _, sample := range soundtrack[second*testFormat.SampleRate : (second+1)*testFormat.SampleRate] {
+				if sample != want {
+					t.Fatalf("%s: want %d at %ds; got %d", name, want, second, sample)
+				}
+			}
+		}
+	}
+
+	entries, err := os.ReadDir(mediaFolder)
+	if err != nil || len(entries) != 2+len(videos) {
+		t.Errorf("want no temporary files in the media folder; got %v %v", entries, err)
+	}
+}
+
+func TestVideoMuxerFailed(t *testing.T) {
+	database := newTestDB(t)
+	mediaFolder := t.TempDir()
+
+	audioID, err := database.InsertAudio(db.Audio{FileType: "wav", Length: 1, DeviceTime: 1000}, []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Create(filepath.Join(mediaFolder, audioID+".wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer, err := NewWAVWriter(file, testFormat)
+	if err == nil {
+		_, err = writer.Write(constant(1000, time.Second))
+	}
+
+	if err == nil {
+		err = writer.Close()
+	}
+
+	file.Close()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	videoID, err := database.InsertVideo(db.Video{FileType: "mp4", Length: 1, DeviceTime: 1000}, []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(filepath.Join(mediaFolder, videoID+".mp4"), []byte("video"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	muxer := &VideoMuxer{
+		Db:            database,
+		Muxer:         MuxerFunc(func(string, string, string) error { return errors.New("corrupt video") }),
+		MediaFolder:   mediaFolder,
+		SegmentLength: time.Second,
+	}
+
+	muxed, err := muxer.MuxVideos(time.Unix(5000, 0))
+	if err != nil || muxed != 0 {
+		t.Fatalf("want no video muxed; got %d %v", muxed, err)
+	}
+
+	video, err := database.GetVideo(videoID)
+	if err != nil || video.Audio != db.VideoAudioFailed || video.Size != 0 {
+		t.Errorf("want the video marked as failed; got %+v %v", video, err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(mediaFolder, videoID+".mp4"))
+	if err != nil || string(data) != "video" {
+		t.Errorf("want the video unchanged; got %q %v", data, err)
+	}
+}
@@ -0,0 +1,199 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// wavHeaderSize is the size of the RIFF, fmt and data headers written by
+// WAVWriter
+const wavHeaderSize = 44
+
+// bytesPerSample is the size of the signed 16 bit samples
+const bytesPerSample = 2
+
+var ErrInvalidWAV = errors.New("audio: invalid WAV file")
+
+// Format is the format of the signed 16 bit little endian PCM audio
+type Format struct {
+	SampleRate int
+	Channels   int
+}
+
+// BytesPerSecond returns the size of a second of audio
+func (format Format) BytesPerSecond() int {
+	return format.SampleRate * format.Channels * bytesPerSample
+}
+
+// Duration returns the duration of the size in bytes of audio
+func (format Format) Duration(size int) time.Duration {
+	if format.BytesPerSecond() == 0 {
+		return 0
+	}
+
+	return time.Duration(size) * time.Second / time.Duration(format.BytesPerSecond())
+}
+
+// WAVWriter writes the PCM audio in a WAV file, the sizes of the header are
+// written by Close
+type WAVWriter struct {
+	file   io.WriteSeeker
+	format Format
+	size   int
+}
+
+// NewWAVWriter writes the header of the WAV file
+func NewWAVWriter(file io.WriteSeeker, format Format) (*WAVWriter, error) {
+	if format.SampleRate <= 0 || format.Channels <= 0 {
+		return nil, errors.New("audio: invalid format")
+	}
+
+	writer := &WAVWriter{file: file, format: format}
+
+	_, err := file.Write(writer.header())
+	if err != nil {
+		return nil, err
+	}
+
+	return writer, nil
+}
+
+// Write writes the PCM audio, signed 16 bit little endian samples
+func (writer *WAVWriter) Write(pcm []byte) (int, error) {
+	n, err := writer.file.Write(pcm)
+	writer.size += n
+
+	return n, err
+}
+
+// Size returns the size of the audio written
+func (writer *WAVWriter) Size() int {
+	return writer.size
+}
+
+// Close writes the sizes in the header, it doesn't close the file
+func (writer *WAVWriter) Close() error {
+	_, err := writer.file.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	_, err = writer.file.Write(writer.header())
+	if err != nil {
+		return err
+	}
+
+	_, err = writer.file.Seek(0, io.SeekEnd)
+
+	return err
+}
+
+func (writer *WAVWriter) header() []byte {
+	header := make([]byte, wavHeaderSize)
+
+	copy(header, "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(wavHeaderSize-8+writer.size))
+	copy(header[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(header[16:], 16)
+	// PCM
+	binary.LittleEndian.PutUint16(header[20:], 1)
+	binary.LittleEndian.PutUint16(header[22:], uint16(writer.format.Channels))
+	binary.LittleEndian.PutUint32(header[24:], uint32(writer.format.SampleRate))
+	binary.LittleEndian.PutUint32(header[28:], uint32(writer.format.BytesPerSecond()))
+	binary.LittleEndian.PutUint16(header[32:], uint16(writer.format.Channels*bytesPerSample))
+	binary.LittleEndian.PutUint16(header[34:], 8*bytesPerSample)
+	copy(header[36:], "data")
+	binary.LittleEndian.PutUint32(header[40:], uint32(writer.size))
+
+	return header
+}
+
+// ReadWAV returns the format and the PCM audio of a WAV file of signed 16 bit
+// samples, the chunks other than fmt and data are skipped
+func ReadWAV(r io.Reader) (format Format, pcm []byte, err error) {
+	header := make([]byte, 12)
+
+	_, err = io.ReadFull(r, header)
+	if err != nil || string(header[:4]) != "RIFF" || string(header[8:]) != "WAVE" {
+		return format, nil, ErrInvalidWAV
+	}
+
+	hasFormat := false
+
+	for {
+		chunk := make([]byte, 8)
+
+		_, err = io.ReadFull(r, chunk)
+		if err != nil {
+			return format, nil, ErrInvalidWAV
+		}
+
+		size := int64(binary.LittleEndian.Uint32(chunk[4:]))
+
+		switch string(chunk[:4]) {
+		case "fmt ":
+			if size < 16 {
+				return format, nil, ErrInvalidWAV
+			}
+
+			fmtChunk := make([]byte, size)
+
+			_, err = io.ReadFull(r, fmtChunk)
+			if err != nil {
+				return format, nil, ErrInvalidWAV
+			}
+
+			if binary.LittleEndian.Uint16(fmtChunk) != 1 || binary.LittleEndian.Uint16(fmtChunk[14:]) != 8*bytesPerSample {
+				return format, nil, errors.New("audio: only 16 bit PCM WAV files are supported")
+			}
+
+			format.Channels = int(binary.LittleEndian.Uint16(fmtChunk[2:]))
+			format.SampleRate = int(binary.LittleEndian.Uint32(fmtChunk[4:]))
+			hasFormat = format.Channels > 0 && format.SampleRate > 0
+		case "data":
+			if !hasFormat {
+				return format, nil, ErrInvalidWAV
+			}
+
+			pcm, err = io.ReadAll(io.LimitReader(r, size))
+			if err != nil {
+				return format, nil, err
+			}
+
+			if int64(len(pcm)) != size {
+				return format, nil, ErrInvalidWAV
+			}
+
+			return format, pcm, nil
+		default:
+			_, err = io.CopyN(io.Discard, r, size+size%2)
+			if err != nil {
+				return format, nil, ErrInvalidWAV
+			}
+		}
+	}
+}
+
+// Samples returns the signed 16 bit little endian samples of the PCM audio
+func Samples(pcm []byte) []int16 {
+	samples := make([]int16, len(pcm)/bytesPerSample)
+
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(pcm[i*bytesPerSample:]))
+	}
+
+	return samples
+}
+
+// PCM returns the signed 16 bit little endian PCM audio of the samples
+func PCM(samples []int16) []byte {
+	pcm := make([]byte, len(samples)*bytesPerSample)
+
+	for i, sample := range samples {
+		binary.LittleEndian.PutUint16(pcm[i*bytesPerSample:], uint16(sample))
+	}
+
+	return pcm
+}
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const AlgorithmBcrypt = "bcrypt"
+const AlgorithmArgon2id = "argon2id"
+
+const argon2SaltLength = 16
+const argon2KeyLength = 32
+
+var errInvalidHash = errors.New("error:invalid_password_hash")
+
+// HashPolicy defines how new password hashes are created, hashes created with
+// weaker settings are upgraded the next time the user logs in
+type HashPolicy struct {
+	Algorithm     string
+	BcryptCost    int
+	Argon2Time    uint32
+	Argon2Memory  uint32
+	Argon2Threads uint8
+}
+
+// DefaultHashPolicy uses the argon2id settings recommended by OWASP, they are
+// light enough to log in quickly on a Raspberry Pi
+var DefaultHashPolicy = HashPolicy{
+	Algorithm:     AlgorithmArgon2id,
+	BcryptCost:    12,
+	Argon2Time:    2,
+	Argon2Memory:  19 * 1024,
+	Argon2Threads: 1,
+}
+
+type argon2Params struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+// dummyHash is compared when the user doesn't exist, so the response time
+// doesn't reveal which usernames are valid
+var dummyHash, _ = DefaultHashPolicy.Hash("gopicam-dummy-password")
+
+// Hash returns the hash of the password that is saved in the DB
+func (policy HashPolicy) Hash(password string) ([]byte, error) {
+	if policy.Algorithm == AlgorithmBcrypt {
+		return bcrypt.GenerateFromPassword([]byte(password), policy.BcryptCost)
+	} else if policy.Algorithm != AlgorithmArgon2id {
+		return nil, errors.New("error:unknown_hash_algorithm|" + policy.Algorithm)
+	}
+
+	salt := make([]byte, argon2SaltLength)
+
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, policy.Argon2Time, policy.Argon2Memory, policy.Argon2Threads, argon2KeyLength)
+
+	encodedHash := fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, policy.Argon2Memory, policy.Argon2Time, policy.Argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+
+	return []byte(encodedHash), nil
+}
+
+// NeedsRehash checks if the hash was created with a different algorithm or
+// weaker settings than the policy
+func (policy HashPolicy) NeedsRehash(hash []byte) bool {
+	if policy.Algorithm == AlgorithmBcrypt {
+		cost, err := bcrypt.Cost(hash)
+
+		return err != nil || cost < policy.BcryptCost
+	}
+
+	params, err := decodeArgon2Hash(hash)
+	if err != nil {
+		return true
+	}
+
+	return params.time < policy.Argon2Time || params.memory < policy.Argon2Memory || params.threads < policy.Argon2Threads
+}
+
+// CheckPassword compares the password with a bcrypt or argon2id hash, use a
+// nil hash when the user doesn't exist
+func CheckPassword(hash []byte, password string) bool {
+	if hash == nil {
+		checkArgon2Password(dummyHash, password)
+		return false
+	}
+
+	if strings.HasPrefix(string(hash), "$argon2id$") {
+		return checkArgon2Password(hash, password)
+	}
+
+	return bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil
+}
+
+func checkArgon2Password(hash []byte, password string) bool {
+	params, err := decodeArgon2Hash(hash)
+	if err != nil {
+		return false
+	}
+
+	key := argon2.IDKey([]byte(password), params.salt, params.time, params.memory, params.threads, uint32(len(params.key)))
+
+	return subtle.ConstantTimeCompare(key, params.key) == 1
+}
+
+// decodeArgon2Hash reads the parameters of a hash in the PHC string format
+// $argon2id$v=19$m=19456,t=2,p=1$salt$key
+func decodeArgon2Hash(hash []byte) (params argon2Params, err error) {
+	parts := strings.Split(string(hash), "$")
+	if len(parts) != 6 || parts[1] != AlgorithmArgon2id {
+		err = errInvalidHash
+		return
+	}
+
+	var version int
+
+	_, err = fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		err = errInvalidHash
+		return
+	}
+
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads)
+	if err != nil {
+		err = errInvalidHash
+		return
+	}
+
+	params.salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return
+	}
+
+	params.key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return
+	}
+
+	if len(params.key) == 0 {
+		err = errInvalidHash
+	}
+
+	return
+}
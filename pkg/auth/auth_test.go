@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHashPolicy(t *testing.T) {
+	legacyHash, err := bcrypt.GenerateFromPassword([]byte("correct horse"), 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	strongBcrypt := HashPolicy{Algorithm: AlgorithmBcrypt, BcryptCost: 10}
+
+	tests := []struct {
+		name       string
+		policy     HashPolicy
+		hash       []byte
+		wantRehash bool
+	}{
+		{
+			name:       "Legacy Bcrypt To Argon2id",
+			policy:     DefaultHashPolicy,
+			hash:       legacyHash,
+			wantRehash: true,
+		},
+		{
+			name:       "Legacy Bcrypt To Higher Cost",
+			policy:     strongBcrypt,
+			hash:       legacyHash,
+			wantRehash: true,
+		},
+		{
+			name:       "Same Bcrypt Cost",
+			policy:     HashPolicy{Algorithm: AlgorithmBcrypt, BcryptCost: 8},
+			hash:       legacyHash,
+			wantRehash: false,
+		},
+		{
+			name:       "Invalid Hash",
+			policy:     DefaultHashPolicy,
+			hash:       []byte("$argon2id$v=19$m=abc"),
+			wantRehash: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.policy.NeedsRehash(tt.hash) != tt.wantRehash {
+				t.Errorf("want rehash %t; got %t", tt.wantRehash, !tt.wantRehash)
+			}
+		})
+	}
+
+	t.Run("Argon2id", func(t *testing.T) {
+		hash, err := DefaultHashPolicy.Hash("correct horse")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !CheckPassword(hash, "correct horse") {
+			t.Errorf("password doesn't match its hash")
+		}
+
+		if CheckPassword(hash, "wrong horse") {
+			t.Errorf("wrong password matches the hash")
+		}
+
+		if DefaultHashPolicy.NeedsRehash(hash) {
+			t.Errorf("hash created with the policy shouldn't need a rehash")
+		}
+
+		strongerPolicy := DefaultHashPolicy
+		strongerPolicy.Argon2Time = 3
+
+		if !strongerPolicy.NeedsRehash(hash) {
+			t.Errorf("hash created with weaker settings should need a rehash")
+		}
+	})
+
+	t.Run("Bcrypt", func(t *testing.T) {
+		if !CheckPassword(legacyHash, "correct horse") {
+			t.Errorf("password doesn't match its bcrypt hash")
+		}
+	})
+
+	t.Run("Unknown User", func(t *testing.T) {
+		if CheckPassword(nil, "gopicam-dummy-password") {
+			t.Errorf("nil hash shouldn't match")
+		}
+	})
+}
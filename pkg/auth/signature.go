@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+)
+
+// The headers of the requests signed by the devices
+const (
+	HeaderDeviceKey = "X-Gopicam-Key"
+	HeaderTimestamp = "X-Gopicam-Timestamp"
+	HeaderNonce     = "X-Gopicam-Nonce"
+	HeaderSignature = "X-Gopicam-Signature"
+)
+
+const deviceKeyLength = 16
+const deviceSecretLength = 32
+
+// GenerateDeviceCredentials returns a random key that identifies a device and
+// the secret that signs its requests
+func GenerateDeviceCredentials() (key string, secret string, err error) {
+	keyBytes := make([]byte, deviceKeyLength)
+
+	_, err = rand.Read(keyBytes)
+	if err != nil {
+		return
+	}
+
+	secretBytes := make([]byte, deviceSecretLength)
+
+	_, err = rand.Read(secretBytes)
+	if err != nil {
+		return
+	}
+
+	return hex.EncodeToString(keyBytes), base64.RawURLEncoding.EncodeToString(secretBytes), nil
+}
+
+// SignRequest returns the hex HMAC-SHA256 of the timestamp, the nonce and the
+// body separated by new lines, the key of the HMAC is the secret of the device
+func SignRequest(secret string, timestamp int64, nonce string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "\n" + nonce + "\n"))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature compares the signature of a request in constant time
+func VerifySignature(secret string, signature string, timestamp int64, nonce string, body []byte) bool {
+	signatureBytes, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	expected, _ := hex.DecodeString(SignRequest(secret, timestamp, nonce, body))
+
+	return hmac.Equal(signatureBytes, expected)
+}
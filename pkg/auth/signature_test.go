@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"testing"
+)
+
+func TestGenerateDeviceCredentials(t *testing.T) {
+	key, secret, err := GenerateDeviceCredentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(key) != 32 || len(secret) != 43 {
+		t.Errorf("want a key of 32 and a secret of 43 characters; got %q %q", key, secret)
+	}
+
+	otherKey, otherSecret, err := GenerateDeviceCredentials()
+	if err != nil || otherKey == key || otherSecret == secret {
+		t.Errorf("want different credentials; got %q %q %v", otherKey, otherSecret, err)
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	secret := "device-secret"
+	body := []byte(`{"_type":"location","lat":52.5,"lon":13.4}`)
+
+	// computed with: printf '1700000000\nn1\n<body>' | openssl dgst -sha256 -hmac device-secret
+	signature := SignRequest(secret, 1700000000, "n1", body)
+	if signature != "aace420dd9eff3e7a79a0220606d579b30632b0121430459616575a5769dee06" {
+		t.Fatalf("want the HMAC-SHA256 of the timestamp, nonce and body; got %q", signature)
+	}
+
+	tests := []struct {
+		name      string
+		secret    string
+		signature string
+		timestamp int64
+		nonce     string
+		body      []byte
+		want      bool
+	}{
+		{"Valid", secret, signature, 1700000000, "n1", body, true},
+		{"Other Secret", "other-secret", signature, 1700000000, "n1", body, false},
+		{"Other Timestamp", secret, signature, 1700000001, "n1", body, false},
+		{"Other Nonce", secret, signature, 1700000000, "n2", body, false},
+		{"Other Body", secret, signature, 1700000000, "n1", []byte(`{"_type":"location","lat":0,"lon":0}`), false},
+		{"Not Hex", secret, "signature", 1700000000, "n1", body, false},
+		{"Empty", secret, "", 1700000000, "n1", body, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifySignature(tt.secret, tt.signature, tt.timestamp, tt.nonce, tt.body); got != tt.want {
+				t.Errorf("want %t; got %t", tt.want, got)
+			}
+		})
+	}
+}
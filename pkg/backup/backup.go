@@ -0,0 +1,340 @@
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// Snapshot is a read transaction of a Bolt DB, the db.Tx of the bbolt store
+// and the bbolt.Tx implement it with WriteTo
+type Snapshot interface {
+	io.WriterTo
+	Size() int64
+}
+
+// File is a DB file of the backups
+type File struct {
+	// name of the file in the backup
+	Name string
+	// path of the DB file, the restore replaces it
+	Path string
+	// runs the function in a read transaction of the open DB
+	View func(fn func(snapshot Snapshot) error) error
+	// checks the restored file before it replaces the DB file
+	Validate func(path string) error
+}
+
+type Options struct {
+	Compress   bool
+	Passphrase string
+}
+
+// BoltView adapts the View method of a bolt or bbolt DB to the View of a File
+func BoltView[Tx Snapshot](view func(fn func(tx Tx) error) error) func(fn func(snapshot Snapshot) error) error {
+	return func(fn func(snapshot Snapshot) error) error {
+		return view(func(tx Tx) error {
+			return fn(tx)
+		})
+	}
+}
+
+// Write streams a tar archive with a consistent copy of every file, the DBs can
+// be used while the backup is written. The archive is compressed with gzip and
+// then encrypted when the options ask for it
+func Write(w io.Writer, files []File, options Options) (err error) {
+	out := w
+
+	var encrypter *encryptWriter
+
+	if options.Passphrase != "" {
+		encrypter, err = newEncryptWriter(w, options.Passphrase)
+		if err != nil {
+			return
+		}
+
+		out = encrypter
+	}
+
+	var compressor *gzip.Writer
+
+	if options.Compress {
+		compressor = gzip.NewWriter(out)
+		out = compressor
+	}
+
+	archive := tar.NewWriter(out)
+	now := time.Now().UTC()
+
+	for _, file := range files {
+		err = file.View(func(snapshot Snapshot) error {
+			err := archive.WriteHeader(&tar.Header{
+				Name:    file.Name,
+				Mode:    0600,
+				Size:    snapshot.Size(),
+				ModTime: now,
+			})
+			if err != nil {
+				return err
+			}
+
+			_, err = snapshot.WriteTo(archive)
+
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("backup of %s error: %s", file.Name, err)
+		}
+	}
+
+	err = archive.Close()
+	if err != nil {
+		return
+	}
+
+	if compressor != nil {
+		err = compressor.Close()
+		if err != nil {
+			return
+		}
+	}
+
+	if encrypter != nil {
+		err = encrypter.Close()
+	}
+
+	return
+}
+
+// Restore extracts the files of a backup next to the DB files, validates them
+// and then replaces the DB files. The DBs must be closed. A backup is restored
+// only when it has all the files and all of them are valid, the replaced DB files
+// are kept with the .pre-restore extension. When a DB file can't be replaced the
+// files already replaced are rolled back from the .pre-restore links. The
+// passphrase is asked only for encrypted backups
+func Restore(r io.Reader, files []File, passphrase func() (string, error)) (err error) {
+	input := bufio.NewReader(r)
+
+	if isEncrypted(input) {
+		if passphrase == nil {
+			return errors.New("the backup is encrypted")
+		}
+
+		password, passphraseErr := passphrase()
+		if passphraseErr != nil {
+			return passphraseErr
+		}
+
+		decrypter, decryptErr := newDecryptReader(input, password)
+		if decryptErr != nil {
+			return decryptErr
+		}
+
+		input = bufio.NewReader(decrypter)
+	}
+
+	var archiveReader io.Reader = input
+
+	if magic, _ := input.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		decompressor, gzipErr := gzip.NewReader(input)
+		if gzipErr != nil {
+			return gzipErr
+		}
+
+		archiveReader = decompressor
+	}
+
+	restored := make(map[string]string)
+
+	// remove the extracted files when the restore fails
+	defer func() {
+		if err != nil {
+			for _, tempPath := range restored {
+				os.Remove(tempPath)
+			}
+		}
+	}()
+
+	archive := tar.NewReader(archiveReader)
+
+	for {
+		header, nextErr := archive.Next()
+		if nextErr == io.EOF {
+			break
+		} else if nextErr != nil {
+			return fmt.Errorf("backup read error: %s", nextErr)
+		}
+
+		file, found := findFile(files, header.Name)
+		if !found {
+			return fmt.Errorf("unknown file %s in the backup", header.Name)
+		}
+
+		if _, duplicated := restored[file.Name]; duplicated {
+			return fmt.Errorf("duplicated file %s in the backup", header.Name)
+		}
+
+		tempPath, extractErr := extractFile(archive, file.Path)
+		if extractErr != nil {
+			return fmt.Errorf("backup read error %s: %s", file.Name, extractErr)
+		}
+
+		restored[file.Name] = tempPath
+
+		if file.Validate != nil {
+			err = file.Validate(tempPath)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %s", file.Name, err)
+			}
+		}
+	}
+
+	// read the rest of the backup, the last block of the encrypted backups is checked at the end
+	_, err = io.Copy(io.Discard, archiveReader)
+	if err != nil {
+		return fmt.Errorf("backup read error: %s", err)
+	}
+
+	for _, file := range files {
+		if _, found := restored[file.Name]; !found {
+			return fmt.Errorf("missing file %s in the backup", file.Name)
+		}
+	}
+
+	previous := make(map[string]bool)
+
+	// the hard links keep the previous DBs before any of them is replaced
+	for _, file := range files {
+		previousPath := file.Path + ".pre-restore"
+
+		os.Remove(previousPath)
+
+		if _, statErr := os.Stat(file.Path); statErr == nil {
+			err = os.Link(file.Path, previousPath)
+			if err != nil {
+				removeLinks(files, previous)
+				return
+			}
+
+			previous[file.Name] = true
+		}
+	}
+
+	var replaced []File
+
+	for _, file := range files {
+		err = rename(restored[file.Name], file.Path)
+		if err != nil {
+			if rollbackErr := rollback(files, replaced, previous); rollbackErr != nil {
+				return fmt.Errorf("restore error: %s, the previous DBs are in the .pre-restore files: %s", err, rollbackErr)
+			}
+
+			return
+		}
+
+		delete(restored, file.Name)
+		replaced = append(replaced, file)
+	}
+
+	return
+}
+
+// rename replaces the DB files, the tests change it to fail the restore
+var rename = os.Rename
+
+// rollback puts back the previous DBs of the replaced files when the restore
+// fails, so all the DBs have the same version
+func rollback(files []File, replaced []File, previous map[string]bool) (err error) {
+	for _, file := range replaced {
+		if previous[file.Name] {
+			err = rename(file.Path+".pre-restore", file.Path)
+		} else {
+			err = os.Remove(file.Path)
+		}
+
+		if err != nil {
+			return
+		}
+
+		delete(previous, file.Name)
+	}
+
+	removeLinks(files, previous)
+
+	return
+}
+
+// removeLinks removes the .pre-restore links of the DB files that weren't replaced
+func removeLinks(files []File, previous map[string]bool) {
+	for _, file := range files {
+		if previous[file.Name] {
+			os.Remove(file.Path + ".pre-restore")
+		}
+	}
+}
+
+func findFile(files []File, name string) (File, bool) {
+	for _, file := range files {
+		if file.Name == name {
+			return file, true
+		}
+	}
+
+	return File{}, false
+}
+
+// extractFile copies the current file of the archive to a temp file in the
+// folder of the DB, so it can be renamed to the DB file
+func extractFile(archive io.Reader, dbPath string) (tempPath string, err error) {
+	tempFile, err := os.CreateTemp(filepath.Dir(dbPath), filepath.Base(dbPath)+".restore-*")
+	if err != nil {
+		return
+	}
+
+	tempPath = tempFile.Name()
+
+	_, err = io.Copy(tempFile, archive)
+	if err == nil {
+		err = tempFile.Sync()
+	}
+
+	closeErr := tempFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(tempPath)
+	}
+
+	return
+}
+
+// CheckBolt opens a Bolt DB file in read only mode and checks the consistency of its pages
+func CheckBolt(path string) error {
+	boltDB, err := bbolt.Open(path, 0600, &bbolt.Options{ReadOnly: true, Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+	defer boltDB.Close()
+
+	return boltDB.View(func(tx *bbolt.Tx) (checkErr error) {
+		// read all the errors so the check finishes before the transaction is closed
+		for err := range tx.Check() {
+			if checkErr == nil {
+				checkErr = err
+			}
+		}
+
+		return
+	})
+}
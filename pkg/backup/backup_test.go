@@ -0,0 +1,361 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// newTestDBs creates two DB files with the value in the bucket "test"
+func newTestDBs(t *testing.T, value string) (dir string, paths []string) {
+	dir = t.TempDir()
+
+	for _, name := range []string{"gopicam.db", "sessions.db"} {
+		dbPath := filepath.Join(dir, name)
+		setValue(t, dbPath, value)
+
+		paths = append(paths, dbPath)
+	}
+
+	return
+}
+
+func setValue(t *testing.T, dbPath string, value string) {
+	boltDB, err := bbolt.Open(dbPath, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer boltDB.Close()
+
+	err = boltDB.Update(func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte("test"))
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte("value"), []byte(value))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func getValue(t *testing.T, dbPath string) string {
+	boltDB, err := bbolt.Open(dbPath, 0600, &bbolt.Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer boltDB.Close()
+
+	var value string
+
+	boltDB.View(func(tx *bbolt.Tx) error {
+		value = string(tx.Bucket([]byte("test")).Get([]byte("value")))
+		return nil
+	})
+
+	return value
+}
+
+// writeBackup opens the DBs and writes a backup of them
+func writeBackup(t *testing.T, paths []string, options Options) []byte {
+	var files []File
+
+	for _, dbPath := range paths {
+		boltDB, err := bbolt.Open(dbPath, 0600, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer boltDB.Close()
+
+		files = append(files, File{Name: filepath.Base(dbPath), Path: dbPath, View: BoltView(boltDB.View)})
+	}
+
+	var backup bytes.Buffer
+
+	err := Write(&backup, files, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return backup.Bytes()
+}
+
+func restoreFiles(paths []string) (files []File) {
+	for _, dbPath := range paths {
+		files = append(files, File{Name: filepath.Base(dbPath), Path: dbPath, Validate: CheckBolt})
+	}
+
+	return
+}
+
+func passphrase(value string) func() (string, error) {
+	return func() (string, error) {
+		return value, nil
+	}
+}
+
+func TestBackupRestore(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+	}{
+		{name: "Plain", options: Options{}},
+		{name: "Gzip", options: Options{Compress: true}},
+		{name: "Encrypted", options: Options{Passphrase: "backup passphrase"}},
+		{name: "Gzip Encrypted", options: Options{Compress: true, Passphrase: "backup passphrase"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, paths := newTestDBs(t, "before backup")
+
+			backup := writeBackup(t, paths, tt.options)
+
+			if tt.options.Passphrase != "" && bytes.Contains(backup, []byte("before backup")) {
+				t.Errorf("the encrypted backup has the data in plain text")
+			}
+
+			for _, dbPath := range paths {
+				setValue(t, dbPath, "after backup")
+			}
+
+			err := Restore(bytes.NewReader(backup), restoreFiles(paths), passphrase(tt.options.Passphrase))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, dbPath := range paths {
+				if value := getValue(t, dbPath); value != "before backup" {
+					t.Errorf("want restored value; got %q", value)
+				}
+
+				if value := getValue(t, dbPath+".pre-restore"); value != "after backup" {
+					t.Errorf("want previous DB; got %q", value)
+				}
+			}
+		})
+	}
+}
+
+func TestRestoreErrors(t *testing.T) {
+	dir, paths := newTestDBs(t, "before backup")
+
+	encrypted := writeBackup(t, paths, Options{Compress: true, Passphrase: "backup passphrase"})
+	plain := writeBackup(t, paths, Options{})
+	incomplete := writeBackup(t, paths[:1], Options{})
+
+	for _, dbPath := range paths {
+		setValue(t, dbPath, "after backup")
+	}
+
+	tests := []struct {
+		name       string
+		backup     []byte
+		passphrase func() (string, error)
+		validate   func(path string) error
+		wantErr    error
+	}{
+		{name: "Wrong Passphrase", backup: encrypted, passphrase: passphrase("wrong passphrase"), wantErr: ErrPassphrase},
+		{name: "Truncated", backup: encrypted[:len(encrypted)-20], passphrase: passphrase("backup passphrase")},
+		{name: "Modified", backup: append(append([]byte{}, encrypted[:100]...), append([]byte{encrypted[100] ^ 1}, encrypted[101:]...)...), passphrase: passphrase("backup passphrase"), wantErr: ErrPassphrase},
+		{name: "Without Passphrase", backup: encrypted},
+		{name: "Missing File", backup: incomplete},
+		{name: "Not A Backup", backup: []byte("not a backup")},
+		{name: "Invalid File", backup: plain, validate: func(path string) error { return errors.New("invalid") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := restoreFiles(paths)
+			if tt.validate != nil {
+				files[1].Validate = tt.validate
+			}
+
+			err := Restore(bytes.NewReader(tt.backup), files, tt.passphrase)
+			if err == nil {
+				t.Fatal("want error; got nil")
+			}
+
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) && !strings.Contains(err.Error(), tt.wantErr.Error()) {
+				t.Errorf("want %s; got %s", tt.wantErr, err)
+			}
+
+			for _, dbPath := range paths {
+				if value := getValue(t, dbPath); value != "after backup" {
+					t.Errorf("the DB was replaced by a failed restore")
+				}
+			}
+
+			entries, _ := os.ReadDir(dir)
+			for _, entry := range entries {
+				if strings.Contains(entry.Name(), ".restore-") {
+					t.Errorf("temp file %s was not removed", entry.Name())
+				}
+			}
+		})
+	}
+}
+
+func TestRestoreRollback(t *testing.T) {
+	dir, paths := newTestDBs(t, "before backup")
+
+	backup := writeBackup(t, paths, Options{})
+
+	for _, dbPath := range paths {
+		setValue(t, dbPath, "after backup")
+	}
+
+	// the second DB can't be replaced, the rollback renames are allowed
+	rename = func(oldPath, newPath string) error {
+		if newPath == paths[1] && !strings.HasSuffix(oldPath, ".pre-restore") {
+			return errors.New("rename failed")
+		}
+
+		return os.Rename(oldPath, newPath)
+	}
+	defer func() { rename = os.Rename }()
+
+	err := Restore(bytes.NewReader(backup), restoreFiles(paths), nil)
+	if err == nil || err.Error() != "rename failed" {
+		t.Fatalf("want rename error; got %v", err)
+	}
+
+	for _, dbPath := range paths {
+		if value := getValue(t, dbPath); value != "after backup" {
+			t.Errorf("want rolled back value; got %q", value)
+		}
+	}
+
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".restore-") || strings.HasSuffix(entry.Name(), ".pre-restore") {
+			t.Errorf("file %s was not removed", entry.Name())
+		}
+	}
+}
+
+func TestEncryption(t *testing.T) {
+	for _, size := range []int{0, 1, blockSize - 1, blockSize, blockSize + 1, 3 * blockSize} {
+		data := make([]byte, size)
+		rand.Read(data)
+
+		var encrypted bytes.Buffer
+
+		writer, err := newEncryptWriter(&encrypted, "passphrase")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// write in pieces that don't match the blocks
+		for written := 0; written < size; written += 1000 {
+			writer.Write(data[written:min(written+1000, size)])
+		}
+
+		err = writer.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		input := bufio.NewReader(&encrypted)
+		if !isEncrypted(input) {
+			t.Fatalf("size %d: missing magic bytes", size)
+		}
+
+		reader, err := newDecryptReader(input, "passphrase")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decrypted, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("size %d: %s", size, err)
+		}
+
+		if !bytes.Equal(decrypted, data) {
+			t.Errorf("size %d: decrypted data is different", size)
+		}
+	}
+}
+
+func TestRotate(t *testing.T) {
+	dir := t.TempDir()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 5; i++ {
+		os.WriteFile(filepath.Join(dir, FileName(start.Add(time.Duration(i)*time.Hour), Options{Compress: true})), []byte("backup"), 0600)
+	}
+
+	os.WriteFile(filepath.Join(dir, "other.txt"), []byte("other"), 0600)
+
+	deleted, err := Rotate(dir, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deleted) != 2 || !strings.Contains(deleted[0], "20240101T000000Z") || !strings.Contains(deleted[1], "20240101T010000Z") {
+		t.Errorf("wrong deleted backups %v", deleted)
+	}
+
+	backups, _ := List(dir)
+	if len(backups) != 3 {
+		t.Errorf("want 3 backups; got %v", backups)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "other.txt")); err != nil {
+		t.Errorf("rotate deleted other files")
+	}
+
+	scheduler := &Scheduler{Dir: dir, Interval: 24 * time.Hour}
+	if next := scheduler.nextBackup(); !next.Equal(start.Add(28 * time.Hour)) {
+		t.Errorf("want next backup %s; got %s", start.Add(28*time.Hour), next)
+	}
+}
+
+func TestSave(t *testing.T) {
+	_, paths := newTestDBs(t, "saved")
+	backupDir := filepath.Join(t.TempDir(), "backups")
+
+	boltDB, err := bbolt.Open(paths[0], 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backupPath, err := Save(backupDir, []File{{Name: "gopicam.db", Path: paths[0], View: BoltView(boltDB.View)}}, Options{Compress: true})
+	boltDB.Close()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasSuffix(backupPath, ".tar.gz") {
+		t.Errorf("wrong backup name %s", backupPath)
+	}
+
+	backup, err := os.Open(backupPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backup.Close()
+
+	setValue(t, paths[0], "changed")
+
+	err = Restore(backup, restoreFiles(paths[:1]), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value := getValue(t, paths[0]); value != "saved" {
+		t.Errorf("want saved value; got %q", value)
+	}
+}
@@ -0,0 +1,204 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// The encrypted backups start with the magic bytes, the version of the format
+// and the salt of the key. The key is derived from the passphrase with argon2id
+// and the data is split in blocks of 64 KiB encrypted with AES-256-GCM. The nonce
+// of a block is its number and a flag on the last block, so the blocks can't be
+// reordered and a truncated backup is detected
+var encryptedMagic = []byte("GPCBKENC")
+
+const (
+	encryptionVersion = 1
+	saltLength        = 16
+	blockSize         = 64 * 1024
+)
+
+var ErrPassphrase = errors.New("the passphrase is wrong or the backup is corrupted")
+
+func isEncrypted(r *bufio.Reader) bool {
+	magic, _ := r.Peek(len(encryptedMagic))
+
+	return bytes.Equal(magic, encryptedMagic)
+}
+
+func newCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key := argon2.IDKey([]byte(passphrase), salt, 2, 19*1024, 1, 32)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func blockNonce(counter uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce, counter)
+
+	if last {
+		nonce[11] = 1
+	}
+
+	return nonce
+}
+
+type encryptWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	block   []byte
+	counter uint64
+}
+
+func newEncryptWriter(w io.Writer, passphrase string) (*encryptWriter, error) {
+	salt := make([]byte, saltLength)
+
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	header := append(append(append([]byte{}, encryptedMagic...), encryptionVersion), salt...)
+
+	_, err = w.Write(header)
+	if err != nil {
+		return nil, err
+	}
+
+	return &encryptWriter{w: w, aead: aead, block: make([]byte, 0, blockSize)}, nil
+}
+
+// Write encrypts the full blocks, a full block is kept until more data is
+// written because the last block has a different nonce
+func (writer *encryptWriter) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		if len(writer.block) == blockSize {
+			err = writer.seal(false)
+			if err != nil {
+				return
+			}
+		}
+
+		copied := copy(writer.block[len(writer.block):blockSize], p)
+		writer.block = writer.block[:len(writer.block)+copied]
+
+		p = p[copied:]
+		n += copied
+	}
+
+	return
+}
+
+// Close encrypts the last block, it doesn't close the underlying writer
+func (writer *encryptWriter) Close() error {
+	return writer.seal(true)
+}
+
+func (writer *encryptWriter) seal(last bool) error {
+	sealed := writer.aead.Seal(nil, blockNonce(writer.counter, last), writer.block, nil)
+
+	_, err := writer.w.Write(sealed)
+	if err != nil {
+		return err
+	}
+
+	writer.counter++
+	writer.block = writer.block[:0]
+
+	return nil
+}
+
+type decryptReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	sealed  []byte
+	plain   []byte
+	counter uint64
+	done    bool
+}
+
+func newDecryptReader(r *bufio.Reader, passphrase string) (*decryptReader, error) {
+	header := make([]byte, len(encryptedMagic)+1+saltLength)
+
+	_, err := io.ReadFull(r, header)
+	if err != nil || !bytes.Equal(header[:len(encryptedMagic)], encryptedMagic) {
+		return nil, errors.New("the backup is not encrypted")
+	}
+
+	if header[len(encryptedMagic)] != encryptionVersion {
+		return nil, errors.New("unknown encryption version")
+	}
+
+	aead, err := newCipher(passphrase, header[len(encryptedMagic)+1:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptReader{r: r, aead: aead, sealed: make([]byte, blockSize+aead.Overhead())}, nil
+}
+
+func (reader *decryptReader) Read(p []byte) (int, error) {
+	for len(reader.plain) == 0 {
+		if reader.done {
+			return 0, io.EOF
+		}
+
+		err := reader.open()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, reader.plain)
+	reader.plain = reader.plain[n:]
+
+	return n, nil
+}
+
+// open decrypts the next block, the last block is shorter than a full block or
+// is followed by the end of the file
+func (reader *decryptReader) open() error {
+	n, err := io.ReadFull(reader.r, reader.sealed)
+
+	last := false
+
+	switch {
+	case err == io.EOF:
+		return ErrPassphrase
+	case err == io.ErrUnexpectedEOF:
+		last = true
+	case err != nil:
+		return err
+	default:
+		_, peekErr := reader.r.Peek(1)
+		last = peekErr == io.EOF
+	}
+
+	reader.plain, err = reader.aead.Open(reader.sealed[:0], blockNonce(reader.counter, last), reader.sealed[:n], nil)
+	if err != nil {
+		return ErrPassphrase
+	}
+
+	reader.counter++
+	reader.done = last
+
+	return nil
+}
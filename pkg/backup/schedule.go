@@ -0,0 +1,163 @@
+package backup
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const fileNamePrefix = "gopicam-backup-"
+const fileNameTimeFormat = "20060102T150405Z"
+
+// FileName returns the name of a backup created at the time, the extension
+// shows if it's compressed and encrypted
+func FileName(created time.Time, options Options) string {
+	name := fileNamePrefix + created.UTC().Format(fileNameTimeFormat) + ".tar"
+
+	if options.Compress {
+		name += ".gz"
+	}
+
+	if options.Passphrase != "" {
+		name += ".enc"
+	}
+
+	return name
+}
+
+// Save writes a backup to a new file in the folder, the file is renamed when the
+// backup is complete so the folder doesn't have partial backups
+func Save(dir string, files []File, options Options) (backupPath string, err error) {
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return
+	}
+
+	backupPath = filepath.Join(dir, FileName(time.Now(), options))
+
+	tempFile, err := os.CreateTemp(dir, ".backup-*")
+	if err != nil {
+		return
+	}
+
+	err = Write(tempFile, files, options)
+	if err == nil {
+		err = tempFile.Sync()
+	}
+
+	closeErr := tempFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(tempFile.Name(), backupPath)
+	}
+
+	if err != nil {
+		os.Remove(tempFile.Name())
+	}
+
+	return
+}
+
+// List returns the paths of the backups in the folder from the oldest to the newest
+func List(dir string) (backups []string, err error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasPrefix(entry.Name(), fileNamePrefix) && strings.Contains(entry.Name(), ".tar") {
+			backups = append(backups, filepath.Join(dir, entry.Name()))
+		}
+	}
+
+	// the names have the time of the backup, they sort by date
+	sort.Strings(backups)
+
+	return
+}
+
+// Rotate deletes the oldest backups of the folder and keeps the newest ones
+func Rotate(dir string, keep int) (deleted []string, err error) {
+	backups, err := List(dir)
+	if err != nil {
+		return
+	}
+
+	for len(backups) > keep {
+		err = os.Remove(backups[0])
+		if err != nil {
+			return
+		}
+
+		deleted = append(deleted, backups[0])
+		backups = backups[1:]
+	}
+
+	return
+}
+
+// Scheduler saves a backup in Dir every Interval and keeps the last Keep backups
+type Scheduler struct {
+	Dir      string
+	Interval time.Duration
+	Keep     int
+	Files    []File
+	Options  Options
+	LogInfo  *log.Logger
+	LogError *log.Logger
+}
+
+// Run saves the backups until the program exits, the first backup is saved one
+// interval after the newest backup of the folder. A zero interval disables the backups
+func (scheduler *Scheduler) Run() {
+	if scheduler.Interval <= 0 {
+		return
+	}
+
+	for {
+		time.Sleep(time.Until(scheduler.nextBackup()))
+
+		backupPath, err := Save(scheduler.Dir, scheduler.Files, scheduler.Options)
+		if err != nil {
+			scheduler.LogError.Println("Backup:", err)
+			time.Sleep(time.Minute)
+			continue
+		}
+
+		scheduler.LogInfo.Println("Backup: saved", backupPath)
+
+		deleted, err := Rotate(scheduler.Dir, scheduler.Keep)
+		if err != nil {
+			scheduler.LogError.Println("Backup:", err)
+		}
+
+		for _, deletedPath := range deleted {
+			scheduler.LogInfo.Println("Backup: deleted", deletedPath)
+		}
+	}
+}
+
+func (scheduler *Scheduler) nextBackup() time.Time {
+	backups, err := List(scheduler.Dir)
+	if err != nil || len(backups) == 0 {
+		return time.Now()
+	}
+
+	name := strings.TrimPrefix(filepath.Base(backups[len(backups)-1]), fileNamePrefix)
+
+	created, err := time.Parse(fileNameTimeFormat, strings.SplitN(name, ".", 2)[0])
+	if err != nil {
+		return time.Now()
+	}
+
+	return created.Add(scheduler.Interval)
+}
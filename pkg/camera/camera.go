@@ -8,10 +8,12 @@ import (
 	"io"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
 	"os/exec"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/jempe/gopicam/pkg/utils"
@@ -26,11 +28,30 @@ const RecordStop = "ca 0"
 const StatusDetectMotion = "md_ready"
 const StatusDetectMotionRecording = "md_video"
 
+// MotionSourceCamera is the motion detected by raspimjpeg, MotionSourceSound a
+// sound louder than the threshold of the audio trigger
+const MotionSourceCamera = "camera"
+const MotionSourceSound = "sound"
+
+// DefaultMotionStopDelay is the time without motion before the video is
+// stopped when MotionStopDelay is 0
+const DefaultMotionStopDelay = 10 * time.Second
+
+// webhookTimeout is the timeout of the requests to the motion webhook
+const webhookTimeout = 10 * time.Second
+
 type CamController struct {
 	ConfigFolder        string
 	LastMotionTimestamp time.Time
 	LogError            *log.Logger
 	LogInfo             *log.Logger
+	// MotionRecord starts a video when motion is detected
+	MotionRecord bool
+	// MotionStopDelay is the time without motion before the video is stopped
+	MotionStopDelay time.Duration
+	// MotionWebhook receives a POST request when motion is detected
+	MotionWebhook string
+	motionMutex   sync.Mutex
 }
 
 // Prepare everything to run raspimjpeg
@@ -121,11 +142,9 @@ func (camController *CamController) StartRaspiMJPEG() {
 		camController.LogError.Println(err)
 	}
 
-	for {
-		scanner := bufio.NewScanner(raspiMJPEGOutput)
-		for scanner.Scan() {
-			fmt.Println(scanner.Text()) // Println will add back the final '\n'
-		}
+	scanner := bufio.NewScanner(raspiMJPEGOutput)
+	for scanner.Scan() {
+		fmt.Println(scanner.Text()) // Println will add back the final '\n'
 	}
 
 	if err := cmd.Wait(); err != nil {
@@ -163,7 +182,9 @@ func (camController *CamController) ReadFIFO() {
 	fifoMessage, err := os.OpenFile(camController.ConfigFolder+"/fifos/FIFO1", os.O_RDONLY, 0600)
 	if err != nil {
 		camController.LogError.Println(err)
+		return
 	}
+	defer fifoMessage.Close()
 
 	camController.LogInfo.Println("Reading FIFO")
 	var fifoBuffer bytes.Buffer
@@ -188,25 +209,77 @@ func (camController *CamController) ReadFIFO() {
 			fmt.Println("FIFO Message:", fifoBuffer.String())
 			fifoBuffer.Reset()
 
-			camController.LastMotionTimestamp = time.Now()
+			camController.Motion(MotionSourceCamera, camController.MotionRecord)
+		} else if status == StatusDetectMotionRecording {
+			// stop recording video after the stop delay without motion
+			duration := time.Now().Sub(camController.lastMotion())
 
-			if status == StatusDetectMotion {
-				camController.LogInfo.Println("Motion Detected, Start Recording")
-				camController.SendCommand(RecordStart)
+			stopDelay := camController.MotionStopDelay
+			if stopDelay == 0 {
+				stopDelay = DefaultMotionStopDelay
 			}
-		} else if status == StatusDetectMotionRecording {
-			// stop recording video after 10 seconds
-			duration := time.Now().Sub(camController.LastMotionTimestamp)
 
-			if duration.Seconds() > 10 {
+			if duration > stopDelay {
 				camController.LogInfo.Println("Motion Detected, Stop Recording")
 				camController.SendCommand(RecordStop)
 			}
 		}
 		time.Sleep(100 * time.Millisecond)
 	}
+}
+
+// Motion saves the time of the motion, sends it to the motion webhook and
+// starts a video when record is set and the motion detection is waiting. The
+// video is stopped by ReadFIFO after the stop delay without motion
+func (camController *CamController) Motion(source string, record bool) {
+	camController.motionMutex.Lock()
+	camController.LastMotionTimestamp = time.Now()
+	motionTime := camController.LastMotionTimestamp
+	camController.motionMutex.Unlock()
+
+	if camController.MotionWebhook != "" {
+		go camController.notifyMotion(source, motionTime)
+	}
+
+	if !record {
+		return
+	}
+
+	status, err := camController.GetStatus()
+	if err != nil {
+		camController.LogError.Println(err)
+		return
+	}
+
+	if status == StatusDetectMotion {
+		camController.LogInfo.Println("Motion Detected, Start Recording")
+		camController.SendCommand(RecordStart)
+	}
+}
+
+func (camController *CamController) lastMotion() time.Time {
+	camController.motionMutex.Lock()
+	defer camController.motionMutex.Unlock()
+
+	return camController.LastMotionTimestamp
+}
+
+// notifyMotion sends the source and the time of the motion to the motion webhook
+func (camController *CamController) notifyMotion(source string, motionTime time.Time) {
+	body := fmt.Sprintf("{\"event\": \"motion\", \"source\": %q, \"time\": %q}", source, motionTime.UTC().Format(time.RFC3339))
+
+	client := &http.Client{Timeout: webhookTimeout}
+
+	response, err := client.Post(camController.MotionWebhook, "application/json", strings.NewReader(body))
+	if err != nil {
+		camController.LogError.Println("Motion webhook:", err)
+		return
+	}
+	response.Body.Close()
 
-	fifoMessage.Close()
+	if response.StatusCode >= 300 {
+		camController.LogError.Println("Motion webhook:", response.Status)
+	}
 }
 
 // Send Command to RaspiMJPEG
@@ -0,0 +1,420 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+)
+
+// The problems found by Check
+const (
+	// ProblemCorrupt is a record that can't be decoded, a record saved with a
+	// key different from its ID or a missing bucket
+	ProblemCorrupt = "corrupt"
+	// ProblemInvalid is a record that doesn't pass the validators of the entity
+	ProblemInvalid = "invalid"
+	// ProblemOrphan is a location or an alert of an unknown device, a session of
+	// an unknown user or an event of an unknown geofence
+	ProblemOrphan = "orphan"
+	// ProblemMissingFile is a photo, video or audio without file in the media folder
+	ProblemMissingFile = "missing_file"
+	// ProblemIndex is an index with entries of missing items or without entries
+	// of some items
+	ProblemIndex = "index"
+)
+
+type CheckIssue struct {
+	Bucket   string `json:"bucket"`
+	Key      string `json:"key,omitempty"`
+	Problem  string `json:"problem"`
+	Error    string `json:"error"`
+	Repaired bool   `json:"repaired"`
+}
+
+type CheckOptions struct {
+	// MediaFolder is the folder of the files of the photos, videos and audios,
+	// the files aren't checked when it's empty
+	MediaFolder string
+	// Repair deletes the corrupt records, the orphans and the media records
+	// without file, creates the missing buckets and rebuilds the wrong indexes.
+	// The invalid records are only reported, they have to be fixed by hand
+	Repair bool
+}
+
+type CheckReport struct {
+	// Records is the number of records checked in each bucket
+	Records map[string]int64 `json:"records"`
+	Issues  []CheckIssue     `json:"issues"`
+}
+
+// Unrepaired returns the number of issues that were not repaired
+func (report CheckReport) Unrepaired() (count int) {
+	for _, issue := range report.Issues {
+		if !issue.Repaired {
+			count++
+		}
+	}
+
+	return
+}
+
+// mediaFile is a record with a file in the media folder
+type mediaFile interface {
+	FileName() string
+}
+
+type checker struct {
+	tx      Tx
+	options CheckOptions
+	report  *CheckReport
+}
+
+func (c *checker) add(issue CheckIssue) {
+	c.report.Issues = append(c.report.Issues, issue)
+}
+
+// Check walks all the buckets in one transaction, it decodes every record,
+// validates it with the validators of its entity and looks for orphans, media
+// records without file and wrong index entries. The DB file pages are checked
+// by CheckFile. With options.Repair the problems that can be repaired are
+// fixed in the same transaction
+func (boltdb *DB) Check(options CheckOptions) (report CheckReport, err error) {
+	report.Records = make(map[string]int64)
+
+	run := boltdb.Store.View
+	if options.Repair {
+		run = boltdb.Store.Update
+	}
+
+	err = run(func(tx Tx) error {
+		c := &checker{tx: tx, options: options, report: &report}
+
+		usernames := make(map[string]bool)
+
+		err := boltdb.Users().check(c, func(user User) (string, string, bool) {
+			usernames[user.Username] = true
+
+			if err := user.validate(); err != nil {
+				return ProblemInvalid, err.Error(), false
+			}
+
+			return "", "", false
+		})
+		if err != nil {
+			return err
+		}
+
+		err = boltdb.Sessions().check(c, func(session Session) (string, string, bool) {
+			if !usernames[session.Username] {
+				return ProblemOrphan, "session of the unknown user " + session.Username, true
+			}
+
+			return "", "", false
+		})
+		if err != nil {
+			return err
+		}
+
+		// the locations can have the ID or the key of their device
+		devices := make(map[string]bool)
+
+		err = boltdb.Devices().check(c, func(device Device) (string, string, bool) {
+			devices[device.ID] = true
+
+			if device.Key != "" {
+				devices[device.Key] = true
+			}
+
+			return "", "", false
+		})
+		if err != nil {
+			return err
+		}
+
+		err = boltdb.Locations().check(c, func(location Location) (string, string, bool) {
+			if !devices[location.Device] {
+				return ProblemOrphan, "location of the unknown device " + location.Device, true
+			}
+
+			return "", "", false
+		})
+		if err != nil {
+			return err
+		}
+
+		err = boltdb.Alerts().check(c, func(alert Alert) (string, string, bool) {
+			if !devices[alert.Device] {
+				return ProblemOrphan, "alert of the unknown device " + alert.Device, true
+			}
+
+			return "", "", false
+		})
+		if err != nil {
+			return err
+		}
+
+		err = boltdb.Photos().check(c, checkFile[Photo](c))
+		if err == nil {
+			err = boltdb.Videos().check(c, checkFile[Video](c))
+		}
+		if err == nil {
+			err = boltdb.Audios().check(c, checkFile[Audio](c))
+		}
+		if err == nil {
+			err = boltdb.Requests().check(c, nil)
+		}
+		if err == nil {
+			err = boltdb.Audits().check(c, nil)
+		}
+		if err == nil {
+			err = checkGeofences(boltdb, c)
+		}
+		if err == nil {
+			checkSettings(c)
+		}
+
+		return err
+	})
+
+	return
+}
+
+// checkGeofences reports the geofences that don't pass their validation and
+// the events of unknown geofences
+func checkGeofences(boltdb *DB, c *checker) error {
+	geofences := make(map[string]bool)
+
+	err := boltdb.Geofences().check(c, func(geofence Geofence) (string, string, bool) {
+		geofences[geofence.ID] = true
+
+		if err := geofence.Check(); err != nil {
+			return ProblemInvalid, err.Error(), false
+		}
+
+		return "", "", false
+	})
+	if err != nil {
+		return err
+	}
+
+	return boltdb.GeofenceEvents().check(c, func(event GeofenceEvent) (string, string, bool) {
+		if !geofences[event.Geofence] {
+			return ProblemOrphan, "event of the unknown geofence " + event.Geofence, true
+		}
+
+		return "", "", false
+	})
+}
+
+// checkSettings reports saved settings that can't be decoded or don't pass the
+// validation, they are fixed with a rollback or a change of the settings
+func checkSettings(c *checker) {
+	if c.tx.Bucket([]byte("configuration")) == nil {
+		return
+	}
+
+	settings, err := readSettings(c.tx)
+	if err != nil {
+		c.add(CheckIssue{Bucket: "configuration", Key: settingsKey, Problem: ProblemCorrupt, Error: err.Error()})
+	} else if err = settings.Validate(); err != nil {
+		c.add(CheckIssue{Bucket: "configuration", Key: settingsKey, Problem: ProblemInvalid, Error: err.Error()})
+	}
+}
+
+// checkFile returns the inspect function of the media records, it reports the
+// records without file in the media folder
+func checkFile[T mediaFile](c *checker) func(item T) (string, string, bool) {
+	return func(item T) (string, string, bool) {
+		if c.options.MediaFolder == "" {
+			return "", "", false
+		}
+
+		_, err := os.Stat(filepath.Join(c.options.MediaFolder, item.FileName()))
+		if errors.Is(err, os.ErrNotExist) {
+			return ProblemMissingFile, "the file " + item.FileName() + " doesn't exist", true
+		} else if err != nil {
+			return ProblemMissingFile, err.Error(), false
+		}
+
+		return "", "", false
+	}
+}
+
+// checkBucket decodes every record of the bucket and reports the records that
+// can't be decoded, the records saved with a key different from their ID and
+// the problems found by inspect, which also tells if the problem can be
+// repaired. The records that can be repaired are deleted when repairing, remove
+// deletes the data of the decoded records in other buckets. It returns the
+// number of records that are left in the bucket with a valid key
+func checkBucket[T any](c *checker, bucket string, id func(item T) string, inspect func(item T) (problem string, message string, repairable bool), remove func(item T) error) (items int64, err error) {
+	b := c.tx.Bucket([]byte(bucket))
+	if b == nil {
+		issue := CheckIssue{Bucket: bucket, Problem: ProblemCorrupt, Error: "the bucket doesn't exist"}
+
+		if c.options.Repair {
+			_, err = c.tx.CreateBucket([]byte(bucket))
+			if err != nil {
+				return
+			}
+
+			issue.Repaired = true
+		}
+
+		c.add(issue)
+
+		return
+	}
+
+	c.report.Records[bucket] = 0
+
+	var deleteKeys [][]byte
+	var deleteItems []T
+
+	cursor := b.Cursor()
+
+	for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+		c.report.Records[bucket]++
+
+		issue := CheckIssue{Bucket: bucket, Key: string(k)}
+		repairable := true
+
+		var item T
+
+		decodeErr := json.Unmarshal(v, &item)
+
+		if decodeErr != nil {
+			issue.Problem, issue.Error = ProblemCorrupt, decodeErr.Error()
+		} else if id(item) != string(k) {
+			issue.Problem, issue.Error = ProblemCorrupt, "the record has the ID "+id(item)
+		} else {
+			issue.Problem, issue.Error, repairable = inspect(item)
+		}
+
+		if issue.Problem == "" {
+			items++
+			continue
+		}
+
+		if c.options.Repair && repairable {
+			deleteKeys = append(deleteKeys, append([]byte{}, k...))
+			issue.Repaired = true
+
+			if decodeErr == nil {
+				deleteItems = append(deleteItems, item)
+			}
+		} else if issue.Problem != ProblemCorrupt {
+			// the records with problems that are kept can be indexed
+			items++
+		}
+
+		c.add(issue)
+	}
+
+	for _, item := range deleteItems {
+		if remove != nil {
+			err = remove(item)
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	for _, k := range deleteKeys {
+		err = b.Delete(k)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// check checks the records and the indexes of the repository, inspect finds
+// the problems that depend on the entity
+func (repo *Repository[T]) check(c *checker, inspect func(item T) (problem string, message string, repairable bool)) error {
+	items, err := checkBucket(c, repo.entity.bucket, func(item T) string {
+		return reflect.ValueOf(item).Field(repo.entity.key.index).String()
+	}, func(item T) (string, string, bool) {
+		if err := repo.Validate(item, []string{}); err != nil {
+			return ProblemInvalid, err.Error(), false
+		}
+
+		if inspect != nil {
+			return inspect(item)
+		}
+
+		return "", "", false
+	}, func(item T) error {
+		return repo.deleteIndexes(c.tx, reflect.ValueOf(item))
+	})
+	if err != nil {
+		return err
+	}
+
+	if c.tx.Bucket([]byte(repo.entity.bucket)) == nil {
+		return nil
+	}
+
+	return repo.checkIndexes(c, items)
+}
+
+// checkIndexes compares the entries of the indexes with the items of the
+// bucket. The stale entries point to missing items or have a different value
+// than the item, the missing entries are the items without entry. The wrong
+// indexes are rebuilt when repairing
+func (repo *Repository[T]) checkIndexes(c *checker, items int64) error {
+	data := c.tx.Bucket([]byte(repo.entity.bucket))
+
+	for _, field := range repo.entity.indexes {
+		indexName := repo.entity.indexBucket(field)
+
+		var valid, stale int64
+
+		index := c.tx.Bucket([]byte(indexName))
+		if index != nil {
+			cursor := index.Cursor()
+
+			for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+				if itemJSON := data.Get(v); itemJSON != nil {
+					item, err := repo.decode(v, itemJSON)
+					if err == nil && bytes.Equal(repo.entity.indexKey(reflect.ValueOf(item), field), k) {
+						valid++
+						continue
+					}
+				}
+
+				stale++
+			}
+		}
+
+		if stale == 0 && valid == items {
+			continue
+		}
+
+		issue := CheckIssue{Bucket: indexName, Problem: ProblemIndex, Error: fmt.Sprintf("%d stale entries and %d missing entries", stale, items-valid)}
+
+		if c.options.Repair {
+			if index != nil {
+				err := c.tx.DeleteBucket([]byte(indexName))
+				if err != nil {
+					return err
+				}
+			}
+
+			_, err := repo.openIndex(c.tx, field)
+			if err != nil {
+				return err
+			}
+
+			issue.Repaired = true
+		}
+
+		c.add(issue)
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package db
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	database, teardown := newMemoryTestDB(t)
+	defer teardown()
+
+	err := database.InitDb()
+	if err != nil {
+		t.Fatalf("error creating database")
+	}
+
+	mediaFolder := t.TempDir()
+
+	_, err = database.InsertDevice(Device{Key: "phone", Name: "Phone"}, []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, device := range []string{"phone", "phone", "ghost"} {
+		_, err := database.InsertLocation(Location{Device: device, DeviceTime: 1700000000}, []string{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	savedPhotoID, _ := database.InsertPhoto(Photo{FileType: "jpg"}, []string{})
+	missingPhotoID, _ := database.InsertPhoto(Photo{FileType: "jpg"}, []string{})
+
+	os.WriteFile(filepath.Join(mediaFolder, savedPhotoID+".jpg"), []byte("photo"), 0600)
+
+	_, err = database.InsertSession(Session{Username: "nobody", IP: "127.0.0.1", UserAgent: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invalidVideo, _ := json.Marshal(Video{ID: "6ba7b810-9dad-11d1-80b4-00c04fd430c8", FileType: strings.Repeat("a", 101)})
+
+	// save records that can't be saved by the repositories and break the indexes
+	err = database.Store.Update(func(tx Tx) error {
+		tx.Bucket([]byte("videos")).Put([]byte("6ba7b810-9dad-11d1-80b4-00c04fd430c8"), invalidVideo)
+		tx.Bucket([]byte("audios")).Put([]byte("6ba7b811-9dad-11d1-80b4-00c04fd430c8"), []byte("{not json"))
+
+		index := tx.Bucket([]byte("locations_by_Device"))
+		k, _ := index.Cursor().Last()
+
+		return index.Delete(k)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantIssues := map[string]string{
+		"sessions":            ProblemOrphan,
+		"locations":           ProblemOrphan,
+		"locations_by_Device": ProblemIndex,
+		"photos":              ProblemMissingFile,
+		"videos":              ProblemInvalid,
+		// the video was saved without index entries
+		"videos_by_DeviceTime": ProblemIndex,
+		"videos_by_Geotag":     ProblemIndex,
+		"videos_by_Audio":      ProblemIndex,
+		"videos_by_Created":    ProblemIndex,
+		"audios":               ProblemCorrupt,
+	}
+
+	checkIssues := func(t *testing.T, report CheckReport, want map[string]string, repaired bool) {
+		t.Helper()
+
+		if len(report.Issues) != len(want) {
+			t.Errorf("want %d issues; got %+v", len(want), report.Issues)
+		}
+
+		for _, issue := range report.Issues {
+			if want[issue.Bucket] != issue.Problem {
+				t.Errorf("unexpected issue %+v", issue)
+			}
+
+			if issue.Repaired != (repaired && issue.Problem != ProblemInvalid) {
+				t.Errorf("wrong repaired %+v", issue)
+			}
+		}
+	}
+
+	t.Run("Corrupt Record Error", func(t *testing.T) {
+		_, _, err := database.GetAudioList(0, 10, Filters{Operator: "AND"}, []string{}, SortBy{Field: "ID", Direction: "ASC"})
+		if err == nil || !strings.Contains(err.Error(), "6ba7b811-9dad-11d1-80b4-00c04fd430c8") {
+			t.Errorf("want decode error with the key; got %v", err)
+		}
+	})
+
+	t.Run("Check", func(t *testing.T) {
+		report, err := database.Check(CheckOptions{MediaFolder: mediaFolder})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkIssues(t, report, wantIssues, false)
+
+		if report.Records["locations"] != 3 || report.Records["photos"] != 2 {
+			t.Errorf("wrong record counts %v", report.Records)
+		}
+
+		if report.Unrepaired() != len(wantIssues) {
+			t.Errorf("want %d unrepaired issues; got %d", len(wantIssues), report.Unrepaired())
+		}
+	})
+
+	t.Run("Repair", func(t *testing.T) {
+		report, err := database.Check(CheckOptions{MediaFolder: mediaFolder, Repair: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkIssues(t, report, wantIssues, true)
+
+		report, err = database.Check(CheckOptions{MediaFolder: mediaFolder})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkIssues(t, report, map[string]string{"videos": ProblemInvalid}, false)
+
+		if _, err := database.GetPhoto(missingPhotoID); err == nil {
+			t.Errorf("the photo without file was not deleted")
+		}
+
+		locations, total, err := database.GetLocationList(0, 10, Filters{Operator: "AND", Conditions: []Condition{{Field: "Device", Comparison: "=", Value: "phone"}}}, []string{}, SortBy{Field: "Device", Direction: "ASC"})
+		if err != nil || total != 2 || len(locations) != 2 {
+			t.Errorf("want 2 locations after rebuilding the index; got %d %v", total, err)
+		}
+	})
+}
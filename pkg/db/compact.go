@@ -0,0 +1,74 @@
+package db
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// compactTxSize is the size of the writes of each transaction of the compaction
+const compactTxSize = 64 << 20
+
+// CompactFile copies the items of a bbolt file to a new file without the pages
+// that were freed by the deletes, bbolt reuses them but the file never
+// shrinks. The file is locked during the compaction and the copy replaces it
+// when it's complete, so a power cut leaves the old file. It returns the sizes
+// of the file before and after the compaction
+func CompactFile(path string, timeout time.Duration) (before int64, after int64, err error) {
+	src, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: timeout})
+	if errors.Is(err, bbolt.ErrTimeout) {
+		return 0, 0, ErrLocked
+	} else if err != nil {
+		return
+	}
+	defer src.Close()
+
+	compactPath := path + ".compact"
+
+	// the copy left by a compaction that was interrupted
+	os.Remove(compactPath)
+
+	dst, err := bbolt.Open(compactPath, 0600, nil)
+	if err != nil {
+		return
+	}
+
+	err = bbolt.Compact(dst, src, compactTxSize)
+
+	closeErr := dst.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(compactPath)
+		return
+	}
+
+	before, err = fileSize(path)
+	if err == nil {
+		after, err = fileSize(compactPath)
+	}
+
+	if err == nil {
+		// the lock of src is kept until the new file is in place
+		err = os.Rename(compactPath, path)
+	}
+
+	if err != nil {
+		os.Remove(compactPath)
+	}
+
+	return
+}
+
+func fileSize(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+
+	return info.Size(), nil
+}
@@ -0,0 +1,72 @@
+package db
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCompactFile(t *testing.T) {
+	database, teardown := newTestDB(t)
+	defer teardown()
+
+	err := database.InitDb()
+	if err != nil {
+		t.Fatalf("error creating database")
+	}
+
+	// insert the locations in one transaction, it's faster than Insert
+	err = database.Store.Update(func(tx Tx) error {
+		repo := database.Locations()
+
+		for i := 0; i < 2000; i++ {
+			err := repo.insert(tx, reflect.ValueOf(Location{ID: uuid.New().String(), Device: "phone", DeviceIndex: i, Wifi: "home network"}))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := database.Locations().DeleteWhere(Filters{Operator: "AND", Conditions: []Condition{{Field: "DeviceIndex", Comparison: ">=", Value: 10}}})
+	if err != nil || deleted != 1990 {
+		t.Fatalf("want 1990 deleted locations; got %d %v", deleted, err)
+	}
+
+	t.Run("Locked", func(t *testing.T) {
+		_, _, err := CompactFile(database.Path, 100*time.Millisecond)
+		if !errors.Is(err, ErrLocked) {
+			t.Errorf("want ErrLocked; got %v", err)
+		}
+	})
+
+	database.Close()
+
+	before, after, err := CompactFile(database.Path, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if after >= before {
+		t.Errorf("want a smaller file; got %d bytes before and %d after", before, after)
+	}
+
+	database.Store = nil
+
+	err = database.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, total, err := database.GetLocationList(0, 20, Filters{Operator: "AND", Conditions: []Condition{{Field: "Device", Comparison: "=", Value: "phone"}}}, []string{}, SortBy{Field: "Device", Direction: "ASC"})
+	if err != nil || total != 10 {
+		t.Errorf("want 10 locations after the compaction; got %d %v", total, err)
+	}
+}
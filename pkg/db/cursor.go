@@ -0,0 +1,74 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// listCursor is the position after the last item of a page, the key is the
+// index key of the item: the encoded value of the sort field and the ID. The
+// token is opaque to the clients, it's JSON encoded in base64
+type listCursor struct {
+	Field     string `json:"f"`
+	Direction string `json:"d"`
+	Key       []byte `json:"k"`
+}
+
+func encodeCursor(field string, direction string, key []byte) string {
+	if key == nil {
+		return ""
+	}
+
+	cursorJSON, _ := json.Marshal(listCursor{Field: field, Direction: direction, Key: key})
+
+	return base64.RawURLEncoding.EncodeToString(cursorJSON)
+}
+
+func decodeCursor(token string) (cursor listCursor, err error) {
+	cursorJSON, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(cursorJSON, &cursor)
+	if err == nil && len(cursor.Key) == 0 {
+		err = errors.New("empty cursor")
+	}
+
+	return
+}
+
+// ListPage returns the page of items that follows the cursor, an empty cursor
+// returns the first page. The next cursor is empty on the last page. The pages
+// don't change when items are added or removed before the cursor and the total
+// results aren't counted, the cursor only works with the same sort it was created with
+func (repo *Repository[T]) ListPage(cursor string, limit int, filters Filters, returnFields []string, sortBy SortBy) (results []T, nextCursor string, err error) {
+	query, err := repo.prepareList(filters, returnFields, sortBy)
+	if err != nil {
+		return
+	}
+
+	query.limit = limit
+	query.paged = true
+
+	if cursor != "" {
+		listCursor, cursorErr := decodeCursor(cursor)
+
+		if cursorErr != nil || listCursor.Field != query.sortField.name || listCursor.Direction != sortBy.Direction {
+			err = errors.New("get_" + repo.entity.item + "_error: cursor error")
+			return
+		}
+
+		query.after = listCursor.Key
+	}
+
+	results, _, next, err := repo.runList(query)
+	if err != nil {
+		return
+	}
+
+	nextCursor = encodeCursor(query.sortField.name, sortBy.Direction, next)
+
+	return
+}
@@ -0,0 +1,129 @@
+package db
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestListPage(t *testing.T) {
+	database, teardown := newMemoryTestDB(t)
+	defer teardown()
+
+	err := database.InitDb()
+	if err != nil {
+		t.Fatalf("error creating database")
+	}
+
+	err = database.createBucket("motion_events")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := NewRepository[motionEvent](database)
+
+	random := rand.New(rand.NewSource(2))
+	cameras := []string{"garden", "garage", "front door"}
+
+	for i := 0; i < 60; i++ {
+		_, err := events.Insert(motionEvent{Camera: cameras[random.Intn(len(cameras))], Level: random.Intn(10), Recorded: random.Intn(2) == 0}, []string{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	filters := []Filters{
+		{Operator: "AND"},
+		{Operator: "AND", Conditions: []Condition{{Field: "Level", Comparison: ">", Value: 3}}},
+		{Operator: "AND", Conditions: []Condition{{Field: "Recorded", Comparison: "=", Value: true}}},
+		{Operator: "OR", Conditions: []Condition{{Field: "Level", Comparison: "=", Value: 1}, {Field: "Camera", Comparison: "=", Value: "garage"}}},
+	}
+
+	for filterIndex, filter := range filters {
+		for _, sortField := range []string{"", "ID", "Level", "Camera", "Created"} {
+			for _, direction := range []string{"ASC", "DESC"} {
+				sortBy := SortBy{Field: sortField, Direction: direction}
+
+				t.Run(fmt.Sprintf("Filter %d %s %s", filterIndex, sortField, direction), func(t *testing.T) {
+					want, _, err := events.List(0, 1000, filter, []string{}, sortBy)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					var got []motionEvent
+
+					cursor := ""
+
+					for pages := 0; pages < 100; pages++ {
+						page, nextCursor, err := events.ListPage(cursor, 7, filter, []string{"Level"}, sortBy)
+						if err != nil {
+							t.Fatal(err)
+						}
+
+						for _, event := range page {
+							got = append(got, motionEvent{ID: event.ID, Level: event.Level})
+						}
+
+						if nextCursor == "" {
+							break
+						}
+
+						cursor = nextCursor
+					}
+
+					if len(got) != len(want) {
+						t.Fatalf("want %d results; got %d", len(want), len(got))
+					}
+
+					for i := range want {
+						if got[i].ID != want[i].ID || got[i].Level != want[i].Level {
+							t.Fatalf("result %d: want %s; got %s", i, want[i].ID, got[i].ID)
+						}
+					}
+				})
+			}
+		}
+	}
+
+	t.Run("New Items While Paging", func(t *testing.T) {
+		sortBy := SortBy{Field: "Created", Direction: "DESC"}
+
+		firstPage, cursor, err := events.ListPage("", 10, Filters{Operator: "AND"}, []string{}, sortBy)
+		if err != nil || cursor == "" {
+			t.Fatalf("first page error: %v", err)
+		}
+
+		_, err = events.Insert(motionEvent{Camera: "garden", Level: 5}, []string{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		secondPage, _, err := events.ListPage(cursor, 10, Filters{Operator: "AND"}, []string{}, sortBy)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		allItems, _, _ := events.List(0, 21, Filters{Operator: "AND"}, []string{}, sortBy)
+
+		if !reflect.DeepEqual(append(firstPage, secondPage...), allItems[1:]) {
+			t.Errorf("second page changed after adding a new item")
+		}
+	})
+
+	t.Run("Invalid Cursor", func(t *testing.T) {
+		_, cursor, _ := events.ListPage("", 5, Filters{Operator: "AND"}, []string{}, SortBy{Field: "Level", Direction: "ASC"})
+
+		for _, invalidCursor := range []string{"not a cursor", "e30"} {
+			_, _, err := events.ListPage(invalidCursor, 5, Filters{Operator: "AND"}, []string{}, SortBy{Field: "Level", Direction: "ASC"})
+			if err == nil {
+				t.Errorf("%q: want error; got nil", invalidCursor)
+			}
+		}
+
+		_, _, err := events.ListPage(cursor, 5, Filters{Operator: "AND"}, []string{}, SortBy{Field: "Level", Direction: "DESC"})
+		if err == nil {
+			t.Errorf("cursor of another sort: want error; got nil")
+		}
+	})
+}
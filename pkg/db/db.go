@@ -4,22 +4,27 @@ import (
 	"encoding/binary"
 	"fmt"
 	"log"
-	"strconv"
-
-	"github.com/boltdb/bolt"
+	"time"
 )
 
-const DB_VERSION = 1
+// DB_VERSION is the version of the last migration
+const DB_VERSION = 11
 const logTag = "BoltDB:"
 
 type DB struct {
-	Db   *bolt.DB
-	Path string
+	// Store is opened by Open, the tests can set a memory store before
+	Store Store
+	Path  string
+	// Timeout is the time that Open waits for the lock of the DB file,
+	// DefaultTimeout if it's 0
+	Timeout time.Duration
 }
 
+// Filters combines the conditions and the nested groups with the AND or OR operator
 type Filters struct {
 	Operator   string
 	Conditions []Condition
+	Groups     []Filters
 }
 
 type Condition struct {
@@ -33,53 +38,44 @@ type SortBy struct {
 	Direction string
 }
 
-func (boltdb *DB) InitDb() error {
+// Open opens the DB file without migrating it, InitDb opens and migrates it
+func (boltdb *DB) Open() error {
 	var err error
 	dbPath := boltdb.Path
 
-	boltdb.Db, err = bolt.Open(dbPath, 0600, nil)
-	if err != nil {
-		log.Println(logTag, "error creating DB")
-		log.Println(err)
-		return err
+	timeout := boltdb.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
 	}
 
-	if boltdb.Db != nil {
-		buckets := []string{"devices", "locations", "photos", "videos", "audios", "requests", "configuration"}
-
-		for _, bucket := range buckets {
-			err = boltdb.createBucket(bucket)
-			if err != nil {
-				log.Println(logTag, "error creating", bucket, "bucket")
-				log.Println(err)
-				return err
-			}
-		}
-	}
-
-	var version int
-
-	migrationsValue := boltdb.GetConfigValue("migrations")
-
-	if migrationsValue != nil {
-		version, err = strconv.Atoi(string(migrationsValue))
-
+	if boltdb.Store == nil {
+		boltdb.Store, err = OpenBoltStore(dbPath, timeout)
 		if err != nil {
-			log.Println(logTag, "error reading current migration")
+			log.Println(logTag, "error creating DB")
 			log.Println(err)
 			return err
-
 		}
 	}
 
-	if DB_VERSION != version {
-		err = boltdb.SetConfigValue("migrations", []byte(strconv.Itoa(DB_VERSION)))
+	err = boltdb.createBucket("configuration")
+	if err != nil {
+		log.Println(logTag, "error creating configuration bucket")
+		log.Println(err)
+	}
+
+	return err
+}
 
-		if err != nil {
-			log.Println(logTag, "error setting current migration")
-			log.Println(err)
-			return err
-		}
+func (boltdb *DB) InitDb() error {
+	err := boltdb.Open()
+	if err != nil {
+		return err
+	}
+
+	_, err = boltdb.Migrate()
+	if err != nil {
+		log.Println(logTag, "error migrating DB")
+		log.Println(err)
 	}
 
 	return err
@@ -88,7 +84,7 @@ func (boltdb *DB) InitDb() error {
 // createBucket creates a new bucket in the boltdb Database only if doesn't exist
 //
 func (boltdb *DB) createBucket(bucketName string) error {
-	err := boltdb.Db.Update(func(tx *bolt.Tx) error {
+	err := boltdb.Store.Update(func(tx Tx) error {
 		_, err := tx.CreateBucketIfNotExists([]byte(bucketName))
 		if err != nil {
 			return fmt.Errorf("create bucket: %s", err)
@@ -102,7 +98,7 @@ func (boltdb *DB) createBucket(bucketName string) error {
 func (boltdb *DB) GetConfigValue(variable string) []byte {
 	var value []byte
 
-	_ = boltdb.Db.View(func(tx *bolt.Tx) error {
+	_ = boltdb.Store.View(func(tx Tx) error {
 		b := tx.Bucket([]byte("configuration"))
 		v := b.Get([]byte(variable))
 
@@ -115,7 +111,7 @@ func (boltdb *DB) GetConfigValue(variable string) []byte {
 }
 
 func (boltdb *DB) SetConfigValue(variable string, value []byte) error {
-	err := boltdb.Db.Update(func(tx *bolt.Tx) error {
+	err := boltdb.Store.Update(func(tx Tx) error {
 		b := tx.Bucket([]byte("configuration"))
 		err := b.Put([]byte(variable), value)
 		return err
@@ -171,9 +167,9 @@ func btoi(v []byte) int {
 }
 
 func (boltdb *DB) Close() {
-	boltdb.Db.Close()
+	boltdb.Store.Close()
 }
 
 func (boltdb *DB) DBPath() string {
-	return boltdb.Db.Path()
+	return boltdb.Store.Path()
 }
@@ -0,0 +1,119 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// The types of the alerts of the devices
+const (
+	AlertLowBattery   = "low_battery"
+	AlertNotReporting = "not_reporting"
+	AlertReporting    = "reporting"
+	AlertWifiJoin     = "wifi_join"
+	AlertWifiLeave    = "wifi_leave"
+)
+
+// Alert is raised when the battery of a device is low, when a device stops
+// reporting or reports again and when it joins or leaves the home Wi-Fi.
+// Location is the location that raised the alert, empty for the devices that
+// are not reporting
+type Alert struct {
+	ID       string    `json:"id"       db:"key,bucket=alerts,sort"`
+	Device   string    `json:"device"   db:"index"`
+	Type     string    `json:"type"     db:"maxlength=20,index"`
+	Message  string    `json:"message"  db:"maxlength=2083,sort"`
+	Location string    `json:"location" db:"sort"`
+	Battery  int       `json:"battery"  db:"sort"`
+	Wifi     string    `json:"wifi"     db:"maxlength=2083,sort"`
+	Created  time.Time `json:"created"  db:"created,index"`
+}
+
+// DeviceState is the last known state of a device, LastSeen is the time the
+// last location was received. LowBattery and NotReporting are set while the
+// alerts are active so they are raised once
+type DeviceState struct {
+	Device       string    `json:"device"`
+	LastSeen     time.Time `json:"last_seen"`
+	Location     string    `json:"location"`
+	DeviceTime   int64     `json:"device_time"`
+	Battery      int       `json:"battery"`
+	Wifi         string    `json:"wifi"`
+	LowBattery   bool      `json:"low_battery"`
+	NotReporting bool      `json:"not_reporting"`
+}
+
+func (boltdb *DB) Alerts() *Repository[Alert] {
+	return NewRepository[Alert](boltdb)
+}
+
+func (boltdb *DB) InsertAlert(alert Alert, fields []string) (string, error) {
+	return boltdb.Alerts().Insert(alert, fields)
+}
+
+func (boltdb *DB) GetAlertList(offset int, limit int, filters Filters, returnFields []string, sortBy SortBy) ([]Alert, int64, error) {
+	return boltdb.Alerts().List(offset, limit, filters, returnFields, sortBy)
+}
+
+func (boltdb *DB) GetAlertPage(cursor string, limit int, filters Filters, returnFields []string, sortBy SortBy) ([]Alert, string, error) {
+	return boltdb.Alerts().ListPage(cursor, limit, filters, returnFields, sortBy)
+}
+
+// GetDeviceState returns the state of the device, ok is false when the device
+// didn't send locations yet
+func (boltdb *DB) GetDeviceState(deviceID string) (state DeviceState, ok bool, err error) {
+	err = boltdb.Store.View(func(tx Tx) error {
+		v := tx.Bucket([]byte("device_states")).Get([]byte(deviceID))
+		if v == nil {
+			return nil
+		}
+
+		ok = true
+
+		return decodeDeviceState(deviceID, v, &state)
+	})
+
+	return
+}
+
+// GetDeviceStates returns the states of all the devices that sent locations
+func (boltdb *DB) GetDeviceStates() (states []DeviceState, err error) {
+	err = boltdb.Store.View(func(tx Tx) error {
+		return tx.Bucket([]byte("device_states")).ForEach(func(k, v []byte) error {
+			var state DeviceState
+
+			err := decodeDeviceState(string(k), v, &state)
+			if err != nil {
+				return err
+			}
+
+			states = append(states, state)
+
+			return nil
+		})
+	})
+
+	return
+}
+
+func decodeDeviceState(deviceID string, v []byte, state *DeviceState) error {
+	err := json.Unmarshal(v, state)
+	if err != nil {
+		return errors.New("corrupt_device_state_error: device state " + deviceID + " can't be decoded: " + err.Error())
+	}
+
+	return nil
+}
+
+// SaveDeviceState saves the state of the device
+func (boltdb *DB) SaveDeviceState(state DeviceState) error {
+	stateJSON, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	return boltdb.Store.Update(func(tx Tx) error {
+		return tx.Bucket([]byte("device_states")).Put([]byte(state.Device), stateJSON)
+	})
+}
@@ -240,13 +240,13 @@ func TestAudioDb(t *testing.T) {
 			}
 
 			filters := Filters{
-				Operator: "AND",
-				Conditions{Condition{}},
+				Operator:   "AND",
+				Conditions: []Condition{},
 			}
 
 			allItems, totalItems, err := database.GetAudioList(0, len(insertedAudioListIDs), filters, []string{}, SortBy{Field: "ID", Direction: "ASC"})
 
-			if err != nil || totalItems != len(insertedAudioListIDs) || len(allItems) != len(insertedAudioListIDs) {
+			if err != nil || totalItems != int64(len(insertedAudioListIDs)) || len(allItems) != len(insertedAudioListIDs) {
 				t.Errorf("Error getting Audio List")
 			} else {
 
@@ -0,0 +1,50 @@
+package db
+
+import (
+	"time"
+)
+
+type Audit struct {
+	ID         string    `json:"id"          db:"key,bucket=audit,sort"`
+	User       string    `json:"user"        db:"maxlength=100,sort"`
+	AuthMethod string    `json:"auth_method" db:"maxlength=100,sort"`
+	IP         string    `json:"ip"          db:"maxlength=200,sort"`
+	Method     string    `json:"method"      db:"maxlength=20,sort"`
+	Endpoint   string    `json:"endpoint"    db:"maxlength=2083,sort"`
+	Command    string    `json:"command"     db:"maxlength=200,sort"`
+	Result     string    `json:"result"      db:"maxlength=2083,sort"`
+	Created    time.Time `json:"created"     db:"created,index"`
+}
+
+func (boltdb *DB) Audits() *Repository[Audit] {
+	return NewRepository[Audit](boltdb)
+}
+
+func (boltdb *DB) GetAudit(auditID string) (Audit, error) {
+	return boltdb.Audits().Get(auditID)
+}
+
+func (boltdb *DB) InsertAudit(audit Audit, fields []string) (string, error) {
+	return boltdb.Audits().Insert(audit, fields)
+}
+
+func (boltdb *DB) UpdateAudit(audit Audit, fields []string) (int64, error) {
+	return boltdb.Audits().Update(audit, fields)
+}
+
+func (boltdb *DB) DeleteAudit(auditID string) (int64, error) {
+	return boltdb.Audits().Delete(auditID)
+}
+
+func (boltdb *DB) GetAuditList(offset int, limit int, filters Filters, returnFields []string, sortBy SortBy) ([]Audit, int64, error) {
+	return boltdb.Audits().List(offset, limit, filters, returnFields, sortBy)
+}
+
+func (boltdb *DB) GetAuditPage(cursor string, limit int, filters Filters, returnFields []string, sortBy SortBy) ([]Audit, string, error) {
+	return boltdb.Audits().ListPage(cursor, limit, filters, returnFields, sortBy)
+}
+
+// DeleteAuditsBefore removes the audit entries older than the retention time
+func (boltdb *DB) DeleteAuditsBefore(before time.Time) (int64, error) {
+	return boltdb.Audits().DeleteWhere(Filters{Operator: "AND", Conditions: []Condition{{Field: "Created", Comparison: "<", Value: before}}})
+}
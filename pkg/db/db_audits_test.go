@@ -0,0 +1,87 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAuditDb(t *testing.T) {
+	database, teardown := newTestDB(t)
+	defer teardown()
+
+	err := database.InitDb()
+	if err != nil {
+		t.Fatalf("error creating database")
+	}
+
+	sampleAudits := []Audit{
+		{User: "admin1", AuthMethod: "session", IP: "192.168.1.10", Method: "POST", Endpoint: "/api/camera/record/stop", Command: "ca 0", Result: "200 OK"},
+		{User: "admin1", AuthMethod: "session", IP: "192.168.1.10", Method: "POST", Endpoint: "/api/camera/record/start", Command: "ca 1", Result: "200 OK"},
+		{User: "admin2", AuthMethod: "session", IP: "192.168.1.11", Method: "POST", Endpoint: "/api/logout", Result: "200 OK"},
+	}
+
+	for _, audit := range sampleAudits {
+		_, err := database.InsertAudit(audit, []string{})
+		if err != nil {
+			t.Fatalf("Error saving audit entry: %s", err)
+		}
+	}
+
+	t.Run("Long Endpoint", func(t *testing.T) {
+		longEndpoint := make([]byte, 2084)
+
+		_, err := database.InsertAudit(Audit{Endpoint: string(longEndpoint)}, []string{})
+		if err == nil {
+			t.Errorf("want error; got nil")
+		}
+	})
+
+	tests := []struct {
+		name    string
+		filters Filters
+		want    int64
+	}{
+		{
+			name:    "All",
+			filters: Filters{Operator: "AND"},
+			want:    3,
+		},
+		{
+			name:    "User",
+			filters: Filters{Operator: "AND", Conditions: []Condition{{Field: "User", Comparison: "=", Value: "admin1"}}},
+			want:    2,
+		},
+		{
+			name:    "Camera Commands",
+			filters: Filters{Operator: "AND", Conditions: []Condition{{Field: "Endpoint", Comparison: "LIKE", Value: "/api/camera/%"}, {Field: "Command", Comparison: "=", Value: "ca 0"}}},
+			want:    1,
+		},
+		{
+			name:    "Users OR",
+			filters: Filters{Operator: "OR", Conditions: []Condition{{Field: "User", Comparison: "=", Value: "admin2"}, {Field: "Command", Comparison: "=", Value: "ca 1"}}},
+			want:    2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, totalResults, err := database.GetAuditList(0, 10, tt.filters, []string{}, SortBy{Field: "Created", Direction: "DESC"})
+
+			if err != nil || totalResults != tt.want {
+				t.Errorf("want %d results; got %d", tt.want, totalResults)
+			}
+		})
+	}
+
+	t.Run("Retention", func(t *testing.T) {
+		rowsAffected, err := database.DeleteAuditsBefore(time.Now().UTC().Add(-time.Hour))
+		if err != nil || rowsAffected != 0 {
+			t.Errorf("recent entries shouldn't be deleted")
+		}
+
+		rowsAffected, err = database.DeleteAuditsBefore(time.Now().UTC().Add(time.Second))
+		if err != nil || rowsAffected != 3 {
+			t.Errorf("want 3 entries deleted; got %d", rowsAffected)
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDeviceDb(t *testing.T) {
+	database, teardown := newMemoryTestDB(t)
+	defer teardown()
+
+	err := database.InitDb()
+	if err != nil {
+		t.Fatalf("error creating database")
+	}
+
+	sampleDevices := []Device{
+		{Key: "7f1c9a0e", Name: "phone", Secret: "secret1"},
+		{Key: "2b44e1d0", Name: "tablet", Secret: "secret2"},
+	}
+
+	for _, device := range sampleDevices {
+		_, err := database.InsertDevice(device, []string{})
+		if err != nil {
+			t.Fatalf("Error saving device: %s", err)
+		}
+	}
+
+	t.Run("Get By Key", func(t *testing.T) {
+		device, err := database.GetDeviceByKey("2b44e1d0")
+		if err != nil || device.Name != "tablet" || device.Secret != "secret2" {
+			t.Errorf("want the tablet; got %+v %v", device, err)
+		}
+
+		for _, key := range []string{"", "2b44", "unknown"} {
+			if _, err := database.GetDeviceByKey(key); err == nil {
+				t.Errorf("want error getting the device of the key %q", key)
+			}
+		}
+	})
+
+	t.Run("Enroll", func(t *testing.T) {
+		device, err := database.EnrollDevice(" watch ")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if device.Name != "watch" || device.Key == "" || device.Secret == "" || device.Created.IsZero() {
+			t.Errorf("want a device with key and secret; got %+v", device)
+		}
+
+		found, err := database.GetDeviceByKey(device.Key)
+		if err != nil || found.ID != device.ID || found.Secret != device.Secret {
+			t.Errorf("want the enrolled device; got %+v %v", found, err)
+		}
+
+		if _, err := database.EnrollDevice("  "); err == nil {
+			t.Errorf("want error enrolling a device without name")
+		}
+	})
+
+	t.Run("Nonces", func(t *testing.T) {
+		now := time.Now()
+		expiration := now.Add(-5 * time.Minute)
+
+		err := database.UseNonce("phone", now.Unix(), "n1", expiration)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = database.UseNonce("phone", now.Unix(), "n1", expiration)
+		if !errors.Is(err, ErrNonceUsed) {
+			t.Errorf("want ErrNonceUsed replaying the request; got %v", err)
+		}
+
+		// the same nonce is accepted with another timestamp or from another device
+		if err = database.UseNonce("phone", now.Unix()+1, "n1", expiration); err != nil {
+			t.Error(err)
+		}
+
+		if err = database.UseNonce("tablet", now.Unix(), "n1", expiration); err != nil {
+			t.Error(err)
+		}
+
+		// the expiration deletes the nonces of the older requests
+		err = database.UseNonce("phone", now.Unix()+600, "n2", now.Add(time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var nonces int
+
+		err = database.Store.View(func(tx Tx) error {
+			nonces = countKeys(tx.Bucket([]byte("device_nonces")))
+			return nil
+		})
+		if err != nil || nonces != 1 {
+			t.Errorf("want 1 nonce after the expiration; got %d %v", nonces, err)
+		}
+	})
+}
@@ -0,0 +1,308 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The shapes of the geofences
+const (
+	ShapeCircle  = "circle"
+	ShapePolygon = "polygon"
+)
+
+// The events of the geofences
+const (
+	EventEnter = "enter"
+	EventExit  = "exit"
+)
+
+// GeofenceCommands are the raspimjpeg commands that the geofences can send
+var GeofenceCommands = []string{"md 1", "md 0", "ca 1", "ca 0", "ru 1", "ru 0", "tl 1", "tl 0", "im"}
+
+// Geofence is a circle, with the center in Latitude and Longitude and the
+// Radius in meters, or a polygon. The coordinates use the scale of the
+// locations. Polygon is a list of vertices in degrees separated by spaces,
+// like "52.52,13.40 52.53,13.40 52.53,13.41".
+//
+// A device enters the geofence when it reports a location inside it and exits
+// when it reports a location more than Hysteresis meters outside it. The
+// locations with an Accuracy worse than MaxAccuracy meters are ignored, 0 uses
+// all of them. Devices is a list of device IDs separated by commas, empty for
+// all the devices. EnterCommand is sent to the camera when the first device
+// enters the geofence and ExitCommand when the last device exits it
+type Geofence struct {
+	ID           string    `json:"id"            db:"key,bucket=geofences,sort"`
+	Name         string    `json:"name"          db:"maxlength=100,sort"`
+	Shape        string    `json:"shape"         db:"maxlength=20,sort"`
+	Latitude     int       `json:"latitude"      db:"sort"`
+	Longitude    int       `json:"longitude"     db:"sort"`
+	Radius       int       `json:"radius"        db:"sort"`
+	Polygon      string    `json:"polygon"       db:"maxlength=2083"`
+	Hysteresis   int       `json:"hysteresis"    db:"sort"`
+	MaxAccuracy  int       `json:"max_accuracy"  db:"sort"`
+	Devices      string    `json:"devices"       db:"maxlength=2083"`
+	EnterCommand string    `json:"enter_command" db:"maxlength=20"`
+	ExitCommand  string    `json:"exit_command"  db:"maxlength=20"`
+	Created      time.Time `json:"created"       db:"created,index"`
+	Updated      time.Time `json:"updated"       db:"updated,sort"`
+}
+
+// GeofenceEvent is saved when a device enters or exits a geofence, Command is
+// the command sent to the camera, empty when the event didn't change the
+// occupation of the geofence
+type GeofenceEvent struct {
+	ID         string    `json:"id"          db:"key,bucket=geofence_events,sort"`
+	Geofence   string    `json:"geofence"    db:"index"`
+	Device     string    `json:"device"      db:"index"`
+	Event      string    `json:"event"       db:"maxlength=10,sort"`
+	Location   string    `json:"location"    db:"sort"`
+	DeviceTime int64     `json:"device_time" db:"sort"`
+	Command    string    `json:"command"     db:"maxlength=20,sort"`
+	Created    time.Time `json:"created"     db:"created,index"`
+}
+
+// GeofenceState is the last known state of a device in a geofence, DeviceTime
+// is the time of the last location used
+type GeofenceState struct {
+	Geofence   string    `json:"geofence"`
+	Device     string    `json:"device"`
+	Inside     bool      `json:"inside"`
+	Location   string    `json:"location"`
+	DeviceTime int64     `json:"device_time"`
+	Updated    time.Time `json:"updated"`
+}
+
+func (boltdb *DB) Geofences() *Repository[Geofence] {
+	return NewRepository[Geofence](boltdb)
+}
+
+func (boltdb *DB) GetGeofence(geofenceID string) (Geofence, error) {
+	return boltdb.Geofences().Get(geofenceID)
+}
+
+func (boltdb *DB) InsertGeofence(geofence Geofence, fields []string) (string, error) {
+	err := geofence.Check()
+	if err != nil {
+		return "", err
+	}
+
+	return boltdb.Geofences().Insert(geofence, fields)
+}
+
+// UpdateGeofence replaces the geofence, the states of the devices are kept
+func (boltdb *DB) UpdateGeofence(geofence Geofence, fields []string) (int64, error) {
+	err := geofence.Check()
+	if err != nil {
+		return 0, err
+	}
+
+	return boltdb.Geofences().Update(geofence, fields)
+}
+
+// DeleteGeofence deletes the geofence with its events and the states of the devices
+func (boltdb *DB) DeleteGeofence(geofenceID string) (int64, error) {
+	rowsAffected, err := boltdb.Geofences().Delete(geofenceID)
+	if err != nil || rowsAffected == 0 {
+		return rowsAffected, err
+	}
+
+	_, err = boltdb.GeofenceEvents().DeleteWhere(Filters{Operator: "AND", Conditions: []Condition{{Field: "Geofence", Comparison: "=", Value: geofenceID}}})
+	if err != nil {
+		return rowsAffected, err
+	}
+
+	return rowsAffected, boltdb.Store.Update(func(tx Tx) error {
+		return deleteGeofenceStates(tx, geofenceID)
+	})
+}
+
+func (boltdb *DB) GetGeofenceList(offset int, limit int, filters Filters, returnFields []string, sortBy SortBy) ([]Geofence, int64, error) {
+	return boltdb.Geofences().List(offset, limit, filters, returnFields, sortBy)
+}
+
+func (boltdb *DB) GeofenceEvents() *Repository[GeofenceEvent] {
+	return NewRepository[GeofenceEvent](boltdb)
+}
+
+func (boltdb *DB) InsertGeofenceEvent(event GeofenceEvent, fields []string) (string, error) {
+	return boltdb.GeofenceEvents().Insert(event, fields)
+}
+
+func (boltdb *DB) GetGeofenceEventList(offset int, limit int, filters Filters, returnFields []string, sortBy SortBy) ([]GeofenceEvent, int64, error) {
+	return boltdb.GeofenceEvents().List(offset, limit, filters, returnFields, sortBy)
+}
+
+func (boltdb *DB) GetGeofenceEventPage(cursor string, limit int, filters Filters, returnFields []string, sortBy SortBy) ([]GeofenceEvent, string, error) {
+	return boltdb.GeofenceEvents().ListPage(cursor, limit, filters, returnFields, sortBy)
+}
+
+// geofenceStateKey groups the states by geofence
+func geofenceStateKey(geofenceID string, deviceID string) []byte {
+	return []byte(geofenceID + "\x00" + deviceID)
+}
+
+// GetGeofenceStates returns the states of the devices that sent locations since
+// the geofence was created
+func (boltdb *DB) GetGeofenceStates(geofenceID string) (states []GeofenceState, err error) {
+	err = boltdb.Store.View(func(tx Tx) error {
+		prefix := geofenceStateKey(geofenceID, "")
+
+		c := tx.Bucket([]byte("geofence_states")).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var state GeofenceState
+
+			err := json.Unmarshal(v, &state)
+			if err != nil {
+				return errors.New("corrupt_geofence_state_error: geofence state " + strings.ReplaceAll(string(k), "\x00", "/") + " can't be decoded: " + err.Error())
+			}
+
+			states = append(states, state)
+		}
+
+		return nil
+	})
+
+	return
+}
+
+// SaveGeofenceState saves the state of the device in the geofence
+func (boltdb *DB) SaveGeofenceState(state GeofenceState) error {
+	state.Updated = time.Now().UTC()
+
+	stateJSON, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	return boltdb.Store.Update(func(tx Tx) error {
+		return tx.Bucket([]byte("geofence_states")).Put(geofenceStateKey(state.Geofence, state.Device), stateJSON)
+	})
+}
+
+func deleteGeofenceStates(tx Tx, geofenceID string) error {
+	b := tx.Bucket([]byte("geofence_states"))
+
+	prefix := geofenceStateKey(geofenceID, "")
+
+	// collect the keys before deleting them, the cursor moves when a key is deleted
+	var keys [][]byte
+
+	c := b.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		keys = append(keys, append([]byte{}, k...))
+	}
+
+	for _, k := range keys {
+		err := b.Delete(k)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Tracks returns true if the geofence uses the locations of the device
+func (geofence Geofence) Tracks(deviceID string) bool {
+	if strings.TrimSpace(geofence.Devices) == "" {
+		return true
+	}
+
+	for _, device := range strings.Split(geofence.Devices, ",") {
+		if strings.TrimSpace(device) == deviceID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Vertices returns the latitude and longitude in degrees of the vertices of
+// the polygon
+func (geofence Geofence) Vertices() (vertices [][2]float64, err error) {
+	for _, vertex := range strings.Fields(geofence.Polygon) {
+		latitudeText, longitudeText, _ := strings.Cut(vertex, ",")
+
+		latitude, latitudeErr := strconv.ParseFloat(latitudeText, 64)
+		longitude, longitudeErr := strconv.ParseFloat(longitudeText, 64)
+
+		if latitudeErr != nil || longitudeErr != nil || !(math.Abs(latitude) <= 90) || !(math.Abs(longitude) <= 180) {
+			return nil, errors.New("error_invalid__geofence___Polygon")
+		}
+
+		vertices = append(vertices, [2]float64{latitude, longitude})
+	}
+
+	if len(vertices) < 3 {
+		return nil, errors.New("error_invalid__geofence___Polygon")
+	}
+
+	return
+}
+
+// Check validates the shape, the distances and the commands of the geofence
+func (geofence Geofence) Check() error {
+	if strings.TrimSpace(geofence.Name) == "" {
+		return errors.New("error_required__geofence___Name")
+	}
+
+	switch geofence.Shape {
+	case ShapeCircle:
+		if geofence.Radius <= 0 {
+			return errors.New("error_invalid__geofence___Radius")
+		}
+
+		if geofence.Latitude < -90*CoordinateScale || geofence.Latitude > 90*CoordinateScale {
+			return errors.New("error_invalid__geofence___Latitude")
+		}
+
+		if geofence.Longitude < -180*CoordinateScale || geofence.Longitude > 180*CoordinateScale {
+			return errors.New("error_invalid__geofence___Longitude")
+		}
+	case ShapePolygon:
+		_, err := geofence.Vertices()
+		if err != nil {
+			return err
+		}
+	default:
+		return errors.New("error_invalid__geofence___Shape")
+	}
+
+	if geofence.Hysteresis < 0 {
+		return errors.New("error_invalid__geofence___Hysteresis")
+	}
+
+	if geofence.MaxAccuracy < 0 {
+		return errors.New("error_invalid__geofence___MaxAccuracy")
+	}
+
+	if !validGeofenceCommand(geofence.EnterCommand) {
+		return errors.New("error_invalid__geofence___EnterCommand")
+	}
+
+	if !validGeofenceCommand(geofence.ExitCommand) {
+		return errors.New("error_invalid__geofence___ExitCommand")
+	}
+
+	return nil
+}
+
+func validGeofenceCommand(command string) bool {
+	if command == "" {
+		return true
+	}
+
+	for _, valid := range GeofenceCommands {
+		if command == valid {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"testing"
+)
+
+func TestGeofenceCheck(t *testing.T) {
+	circle := Geofence{Name: "Home", Shape: ShapeCircle, Latitude: ScaleCoordinate(52.52), Longitude: ScaleCoordinate(13.405), Radius: 100, EnterCommand: "md 0", ExitCommand: "md 1"}
+	polygon := Geofence{Name: "Block", Shape: ShapePolygon, Polygon: "52.519,13.404 52.521,13.404 52.521,13.406"}
+
+	tests := []struct {
+		name   string
+		change func(geofence *Geofence)
+		base   Geofence
+		want   string
+	}{
+		{"Circle", func(geofence *Geofence) {}, circle, ""},
+		{"Polygon", func(geofence *Geofence) {}, polygon, ""},
+		{"Without Name", func(geofence *Geofence) { geofence.Name = " " }, circle, "error_required__geofence___Name"},
+		{"Unknown Shape", func(geofence *Geofence) { geofence.Shape = "square" }, circle, "error_invalid__geofence___Shape"},
+		{"Without Radius", func(geofence *Geofence) { geofence.Radius = 0 }, circle, "error_invalid__geofence___Radius"},
+		{"Wrong Latitude", func(geofence *Geofence) { geofence.Latitude = ScaleCoordinate(91) }, circle, "error_invalid__geofence___Latitude"},
+		{"Two Vertices", func(geofence *Geofence) { geofence.Polygon = "52.519,13.404 52.521,13.404" }, polygon, "error_invalid__geofence___Polygon"},
+		{"Wrong Vertex", func(geofence *Geofence) { geofence.Polygon += " 52.52" }, polygon, "error_invalid__geofence___Polygon"},
+		{"Negative Hysteresis", func(geofence *Geofence) { geofence.Hysteresis = -1 }, circle, "error_invalid__geofence___Hysteresis"},
+		{"Unknown Command", func(geofence *Geofence) { geofence.ExitCommand = "rm -rf" }, circle, "error_invalid__geofence___ExitCommand"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			geofence := tt.base
+			tt.change(&geofence)
+
+			err := geofence.Check()
+
+			if tt.want == "" && err != nil {
+				t.Errorf("want valid geofence; got %v", err)
+			} else if tt.want != "" && (err == nil || err.Error() != tt.want) {
+				t.Errorf("want %s; got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestGeofenceTracks(t *testing.T) {
+	geofence := Geofence{Devices: "alice, bob"}
+
+	if !geofence.Tracks("bob") || geofence.Tracks("carol") || !(Geofence{}).Tracks("carol") {
+		t.Error("want the devices of the list tracked, or all the devices without list")
+	}
+}
@@ -0,0 +1,45 @@
+package db
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestInsertDeviceLocation(t *testing.T) {
+	database, teardown := newMemoryTestDB(t)
+	defer teardown()
+
+	err := database.InitDb()
+	if err != nil {
+		t.Fatalf("error creating database")
+	}
+
+	location := Location{Device: "phone", DeviceIndex: 1700000000, Latitude: ScaleCoordinate(52.520008), Longitude: ScaleCoordinate(13.404954)}
+
+	_, err = database.InsertDeviceLocation(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = database.InsertDeviceLocation(location)
+	if !errors.Is(err, ErrDuplicateLocation) {
+		t.Errorf("want ErrDuplicateLocation; got %v", err)
+	}
+
+	// the same index of another device and another index of the device are saved
+	for _, other := range []Location{{Device: "tablet", DeviceIndex: 1700000000}, {Device: "phone", DeviceIndex: 1700000001}} {
+		if _, err := database.InsertDeviceLocation(other); err != nil {
+			t.Errorf("want %+v saved; got %v", other, err)
+		}
+	}
+
+	locations, total, err := database.GetLocationList(0, 10, Filters{Operator: "AND", Conditions: []Condition{{Field: "Device", Comparison: "=", Value: "phone"}}}, []string{}, SortBy{Field: "DeviceIndex", Direction: "ASC"})
+	if err != nil || total != 2 {
+		t.Fatalf("want 2 locations of the phone; got %d %v", total, err)
+	}
+
+	latitude, longitude := locations[0].Coordinates()
+	if locations[0].Latitude != 525200080 || latitude != 52.520008 || longitude != 13.404954 {
+		t.Errorf("want the coordinates scaled by 1e7; got %d %f %f", locations[0].Latitude, latitude, longitude)
+	}
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"time"
+)
+
+// ErrNonceUsed is returned by UseNonce when a signed request is replayed
+var ErrNonceUsed = errors.New("the nonce was already used")
+
+// nonceKey sorts the nonces by the timestamp of their request, the replays of a
+// request have the same timestamp so they have the same key
+func nonceKey(timestamp int64, deviceID string, nonce string) []byte {
+	return append(itob(int(timestamp)), []byte(deviceID+"\x00"+nonce)...)
+}
+
+// UseNonce saves the nonce of a signed request of a device, it returns
+// ErrNonceUsed if the device already sent a request with the same timestamp and
+// nonce. The nonces of the requests older than expiration are deleted, the
+// requests with older timestamps must be rejected as stale before calling it
+func (boltdb *DB) UseNonce(deviceID string, timestamp int64, nonce string, expiration time.Time) error {
+	return boltdb.Store.Update(func(tx Tx) error {
+		b := tx.Bucket([]byte("device_nonces"))
+
+		key := nonceKey(timestamp, deviceID, nonce)
+
+		if b.Get(key) != nil {
+			return ErrNonceUsed
+		}
+
+		// collect the keys before deleting them, the cursor moves when a key is deleted
+		var expired [][]byte
+
+		limit := itob(int(expiration.Unix()))
+
+		c := b.Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k[:8], limit) < 0; k, _ = c.Next() {
+			expired = append(expired, append([]byte{}, k...))
+		}
+
+		for _, k := range expired {
+			err := b.Delete(k)
+			if err != nil {
+				return err
+			}
+		}
+
+		// the value is the time the request was received
+		return b.Put(key, []byte(time.Now().UTC().Format(time.RFC3339)))
+	})
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"math"
+	"time"
+)
+
+// Session is the registry entry of a logged in browser, the scs session only
+// keeps the ID so the session can be listed and revoked from the API
+type Session struct {
+	ID        string    `json:"id"         db:"key,bucket=sessions,sort"`
+	Username  string    `json:"username"   db:"maxlength=100,sort"`
+	IP        string    `json:"ip"         db:"maxlength=100"`
+	UserAgent string    `json:"user_agent" db:"maxlength=512"`
+	Created   time.Time `json:"created"    db:"created,sort"`
+	LastSeen  time.Time `json:"last_seen"  db:"updated,sort"`
+}
+
+func (boltdb *DB) Sessions() *Repository[Session] {
+	return NewRepository[Session](boltdb)
+}
+
+func (boltdb *DB) GetSession(sessionID string) (Session, error) {
+	return boltdb.Sessions().Get(sessionID)
+}
+
+func (boltdb *DB) InsertSession(session Session) (string, error) {
+	session.ID = ""
+
+	return boltdb.Sessions().Insert(session, []string{})
+}
+
+// TouchSession updates the last time the session was used
+func (boltdb *DB) TouchSession(sessionID string, ip string) error {
+	_, err := boltdb.Sessions().Update(Session{ID: sessionID, IP: ip}, []string{"IP"})
+
+	return err
+}
+
+func (boltdb *DB) DeleteSession(sessionID string) (int64, error) {
+	return boltdb.Sessions().Delete(sessionID)
+}
+
+// GetSessionList returns the sessions of a user sorted by last use, if username
+// is empty the sessions of all users are returned
+func (boltdb *DB) GetSessionList(username string) ([]Session, error) {
+	filters := Filters{Operator: "AND"}
+	if username != "" {
+		filters = usernameFilter(username)
+	}
+
+	sessions, _, err := boltdb.Sessions().List(0, math.MaxInt32, filters, []string{}, SortBy{Field: "LastSeen", Direction: "DESC"})
+
+	return sessions, err
+}
+
+// DeleteUserSessions removes every session of a user, it's used to log out everywhere
+func (boltdb *DB) DeleteUserSessions(username string) (int64, error) {
+	return boltdb.Sessions().DeleteWhere(usernameFilter(username))
+}
+
+// DeleteExpiredSessions removes the sessions that haven't been used during the
+// idle timeout or are older than the session lifetime
+func (boltdb *DB) DeleteExpiredSessions(idleTimeout time.Duration, lifetime time.Duration) (int64, error) {
+	now := time.Now().UTC()
+
+	filters := Filters{Operator: "OR"}
+
+	if idleTimeout > 0 {
+		filters.Conditions = append(filters.Conditions, Condition{Field: "LastSeen", Comparison: "<", Value: now.Add(-idleTimeout)})
+	}
+
+	if lifetime > 0 {
+		filters.Conditions = append(filters.Conditions, Condition{Field: "Created", Comparison: "<", Value: now.Add(-lifetime)})
+	}
+
+	if len(filters.Conditions) == 0 {
+		return 0, nil
+	}
+
+	return boltdb.Sessions().DeleteWhere(filters)
+}
+
+// Expired checks if the session is no longer valid, a zero duration disables the check
+func (session Session) Expired(now time.Time, idleTimeout time.Duration, lifetime time.Duration) bool {
+	if idleTimeout > 0 && now.Sub(session.LastSeen) > idleTimeout {
+		return true
+	}
+
+	if lifetime > 0 && now.Sub(session.Created) > lifetime {
+		return true
+	}
+
+	return false
+}
+
+func (session Session) ValidUsernameDefault() (validField bool, err error) {
+	return validDefault(session, "Username")
+}
+func (session Session) ValidIPDefault() (validField bool, err error) {
+	return validDefault(session, "IP")
+}
+func (session Session) ValidUserAgentDefault() (validField bool, err error) {
+	return validDefault(session, "UserAgent")
+}
@@ -0,0 +1,117 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionDb(t *testing.T) {
+	database, teardown := newTestDB(t)
+	defer teardown()
+
+	err := database.InitDb()
+	if err != nil {
+		t.Fatalf("error creating database")
+	}
+
+	var sessionIDs []string
+
+	for _, username := range []string{"admin1", "admin1", "admin2"} {
+		sessionID, err := database.InsertSession(Session{Username: username, IP: "192.168.1.10", UserAgent: "Mozilla/5.0"})
+		if err != nil {
+			t.Fatalf("Error saving session: %s", err)
+		}
+
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+
+	t.Run("Get Session", func(t *testing.T) {
+		session, err := database.GetSession(sessionIDs[0])
+
+		if err != nil || session.Username != "admin1" || session.IP != "192.168.1.10" {
+			t.Errorf("Session was not saved correctly")
+		}
+
+		if session.Created.IsZero() || !session.LastSeen.Equal(session.Created) {
+			t.Errorf("Session timestamps were not saved correctly")
+		}
+	})
+
+	t.Run("Long User Agent", func(t *testing.T) {
+		longUserAgent := make([]byte, 513)
+
+		_, err := database.InsertSession(Session{Username: "admin1", UserAgent: string(longUserAgent)})
+		if err == nil {
+			t.Errorf("want error; got nil")
+		}
+	})
+
+	t.Run("Touch Session", func(t *testing.T) {
+		err := database.TouchSession(sessionIDs[1], "10.0.0.1")
+		if err != nil {
+			t.Errorf("Error updating session")
+		}
+
+		session, _ := database.GetSession(sessionIDs[1])
+
+		if session.IP != "10.0.0.1" || !session.LastSeen.After(session.Created) {
+			t.Errorf("Session was not updated correctly")
+		}
+	})
+
+	t.Run("Session List", func(t *testing.T) {
+		sessionList, err := database.GetSessionList("admin1")
+
+		if err != nil || len(sessionList) != 2 {
+			t.Fatalf("want 2 sessions; got %d", len(sessionList))
+		}
+
+		// the most recently used session goes first
+		if sessionList[0].ID != sessionIDs[1] {
+			t.Errorf("Sessions are not sorted by last seen")
+		}
+	})
+
+	t.Run("Expired Sessions", func(t *testing.T) {
+		session, _ := database.GetSession(sessionIDs[2])
+
+		if session.Expired(time.Now(), time.Hour, 24*time.Hour) {
+			t.Errorf("new session shouldn't be expired")
+		}
+
+		if !session.Expired(time.Now().Add(2*time.Hour), time.Hour, 24*time.Hour) {
+			t.Errorf("idle session should be expired")
+		}
+
+		if !session.Expired(time.Now().Add(25*time.Hour), 0, 24*time.Hour) {
+			t.Errorf("old session should be expired")
+		}
+	})
+
+	t.Run("Delete User Sessions", func(t *testing.T) {
+		rowsAffected, err := database.DeleteUserSessions("admin1")
+
+		if err != nil || rowsAffected != 2 {
+			t.Errorf("want 2 sessions deleted; got %d", rowsAffected)
+		}
+
+		sessionList, _ := database.GetSessionList("")
+
+		if len(sessionList) != 1 || sessionList[0].Username != "admin2" {
+			t.Errorf("Sessions of other users shouldn't be deleted")
+		}
+	})
+
+	t.Run("Delete Session", func(t *testing.T) {
+		rowsAffected, err := database.DeleteSession(sessionIDs[2])
+
+		if err != nil || rowsAffected != 1 {
+			t.Errorf("Error deleting session")
+		}
+
+		_, err = database.GetSession(sessionIDs[2])
+		if err == nil {
+			t.Errorf("Session was not deleted")
+		}
+	})
+}
@@ -10,7 +10,7 @@ import (
 	"github.com/jempe/gopicam/pkg/utils"
 )
 
-func newTestDB(t *testing.T) (*DB, func()) {
+func newTestDB(t testing.TB) (*DB, func()) {
 
 	// create and remove empty file, we need the file path to create the DB file
 	testFile, err := ioutil.TempFile("", "gopicam-dbtest.*.db")
@@ -33,6 +33,14 @@ func newTestDB(t *testing.T) (*DB, func()) {
 	}
 }
 
+// newMemoryTestDB returns a DB with a memory store for the tests that don't
+// need a DB file, they are faster because the store doesn't write to the disk
+func newMemoryTestDB(t testing.TB) (*DB, func()) {
+	database := &DB{Store: NewMemoryStore()}
+
+	return database, database.Close
+}
+
 func TestInitDb(t *testing.T) {
 	database, teardown := newTestDB(t)
 	defer teardown()
@@ -0,0 +1,228 @@
+package db
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/jempe/gopicam/pkg/validator"
+)
+
+const MinUsernameLength = 6
+const MaxUsernameLength = 25
+
+// ErrSetupCompleted is returned when the first account is created after the setup has been locked
+var ErrSetupCompleted = errors.New("setup_completed")
+
+type User struct {
+	ID       string    `json:"id"       db:"key,bucket=users,sort"`
+	Username string    `json:"username" db:"maxlength=25,sort"`
+	Password []byte    `json:"password" db:"secret"`
+	Created  time.Time `json:"created"  db:"created,sort"`
+	Updated  time.Time `json:"updated"  db:"updated,sort"`
+}
+
+func (boltdb *DB) Users() *Repository[User] {
+	return NewRepository[User](boltdb)
+}
+
+// validate checks the characters of the username and the password hash, the
+// repository only checks the length of the username
+func (user User) validate() error {
+	_, err := user.ValidUsernameDefault()
+	if err != nil {
+		return err
+	}
+
+	_, err = user.ValidPasswordDefault()
+
+	return err
+}
+
+func usernameFilter(username string) Filters {
+	return Filters{Operator: "AND", Conditions: []Condition{{Field: "Username", Comparison: "=", Value: username}}}
+}
+
+func (boltdb *DB) GetUser(userID string) (User, error) {
+	return boltdb.Users().Get(userID)
+}
+
+// GetUserByUsername returns the user account that has the username
+func (boltdb *DB) GetUserByUsername(username string) (user User, err error) {
+	err = boltdb.Store.View(func(tx Tx) error {
+		var found bool
+
+		user, found, err = boltdb.Users().first(tx, usernameFilter(username))
+		if err == nil && !found {
+			err = errors.New("user not found")
+		}
+
+		return err
+	})
+
+	return
+}
+
+func (boltdb *DB) InsertUser(user User) (string, error) {
+	if err := user.validate(); err != nil {
+		return "", err
+	}
+
+	repo := boltdb.Users()
+
+	return repo.insertWith(user, []string{}, func(tx Tx, item reflect.Value) error {
+		return checkUsername(tx, repo, user.Username)
+	})
+}
+
+// checkUsername returns an error if the username is taken
+func checkUsername(tx Tx, repo *Repository[User], username string) error {
+	_, found, err := repo.first(tx, usernameFilter(username))
+	if err == nil && found {
+		err = errors.New("insert_user_error: user " + username + " already exists")
+	}
+
+	return err
+}
+
+// InsertFirstUser creates the first account of the setup page, it fails if the
+// setup has been completed before so the page can't be used to take over the camera
+func (boltdb *DB) InsertFirstUser(user User) (string, error) {
+	if err := user.validate(); err != nil {
+		return "", err
+	}
+
+	return boltdb.Users().insertWith(user, []string{}, func(tx Tx, item reflect.Value) error {
+		configuration := tx.Bucket([]byte("configuration"))
+
+		firstUserKey, _ := tx.Bucket([]byte("users")).Cursor().First()
+
+		if configuration.Get([]byte("setup_completed")) != nil || firstUserKey != nil {
+			return ErrSetupCompleted
+		}
+
+		return configuration.Put([]byte("setup_completed"), []byte(time.Now().UTC().Format(time.RFC3339)))
+	})
+}
+
+// SetupRequired checks if the first account has to be created from the setup page
+func (boltdb *DB) SetupRequired() bool {
+	if boltdb.GetConfigValue("setup_completed") != nil {
+		return false
+	}
+
+	totalUsers, err := boltdb.CountUsers()
+
+	return err == nil && totalUsers == 0
+}
+
+// UpdateUserPassword replaces the password hash of the user
+func (boltdb *DB) UpdateUserPassword(username string, password []byte) (int64, error) {
+	user, err := boltdb.GetUserByUsername(username)
+	if err != nil {
+		return 0, err
+	}
+
+	user.Password = password
+
+	if err := user.validate(); err != nil {
+		return 0, err
+	}
+
+	return boltdb.Users().Update(user, []string{"Password"})
+}
+
+// DeleteUser removes the account and its sessions
+func (boltdb *DB) DeleteUser(username string) (rowsAffected int64, err error) {
+	user, err := boltdb.GetUserByUsername(username)
+	if err != nil {
+		return
+	}
+
+	rowsAffected, err = boltdb.Users().Delete(user.ID)
+	if err != nil {
+		return
+	}
+
+	_, err = boltdb.DeleteUserSessions(user.Username)
+
+	return
+}
+
+// GetUserList returns all the accounts sorted by username
+func (boltdb *DB) GetUserList() ([]User, error) {
+	users, _, err := boltdb.Users().List(0, math.MaxInt32, Filters{Operator: "AND"}, []string{}, SortBy{Field: "Username", Direction: "ASC"})
+
+	return users, err
+}
+
+func (boltdb *DB) CountUsers() (totalUsers int, err error) {
+	err = boltdb.Store.View(func(tx Tx) error {
+		return tx.Bucket([]byte("users")).ForEach(func(k, v []byte) error {
+			totalUsers++
+			return nil
+		})
+	})
+
+	return
+}
+
+// migrateLegacyAdmin moves the admin account stored in the configuration bucket
+// by the first versions of gopicam to the users bucket
+func migrateLegacyAdmin(tx Tx) error {
+	configuration := tx.Bucket([]byte("configuration"))
+
+	username := configuration.Get([]byte("username"))
+	password := configuration.Get([]byte("password"))
+
+	if username == nil || password == nil {
+		return nil
+	}
+
+	user := User{Username: string(username), Password: append([]byte{}, password...)}
+
+	err := user.validate()
+	if err != nil {
+		return err
+	}
+
+	repo := NewRepository[User](nil)
+
+	item, err := repo.prepareInsert(user, []string{})
+	if err != nil {
+		return err
+	}
+
+	err = repo.insert(tx, item)
+	if err != nil {
+		return err
+	}
+
+	err = configuration.Put([]byte("setup_completed"), []byte(time.Now().UTC().Format(time.RFC3339)))
+	if err != nil {
+		return err
+	}
+
+	err = configuration.Delete([]byte("username"))
+	if err != nil {
+		return err
+	}
+
+	return configuration.Delete([]byte("password"))
+}
+
+func (user User) ValidUsernameDefault() (validField bool, err error) {
+	validField, err = validator.ValidateUsername(user.Username, MinUsernameLength, MaxUsernameLength)
+
+	return
+}
+func (user User) ValidPasswordDefault() (validField bool, err error) {
+	validField = len(user.Password) > 0
+	if !validField {
+		err = errors.New("error_required__user___Password")
+		return
+	}
+
+	return
+}
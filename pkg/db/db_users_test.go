@@ -0,0 +1,157 @@
+package db
+
+import (
+	"testing"
+)
+
+func TestUserDb(t *testing.T) {
+	database, teardown := newTestDB(t)
+	defer teardown()
+
+	err := database.InitDb()
+	if err != nil {
+		t.Fatalf("error creating database")
+	}
+
+	t.Run("Setup Required", func(t *testing.T) {
+		if !database.SetupRequired() {
+			t.Errorf("setup should be required without users")
+		}
+	})
+
+	tests := []struct {
+		name    string
+		input   User
+		wantErr bool
+	}{
+		{
+			name:    "Valid",
+			input:   User{Username: "admin1", Password: []byte("hash")},
+			wantErr: false,
+		},
+		{
+			name:    "Short Username",
+			input:   User{Username: "admin", Password: []byte("hash")},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid Chars",
+			input:   User{Username: "Admin User", Password: []byte("hash")},
+			wantErr: true,
+		},
+		{
+			name:    "Empty Password",
+			input:   User{Username: "admin2"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := database.InsertFirstUser(tt.input)
+
+			if err != nil && !tt.wantErr {
+				t.Errorf("want nil error; got %s", err)
+			}
+			if err == nil && tt.wantErr {
+				t.Errorf("want error; got nil")
+			}
+		})
+	}
+
+	t.Run("Setup Locked", func(t *testing.T) {
+		if database.SetupRequired() {
+			t.Errorf("setup should be locked after the first account")
+		}
+
+		_, err := database.InsertFirstUser(User{Username: "intruder", Password: []byte("hash")})
+		if err != ErrSetupCompleted {
+			t.Errorf("want %s; got %v", ErrSetupCompleted, err)
+		}
+	})
+
+	t.Run("Duplicated Username", func(t *testing.T) {
+		_, err := database.InsertUser(User{Username: "admin1", Password: []byte("hash")})
+		if err == nil {
+			t.Errorf("want error; got nil")
+		}
+	})
+
+	t.Run("Update Password", func(t *testing.T) {
+		_, err := database.UpdateUserPassword("admin1", []byte("newhash"))
+		if err != nil {
+			t.Fatalf("error updating password")
+		}
+
+		user, err := database.GetUserByUsername("admin1")
+		if err != nil || string(user.Password) != "newhash" {
+			t.Errorf("Password was not saved correctly")
+		}
+	})
+
+	t.Run("Password Hash Not Filterable", func(t *testing.T) {
+		_, _, err := database.Users().List(0, 10, Filters{Operator: "AND", Conditions: []Condition{{Field: "Password", Comparison: "=", Value: "newhash"}}}, []string{}, SortBy{Direction: "ASC"})
+		if err == nil {
+			t.Errorf("want error of the filter of the password; got nil")
+		}
+
+		userList, err := database.GetUserList()
+		if err != nil || len(userList) != 1 || string(userList[0].Password) != "newhash" {
+			t.Errorf("want the user with its password hash; got %v %v", userList, err)
+		}
+
+		userList, _, err = database.Users().List(0, 10, Filters{Operator: "AND"}, []string{"Username", "Password"}, SortBy{Direction: "ASC"})
+		if err != nil || len(userList) != 1 || userList[0].Username != "admin1" || userList[0].Password != nil {
+			t.Errorf("want the user without the password hash in the return fields; got %v %v", userList, err)
+		}
+	})
+
+	t.Run("Delete User", func(t *testing.T) {
+		_, err := database.InsertSession(Session{Username: "admin1"})
+		if err != nil {
+			t.Fatalf("error saving session")
+		}
+
+		rowsAffected, err := database.DeleteUser("admin1")
+		if err != nil || rowsAffected != 1 {
+			t.Errorf("Error deleting user")
+		}
+
+		sessionList, _ := database.GetSessionList("admin1")
+		if len(sessionList) != 0 {
+			t.Errorf("Sessions of the deleted user were not removed")
+		}
+
+		// the setup page stays locked even if all users are deleted
+		if database.SetupRequired() {
+			t.Errorf("setup should stay locked")
+		}
+	})
+}
+
+func TestMigrateLegacyAdmin(t *testing.T) {
+	database, teardown := newTestDB(t)
+	defer teardown()
+
+	err := database.InitDb()
+	if err != nil {
+		t.Fatalf("error creating database")
+	}
+
+	database.SetConfigValue("username", []byte("oldadmin"))
+	database.SetConfigValue("password", []byte("oldhash"))
+
+	err = database.Store.Update(migrateLegacyAdmin)
+	if err != nil {
+		t.Fatalf("error migrating admin: %s", err)
+	}
+
+	user, err := database.GetUserByUsername("oldadmin")
+	if err != nil || string(user.Password) != "oldhash" {
+		t.Errorf("Admin account was not migrated")
+	}
+
+	if database.GetConfigValue("username") != nil || database.GetConfigValue("password") != nil {
+		t.Errorf("Legacy admin account was not removed from configuration")
+	}
+}
@@ -0,0 +1,499 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The export formats are JSON Lines, one JSON object per line with the same
+// keys as the API, and CSV with a header row of the JSON keys. The dates of the
+// CSV files use the RFC 3339 format
+const (
+	FormatJSONL = "jsonl"
+	FormatCSV   = "csv"
+)
+
+// ExportBuckets are the buckets that can be exported and imported
+var ExportBuckets = []string{"devices", "locations", "photos", "videos", "audios", "requests"}
+
+// maxImportErrors is the number of row errors returned by an import, the other
+// rows with errors are only counted
+const maxImportErrors = 100
+
+// importBatchSize is the number of items saved in each transaction of an import
+const importBatchSize = 1000
+
+type ImportError struct {
+	Row   int    `json:"row"`
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error"`
+}
+
+type ImportResult struct {
+	Imported int64         `json:"imported"`
+	Failed   int64         `json:"failed"`
+	Errors   []ImportError `json:"errors"`
+}
+
+// Exportable is the repository of a bucket that can be exported and imported
+type Exportable interface {
+	Export(w io.Writer, format string, filters Filters) (int64, error)
+	Import(r io.Reader, format string, dryRun bool) (ImportResult, error)
+}
+
+// Exportable returns the repository of one of the ExportBuckets
+func (boltdb *DB) Exportable(bucket string) (Exportable, error) {
+	switch bucket {
+	case "devices":
+		return boltdb.Devices(), nil
+	case "locations":
+		return boltdb.Locations(), nil
+	case "photos":
+		return boltdb.Photos(), nil
+	case "videos":
+		return boltdb.Videos(), nil
+	case "audios":
+		return boltdb.Audios(), nil
+	case "requests":
+		return boltdb.Requests(), nil
+	}
+
+	return nil, errors.New("export_error: unknown bucket " + bucket)
+}
+
+func checkFormat(format string) error {
+	if format != FormatJSONL && format != FormatCSV {
+		return errors.New("export_error: unknown format " + format)
+	}
+
+	return nil
+}
+
+// columnName is the name of the field in the CSV header, the JSON key when it has one
+func (field *entityField) columnName() string {
+	if field.jsonName != "" && field.jsonName != "-" {
+		return field.jsonName
+	}
+
+	return field.name
+}
+
+// Export writes the items that meet the filters sorted by ID, it reads the
+// bucket in one transaction so the export is a consistent snapshot
+func (repo *Repository[T]) Export(w io.Writer, format string, filters Filters) (count int64, err error) {
+	err = checkFormat(format)
+	if err != nil {
+		return
+	}
+
+	if filters.Operator == "" {
+		filters.Operator = "AND"
+	}
+
+	compiled, err := repo.entity.compileFilters(filters)
+	if err != nil {
+		return
+	}
+
+	var csvWriter *csv.Writer
+
+	output := bufio.NewWriter(w)
+
+	if format == FormatCSV {
+		csvWriter = csv.NewWriter(output)
+
+		var header []string
+		for _, field := range repo.entity.exportFields() {
+			header = append(header, field.columnName())
+		}
+
+		err = csvWriter.Write(header)
+		if err != nil {
+			return
+		}
+	}
+
+	err = repo.boltdb.Store.View(func(tx Tx) error {
+		c := tx.Bucket([]byte(repo.entity.bucket)).Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			item, err := repo.decode(k, v)
+			if err != nil {
+				return err
+			}
+
+			itemValue := reflect.ValueOf(item)
+
+			if !compiled.match(itemValue) {
+				continue
+			}
+
+			itemValue = repo.entity.clearSecrets(itemValue)
+
+			if csvWriter != nil {
+				err = csvWriter.Write(repo.entity.csvRecord(itemValue))
+			} else {
+				err = writeJSONLine(output, itemValue.Interface())
+			}
+
+			if err != nil {
+				return err
+			}
+
+			count++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	if csvWriter != nil {
+		csvWriter.Flush()
+
+		err = csvWriter.Error()
+		if err != nil {
+			return
+		}
+	}
+
+	err = output.Flush()
+
+	return
+}
+
+func writeJSONLine(w io.Writer, item interface{}) error {
+	itemJSON, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(itemJSON, '\n'))
+
+	return err
+}
+
+// exportFields are the fields of the exports, without the secret fields
+func (e *entity) exportFields() (fields []*entityField) {
+	for _, field := range e.fields {
+		if !field.secret {
+			fields = append(fields, field)
+		}
+	}
+
+	return
+}
+
+// clearSecrets returns a copy of the item with zero values in the secret fields
+func (e *entity) clearSecrets(item reflect.Value) reflect.Value {
+	cleared := reflect.New(item.Type()).Elem()
+	cleared.Set(item)
+
+	for _, field := range e.fields {
+		if field.secret {
+			cleared.Field(field.index).SetZero()
+		}
+	}
+
+	return cleared
+}
+
+func (e *entity) csvRecord(item reflect.Value) []string {
+	fields := e.exportFields()
+
+	record := make([]string, len(fields))
+
+	for i, field := range fields {
+		value := item.Field(field.index)
+
+		if field.fieldType == timeType {
+			record[i] = value.Interface().(time.Time).Format(time.RFC3339Nano)
+			continue
+		}
+
+		switch kindGroup(value.Kind()) {
+		case "string":
+			record[i] = value.String()
+		case "integer":
+			if isSignedInteger(value.Kind()) {
+				record[i] = strconv.FormatInt(value.Int(), 10)
+			} else {
+				record[i] = strconv.FormatUint(value.Uint(), 10)
+			}
+		case "float":
+			record[i] = strconv.FormatFloat(value.Float(), 'g', -1, value.Type().Bits())
+		case "bool":
+			record[i] = strconv.FormatBool(value.Bool())
+		}
+	}
+
+	return record
+}
+
+// Import validates every row and saves the valid ones, the rows with errors are
+// reported with their number: the line of the JSON Lines files and the record
+// of the CSV files, the header is the row 0. The rows without ID get a new one
+// and the rows without created date get the current time. The IDs that already
+// exist are errors. With dryRun the rows are only validated
+func (repo *Repository[T]) Import(r io.Reader, format string, dryRun bool) (result ImportResult, err error) {
+	err = checkFormat(format)
+	if err != nil {
+		return
+	}
+
+	var batch []reflect.Value
+	var batchRows []int
+
+	seen := make(map[string]bool)
+
+	addError := func(row int, id string, rowErr error) {
+		result.Failed++
+
+		if len(result.Errors) < maxImportErrors {
+			result.Errors = append(result.Errors, ImportError{Row: row, ID: id, Error: rowErr.Error()})
+		}
+	}
+
+	saveBatch := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		saveItems := func(tx Tx) error {
+			b := tx.Bucket([]byte(repo.entity.bucket))
+
+			for i, item := range batch {
+				id := item.Field(repo.entity.key.index).String()
+
+				if b.Get([]byte(id)) != nil {
+					addError(batchRows[i], id, errors.New("insert_"+repo.entity.item+"_error: "+repo.entity.item+" with ID "+id+" already exists"))
+					continue
+				}
+
+				if !dryRun {
+					err := repo.insert(tx, item)
+					if err != nil {
+						return err
+					}
+				}
+
+				result.Imported++
+			}
+
+			return nil
+		}
+
+		var err error
+
+		if dryRun {
+			err = repo.boltdb.Store.View(saveItems)
+		} else {
+			err = repo.boltdb.Store.Update(saveItems)
+		}
+
+		batch, batchRows = batch[:0], batchRows[:0]
+
+		return err
+	}
+
+	readRow, err := repo.rowReader(r, format)
+	if err != nil {
+		return
+	}
+
+	for {
+		row, item, rowErr, readErr := readRow()
+		if readErr == io.EOF {
+			break
+		} else if readErr != nil {
+			err = fmt.Errorf("import_error: row %d: %s", row, readErr)
+			return
+		}
+
+		if rowErr == nil {
+			rowErr = repo.prepareImport(item)
+		}
+
+		id := item.Field(repo.entity.key.index).String()
+
+		if rowErr == nil && seen[id] {
+			rowErr = errors.New("insert_" + repo.entity.item + "_error: duplicated ID " + id)
+		}
+
+		if rowErr != nil {
+			addError(row, id, rowErr)
+			continue
+		}
+
+		seen[id] = true
+
+		batch = append(batch, item)
+		batchRows = append(batchRows, row)
+
+		if len(batch) == importBatchSize {
+			err = saveBatch()
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	err = saveBatch()
+
+	// the IDs that already exist are found when the batches are saved
+	sort.SliceStable(result.Errors, func(i, j int) bool {
+		return result.Errors[i].Row < result.Errors[j].Row
+	})
+
+	return
+}
+
+// prepareImport sets the missing ID and timestamps of an imported item and
+// validates it like the Valid*Default methods of the entity, the secret fields
+// aren't imported
+func (repo *Repository[T]) prepareImport(item reflect.Value) error {
+	for _, field := range repo.entity.fields {
+		if field.secret {
+			item.Field(field.index).SetZero()
+		}
+	}
+
+	key := item.Field(repo.entity.key.index)
+
+	if key.String() == "" {
+		newID, err := uuid.NewRandom()
+		if err != nil {
+			return err
+		}
+
+		key.SetString(newID.String())
+	}
+
+	now := time.Now().UTC()
+
+	for _, field := range repo.entity.fields {
+		if (field.created || field.updated) && item.Field(field.index).Interface().(time.Time).IsZero() {
+			item.Field(field.index).Set(reflect.ValueOf(now))
+		}
+	}
+
+	return repo.Validate(item.Interface().(T), []string{})
+}
+
+// rowReader returns a function that reads the next item of the file. The errors
+// of the row are returned in rowErr, err is io.EOF at the end of the file or an
+// error that stops the import
+func (repo *Repository[T]) rowReader(r io.Reader, format string) (func() (row int, item reflect.Value, rowErr error, err error), error) {
+	itemType := reflect.TypeOf((*T)(nil)).Elem()
+
+	if format == FormatJSONL {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+		line := 0
+
+		return func() (int, reflect.Value, error, error) {
+			item := reflect.New(itemType).Elem()
+
+			for scanner.Scan() {
+				line++
+
+				if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+					continue
+				}
+
+				decoder := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+				decoder.DisallowUnknownFields()
+
+				return line, item, decoder.Decode(item.Addr().Interface()), nil
+			}
+
+			if scanner.Err() != nil {
+				return line + 1, item, nil, scanner.Err()
+			}
+
+			return line, item, nil, io.EOF
+		}, nil
+	}
+
+	csvReader := csv.NewReader(r)
+
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("import_error: CSV header error: %s", err)
+	}
+
+	columns := make([]*entityField, len(header))
+
+	for i, name := range header {
+		columns[i] = repo.entity.lookupField(name)
+		if columns[i] == nil {
+			return nil, fmt.Errorf("import_error: unknown column %s", name)
+		}
+	}
+
+	csvReader.FieldsPerRecord = len(header)
+	csvReader.ReuseRecord = true
+
+	row := 0
+
+	return func() (int, reflect.Value, error, error) {
+		item := reflect.New(itemType).Elem()
+
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			return row, item, nil, err
+		}
+
+		row++
+
+		if errors.Is(err, csv.ErrFieldCount) {
+			return row, item, err, nil
+		} else if err != nil {
+			// the rest of a broken CSV file can't be read
+			return row, item, nil, err
+		}
+
+		for i, field := range columns {
+			err = setCSVValue(item.Field(field.index), record[i])
+			if err != nil {
+				return row, item, fmt.Errorf("column %s: %s", header[i], err), nil
+			}
+		}
+
+		return row, item, nil, nil
+	}, nil
+}
+
+// setCSVValue parses the value of a CSV cell, the empty cells are zero values
+func setCSVValue(target reflect.Value, value string) error {
+	if target.Kind() == reflect.String {
+		target.SetString(value)
+		return nil
+	}
+
+	if value == "" {
+		return nil
+	}
+
+	parsed, err := parseValue(value, target.Type())
+	if err != nil {
+		return err
+	}
+
+	target.Set(parsed)
+
+	return nil
+}
@@ -0,0 +1,260 @@
+package db
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportImport(t *testing.T) {
+	database, teardown := newTestDB(t)
+	defer teardown()
+
+	err := database.InitDb()
+	if err != nil {
+		t.Fatalf("error creating database")
+	}
+
+	for i := 0; i < 5; i++ {
+		_, err := database.InsertLocation(Location{Device: "phone", DeviceIndex: i, Latitude: 40712800 + i, Longitude: -74006000, DeviceTime: int64(1700000000 + i), Wifi: "home, \"5G\""}, []string{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = database.InsertLocation(Location{Device: "tablet", DeviceTime: 1700000100}, []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locations, _, err := database.GetLocationList(0, 10, Filters{Operator: "AND"}, []string{}, SortBy{Field: "ID", Direction: "ASC"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := database.Exportable("locations")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []string{FormatJSONL, FormatCSV} {
+		t.Run(format, func(t *testing.T) {
+			var exported bytes.Buffer
+
+			count, err := repo.Export(&exported, format, Filters{})
+			if err != nil || count != 6 {
+				t.Fatalf("want 6 exported locations; got %d %v", count, err)
+			}
+
+			if format == FormatCSV && !strings.HasPrefix(exported.String(), "id,device_index,device,latitude,") {
+				t.Errorf("wrong CSV header %s", strings.SplitN(exported.String(), "\n", 2)[0])
+			}
+
+			target, targetTeardown := newTestDB(t)
+			defer targetTeardown()
+
+			err = target.InitDb()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			targetRepo, _ := target.Exportable("locations")
+
+			result, err := targetRepo.Import(bytes.NewReader(exported.Bytes()), format, false)
+			if err != nil || result.Imported != 6 || result.Failed != 0 {
+				t.Fatalf("want 6 imported locations; got %+v %v", result, err)
+			}
+
+			imported, _, err := target.GetLocationList(0, 10, Filters{Operator: "AND"}, []string{}, SortBy{Field: "ID", Direction: "ASC"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := range locations {
+				if !imported[i].Created.Equal(locations[i].Created) {
+					t.Errorf("created date was not imported")
+				}
+
+				imported[i].Created = locations[i].Created
+
+				if imported[i] != locations[i] {
+					t.Errorf("want %+v; got %+v", locations[i], imported[i])
+				}
+			}
+
+			// the indexes are updated by the import
+			phoneLocations, total, err := target.GetLocationList(0, 10, Filters{Operator: "AND", Conditions: []Condition{{Field: "Device", Comparison: "=", Value: "phone"}}}, []string{}, SortBy{Field: "DeviceTime", Direction: "DESC"})
+			if err != nil || total != 5 || phoneLocations[0].DeviceIndex != 4 {
+				t.Errorf("wrong indexed list %d %v", total, err)
+			}
+
+			result, err = targetRepo.Import(bytes.NewReader(exported.Bytes()), format, false)
+			if err != nil || result.Imported != 0 || result.Failed != 6 || !strings.Contains(result.Errors[0].Error, "already exists") {
+				t.Errorf("want already exists errors; got %+v %v", result, err)
+			}
+		})
+	}
+
+	t.Run("Filtered Export", func(t *testing.T) {
+		var exported bytes.Buffer
+
+		filters, _ := ParseFilter("device=tablet")
+
+		count, err := repo.Export(&exported, FormatJSONL, filters)
+		if err != nil || count != 1 || !strings.Contains(exported.String(), `"device":"tablet"`) {
+			t.Errorf("want tablet location; got %d %v %s", count, err, exported.String())
+		}
+	})
+
+	t.Run("Unknown Bucket", func(t *testing.T) {
+		_, err := database.Exportable("users")
+		if err == nil {
+			t.Errorf("want error; got nil")
+		}
+	})
+}
+
+func TestImportErrors(t *testing.T) {
+	database, teardown := newTestDB(t)
+	defer teardown()
+
+	err := database.InitDb()
+	if err != nil {
+		t.Fatalf("error creating database")
+	}
+
+	repo, _ := database.Exportable("photos")
+
+	jsonl := `{"file_type":"jpg","width":1920,"height":1080,"device_time":1700000000}
+
+{"id":"not-a-uuid","file_type":"jpg"}
+{"file_type":"` + strings.Repeat("a", 101) + `"}
+{"file_type":"jpg","color":"red"}
+{"file_type":"jpg"
+{"id":"6ba7b810-9dad-11d1-80b4-00c04fd430c8","file_type":"png","created":"2024-01-02T03:04:05Z"}
+{"id":"6ba7b810-9dad-11d1-80b4-00c04fd430c8","file_type":"png"}
+`
+
+	t.Run("Dry Run", func(t *testing.T) {
+		result, err := repo.Import(strings.NewReader(jsonl), FormatJSONL, true)
+		if err != nil || result.Imported != 2 || result.Failed != 5 {
+			t.Fatalf("want 2 valid and 5 invalid rows; got %+v %v", result, err)
+		}
+
+		_, total, _ := database.GetPhotoList(0, 10, Filters{Operator: "AND"}, []string{}, SortBy{Field: "ID", Direction: "ASC"})
+		if total != 0 {
+			t.Errorf("dry run saved %d photos", total)
+		}
+	})
+
+	result, err := repo.Import(strings.NewReader(jsonl), FormatJSONL, false)
+	if err != nil || result.Imported != 2 || result.Failed != 5 {
+		t.Fatalf("want 2 imported and 5 invalid rows; got %+v %v", result, err)
+	}
+
+	wantErrors := []struct {
+		row   int
+		error string
+	}{
+		{3, "error_uuid__photo___ID"},
+		{4, "error_maxlength__photo___FileType"},
+		{5, "unknown field"},
+		{6, "unexpected EOF"},
+		{8, "duplicated ID"},
+	}
+
+	for i, want := range wantErrors {
+		if result.Errors[i].Row != want.row || !strings.Contains(result.Errors[i].Error, want.error) {
+			t.Errorf("want row %d error %s; got %+v", want.row, want.error, result.Errors[i])
+		}
+	}
+
+	photo, err := database.GetPhoto("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	if err != nil || !photo.Created.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("wrong imported photo %+v %v", photo, err)
+	}
+
+	t.Run("CSV", func(t *testing.T) {
+		csvData := "file_type,Width,device_time\njpg,640,1700000000\npng,wide,1700000000\ngif,10\n"
+
+		result, err := repo.Import(strings.NewReader(csvData), FormatCSV, false)
+		if err != nil || result.Imported != 1 || result.Failed != 2 {
+			t.Fatalf("want 1 imported and 2 invalid rows; got %+v %v", result, err)
+		}
+
+		if result.Errors[0].Row != 2 || !strings.Contains(result.Errors[0].Error, "column Width") || result.Errors[1].Row != 3 {
+			t.Errorf("wrong row errors %+v", result.Errors)
+		}
+
+		_, err = repo.Import(strings.NewReader("file_type,color\njpg,red\n"), FormatCSV, false)
+		if err == nil {
+			t.Errorf("want unknown column error; got nil")
+		}
+	})
+}
+
+func TestExportDeviceSecrets(t *testing.T) {
+	database, teardown := newTestDB(t)
+	defer teardown()
+
+	err := database.InitDb()
+	if err != nil {
+		t.Fatalf("error creating database")
+	}
+
+	device, err := database.EnrollDevice("phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := database.Exportable("devices")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []string{FormatJSONL, FormatCSV} {
+		t.Run(format, func(t *testing.T) {
+			var exported bytes.Buffer
+
+			count, err := repo.Export(&exported, format, Filters{})
+			if err != nil || count != 1 || !strings.Contains(exported.String(), device.Key) {
+				t.Fatalf("want the device exported; got %d %v", count, err)
+			}
+
+			if strings.Contains(exported.String(), device.Secret) || strings.Contains(exported.String(), "secret") {
+				t.Errorf("want the export without the secret; got %s", exported.String())
+			}
+		})
+	}
+
+	t.Run("Import", func(t *testing.T) {
+		line := `{"id":"6ba7b810-9dad-11d1-80b4-00c04fd430c8","key":"k1","name":"watch","secret":"imported"}` + "\n"
+
+		result, err := repo.Import(strings.NewReader(line), FormatJSONL, false)
+		if err != nil || result.Imported != 1 {
+			t.Fatalf("want the device imported; got %+v %v", result, err)
+		}
+
+		imported, err := database.GetDevice("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+		if err != nil || imported.Secret != "" {
+			t.Errorf("want the device imported without secret; got %+v %v", imported, err)
+		}
+	})
+
+	t.Run("Filter", func(t *testing.T) {
+		var exported bytes.Buffer
+
+		filters, _ := ParseFilter("secret=" + device.Secret)
+
+		_, err := repo.Export(&exported, FormatJSONL, filters)
+		if err == nil {
+			t.Errorf("want error of the filter of the secret; got nil")
+		}
+
+		_, _, err = database.GetDeviceList(0, 10, Filters{Operator: "AND"}, []string{}, SortBy{Field: "Secret", Direction: "ASC"})
+		if err == nil {
+			t.Errorf("want error of the sort by secret; got nil")
+		}
+	})
+}
@@ -0,0 +1,341 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ParseFilter reads the filters of a query string, for example
+//
+//	created>2024-01-01 AND file_type IN (jpg,mp4)
+//	(size>=1000 OR length BETWEEN 10 AND 60) AND file_type NOT LIKE 'h26%'
+//
+// The fields can be the names of the struct fields or their JSON keys. AND binds
+// tighter than OR and parentheses group the conditions. The values are strings
+// that the repository converts to the type of the field, the values with spaces
+// or special characters are quoted with ' or ". The keywords are case insensitive
+func ParseFilter(expression string) (filters Filters, err error) {
+	tokens, err := tokenizeFilter(expression)
+	if err != nil {
+		return
+	}
+
+	if len(tokens) == 0 {
+		return Filters{Operator: "AND"}, nil
+	}
+
+	parser := &filterParser{tokens: tokens}
+
+	filters, err = parser.parseOr()
+	if err != nil {
+		return
+	}
+
+	if !parser.done() {
+		err = parser.errorf("unexpected %q", parser.peek().text)
+	}
+
+	return
+}
+
+const (
+	tokenWord = iota
+	tokenString
+	tokenComparison
+	tokenOpen
+	tokenClose
+	tokenComma
+)
+
+type filterToken struct {
+	kind     int
+	text     string
+	position int
+}
+
+// the characters that end a word without quotes
+const filterSpecialChars = "()<>=!,'\""
+
+func tokenizeFilter(expression string) (tokens []filterToken, err error) {
+	runes := []rune(expression)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, filterToken{kind: tokenOpen, text: "(", position: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, filterToken{kind: tokenClose, text: ")", position: i})
+			i++
+		case r == ',':
+			tokens = append(tokens, filterToken{kind: tokenComma, text: ",", position: i})
+			i++
+		case r == '\'' || r == '"':
+			start := i
+			i++
+
+			var value strings.Builder
+
+			for i < len(runes) && runes[i] != r {
+				value.WriteRune(runes[i])
+				i++
+			}
+
+			if i == len(runes) {
+				return nil, fmt.Errorf("filter error at %d: unterminated string", start)
+			}
+
+			i++
+
+			tokens = append(tokens, filterToken{kind: tokenString, text: value.String(), position: start})
+		case strings.ContainsRune("<>=!", r):
+			start := i
+
+			for i < len(runes) && strings.ContainsRune("<>=!", runes[i]) {
+				i++
+			}
+
+			comparison := string(runes[start:i])
+
+			if comparison == "<>" {
+				comparison = "!="
+			}
+
+			if !comparisons[comparison] {
+				return nil, fmt.Errorf("filter error at %d: unknown comparison %q", start, comparison)
+			}
+
+			tokens = append(tokens, filterToken{kind: tokenComparison, text: comparison, position: start})
+		default:
+			start := i
+
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune(filterSpecialChars, runes[i]) {
+				i++
+			}
+
+			tokens = append(tokens, filterToken{kind: tokenWord, text: string(runes[start:i]), position: start})
+		}
+	}
+
+	return
+}
+
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (parser *filterParser) done() bool {
+	return parser.pos >= len(parser.tokens)
+}
+
+func (parser *filterParser) peek() filterToken {
+	if parser.done() {
+		return filterToken{kind: -1, position: -1}
+	}
+
+	return parser.tokens[parser.pos]
+}
+
+func (parser *filterParser) next() filterToken {
+	token := parser.peek()
+	parser.pos++
+
+	return token
+}
+
+// keyword checks if the next token is the keyword and consumes it
+func (parser *filterParser) keyword(keyword string) bool {
+	token := parser.peek()
+
+	if token.kind == tokenWord && strings.EqualFold(token.text, keyword) {
+		parser.pos++
+		return true
+	}
+
+	return false
+}
+
+func (parser *filterParser) errorf(format string, args ...interface{}) error {
+	position := parser.peek().position
+	if position < 0 {
+		return fmt.Errorf("filter error at the end: "+format, args...)
+	}
+
+	return fmt.Errorf("filter error at %d: "+format, append([]interface{}{position}, args...)...)
+}
+
+func (parser *filterParser) parseOr() (Filters, error) {
+	return parser.parseList("OR", parser.parseAnd)
+}
+
+func (parser *filterParser) parseAnd() (Filters, error) {
+	return parser.parseList("AND", parser.parseFactor)
+}
+
+// parseList reads the parts joined by the operator, the conditions of the
+// parts that have the same operator are added to the same group
+func (parser *filterParser) parseList(operator string, parsePart func() (Filters, error)) (Filters, error) {
+	var parts []Filters
+
+	for {
+		part, err := parsePart()
+		if err != nil {
+			return Filters{}, err
+		}
+
+		parts = append(parts, part)
+
+		if !parser.keyword(operator) {
+			break
+		}
+	}
+
+	if len(parts) == 1 {
+		return parts[0], nil
+	}
+
+	filters := Filters{Operator: operator}
+
+	for _, part := range parts {
+		if part.Operator == operator || (len(part.Conditions) == 1 && len(part.Groups) == 0) {
+			filters.Conditions = append(filters.Conditions, part.Conditions...)
+			filters.Groups = append(filters.Groups, part.Groups...)
+		} else {
+			filters.Groups = append(filters.Groups, part)
+		}
+	}
+
+	return filters, nil
+}
+
+func (parser *filterParser) parseFactor() (Filters, error) {
+	if parser.peek().kind == tokenOpen {
+		parser.next()
+
+		filters, err := parser.parseOr()
+		if err != nil {
+			return filters, err
+		}
+
+		if parser.next().kind != tokenClose {
+			parser.pos--
+			return filters, parser.errorf("missing )")
+		}
+
+		return filters, nil
+	}
+
+	condition, err := parser.parseCondition()
+	if err != nil {
+		return Filters{}, err
+	}
+
+	return Filters{Operator: "AND", Conditions: []Condition{condition}}, nil
+}
+
+func (parser *filterParser) parseCondition() (condition Condition, err error) {
+	field := parser.peek()
+	if field.kind != tokenWord {
+		err = parser.errorf("missing field")
+		return
+	}
+
+	parser.next()
+
+	condition.Field = field.text
+
+	switch {
+	case parser.peek().kind == tokenComparison:
+		condition.Comparison = parser.next().text
+	case parser.keyword("NOT"):
+		if parser.keyword("LIKE") {
+			condition.Comparison = "NOT LIKE"
+		} else if parser.keyword("IN") {
+			condition.Comparison = "NOT IN"
+		} else {
+			err = parser.errorf("NOT must be followed by LIKE or IN")
+			return
+		}
+	case parser.keyword("LIKE"):
+		condition.Comparison = "LIKE"
+	case parser.keyword("IN"):
+		condition.Comparison = "IN"
+	case parser.keyword("BETWEEN"):
+		condition.Comparison = "BETWEEN"
+	default:
+		err = parser.errorf("missing comparison after %s", field.text)
+		return
+	}
+
+	switch condition.Comparison {
+	case "IN", "NOT IN":
+		condition.Value, err = parser.parseValueList()
+	case "BETWEEN":
+		var low, high string
+
+		low, err = parser.parseValue()
+		if err != nil {
+			return
+		}
+
+		if !parser.keyword("AND") {
+			err = parser.errorf("BETWEEN must be followed by <value> AND <value>")
+			return
+		}
+
+		high, err = parser.parseValue()
+
+		condition.Value = []string{low, high}
+	default:
+		condition.Value, err = parser.parseValue()
+	}
+
+	return
+}
+
+func (parser *filterParser) parseValue() (string, error) {
+	token := parser.peek()
+
+	if token.kind != tokenWord && token.kind != tokenString {
+		return "", parser.errorf("missing value")
+	}
+
+	parser.next()
+
+	return token.text, nil
+}
+
+func (parser *filterParser) parseValueList() (values []string, err error) {
+	if parser.next().kind != tokenOpen {
+		parser.pos--
+		err = parser.errorf("missing ( before the list of values")
+		return
+	}
+
+	for {
+		var value string
+
+		value, err = parser.parseValue()
+		if err != nil {
+			return
+		}
+
+		values = append(values, value)
+
+		token := parser.next()
+
+		if token.kind == tokenClose {
+			return
+		} else if token.kind != tokenComma {
+			parser.pos--
+			err = parser.errorf("missing , or ) in the list of values")
+			return
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		want       Filters
+		wantErr    bool
+	}{
+		{
+			name:       "Empty",
+			expression: "  ",
+			want:       Filters{Operator: "AND"},
+		},
+		{
+			name:       "Comparison Without Spaces",
+			expression: "created>2024-01-01",
+			want:       Filters{Operator: "AND", Conditions: []Condition{{Field: "created", Comparison: ">", Value: "2024-01-01"}}},
+		},
+		{
+			name:       "AND IN",
+			expression: "created>2024-01-01 AND file_type IN (jpg,mp4)",
+			want: Filters{Operator: "AND", Conditions: []Condition{
+				{Field: "created", Comparison: ">", Value: "2024-01-01"},
+				{Field: "file_type", Comparison: "IN", Value: []string{"jpg", "mp4"}},
+			}},
+		},
+		{
+			name:       "AND Before OR",
+			expression: "size >= 10 and size <= 20 or file_type = 'h 264'",
+			want: Filters{Operator: "OR", Conditions: []Condition{
+				{Field: "file_type", Comparison: "=", Value: "h 264"},
+			}, Groups: []Filters{
+				{Operator: "AND", Conditions: []Condition{{Field: "size", Comparison: ">=", Value: "10"}, {Field: "size", Comparison: "<=", Value: "20"}}},
+			}},
+		},
+		{
+			name:       "Nested Groups",
+			expression: `(Size>=1000 OR length BETWEEN 10 AND 60) AND file_type NOT LIKE "h26%" AND id NOT IN ('a', "b")`,
+			want: Filters{Operator: "AND", Conditions: []Condition{
+				{Field: "file_type", Comparison: "NOT LIKE", Value: "h26%"},
+				{Field: "id", Comparison: "NOT IN", Value: []string{"a", "b"}},
+			}, Groups: []Filters{
+				{Operator: "OR", Conditions: []Condition{{Field: "Size", Comparison: ">=", Value: "1000"}, {Field: "length", Comparison: "BETWEEN", Value: []string{"10", "60"}}}},
+			}},
+		},
+		{
+			name:       "Not Equal",
+			expression: "wifi<>home AND battery!=100",
+			want: Filters{Operator: "AND", Conditions: []Condition{
+				{Field: "wifi", Comparison: "!=", Value: "home"},
+				{Field: "battery", Comparison: "!=", Value: "100"},
+			}},
+		},
+		{name: "Missing Value", expression: "size >", wantErr: true},
+		{name: "Missing Comparison", expression: "size 10", wantErr: true},
+		{name: "Unknown Comparison", expression: "size => 10", wantErr: true},
+		{name: "Unterminated String", expression: "name = 'garden", wantErr: true},
+		{name: "Missing Parenthesis", expression: "(size > 10 OR size < 5", wantErr: true},
+		{name: "IN Without List", expression: "file_type IN jpg", wantErr: true},
+		{name: "BETWEEN Without AND", expression: "size BETWEEN 1 OR 2", wantErr: true},
+		{name: "Trailing Tokens", expression: "size > 1 size < 2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filters, err := ParseFilter(tt.expression)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("want error; got %+v", filters)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if !reflect.DeepEqual(filters, tt.want) {
+				t.Errorf("want %+v; got %+v", tt.want, filters)
+			}
+		})
+	}
+}
@@ -0,0 +1,391 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// comparisons of the conditions, IN and NOT IN take a list of values and
+// BETWEEN a list with the lowest and the highest value, both included
+var comparisons = map[string]bool{
+	"=":        true,
+	"!=":       true,
+	">":        true,
+	">=":       true,
+	"<":        true,
+	"<=":       true,
+	"LIKE":     true,
+	"NOT LIKE": true,
+	"IN":       true,
+	"NOT IN":   true,
+	"BETWEEN":  true,
+}
+
+// formats of the dates accepted in the string values of time fields, the dates
+// without time zone are UTC
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+type compiledCondition struct {
+	field      *entityField
+	comparison string
+	value      reflect.Value
+	values     []reflect.Value
+}
+
+type compiledFilters struct {
+	operator   string
+	conditions []compiledCondition
+	groups     []compiledFilters
+}
+
+func (filters compiledFilters) empty() bool {
+	return len(filters.conditions) == 0 && len(filters.groups) == 0
+}
+
+// compileFilters checks the fields, operators and comparisons of the filters
+// and converts the values to the type of the fields
+func (e *entity) compileFilters(filters Filters) (compiled compiledFilters, err error) {
+	compiled.operator = strings.ToUpper(filters.Operator)
+
+	if !(compiled.operator == "AND" || compiled.operator == "OR") {
+		err = errors.New("filter operator error")
+		return
+	}
+
+	for _, condition := range filters.Conditions {
+		compiledCondition, err := e.compileCondition(condition)
+		if err != nil {
+			return compiled, err
+		}
+
+		compiled.conditions = append(compiled.conditions, compiledCondition)
+	}
+
+	for _, group := range filters.Groups {
+		compiledGroup, err := e.compileFilters(group)
+		if err != nil {
+			return compiled, err
+		}
+
+		compiled.groups = append(compiled.groups, compiledGroup)
+	}
+
+	return
+}
+
+func (e *entity) compileCondition(condition Condition) (compiled compiledCondition, err error) {
+	compiled.field = e.lookupField(condition.Field)
+	if compiled.field == nil {
+		err = errors.New("condition field error " + condition.Field)
+		return
+	}
+
+	compiled.comparison = strings.Join(strings.Fields(strings.ToUpper(condition.Comparison)), " ")
+	if compiled.comparison == "<>" {
+		compiled.comparison = "!="
+	}
+
+	if !comparisons[compiled.comparison] {
+		err = errors.New("condition operator error " + condition.Comparison)
+		return
+	}
+
+	fieldType := compiled.field.fieldType
+
+	if (compiled.comparison == "LIKE" || compiled.comparison == "NOT LIKE") && fieldType.Kind() != reflect.String {
+		err = errors.New("condition operator error " + compiled.comparison + " " + compiled.field.name)
+		return
+	}
+
+	if compiled.comparison == "IN" || compiled.comparison == "NOT IN" || compiled.comparison == "BETWEEN" {
+		list := reflect.ValueOf(condition.Value)
+
+		if !list.IsValid() || (list.Kind() != reflect.Slice && list.Kind() != reflect.Array) {
+			err = fmt.Errorf("condition value error %s: %s needs a list of values", compiled.field.name, compiled.comparison)
+			return
+		}
+
+		if list.Len() == 0 || (compiled.comparison == "BETWEEN" && list.Len() != 2) {
+			err = fmt.Errorf("condition value error %s: wrong number of values for %s", compiled.field.name, compiled.comparison)
+			return
+		}
+
+		for i := 0; i < list.Len(); i++ {
+			value, coerceErr := coerceValue(list.Index(i).Interface(), fieldType)
+			if coerceErr != nil {
+				err = fmt.Errorf("condition value error %s: %s", compiled.field.name, coerceErr)
+				return
+			}
+
+			compiled.values = append(compiled.values, value)
+		}
+
+		return
+	}
+
+	compiled.value, err = coerceValue(condition.Value, fieldType)
+	if err != nil {
+		err = fmt.Errorf("condition value error %s: %s", compiled.field.name, err)
+	}
+
+	return
+}
+
+// coerceValue converts the value of a condition to the type of the field. Any
+// integer can be compared with an integer field if it fits in the type and the
+// strings are parsed, so the values of the query strings can be used directly
+func coerceValue(value interface{}, fieldType reflect.Type) (reflect.Value, error) {
+	v := reflect.ValueOf(value)
+
+	if !v.IsValid() {
+		return v, errors.New("missing value")
+	}
+
+	if v.Type() == fieldType {
+		return v, nil
+	}
+
+	if v.Kind() == reflect.String && fieldType.Kind() != reflect.String {
+		return parseValue(v.String(), fieldType)
+	}
+
+	if fieldType == timeType || v.Type() == timeType {
+		return v, fmt.Errorf("can't use %T as %s", value, fieldType)
+	}
+
+	switch {
+	case kindGroup(v.Kind()) == "integer" && kindGroup(fieldType.Kind()) == "integer":
+		converted := reflect.New(fieldType).Elem()
+
+		if isSignedInteger(v.Kind()) && isSignedInteger(fieldType.Kind()) {
+			if converted.OverflowInt(v.Int()) {
+				return v, fmt.Errorf("%v overflows %s", value, fieldType)
+			}
+
+			converted.SetInt(v.Int())
+		} else if isSignedInteger(v.Kind()) {
+			if v.Int() < 0 || converted.OverflowUint(uint64(v.Int())) {
+				return v, fmt.Errorf("%v overflows %s", value, fieldType)
+			}
+
+			converted.SetUint(uint64(v.Int()))
+		} else if isSignedInteger(fieldType.Kind()) {
+			if v.Uint() > 1<<63-1 || converted.OverflowInt(int64(v.Uint())) {
+				return v, fmt.Errorf("%v overflows %s", value, fieldType)
+			}
+
+			converted.SetInt(int64(v.Uint()))
+		} else {
+			if converted.OverflowUint(v.Uint()) {
+				return v, fmt.Errorf("%v overflows %s", value, fieldType)
+			}
+
+			converted.SetUint(v.Uint())
+		}
+
+		return converted, nil
+	case kindGroup(fieldType.Kind()) == "float" && (kindGroup(v.Kind()) == "float" || kindGroup(v.Kind()) == "integer"):
+		return v.Convert(fieldType), nil
+	case kindGroup(v.Kind()) != "" && kindGroup(v.Kind()) == kindGroup(fieldType.Kind()):
+		return v.Convert(fieldType), nil
+	}
+
+	return v, fmt.Errorf("can't use %T as %s", value, fieldType)
+}
+
+func parseValue(value string, fieldType reflect.Type) (reflect.Value, error) {
+	if fieldType == timeType {
+		for _, layout := range timeLayouts {
+			t, err := time.ParseInLocation(layout, value, time.UTC)
+			if err == nil {
+				return reflect.ValueOf(t), nil
+			}
+		}
+
+		return reflect.Value{}, fmt.Errorf("invalid date %q", value)
+	}
+
+	converted := reflect.New(fieldType).Elem()
+
+	switch kindGroup(fieldType.Kind()) {
+	case "integer":
+		if isSignedInteger(fieldType.Kind()) {
+			n, err := strconv.ParseInt(value, 10, fieldType.Bits())
+			if err != nil {
+				return converted, fmt.Errorf("invalid integer %q", value)
+			}
+
+			converted.SetInt(n)
+		} else {
+			n, err := strconv.ParseUint(value, 10, fieldType.Bits())
+			if err != nil {
+				return converted, fmt.Errorf("invalid integer %q", value)
+			}
+
+			converted.SetUint(n)
+		}
+	case "float":
+		n, err := strconv.ParseFloat(value, fieldType.Bits())
+		if err != nil {
+			return converted, fmt.Errorf("invalid number %q", value)
+		}
+
+		converted.SetFloat(n)
+	case "bool":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return converted, fmt.Errorf("invalid boolean %q", value)
+		}
+
+		converted.SetBool(b)
+	default:
+		return converted, fmt.Errorf("can't use %q as %s", value, fieldType)
+	}
+
+	return converted, nil
+}
+
+func isSignedInteger(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Int64
+}
+
+func (filters compiledFilters) match(item reflect.Value) bool {
+	if filters.empty() {
+		return true
+	}
+
+	for _, condition := range filters.conditions {
+		meetCondition := condition.match(item.Field(condition.field.index))
+
+		if meetCondition && filters.operator == "OR" {
+			return true
+		} else if !meetCondition && filters.operator == "AND" {
+			return false
+		}
+	}
+
+	for _, group := range filters.groups {
+		meetGroup := group.match(item)
+
+		if meetGroup && filters.operator == "OR" {
+			return true
+		} else if !meetGroup && filters.operator == "AND" {
+			return false
+		}
+	}
+
+	return filters.operator == "AND"
+}
+
+func (condition compiledCondition) match(value reflect.Value) bool {
+	switch condition.comparison {
+	case "LIKE":
+		return like(value.String(), condition.value.String())
+	case "NOT LIKE":
+		return !like(value.String(), condition.value.String())
+	case "IN", "NOT IN":
+		found := false
+
+		for _, conditionValue := range condition.values {
+			if compareValues(value, conditionValue) == 0 {
+				found = true
+				break
+			}
+		}
+
+		return found == (condition.comparison == "IN")
+	case "BETWEEN":
+		return compareValues(value, condition.values[0]) >= 0 && compareValues(value, condition.values[1]) <= 0
+	}
+
+	result := compareValues(value, condition.value)
+
+	switch condition.comparison {
+	case "=":
+		return result == 0
+	case "!=":
+		return result != 0
+	case ">":
+		return result > 0
+	case ">=":
+		return result >= 0
+	case "<":
+		return result < 0
+	case "<=":
+		return result <= 0
+	}
+
+	return false
+}
+
+// like supports the % wildcard at the start and the end of the pattern
+func like(value string, pattern string) bool {
+	if strings.HasPrefix(pattern, "%") && strings.HasSuffix(pattern, "%") && len(pattern) > 1 {
+		return strings.Contains(value, strings.TrimSuffix(strings.TrimPrefix(pattern, "%"), "%"))
+	} else if strings.HasPrefix(pattern, "%") {
+		return strings.HasSuffix(value, strings.TrimPrefix(pattern, "%"))
+	} else if strings.HasSuffix(pattern, "%") {
+		return strings.HasPrefix(value, strings.TrimSuffix(pattern, "%"))
+	}
+
+	return value == pattern
+}
+
+func kindGroup(kind reflect.Kind) string {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "bool"
+	}
+
+	return ""
+}
+
+// compareValues returns -1, 0 or 1 when a is less than, equal to or greater than b,
+// both values must have the same type
+func compareValues(a reflect.Value, b reflect.Value) int {
+	if a.Type() == timeType {
+		return a.Interface().(time.Time).Compare(b.Interface().(time.Time))
+	}
+
+	switch a.Kind() {
+	case reflect.String:
+		return strings.Compare(a.String(), b.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return compareOrdered(a.Int(), b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return compareOrdered(a.Uint(), b.Uint())
+	case reflect.Float32, reflect.Float64:
+		return compareOrdered(a.Float(), b.Float())
+	case reflect.Bool:
+		if a.Bool() == b.Bool() {
+			return 0
+		} else if b.Bool() {
+			return -1
+		}
+
+		return 1
+	}
+
+	return 0
+}
+
+func compareOrdered[V int64 | uint64 | float64](a V, b V) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,145 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCoerceValue(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     interface{}
+		fieldType reflect.Type
+		want      interface{}
+		wantErr   bool
+	}{
+		{name: "Same Type", value: 42, fieldType: reflect.TypeOf(0), want: 42},
+		{name: "Int To Int64", value: 42, fieldType: reflect.TypeOf(int64(0)), want: int64(42)},
+		{name: "Int64 To Int8 Overflow", value: int64(300), fieldType: reflect.TypeOf(int8(0)), wantErr: true},
+		{name: "Negative To Uint", value: -1, fieldType: reflect.TypeOf(uint(0)), wantErr: true},
+		{name: "Int To Float", value: 2, fieldType: reflect.TypeOf(0.0), want: 2.0},
+		{name: "String To Int", value: "-15", fieldType: reflect.TypeOf(0), want: -15},
+		{name: "String To Bool", value: "true", fieldType: reflect.TypeOf(false), want: true},
+		{name: "Invalid Integer", value: "ten", fieldType: reflect.TypeOf(0), wantErr: true},
+		{name: "Date", value: "2024-01-02", fieldType: timeType, want: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{name: "RFC 3339", value: "2024-01-02T03:04:05+02:00", fieldType: timeType, want: time.Date(2024, 1, 2, 1, 4, 5, 0, time.UTC)},
+		{name: "Invalid Date", value: "yesterday", fieldType: timeType, wantErr: true},
+		{name: "Int To Time", value: 5, fieldType: timeType, wantErr: true},
+		{name: "Int To String", value: 5, fieldType: reflect.TypeOf(""), wantErr: true},
+		{name: "Nil", value: nil, fieldType: reflect.TypeOf(""), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := coerceValue(tt.value, tt.fieldType)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("want error; got %v", value)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if wantTime, ok := tt.want.(time.Time); ok {
+				if !wantTime.Equal(value.Interface().(time.Time)) {
+					t.Errorf("want %v; got %v", tt.want, value)
+				}
+				return
+			}
+
+			if value.Interface() != tt.want {
+				t.Errorf("want %v (%T); got %v (%T)", tt.want, tt.want, value.Interface(), value.Interface())
+			}
+		})
+	}
+}
+
+func TestFilters(t *testing.T) {
+	database, teardown := newTestDB(t)
+	defer teardown()
+
+	err := database.InitDb()
+	if err != nil {
+		t.Fatalf("error creating database")
+	}
+
+	err = database.createBucket("motion_events")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := NewRepository[motionEvent](database)
+
+	for _, event := range []motionEvent{
+		{Camera: "garden", Level: 30, Recorded: true},
+		{Camera: "garage", Level: 10},
+		{Camera: "front door", Level: 20, Recorded: true},
+		{Camera: "back", Level: 40},
+	} {
+		_, err := events.Insert(event, []string{})
+		if err != nil {
+			t.Fatalf("Error saving item: %s", err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		filter     string
+		wantLevels []int
+		wantErr    bool
+	}{
+		{name: "Not Equal", filter: "camera != garden", wantLevels: []int{10, 20, 40}},
+		{name: "Greater Or Equal", filter: "level >= 20", wantLevels: []int{20, 30, 40}},
+		{name: "Less Or Equal", filter: "Level <= 20", wantLevels: []int{10, 20}},
+		{name: "BETWEEN", filter: "level BETWEEN 15 AND 30", wantLevels: []int{20, 30}},
+		{name: "IN", filter: "camera IN (back, garage, patio)", wantLevels: []int{10, 40}},
+		{name: "NOT IN", filter: "camera NOT IN (back, garage)", wantLevels: []int{20, 30}},
+		{name: "NOT LIKE", filter: "camera NOT LIKE 'ga%'", wantLevels: []int{20, 40}},
+		{name: "Bool", filter: "recorded = true", wantLevels: []int{20, 30}},
+		{name: "Nested Groups", filter: "(camera LIKE 'ga%' AND level > 15) OR (recorded = false AND level > 35)", wantLevels: []int{30, 40}},
+		{name: "Date", filter: "created > 2020-01-01 AND created < 2100-01-01T00:00:00Z", wantLevels: []int{10, 20, 30, 40}},
+		{name: "Invalid Integer", filter: "level > ten", wantErr: true},
+		{name: "Invalid Date", filter: "created > yesterday", wantErr: true},
+		{name: "LIKE On Integer", filter: "level LIKE '1%'", wantErr: true},
+		{name: "Unknown Field", filter: "size > 1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filters, err := ParseFilter(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			results, _, err := events.List(0, 10, filters, []string{}, SortBy{Field: "level", Direction: "ASC"})
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("want error; got nil")
+				}
+				return
+			}
+
+			var levels []int
+			for _, event := range results {
+				levels = append(levels, event.Level)
+			}
+
+			if err != nil || !reflect.DeepEqual(levels, tt.wantLevels) {
+				t.Errorf("want %v; got %v (%v)", tt.wantLevels, levels, err)
+			}
+		})
+	}
+
+	t.Run("Wrong Go Type", func(t *testing.T) {
+		_, _, err := events.List(0, 10, Filters{Operator: "AND", Conditions: []Condition{{Field: "Created", Comparison: "=", Value: 5}}}, []string{}, SortBy{Direction: "ASC"})
+		if err == nil {
+			t.Errorf("want error; got nil")
+		}
+	})
+}
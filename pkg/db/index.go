@@ -0,0 +1,435 @@
+package db
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// The secondary indexes are buckets named <bucket>_by_<Field>. The keys are the
+// encoded value of the field followed by the ID of the item and the values are
+// the ID, so a cursor over the index returns the items sorted by the field.
+// The encoding keeps the byte order of the values:
+//
+//	string     the bytes followed by a 0 byte
+//	integers   8 bytes big endian, the sign bit of signed integers is flipped
+//	floats     8 bytes of the IEEE 754 bits, flipped to sort negative numbers first
+//	bool       1 byte
+//	time.Time  8 bytes of the Unix seconds like integers and 4 bytes of nanoseconds
+//
+// Every prefix comparison of the encoded values gives the same result as comparing
+// the values, the range scans compare the start of the index keys with the bounds
+
+func (e *entity) indexBucket(field *entityField) string {
+	return e.bucket + "_by_" + field.name
+}
+
+func encodeIndexValue(value reflect.Value) []byte {
+	if value.Type() == timeType {
+		t := value.Interface().(time.Time)
+
+		encoded := make([]byte, 12)
+		binary.BigEndian.PutUint64(encoded, uint64(t.Unix())^(1<<63))
+		binary.BigEndian.PutUint32(encoded[8:], uint32(t.Nanosecond()))
+
+		return encoded
+	}
+
+	encoded := make([]byte, 8)
+
+	switch value.Kind() {
+	case reflect.String:
+		return append([]byte(value.String()), 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		binary.BigEndian.PutUint64(encoded, uint64(value.Int())^(1<<63))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		binary.BigEndian.PutUint64(encoded, value.Uint())
+	case reflect.Float32, reflect.Float64:
+		bits := math.Float64bits(value.Float())
+		if bits&(1<<63) == 0 {
+			bits ^= 1 << 63
+		} else {
+			bits = ^bits
+		}
+
+		binary.BigEndian.PutUint64(encoded, bits)
+	case reflect.Bool:
+		if value.Bool() {
+			return []byte{1}
+		}
+
+		return []byte{0}
+	}
+
+	return encoded
+}
+
+func (e *entity) indexKey(item reflect.Value, field *entityField) []byte {
+	return append(encodeIndexValue(item.Field(field.index)), item.Field(e.key.index).String()...)
+}
+
+// sortKey is the position of the item in the list sorted by the field, the
+// bucket of the items is sorted by ID so its key is the ID
+func (e *entity) sortKey(item reflect.Value, field *entityField) []byte {
+	if field.key {
+		return []byte(item.Field(e.key.index).String())
+	}
+
+	return e.indexKey(item, field)
+}
+
+// openIndex returns the index bucket of the field, when it doesn't exist it's
+// created with the items that are already saved
+func (repo *Repository[T]) openIndex(tx Tx, field *entityField) (Bucket, error) {
+	indexName := []byte(repo.entity.indexBucket(field))
+
+	if index := tx.Bucket(indexName); index != nil {
+		return index, nil
+	}
+
+	index, err := tx.CreateBucket(indexName)
+	if err != nil {
+		return nil, err
+	}
+
+	c := tx.Bucket([]byte(repo.entity.bucket)).Cursor()
+
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		item, err := repo.decode(k, v)
+		if err != nil {
+			return nil, err
+		}
+
+		err = index.Put(repo.entity.indexKey(reflect.ValueOf(item), field), k)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return index, nil
+}
+
+func (repo *Repository[T]) putIndexes(tx Tx, item reflect.Value) error {
+	for _, field := range repo.entity.indexes {
+		index, err := repo.openIndex(tx, field)
+		if err != nil {
+			return err
+		}
+
+		err = index.Put(repo.entity.indexKey(item, field), []byte(item.Field(repo.entity.key.index).String()))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (repo *Repository[T]) deleteIndexes(tx Tx, item reflect.Value) error {
+	for _, field := range repo.entity.indexes {
+		index, err := repo.openIndex(tx, field)
+		if err != nil {
+			return err
+		}
+
+		err = index.Delete(repo.entity.indexKey(item, field))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createIndexes builds the indexes that don't exist yet, the migrations call it
+// so the lists of an upgraded DB don't fall back to full scans
+func (repo *Repository[T]) createIndexes(tx Tx) error {
+	for _, field := range repo.entity.indexes {
+		_, err := repo.openIndex(tx, field)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (repo *Repository[T]) ensureIndexes() error {
+	return repo.boltdb.Store.Update(repo.createIndexes)
+}
+
+// indexBound is a lower or upper limit of the index keys
+type indexBound struct {
+	value     []byte
+	inclusive bool
+	upper     bool
+}
+
+func (bound indexBound) allows(key []byte) bool {
+	prefix := key
+	if len(prefix) > len(bound.value) {
+		prefix = prefix[:len(bound.value)]
+	}
+
+	result := bytes.Compare(prefix, bound.value)
+
+	if bound.upper {
+		return result < 0 || (bound.inclusive && result == 0)
+	}
+
+	return result > 0 || (bound.inclusive && result == 0)
+}
+
+// indexBounds converts the conditions on the indexed field to bounds of the
+// index keys, the other conditions and the groups are checked on the items. An
+// OR with more than one condition or group can't limit the range. The IDs of the
+// bucket of the items don't have the encoding of the indexes, their conditions
+// are checked on the items
+func indexBounds(field *entityField, filters compiledFilters) (bounds []indexBound, itemFilters compiledFilters) {
+	if filters.operator == "OR" && len(filters.conditions)+len(filters.groups) > 1 {
+		return nil, filters
+	}
+
+	itemFilters = compiledFilters{operator: filters.operator, groups: filters.groups}
+
+	for _, condition := range filters.conditions {
+		if condition.field != field || field.key {
+			itemFilters.conditions = append(itemFilters.conditions, condition)
+			continue
+		}
+
+		switch condition.comparison {
+		case "=":
+			encoded := encodeIndexValue(condition.value)
+			bounds = append(bounds, indexBound{value: encoded, inclusive: true}, indexBound{value: encoded, inclusive: true, upper: true})
+		case ">":
+			bounds = append(bounds, indexBound{value: encodeIndexValue(condition.value)})
+		case ">=":
+			bounds = append(bounds, indexBound{value: encodeIndexValue(condition.value), inclusive: true})
+		case "<":
+			bounds = append(bounds, indexBound{value: encodeIndexValue(condition.value), upper: true})
+		case "<=":
+			bounds = append(bounds, indexBound{value: encodeIndexValue(condition.value), inclusive: true, upper: true})
+		case "BETWEEN":
+			bounds = append(bounds, indexBound{value: encodeIndexValue(condition.values[0]), inclusive: true}, indexBound{value: encodeIndexValue(condition.values[1]), inclusive: true, upper: true})
+		case "LIKE":
+			pattern := condition.value.String()
+
+			if strings.HasPrefix(pattern, "%") || !strings.HasSuffix(pattern, "%") {
+				if strings.Contains(pattern, "%") {
+					itemFilters.conditions = append(itemFilters.conditions, condition)
+					continue
+				}
+
+				encoded := encodeIndexValue(condition.value)
+				bounds = append(bounds, indexBound{value: encoded, inclusive: true}, indexBound{value: encoded, inclusive: true, upper: true})
+				continue
+			}
+
+			prefix := []byte(strings.TrimSuffix(pattern, "%"))
+			bounds = append(bounds, indexBound{value: prefix, inclusive: true}, indexBound{value: prefix, inclusive: true, upper: true})
+		default:
+			itemFilters.conditions = append(itemFilters.conditions, condition)
+		}
+	}
+
+	return
+}
+
+// successor returns the smallest key that is greater than every key that starts
+// with the prefix, nil if there isn't one
+func successor(prefix []byte) []byte {
+	next := append([]byte{}, prefix...)
+
+	for i := len(next) - 1; i >= 0; i-- {
+		if next[i] < 0xff {
+			next[i]++
+			return next[:i+1]
+		}
+	}
+
+	return nil
+}
+
+// seekIndex moves the cursor to the first key of the scan, the bound with the
+// highest value is used for ascending scans and the lowest for descending ones
+func seekIndex(c Cursor, bounds []indexBound, descending bool) (k []byte, v []byte) {
+	var start *indexBound
+
+	for i, bound := range bounds {
+		if bound.upper != descending {
+			continue
+		}
+
+		if start == nil {
+			start = &bounds[i]
+			continue
+		}
+
+		result := bytes.Compare(bound.value, start.value)
+		if (!descending && result > 0) || (descending && result < 0) {
+			start = &bounds[i]
+		}
+	}
+
+	if start == nil {
+		if descending {
+			return c.Last()
+		}
+
+		return c.First()
+	}
+
+	if !descending {
+		if start.inclusive {
+			return c.Seek(start.value)
+		}
+
+		next := successor(start.value)
+		if next == nil {
+			return nil, nil
+		}
+
+		return c.Seek(next)
+	}
+
+	target := start.value
+	if start.inclusive {
+		target = successor(start.value)
+	}
+
+	if target == nil {
+		return c.Last()
+	}
+
+	k, v = c.Seek(target)
+	if k == nil {
+		return c.Last()
+	}
+
+	return c.Prev()
+}
+
+// seekAfter moves the cursor to the first key after the key of a cursor token
+func seekAfter(c Cursor, after []byte, descending bool) (k []byte, v []byte) {
+	k, v = c.Seek(after)
+
+	if descending {
+		if k == nil {
+			return c.Last()
+		}
+
+		return c.Prev()
+	}
+
+	if bytes.Equal(k, after) {
+		return c.Next()
+	}
+
+	return
+}
+
+// listIndexed walks the index of the sort field in order and stops at the end
+// of the range of the conditions. Only the items of the page are decoded when
+// all the conditions are on the indexed field, otherwise the items are decoded
+// to check the other conditions and count the total results. The cursor pages
+// stop at the first result after the page
+func (repo *Repository[T]) listIndexed(tx Tx, index Bucket, query *listQuery) (results []T, totalResults int64, next []byte, err error) {
+	data := tx.Bucket([]byte(repo.entity.bucket))
+
+	bounds, itemFilters := indexBounds(query.sortField, query.filters)
+
+	c := index.Cursor()
+
+	nextKey := c.Next
+	if query.descending {
+		nextKey = c.Prev
+	}
+
+	var k, v, lastKey []byte
+
+	if query.after != nil {
+		k, v = seekAfter(c, query.after, query.descending)
+	} else {
+		k, v = seekIndex(c, bounds, query.descending)
+	}
+
+	for ; k != nil; k, v = nextKey() {
+		inRange, afterRange := true, false
+
+		for _, bound := range bounds {
+			if !bound.allows(k) {
+				inRange = false
+
+				if bound.upper != query.descending {
+					afterRange = true
+				}
+			}
+		}
+
+		if afterRange {
+			break
+		} else if !inRange {
+			continue
+		}
+
+		inPage := totalResults >= int64(query.offset) && totalResults < int64(query.offset)+int64(query.limit)
+
+		if itemFilters.empty() && !inPage {
+			if query.paged {
+				next = lastKey
+				break
+			}
+
+			totalResults++
+			continue
+		}
+
+		id, itemJSON := k, v
+		if !query.sortField.key {
+			id, itemJSON = v, data.Get(v)
+		}
+
+		if itemJSON == nil {
+			err = fmt.Errorf("corrupt_%s_error: the index %s has the missing %s %s", repo.entity.item, repo.entity.indexBucket(query.sortField), repo.entity.item, id)
+			return
+		}
+
+		var item T
+
+		item, err = repo.decode(id, itemJSON)
+		if err != nil {
+			return
+		}
+
+		if !itemFilters.match(reflect.ValueOf(item)) {
+			continue
+		}
+
+		if !inPage && query.paged {
+			next = lastKey
+			break
+		}
+
+		if inPage {
+			results = append(results, repo.selectFields(item, query.returnFields))
+			lastKey = append([]byte{}, k...)
+		}
+
+		totalResults++
+	}
+
+	if !query.paged && totalResults > int64(query.offset)+int64(query.limit) {
+		next = lastKey
+	}
+
+	if query.paged {
+		totalResults = 0
+	}
+
+	return
+}
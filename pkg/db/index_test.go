@@ -0,0 +1,211 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestIndexes(t *testing.T) {
+	database, teardown := newMemoryTestDB(t)
+	defer teardown()
+
+	err := database.InitDb()
+	if err != nil {
+		t.Fatalf("error creating database")
+	}
+
+	err = database.createBucket("motion_events")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := NewRepository[motionEvent](database)
+
+	random := rand.New(rand.NewSource(1))
+	cameras := []string{"garden", "garage", "front door", "back", "gate", ""}
+
+	var ids []string
+
+	for i := 0; i < 300; i++ {
+		id, err := events.Insert(motionEvent{Camera: cameras[random.Intn(len(cameras))], Level: random.Intn(101) - 50, Recorded: random.Intn(2) == 0}, []string{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	for _, id := range ids[:50] {
+		_, err := events.Update(motionEvent{ID: id, Camera: cameras[random.Intn(len(cameras))], Level: random.Intn(101) - 50}, []string{"Camera", "Level"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, id := range ids[250:] {
+		_, err := events.Delete(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("Index Entries", func(t *testing.T) {
+		database.Store.View(func(tx Tx) error {
+			for _, bucket := range []string{"motion_events_by_Level", "motion_events_by_Camera"} {
+				if keys := countKeys(tx.Bucket([]byte(bucket))); keys != 250 {
+					t.Errorf("%s: want 250 keys; got %d", bucket, keys)
+				}
+			}
+
+			return nil
+		})
+	})
+
+	filters := []Filters{
+		{Operator: "AND"},
+		{Operator: "AND", Conditions: []Condition{{Field: "Level", Comparison: "=", Value: 10}}},
+		{Operator: "AND", Conditions: []Condition{{Field: "Level", Comparison: ">", Value: -10}, {Field: "Level", Comparison: "<", Value: 25}}},
+		{Operator: "AND", Conditions: []Condition{{Field: "Level", Comparison: ">", Value: 0}, {Field: "Recorded", Comparison: "=", Value: true}}},
+		{Operator: "OR", Conditions: []Condition{{Field: "Level", Comparison: "<", Value: -40}}},
+		{Operator: "OR", Conditions: []Condition{{Field: "Level", Comparison: "<", Value: -40}, {Field: "Camera", Comparison: "=", Value: "gate"}}},
+		{Operator: "AND", Conditions: []Condition{{Field: "Camera", Comparison: "LIKE", Value: "ga%"}}},
+		{Operator: "AND", Conditions: []Condition{{Field: "Camera", Comparison: "LIKE", Value: "%a%"}}},
+		{Operator: "AND", Conditions: []Condition{{Field: "Camera", Comparison: "=", Value: ""}}},
+		{Operator: "AND", Conditions: []Condition{{Field: "Camera", Comparison: ">", Value: "front door"}, {Field: "Camera", Comparison: "<", Value: "garden"}}},
+		{Operator: "AND", Conditions: []Condition{{Field: "Level", Comparison: ">=", Value: -10}, {Field: "Level", Comparison: "<=", Value: 25}}},
+		{Operator: "AND", Conditions: []Condition{{Field: "Level", Comparison: "BETWEEN", Value: []int{-5, 5}}, {Field: "Camera", Comparison: "!=", Value: "gate"}}},
+		{Operator: "AND", Conditions: []Condition{{Field: "Camera", Comparison: "IN", Value: []string{"gate", "back"}}}},
+		{Operator: "AND", Conditions: []Condition{{Field: "Level", Comparison: ">", Value: 0}}, Groups: []Filters{{Operator: "OR", Conditions: []Condition{{Field: "Camera", Comparison: "=", Value: "gate"}, {Field: "Recorded", Comparison: "=", Value: true}}}}},
+	}
+
+	pages := [][2]int{{0, 1000}, {0, 7}, {13, 5}, {240, 20}}
+
+	compare := func(t *testing.T) {
+		for filterIndex, filter := range filters {
+			for _, sortField := range []string{"Level", "Camera"} {
+				for _, descending := range []bool{false, true} {
+					for _, page := range pages {
+						name := fmt.Sprintf("Filter %d %s Desc %t Page %v", filterIndex, sortField, descending, page)
+
+						compiledFilters, err := events.entity.compileFilters(filter)
+						if err != nil {
+							t.Fatal(err)
+						}
+
+						field := events.entity.byName[sortField]
+
+						database.Store.View(func(tx Tx) error {
+							index := tx.Bucket([]byte(events.entity.indexBucket(field)))
+
+							query := &listQuery{sortField: field, descending: descending, offset: page[0], limit: page[1], filters: compiledFilters}
+
+							indexResults, indexTotal, indexNext, err := events.listIndexed(tx, index, query)
+							if err != nil {
+								t.Fatalf("%s: %s", name, err)
+							}
+
+							scanResults, scanTotal, scanNext, err := events.listScan(tx, query)
+							if err != nil {
+								t.Fatalf("%s: %s", name, err)
+							}
+
+							if indexTotal != scanTotal || !reflect.DeepEqual(indexResults, scanResults) || !bytes.Equal(indexNext, scanNext) {
+								t.Errorf("%s: index returned %d of %d results, scan %d of %d", name, len(indexResults), indexTotal, len(scanResults), scanTotal)
+							}
+
+							return nil
+						})
+					}
+				}
+			}
+		}
+	}
+
+	t.Run("Index And Scan Results", compare)
+
+	t.Run("Rebuild Index", func(t *testing.T) {
+		err := database.Store.Update(func(tx Tx) error {
+			return tx.DeleteBucket([]byte("motion_events_by_Level"))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, totalResults, err := events.List(0, 10, Filters{Operator: "AND"}, []string{}, SortBy{Field: "Level", Direction: "ASC"})
+		if err != nil || totalResults != 250 {
+			t.Errorf("list without index: want 250 results; got %d (%v)", totalResults, err)
+		}
+
+		err = events.ensureIndexes()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		compare(t)
+	})
+}
+
+func BenchmarkLocationList(b *testing.B) {
+	database, teardown := newMemoryTestDB(b)
+	defer teardown()
+
+	err := database.InitDb()
+	if err != nil {
+		b.Fatalf("error creating database")
+	}
+
+	devices := []string{"phone", "tablet", "car", "bike"}
+
+	for i := 0; i < 5000; i++ {
+		_, err := database.InsertLocation(Location{Device: devices[i%len(devices)], Latitude: i, Longitude: i, Battery: i % 100, DeviceTime: int64(1700000000 + i*60)}, []string{})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	benchmarks := []struct {
+		name    string
+		filters Filters
+		sortBy  SortBy
+	}{
+		{
+			name:    "Indexed Latest",
+			filters: Filters{Operator: "AND"},
+			sortBy:  SortBy{Field: "DeviceTime", Direction: "DESC"},
+		},
+		{
+			name:    "Indexed Range",
+			filters: Filters{Operator: "AND", Conditions: []Condition{{Field: "DeviceTime", Comparison: ">", Value: int64(1700060000)}, {Field: "DeviceTime", Comparison: "<", Value: int64(1700070000)}}},
+			sortBy:  SortBy{Field: "DeviceTime", Direction: "ASC"},
+		},
+		{
+			name:    "Indexed Device",
+			filters: Filters{Operator: "AND", Conditions: []Condition{{Field: "Device", Comparison: "=", Value: "car"}}},
+			sortBy:  SortBy{Field: "Device", Direction: "ASC"},
+		},
+		{
+			name:    "Scan",
+			filters: Filters{Operator: "AND"},
+			sortBy:  SortBy{Field: "Battery", Direction: "DESC"},
+		},
+		{
+			name:    "Scan Range",
+			filters: Filters{Operator: "AND", Conditions: []Condition{{Field: "DeviceTime", Comparison: ">", Value: int64(1700060000)}, {Field: "DeviceTime", Comparison: "<", Value: int64(1700070000)}}},
+			sortBy:  SortBy{Field: "Battery", Direction: "ASC"},
+		},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, _, err := database.GetLocationList(0, 20, bm.filters, []string{}, bm.sortBy)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
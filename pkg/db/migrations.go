@@ -0,0 +1,415 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// Migration changes the schema of the DB from the previous version to Version,
+// it runs inside the write transaction that saves its record, so a migration
+// that fails doesn't leave the DB half migrated
+type Migration struct {
+	Version     int
+	Description string
+	Migrate     func(tx Tx) error
+}
+
+// MigrationRecord is saved in the migrations bucket when a migration is applied,
+// Backup is the copy of the DB taken before migrating
+type MigrationRecord struct {
+	Version     int       `json:"version"`
+	Description string    `json:"description"`
+	Applied     time.Time `json:"applied"`
+	Backup      string    `json:"backup,omitempty"`
+}
+
+type MigrationStatus struct {
+	Version int
+	Latest  int
+	Applied []MigrationRecord
+	Pending []Migration
+}
+
+// migrations are sorted by version, the last one is DB_VERSION. New schema
+// changes are added at the end, the buckets created by the applied migrations
+// must not change. The index migrations intentionally rebuild from the current
+// schema: createIndexes builds the missing indexes of the current db tags, so
+// an old DB gets the indexes added later in its first index migration and the
+// later ones find them built. A new index still needs a new migration to be
+// built in the DBs that are already up to date
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "create the buckets of the devices and the media",
+		Migrate:     createBuckets("devices", "locations", "photos", "videos", "audios", "requests"),
+	},
+	{
+		Version:     2,
+		Description: "create the users and sessions buckets and move the admin account to the users bucket",
+		Migrate: func(tx Tx) error {
+			err := createBuckets("users", "sessions")(tx)
+			if err != nil {
+				return err
+			}
+
+			return migrateLegacyAdmin(tx)
+		},
+	},
+	{
+		Version:     3,
+		Description: "create the audit bucket",
+		Migrate:     createBuckets("audit"),
+	},
+	{
+		Version:     4,
+		Description: "build the secondary indexes",
+		Migrate: func(tx Tx) error {
+			indexedRepositories := []interface{ createIndexes(Tx) error }{NewRepository[Device](nil), NewRepository[Location](nil), NewRepository[Photo](nil), NewRepository[Video](nil), NewRepository[Audio](nil), NewRepository[Request](nil), NewRepository[Audit](nil)}
+
+			for _, repo := range indexedRepositories {
+				err := repo.createIndexes(tx)
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	},
+	{
+		Version:     5,
+		Description: "create the settings_history bucket",
+		Migrate:     createBuckets("settings_history"),
+	},
+	{
+		Version:     6,
+		Description: "create the device_nonces bucket and the index of the device keys",
+		Migrate: func(tx Tx) error {
+			err := createBuckets("device_nonces")(tx)
+			if err != nil {
+				return err
+			}
+
+			return NewRepository[Device](nil).createIndexes(tx)
+		},
+	},
+	{
+		Version:     7,
+		Description: "build the index of the device indexes of the locations",
+		Migrate: func(tx Tx) error {
+			return NewRepository[Location](nil).createIndexes(tx)
+		},
+	},
+	{
+		Version:     8,
+		Description: "create the geofences, geofence_events and geofence_states buckets",
+		Migrate: func(tx Tx) error {
+			err := createBuckets("geofences", "geofence_events", "geofence_states")(tx)
+			if err != nil {
+				return err
+			}
+
+			err = NewRepository[Geofence](nil).createIndexes(tx)
+			if err != nil {
+				return err
+			}
+
+			return NewRepository[GeofenceEvent](nil).createIndexes(tx)
+		},
+	},
+	{
+		Version:     9,
+		Description: "create the alerts and device_states buckets",
+		Migrate: func(tx Tx) error {
+			err := createBuckets("alerts", "device_states")(tx)
+			if err != nil {
+				return err
+			}
+
+			return NewRepository[Alert](nil).createIndexes(tx)
+		},
+	},
+	{
+		Version:     10,
+		Description: "build the index of the geotags of the photos and videos",
+		Migrate: func(tx Tx) error {
+			err := NewRepository[Photo](nil).createIndexes(tx)
+			if err != nil {
+				return err
+			}
+
+			return NewRepository[Video](nil).createIndexes(tx)
+		},
+	},
+	{
+		Version:     11,
+		Description: "build the index of the audio of the videos",
+		Migrate: func(tx Tx) error {
+			return NewRepository[Video](nil).createIndexes(tx)
+		},
+	},
+}
+
+var errDryRun = errors.New("dry run")
+
+func createBuckets(buckets ...string) func(tx Tx) error {
+	return func(tx Tx) error {
+		for _, bucket := range buckets {
+			_, err := tx.CreateBucketIfNotExists([]byte(bucket))
+			if err != nil {
+				return fmt.Errorf("create bucket %s: %s", bucket, err)
+			}
+		}
+
+		return nil
+	}
+}
+
+func latestVersion() int {
+	if len(migrations) == 0 {
+		return 0
+	}
+
+	return migrations[len(migrations)-1].Version
+}
+
+// readVersion returns the version saved in the configuration, the DBs created
+// before the migrations don't have it and are version 0
+func readVersion(tx Tx) (int, error) {
+	value := tx.Bucket([]byte("configuration")).Get([]byte("migrations"))
+	if value == nil {
+		return 0, nil
+	}
+
+	version, err := strconv.Atoi(string(value))
+	if err != nil {
+		return 0, fmt.Errorf("current migration error: %s", err)
+	}
+
+	if version > latestVersion() {
+		return version, fmt.Errorf("the DB version %d is newer than the version %d of gopicam", version, latestVersion())
+	}
+
+	return version, nil
+}
+
+func pendingMigrations(version int) (pending []Migration) {
+	for _, migration := range migrations {
+		if migration.Version > version {
+			pending = append(pending, migration)
+		}
+	}
+
+	return
+}
+
+// applyMigration runs the migration and saves its record and the new version
+func applyMigration(tx Tx, migration Migration, record MigrationRecord) error {
+	err := migration.Migrate(tx)
+	if err != nil {
+		return fmt.Errorf("migration %d error: %s", migration.Version, err)
+	}
+
+	history, err := tx.CreateBucketIfNotExists([]byte("migrations"))
+	if err != nil {
+		return err
+	}
+
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	err = history.Put(itob(migration.Version), recordJSON)
+	if err != nil {
+		return err
+	}
+
+	return tx.Bucket([]byte("configuration")).Put([]byte("migrations"), []byte(strconv.Itoa(migration.Version)))
+}
+
+// Migrate applies the pending migrations in order, each one in its own
+// transaction. A copy of the DB is saved next to the DB file before migrating
+// a DB that has data, the memory stores are migrated without copy. The
+// migrations stop at the first error
+func (boltdb *DB) Migrate() (applied []MigrationRecord, err error) {
+	var version int
+
+	err = boltdb.Store.View(func(tx Tx) error {
+		version, err = readVersion(tx)
+		return err
+	})
+	if err != nil {
+		return
+	}
+
+	pending := pendingMigrations(version)
+	if len(pending) == 0 {
+		return
+	}
+
+	var backupPath string
+
+	if version > 0 && boltdb.DBPath() != "" {
+		backupPath, err = boltdb.backupBeforeMigration(version)
+		if err != nil {
+			return
+		}
+
+		log.Println(logTag, "DB backup saved in", backupPath)
+	}
+
+	for _, migration := range pending {
+		record := MigrationRecord{
+			Version:     migration.Version,
+			Description: migration.Description,
+			Applied:     time.Now().UTC(),
+			Backup:      backupPath,
+		}
+
+		err = boltdb.Store.Update(func(tx Tx) error {
+			return applyMigration(tx, migration, record)
+		})
+		if err != nil {
+			return
+		}
+
+		log.Println(logTag, "applied migration", migration.Version, migration.Description)
+
+		applied = append(applied, record)
+	}
+
+	return
+}
+
+// MigrateDryRun runs the pending migrations in a transaction that is rolled
+// back, it returns the migrations that would be applied
+func (boltdb *DB) MigrateDryRun() (pending []Migration, err error) {
+	err = boltdb.Store.Update(func(tx Tx) error {
+		version, err := readVersion(tx)
+		if err != nil {
+			return err
+		}
+
+		pending = pendingMigrations(version)
+
+		for _, migration := range pending {
+			err = applyMigration(tx, migration, MigrationRecord{Version: migration.Version, Description: migration.Description})
+			if err != nil {
+				return err
+			}
+		}
+
+		return errDryRun
+	})
+
+	if err == errDryRun {
+		err = nil
+	}
+
+	return
+}
+
+// MigrationStatus returns the current version, the applied migrations and the
+// pending ones
+func (boltdb *DB) MigrationStatus() (status MigrationStatus, err error) {
+	status.Latest = latestVersion()
+
+	err = boltdb.Store.View(func(tx Tx) error {
+		status.Version, err = readVersion(tx)
+		if err != nil {
+			return err
+		}
+
+		history := tx.Bucket([]byte("migrations"))
+		if history == nil {
+			return nil
+		}
+
+		return history.ForEach(func(k, v []byte) error {
+			var record MigrationRecord
+
+			err := json.Unmarshal(v, &record)
+			if err != nil {
+				return err
+			}
+
+			status.Applied = append(status.Applied, record)
+
+			return nil
+		})
+	})
+
+	status.Pending = pendingMigrations(status.Version)
+
+	return
+}
+
+// backupBeforeMigration copies the DB to <DB file>.v<version>-<UTC time>.bak
+func (boltdb *DB) backupBeforeMigration(version int) (backupPath string, err error) {
+	backupPath = fmt.Sprintf("%s.v%d-%s.bak", boltdb.DBPath(), version, time.Now().UTC().Format("20060102T150405Z"))
+
+	backupFile, err := os.OpenFile(backupPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return
+	}
+
+	err = boltdb.Store.View(func(tx Tx) error {
+		_, err := tx.WriteTo(backupFile)
+		return err
+	})
+
+	closeErr := backupFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(backupPath)
+	}
+
+	return
+}
+
+// CheckFile checks a DB file that isn't open, like a backup before it's
+// restored. Its pages must be consistent and its version can't be newer than
+// DB_VERSION, the older versions are migrated when the DB is opened
+func CheckFile(path string) (version int, err error) {
+	boltDB, err := bbolt.Open(path, 0600, &bbolt.Options{ReadOnly: true, Timeout: time.Second})
+	if err != nil {
+		return
+	}
+	defer boltDB.Close()
+
+	err = boltDB.View(func(tx *bbolt.Tx) error {
+		var checkErr error
+
+		// read all the errors so the check finishes before the transaction is closed
+		for err := range tx.Check() {
+			if checkErr == nil {
+				checkErr = err
+			}
+		}
+
+		if checkErr != nil {
+			return checkErr
+		}
+
+		if tx.Bucket([]byte("configuration")) == nil {
+			return errors.New("missing configuration bucket")
+		}
+
+		version, checkErr = readVersion(boltTx{tx})
+
+		return checkErr
+	})
+
+	return
+}
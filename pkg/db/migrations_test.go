@@ -0,0 +1,229 @@
+package db
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jempe/gopicam/pkg/utils"
+)
+
+// openFixtureDB copies the DB created by the version 1 of gopicam to a temp
+// directory, it has the admin account in the configuration bucket, 3 photos,
+// 3 locations and a video
+func openFixtureDB(t *testing.T) *DB {
+	fixture, err := os.ReadFile(filepath.Join("testdata", "gopicam-v1.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbPath := filepath.Join(t.TempDir(), "gopicam.db")
+
+	err = os.WriteFile(dbPath, fixture, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	database := &DB{Path: dbPath}
+
+	err = database.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(database.Close)
+
+	return database
+}
+
+func TestMigrationsOrder(t *testing.T) {
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			t.Errorf("want migration %d; got %d", i+1, migration.Version)
+		}
+
+		if migration.Description == "" || migration.Migrate == nil {
+			t.Errorf("migration %d is incomplete", migration.Version)
+		}
+	}
+
+	if latestVersion() != DB_VERSION {
+		t.Errorf("want last migration %d; got %d", DB_VERSION, latestVersion())
+	}
+}
+
+func TestMigrateFromV1(t *testing.T) {
+	database := openFixtureDB(t)
+
+	status, err := database.MigrationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status.Version != 1 || len(status.Pending) != DB_VERSION-1 || len(status.Applied) != 0 {
+		t.Fatalf("want version 1 with %d pending migrations; got %+v", DB_VERSION-1, status)
+	}
+
+	t.Run("Dry Run", func(t *testing.T) {
+		pending, err := database.MigrateDryRun()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(pending) != DB_VERSION-1 {
+			t.Errorf("want %d migrations; got %d", DB_VERSION-1, len(pending))
+		}
+
+		if string(database.GetConfigValue("migrations")) != "1" || database.GetConfigValue("username") == nil {
+			t.Errorf("dry run changed the DB")
+		}
+	})
+
+	applied, err := database.Migrate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(applied) != DB_VERSION-1 {
+		t.Fatalf("want %d applied migrations; got %d", DB_VERSION-1, len(applied))
+	}
+
+	t.Run("History", func(t *testing.T) {
+		status, err := database.MigrationStatus()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if status.Version != DB_VERSION || len(status.Pending) != 0 {
+			t.Errorf("want version %d without pending migrations; got %+v", DB_VERSION, status)
+		}
+
+		for i, record := range status.Applied {
+			if record.Version != i+2 || record.Applied.IsZero() || record.Backup == "" {
+				t.Errorf("wrong migration record %+v", record)
+			}
+		}
+
+		if !utils.Exists(applied[0].Backup) {
+			t.Errorf("backup %s doesn't exist", applied[0].Backup)
+		}
+	})
+
+	t.Run("Admin Account", func(t *testing.T) {
+		user, err := database.GetUserByUsername("oldadmin")
+		if err != nil || len(user.Password) == 0 {
+			t.Errorf("admin account was not migrated")
+		}
+
+		if database.SetupRequired() {
+			t.Errorf("setup should not be required after migrating the admin account")
+		}
+	})
+
+	t.Run("Indexed Lists", func(t *testing.T) {
+		err := database.Store.View(func(tx Tx) error {
+			if tx.Bucket([]byte("photos_by_DeviceTime")) == nil || tx.Bucket([]byte("audit")) == nil {
+				return errors.New("missing buckets")
+			}
+
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		photos, total, err := database.GetPhotoList(0, 10, Filters{Operator: "AND"}, []string{"DeviceTime"}, SortBy{Field: "DeviceTime", Direction: "DESC"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if total != 3 || photos[0].DeviceTime != 1700000300 || photos[2].DeviceTime != 1700000100 {
+			t.Errorf("wrong photos %+v", photos)
+		}
+
+		_, total, err = database.GetLocationList(0, 10, Filters{Operator: "AND", Conditions: []Condition{{Field: "Device", Comparison: "=", Value: "phone"}}}, []string{}, SortBy{Field: "Device", Direction: "ASC"})
+		if err != nil || total != 3 {
+			t.Errorf("want 3 locations; got %d %v", total, err)
+		}
+	})
+
+	t.Run("Up To Date", func(t *testing.T) {
+		applied, err := database.Migrate()
+		if err != nil || len(applied) != 0 {
+			t.Errorf("want no migrations; got %d %v", len(applied), err)
+		}
+	})
+}
+
+func TestFailedMigration(t *testing.T) {
+	database := openFixtureDB(t)
+
+	registered := migrations
+	defer func() { migrations = registered }()
+
+	migrations = append(migrations[:2:2], Migration{
+		Version:     3,
+		Description: "failing migration",
+		Migrate: func(tx Tx) error {
+			_, err := tx.CreateBucket([]byte("half_migrated"))
+			if err != nil {
+				return err
+			}
+
+			return errors.New("failed")
+		},
+	})
+
+	_, err := database.Migrate()
+	if err == nil {
+		t.Fatal("want migration error; got nil")
+	}
+
+	if string(database.GetConfigValue("migrations")) != "2" {
+		t.Errorf("want version 2; got %s", database.GetConfigValue("migrations"))
+	}
+
+	database.Store.View(func(tx Tx) error {
+		if tx.Bucket([]byte("half_migrated")) != nil {
+			t.Errorf("failed migration was not rolled back")
+		}
+
+		return nil
+	})
+}
+
+func TestNewerDB(t *testing.T) {
+	database := openFixtureDB(t)
+
+	database.SetConfigValue("migrations", []byte("1000"))
+
+	_, err := database.Migrate()
+	if err == nil {
+		t.Errorf("want version error; got nil")
+	}
+}
+
+func TestCheckFile(t *testing.T) {
+	version, err := CheckFile(filepath.Join("testdata", "gopicam-v1.db"))
+	if err != nil || version != 1 {
+		t.Errorf("want version 1; got %d %v", version, err)
+	}
+
+	database := openFixtureDB(t)
+	database.SetConfigValue("migrations", []byte("1000"))
+	database.Close()
+
+	_, err = CheckFile(database.Path)
+	if err == nil {
+		t.Errorf("want version error; got nil")
+	}
+
+	notDB := filepath.Join(t.TempDir(), "not.db")
+	os.WriteFile(notDB, []byte("not a DB"), 0600)
+
+	_, err = CheckFile(notDB)
+	if err == nil {
+		t.Errorf("want invalid DB error; got nil")
+	}
+}
@@ -0,0 +1,720 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/jempe/gopicam/pkg/validator"
+)
+
+// Repository stores the items of an entity as JSON in its bucket and provides
+// the Get/Insert/Update/Delete/List operations. The entity is described with
+// db struct tags, the options are separated by commas:
+//
+//	key             the UUID that identifies the item, it's created on insert
+//	bucket=<name>   the bucket of the entity, it goes in the key field
+//	maxlength=<n>   maximum length of a string field
+//	created         timestamp saved when the item is inserted
+//	updated         timestamp saved when the item is inserted or updated
+//	sort            the list can be sorted by the field
+//	index           sortable field with a secondary index, see index.go
+//	secret          the field is saved but it can't be used in the filters, the
+//	                sort and the return fields and it isn't exported or
+//	                imported, like the password hashes. It can be a []byte
+//
+// Every exported field can be used in the filters and the return fields of the
+// list, the filters and the sort also accept the names of the JSON keys. The name of the item in the errors is the lowercase name of the type
+type Repository[T any] struct {
+	boltdb *DB
+	entity *entity
+}
+
+type entityField struct {
+	name      string
+	jsonName  string
+	index     int
+	fieldType reflect.Type
+	key       bool
+	maxLength int
+	created   bool
+	updated   bool
+	sortable  bool
+	indexed   bool
+	secret    bool
+}
+
+type entity struct {
+	item    string
+	bucket  string
+	key     *entityField
+	fields  []*entityField
+	indexes []*entityField
+	byName  map[string]*entityField
+	byJSON  map[string]*entityField
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+var bytesType = reflect.TypeOf([]byte{})
+
+// entities caches the parsed struct tags of every entity type
+var entities sync.Map
+
+func NewRepository[T any](boltdb *DB) *Repository[T] {
+	return &Repository[T]{boltdb: boltdb, entity: entityOf(reflect.TypeOf((*T)(nil)).Elem())}
+}
+
+// entityOf returns the description of the entity type, the tags are checked
+// the first time the type is used and a wrong tag is a programming error
+func entityOf(entityType reflect.Type) *entity {
+	if cached, ok := entities.Load(entityType); ok {
+		return cached.(*entity)
+	}
+
+	parsedEntity, err := parseEntity(entityType)
+	if err != nil {
+		panic(err)
+	}
+
+	cached, _ := entities.LoadOrStore(entityType, parsedEntity)
+
+	return cached.(*entity)
+}
+
+func parseEntity(entityType reflect.Type) (*entity, error) {
+	if entityType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("db: %s is not a struct", entityType)
+	}
+
+	parsedEntity := &entity{item: strings.ToLower(entityType.Name()), byName: map[string]*entityField{}, byJSON: map[string]*entityField{}}
+
+	for i := 0; i < entityType.NumField(); i++ {
+		structField := entityType.Field(i)
+		if !structField.IsExported() {
+			continue
+		}
+
+		jsonName, _, _ := strings.Cut(structField.Tag.Get("json"), ",")
+
+		field := &entityField{name: structField.Name, jsonName: jsonName, index: i, fieldType: structField.Type}
+
+		tag := structField.Tag.Get("db")
+
+		for _, option := range strings.Split(tag, ",") {
+			optionName, optionValue, _ := strings.Cut(strings.TrimSpace(option), "=")
+
+			switch optionName {
+			case "":
+			case "key":
+				field.key = true
+			case "bucket":
+				parsedEntity.bucket = optionValue
+			case "maxlength":
+				maxLength, err := strconv.Atoi(optionValue)
+				if err != nil || maxLength <= 0 {
+					return nil, fmt.Errorf("db: invalid maxlength of %s.%s", entityType.Name(), structField.Name)
+				}
+
+				field.maxLength = maxLength
+			case "created":
+				field.created = true
+			case "updated":
+				field.updated = true
+			case "sort":
+				field.sortable = true
+			case "index":
+				field.indexed = true
+				field.sortable = true
+			case "secret":
+				field.secret = true
+			default:
+				return nil, fmt.Errorf("db: unknown tag option %q of %s.%s", optionName, entityType.Name(), structField.Name)
+			}
+		}
+
+		if !supportedType(structField.Type) && !(field.secret && structField.Type == bytesType) {
+			return nil, fmt.Errorf("db: unsupported type %s of %s.%s", structField.Type, entityType.Name(), structField.Name)
+		}
+
+		if field.secret && (field.key || field.sortable) {
+			return nil, fmt.Errorf("db: the secret %s.%s can't be sorted", entityType.Name(), structField.Name)
+		}
+
+		if (field.key || field.maxLength > 0) && structField.Type.Kind() != reflect.String {
+			return nil, fmt.Errorf("db: %s.%s must be a string", entityType.Name(), structField.Name)
+		}
+
+		if (field.created || field.updated) && structField.Type != timeType {
+			return nil, fmt.Errorf("db: %s.%s must be a time.Time", entityType.Name(), structField.Name)
+		}
+
+		if field.key {
+			if parsedEntity.key != nil {
+				return nil, fmt.Errorf("db: %s has more than one key", entityType.Name())
+			}
+
+			parsedEntity.key = field
+		}
+
+		if field.indexed {
+			parsedEntity.indexes = append(parsedEntity.indexes, field)
+		}
+
+		parsedEntity.fields = append(parsedEntity.fields, field)
+
+		if field.secret {
+			continue
+		}
+
+		parsedEntity.byName[field.name] = field
+
+		if field.jsonName != "" && field.jsonName != "-" {
+			parsedEntity.byJSON[field.jsonName] = field
+		}
+	}
+
+	if parsedEntity.key == nil || parsedEntity.bucket == "" {
+		return nil, fmt.Errorf("db: %s needs a key field with the bucket name", entityType.Name())
+	}
+
+	return parsedEntity, nil
+}
+
+// lookupField finds a field by its name or the name of its JSON key
+func (e *entity) lookupField(name string) *entityField {
+	if field, ok := e.byName[name]; ok {
+		return field
+	}
+
+	return e.byJSON[name]
+}
+
+func supportedType(fieldType reflect.Type) bool {
+	if fieldType == timeType {
+		return true
+	}
+
+	switch fieldType.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	return false
+}
+
+// Bucket returns the name of the bucket of the entity
+func (repo *Repository[T]) Bucket() string {
+	return repo.entity.bucket
+}
+
+func (repo *Repository[T]) Get(id string) (item T, err error) {
+	validID, err := validator.UUID(id)
+	if !validID {
+		return
+	}
+
+	err = repo.boltdb.Store.View(func(tx Tx) error {
+		item, err = repo.get(tx, id)
+		return err
+	})
+
+	return
+}
+
+func (repo *Repository[T]) get(tx Tx, id string) (item T, err error) {
+	v := tx.Bucket([]byte(repo.entity.bucket)).Get([]byte(id))
+
+	if v == nil {
+		err = errors.New(repo.entity.item + " not found")
+		return
+	}
+
+	return repo.decode([]byte(id), v)
+}
+
+// decode unmarshals an item of the bucket, the error has the key of the item so
+// the corrupt records can be found and removed with gopicam db check
+func (repo *Repository[T]) decode(k []byte, v []byte) (item T, err error) {
+	err = json.Unmarshal(v, &item)
+	if err != nil {
+		err = fmt.Errorf("corrupt_%s_error: %s %s can't be decoded: %s", repo.entity.item, repo.entity.item, k, err)
+	}
+
+	return
+}
+
+// Insert saves the fields of the item, the other fields keep their zero value.
+// An empty fields list saves all of them. The ID is created if it's empty
+func (repo *Repository[T]) Insert(item T, fields []string) (id string, err error) {
+	return repo.insertWith(item, fields, nil)
+}
+
+// insertWith inserts the item after running check in the same transaction,
+// the item isn't saved when check returns an error
+func (repo *Repository[T]) insertWith(item T, fields []string, check func(tx Tx, item reflect.Value) error) (id string, err error) {
+	target, err := repo.prepareInsert(item, fields)
+	if err != nil {
+		return
+	}
+
+	err = repo.boltdb.Store.Update(func(tx Tx) error {
+		if check != nil {
+			err := check(tx, target)
+			if err != nil {
+				return err
+			}
+		}
+
+		return repo.insert(tx, target)
+	})
+
+	if err == nil {
+		id = target.Field(repo.entity.key.index).String()
+	}
+
+	return
+}
+
+// prepareInsert returns the validated copy of the fields of the item that is
+// saved by insert, with the new ID and the timestamps
+func (repo *Repository[T]) prepareInsert(item T, fields []string) (target reflect.Value, err error) {
+	source := reflect.ValueOf(&item).Elem()
+	key := source.Field(repo.entity.key.index)
+
+	if key.String() == "" {
+		newID, err := uuid.NewRandom()
+		if err != nil {
+			return target, err
+		}
+
+		key.SetString(newID.String())
+	}
+
+	target = reflect.New(source.Type()).Elem()
+
+	err = repo.copyFields(target, source, fields)
+	if err != nil {
+		return
+	}
+
+	now := time.Now().UTC()
+
+	for _, field := range repo.entity.fields {
+		if field.created || field.updated {
+			target.Field(field.index).Set(reflect.ValueOf(now))
+		}
+	}
+
+	return
+}
+
+// insert saves a new item and its index keys, the item must be validated
+func (repo *Repository[T]) insert(tx Tx, item reflect.Value) error {
+	validationErrorPrefix := "insert_" + repo.entity.item + "_error:"
+
+	id := item.Field(repo.entity.key.index).String()
+
+	b := tx.Bucket([]byte(repo.entity.bucket))
+
+	if b.Get([]byte(id)) != nil {
+		return errors.New(validationErrorPrefix + " " + repo.entity.item + " with ID " + id + " already exists")
+	}
+
+	itemJSON, err := json.Marshal(item.Interface())
+	if err != nil {
+		return err
+	}
+
+	err = repo.putIndexes(tx, item)
+	if err != nil {
+		return err
+	}
+
+	return b.Put([]byte(id), itemJSON)
+}
+
+// Update replaces the fields of a saved item, an empty fields list updates all of them
+func (repo *Repository[T]) Update(item T, fields []string) (rowsAffected int64, err error) {
+	source := reflect.ValueOf(&item).Elem()
+	id := source.Field(repo.entity.key.index).String()
+
+	validID, err := validator.UUID(id)
+	if !validID {
+		return
+	}
+
+	err = repo.boltdb.Store.Update(func(tx Tx) error {
+		itemData, err := repo.get(tx, id)
+		if err != nil {
+			return err
+		}
+
+		target := reflect.ValueOf(&itemData).Elem()
+
+		err = repo.deleteIndexes(tx, target)
+		if err != nil {
+			return err
+		}
+
+		err = repo.copyFields(target, source, fields)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+
+		for _, field := range repo.entity.fields {
+			if field.updated {
+				target.Field(field.index).Set(reflect.ValueOf(now))
+			}
+		}
+
+		itemJSON, err := json.Marshal(itemData)
+		if err != nil {
+			return err
+		}
+
+		err = repo.putIndexes(tx, target)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Bucket([]byte(repo.entity.bucket)).Put([]byte(id), itemJSON)
+		if err == nil {
+			rowsAffected = 1
+		}
+
+		return err
+	})
+
+	return
+}
+
+func (repo *Repository[T]) Delete(id string) (rowsAffected int64, err error) {
+	validID, err := validator.UUID(id)
+	if !validID {
+		return
+	}
+
+	err = repo.boltdb.Store.Update(func(tx Tx) error {
+		itemData, err := repo.get(tx, id)
+		if err != nil {
+			return err
+		}
+
+		err = repo.deleteIndexes(tx, reflect.ValueOf(itemData))
+		if err != nil {
+			return err
+		}
+
+		err = tx.Bucket([]byte(repo.entity.bucket)).Delete([]byte(id))
+		if err == nil {
+			rowsAffected = 1
+		}
+
+		return err
+	})
+
+	return
+}
+
+// DeleteWhere removes the items that meet the filters in one transaction
+func (repo *Repository[T]) DeleteWhere(filters Filters) (rowsAffected int64, err error) {
+	compiledFilters, err := repo.entity.compileFilters(filters)
+	if err != nil {
+		err = errors.New("delete_" + repo.entity.item + "_error: " + err.Error())
+		return
+	}
+
+	err = repo.boltdb.Store.Update(func(tx Tx) error {
+		b := tx.Bucket([]byte(repo.entity.bucket))
+
+		var deleteItems []T
+
+		c := b.Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			item, err := repo.decode(k, v)
+			if err != nil {
+				return err
+			}
+
+			if compiledFilters.match(reflect.ValueOf(item)) {
+				deleteItems = append(deleteItems, item)
+			}
+		}
+
+		for _, item := range deleteItems {
+			itemValue := reflect.ValueOf(item)
+
+			err := repo.deleteIndexes(tx, itemValue)
+			if err != nil {
+				return err
+			}
+
+			err = b.Delete([]byte(itemValue.Field(repo.entity.key.index).String()))
+			if err != nil {
+				return err
+			}
+		}
+
+		rowsAffected = int64(len(deleteItems))
+
+		return nil
+	})
+
+	return
+}
+
+// copyFields validates the key and the selected fields of the source and copies
+// them to the target, the timestamps are managed by the repository
+func (repo *Repository[T]) copyFields(target reflect.Value, source reflect.Value, fields []string) error {
+	for _, field := range repo.entity.fields {
+		if field.created || field.updated || (!field.key && !emptyOrContains(fields, field.name)) {
+			continue
+		}
+
+		err := repo.entity.validateField(source, field)
+		if err != nil {
+			return err
+		}
+
+		target.Field(field.index).Set(source.Field(field.index))
+	}
+
+	return nil
+}
+
+// Validate checks the selected fields of the item, an empty list checks all of them
+func (repo *Repository[T]) Validate(item T, fields []string) error {
+	source := reflect.ValueOf(item)
+
+	for _, field := range repo.entity.fields {
+		if !emptyOrContains(fields, field.name) {
+			continue
+		}
+
+		err := repo.entity.validateField(source, field)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validDefault checks a field of the item with the validation of its tags, it's
+// used by the Valid*Default methods of the entities
+func validDefault[T any](item T, field string) (validField bool, err error) {
+	err = NewRepository[T](nil).Validate(item, []string{field})
+
+	return err == nil, err
+}
+
+func (e *entity) validateField(source reflect.Value, field *entityField) error {
+	value := source.Field(field.index)
+
+	if field.key {
+		validField, _ := validator.UUID(value.String())
+		if !validField {
+			return errors.New("error_uuid__" + e.item + "___" + field.name)
+		}
+	}
+
+	if field.maxLength > 0 {
+		validField, _ := validator.MaxLength(value.String(), field.maxLength)
+		if !validField {
+			return errors.New("error_maxlength__" + e.item + "___" + field.name)
+		}
+	}
+
+	return nil
+}
+
+// listQuery holds the checked parameters of a list, the lists without a sort
+// field are sorted by ID
+type listQuery struct {
+	sortField    *entityField
+	descending   bool
+	offset       int
+	limit        int
+	after        []byte
+	paged        bool
+	filters      compiledFilters
+	returnFields []string
+}
+
+// List returns the items that meet the filters sorted by a sortable field, only
+// the ID and the returnFields are set in the results, an empty list returns all
+// the fields. totalResults is the number of items before applying offset and limit
+func (repo *Repository[T]) List(offset int, limit int, filters Filters, returnFields []string, sortBy SortBy) (results []T, totalResults int64, err error) {
+	query, err := repo.prepareList(filters, returnFields, sortBy)
+	if err != nil {
+		return
+	}
+
+	query.offset = offset
+	query.limit = limit
+
+	results, totalResults, _, err = repo.runList(query)
+
+	return
+}
+
+func (repo *Repository[T]) prepareList(filters Filters, returnFields []string, sortBy SortBy) (query *listQuery, err error) {
+	validationErrorPrefix := "get_" + repo.entity.item + "_error:"
+
+	if !(sortBy.Direction == "ASC" || sortBy.Direction == "DESC") {
+		err = errors.New(validationErrorPrefix + " sort Direction error")
+		return
+	}
+
+	query = &listQuery{sortField: repo.entity.key, descending: sortBy.Direction == "DESC", returnFields: returnFields}
+
+	if sortBy.Field != "" {
+		query.sortField = repo.entity.lookupField(sortBy.Field)
+		if query.sortField == nil || !query.sortField.sortable {
+			err = errors.New(validationErrorPrefix + " sort Field error " + sortBy.Field)
+			return
+		}
+	}
+
+	query.filters, err = repo.entity.compileFilters(filters)
+	if err != nil {
+		err = errors.New(validationErrorPrefix + " " + err.Error())
+	}
+
+	return
+}
+
+// runList uses the index of the sort field when it exists, the bucket of the
+// items is the index of the ID
+func (repo *Repository[T]) runList(query *listQuery) (results []T, totalResults int64, next []byte, err error) {
+	err = repo.boltdb.Store.View(func(tx Tx) error {
+		var index Bucket
+
+		if query.sortField.key {
+			index = tx.Bucket([]byte(repo.entity.bucket))
+		} else if query.sortField.indexed {
+			index = tx.Bucket([]byte(repo.entity.indexBucket(query.sortField)))
+		}
+
+		if index != nil {
+			results, totalResults, next, err = repo.listIndexed(tx, index, query)
+			return err
+		}
+
+		results, totalResults, next, err = repo.listScan(tx, query)
+		return err
+	})
+
+	return
+}
+
+// listScan reads every item of the bucket and sorts the items that meet the
+// conditions in memory, it's used when the sort field doesn't have an index
+func (repo *Repository[T]) listScan(tx Tx, query *listQuery) (results []T, totalResults int64, next []byte, err error) {
+	var itemList []T
+
+	c := tx.Bucket([]byte(repo.entity.bucket)).Cursor()
+
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		var item T
+
+		item, err = repo.decode(k, v)
+		if err != nil {
+			return
+		}
+
+		if query.after != nil {
+			result := bytes.Compare(repo.entity.sortKey(reflect.ValueOf(item), query.sortField), query.after)
+			if (!query.descending && result <= 0) || (query.descending && result >= 0) {
+				continue
+			}
+		}
+
+		if query.filters.match(reflect.ValueOf(item)) {
+			itemList = append(itemList, item)
+		}
+	}
+
+	// equal values are sorted by ID like the keys of the indexes
+	sort.Slice(itemList, func(i, j int) bool {
+		result := compareValues(reflect.ValueOf(itemList[i]).Field(query.sortField.index), reflect.ValueOf(itemList[j]).Field(query.sortField.index))
+		if result == 0 {
+			result = compareValues(reflect.ValueOf(itemList[i]).Field(repo.entity.key.index), reflect.ValueOf(itemList[j]).Field(repo.entity.key.index))
+		}
+
+		if query.descending {
+			return result > 0
+		}
+
+		return result < 0
+	})
+
+	for index, item := range itemList {
+		if index >= query.offset && index < (query.offset+query.limit) {
+			results = append(results, repo.selectFields(item, query.returnFields))
+		}
+	}
+
+	if len(results) > 0 && query.offset+query.limit < len(itemList) {
+		next = repo.entity.sortKey(reflect.ValueOf(itemList[query.offset+query.limit-1]), query.sortField)
+	}
+
+	if !query.paged {
+		totalResults = int64(len(itemList))
+	}
+
+	return
+}
+
+// first returns the first item by ID that meets the filters in the transaction
+func (repo *Repository[T]) first(tx Tx, filters Filters) (item T, found bool, err error) {
+	query, err := repo.prepareList(filters, []string{}, SortBy{Direction: "ASC"})
+	if err != nil {
+		return
+	}
+
+	query.limit = 1
+
+	results, _, _, err := repo.listScan(tx, query)
+	if err != nil || len(results) == 0 {
+		return
+	}
+
+	return results[0], true, nil
+}
+
+// selectFields returns a copy of the item with the ID and the returnFields,
+// the secret fields are only returned with all the fields
+func (repo *Repository[T]) selectFields(item T, returnFields []string) T {
+	if len(returnFields) == 0 {
+		return item
+	}
+
+	var result T
+
+	source := reflect.ValueOf(item)
+	target := reflect.ValueOf(&result).Elem()
+
+	for _, field := range repo.entity.fields {
+		if field.key || (!field.secret && Contains(returnFields, field.name)) {
+			target.Field(field.index).Set(source.Field(field.index))
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,219 @@
+package db
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type motionEvent struct {
+	ID       string    `json:"id"       db:"key,bucket=motion_events,sort"`
+	Camera   string    `json:"camera"   db:"maxlength=20,index"`
+	Level    int       `json:"level"    db:"index"`
+	Recorded bool      `json:"recorded"`
+	Created  time.Time `json:"created"  db:"created,sort"`
+}
+
+func TestRepository(t *testing.T) {
+	database, teardown := newTestDB(t)
+	defer teardown()
+
+	err := database.InitDb()
+	if err != nil {
+		t.Fatalf("error creating database")
+	}
+
+	err = database.createBucket("motion_events")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := NewRepository[motionEvent](database)
+
+	for _, event := range []motionEvent{
+		{Camera: "garden", Level: 30, Recorded: true},
+		{Camera: "garage", Level: 10},
+		{Camera: "front door", Level: 20, Recorded: true},
+	} {
+		_, err := events.Insert(event, []string{})
+		if err != nil {
+			t.Fatalf("Error saving item: %s", err)
+		}
+	}
+
+	t.Run("Validation", func(t *testing.T) {
+		_, err := events.Insert(motionEvent{Camera: "a camera name longer than 20 chars"}, []string{})
+		if err == nil || err.Error() != "error_maxlength__motionevent___Camera" {
+			t.Errorf("want maxlength error; got %v", err)
+		}
+
+		_, err = events.Insert(motionEvent{ID: "not-an-uuid"}, []string{})
+		if err == nil || err.Error() != "error_uuid__motionevent___ID" {
+			t.Errorf("want uuid error; got %v", err)
+		}
+	})
+
+	tests := []struct {
+		name       string
+		filters    Filters
+		sortBy     SortBy
+		wantLevels []int
+		wantErr    bool
+	}{
+		{
+			name:       "Sort ASC",
+			filters:    Filters{Operator: "AND"},
+			sortBy:     SortBy{Field: "Level", Direction: "ASC"},
+			wantLevels: []int{10, 20, 30},
+		},
+		{
+			name:       "Sort DESC",
+			filters:    Filters{Operator: "AND"},
+			sortBy:     SortBy{Field: "Camera", Direction: "DESC"},
+			wantLevels: []int{30, 10, 20},
+		},
+		{
+			name:       "LIKE",
+			filters:    Filters{Operator: "AND", Conditions: []Condition{{Field: "Camera", Comparison: "LIKE", Value: "ga%"}}},
+			sortBy:     SortBy{Field: "Level", Direction: "ASC"},
+			wantLevels: []int{10, 30},
+		},
+		{
+			name:       "Int64 Value",
+			filters:    Filters{Operator: "AND", Conditions: []Condition{{Field: "Level", Comparison: ">", Value: int64(15)}, {Field: "Recorded", Comparison: "=", Value: true}}},
+			sortBy:     SortBy{Field: "Level", Direction: "ASC"},
+			wantLevels: []int{20, 30},
+		},
+		{
+			name:       "OR",
+			filters:    Filters{Operator: "OR", Conditions: []Condition{{Field: "Level", Comparison: "<", Value: 15}, {Field: "Camera", Comparison: "=", Value: "garden"}}},
+			sortBy:     SortBy{Field: "Level", Direction: "DESC"},
+			wantLevels: []int{30, 10},
+		},
+		{
+			name:    "Wrong Value Type",
+			filters: Filters{Operator: "AND", Conditions: []Condition{{Field: "Level", Comparison: "=", Value: "ten"}}},
+			sortBy:  SortBy{Field: "Level", Direction: "ASC"},
+			wantErr: true,
+		},
+		{
+			name:    "Unknown Field",
+			filters: Filters{Operator: "AND", Conditions: []Condition{{Field: "Size", Comparison: "=", Value: 1}}},
+			sortBy:  SortBy{Field: "Level", Direction: "ASC"},
+			wantErr: true,
+		},
+		{
+			name:    "Not Sortable",
+			filters: Filters{Operator: "AND"},
+			sortBy:  SortBy{Field: "Recorded", Direction: "ASC"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, totalResults, err := events.List(0, 10, tt.filters, []string{}, tt.sortBy)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("want error; got nil")
+				}
+				return
+			}
+
+			var levels []int
+			for _, event := range results {
+				levels = append(levels, event.Level)
+			}
+
+			if err != nil || totalResults != int64(len(tt.wantLevels)) || !reflect.DeepEqual(levels, tt.wantLevels) {
+				t.Errorf("want %v; got %v (%v)", tt.wantLevels, levels, err)
+			}
+		})
+	}
+
+	t.Run("Return Fields And Pagination", func(t *testing.T) {
+		results, totalResults, err := events.List(1, 1, Filters{Operator: "AND"}, []string{"Camera"}, SortBy{Field: "Level", Direction: "ASC"})
+
+		if err != nil || totalResults != 3 || len(results) != 1 {
+			t.Fatalf("want 1 of 3 results; got %d of %d (%v)", len(results), totalResults, err)
+		}
+
+		if results[0].ID == "" || results[0].Camera != "front door" || results[0].Level != 0 || !results[0].Created.IsZero() {
+			t.Errorf("want only ID and Camera; got %+v", results[0])
+		}
+	})
+
+	t.Run("Update And Delete", func(t *testing.T) {
+		results, _, _ := events.List(0, 1, Filters{Operator: "AND"}, []string{}, SortBy{Field: "Level", Direction: "ASC"})
+
+		event := results[0]
+		event.Level = 99
+		event.Camera = "changed"
+
+		_, err := events.Update(event, []string{"Level"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		savedEvent, err := events.Get(event.ID)
+		if err != nil || savedEvent.Level != 99 || savedEvent.Camera != "garage" || !savedEvent.Created.Equal(event.Created) {
+			t.Errorf("Update didn't save only the selected fields: %+v", savedEvent)
+		}
+
+		rowsAffected, err := events.Delete(event.ID)
+		if err != nil || rowsAffected != 1 {
+			t.Errorf("Error deleting item")
+		}
+
+		_, err = events.Get(event.ID)
+		if err == nil {
+			t.Errorf("deleted item still exists")
+		}
+	})
+
+	t.Run("Valid Default", func(t *testing.T) {
+		audio := Audio{ID: "not-an-uuid", FileType: strings.Repeat("a", 101)}
+
+		if valid, err := audio.ValidIDDefault(); valid || err == nil || err.Error() != "error_uuid__audio___ID" {
+			t.Errorf("want uuid error; got %v %v", valid, err)
+		}
+
+		if valid, err := audio.ValidFileTypeDefault(); valid || err == nil || err.Error() != "error_maxlength__audio___FileType" {
+			t.Errorf("want maxlength error; got %v %v", valid, err)
+		}
+
+		if valid, err := audio.ValidDeviceTimeDefault(); !valid || err != nil {
+			t.Errorf("want valid DeviceTime; got %v %v", valid, err)
+		}
+	})
+
+	t.Run("Invalid Tags", func(t *testing.T) {
+		type noKey struct {
+			Name string `db:"maxlength=10"`
+		}
+
+		type wrongType struct {
+			ID   string `db:"key,bucket=wrong"`
+			Size int    `db:"maxlength=10"`
+		}
+
+		type sortedSecret struct {
+			ID     string `db:"key,bucket=wrong"`
+			Secret string `db:"secret,sort"`
+		}
+
+		type plainBytes struct {
+			ID   string `db:"key,bucket=wrong"`
+			Data []byte
+		}
+
+		for _, entityType := range []reflect.Type{reflect.TypeOf(noKey{}), reflect.TypeOf(wrongType{}), reflect.TypeOf(sortedSecret{}), reflect.TypeOf(plainBytes{})} {
+			_, err := parseEntity(entityType)
+			if err == nil {
+				t.Errorf("%s: want error; got nil", entityType.Name())
+			}
+		}
+	})
+}
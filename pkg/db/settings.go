@@ -0,0 +1,545 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// settingsKey is the key of the settings in the configuration bucket
+const settingsKey = "settings"
+
+// settingsEnvPrefix is the prefix of the environment variables of the settings,
+// the variable of server.port is GOPICAM_SERVER_PORT
+const settingsEnvPrefix = "GOPICAM_"
+
+// Duration is a time.Duration saved as text like 72h0m0s, the settings files
+// and the API use the format of time.ParseDuration
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var text string
+
+	err := json.Unmarshal(data, &text)
+	if err != nil {
+		return errors.New("the duration must be a string like 72h")
+	}
+
+	parsed, err := time.ParseDuration(text)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(parsed)
+
+	return nil
+}
+
+// Settings are saved in the configuration bucket, the fields that are not saved
+// have the value of DefaultSettings. The environment variables and the flags
+// override the saved settings, the changes are applied when gopicam starts
+type Settings struct {
+	Server       ServerSettings       `json:"server"`
+	Sessions     SessionSettings      `json:"sessions"`
+	Passwords    PasswordSettings     `json:"passwords"`
+	Motion       MotionSettings       `json:"motion"`
+	Retention    RetentionSettings    `json:"retention"`
+	Backups      BackupSettings       `json:"backups"`
+	Presence     PresenceSettings     `json:"presence"`
+	Geotag       GeotagSettings       `json:"geotag"`
+	Audio        AudioSettings        `json:"audio"`
+	Speaker      SpeakerSettings      `json:"speaker"`
+	Integrations IntegrationsSettings `json:"integrations"`
+}
+
+type ServerSettings struct {
+	Port int `json:"port"`
+	// Insecure runs the server without TLS
+	Insecure bool `json:"insecure"`
+	// CertFile and KeyFile are relative to the config folder
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+}
+
+type SessionSettings struct {
+	IdleTimeout Duration `json:"idle_timeout"`
+	Lifetime    Duration `json:"lifetime"`
+}
+
+type PasswordSettings struct {
+	MinLength   int    `json:"min_length"`
+	AllowCommon bool   `json:"allow_common"`
+	Hash        string `json:"hash"`
+	BcryptCost  int    `json:"bcrypt_cost"`
+}
+
+type MotionSettings struct {
+	// Record starts a video when motion is detected
+	Record bool `json:"record"`
+	// StopDelay is the time without motion before the video is stopped
+	StopDelay Duration `json:"stop_delay"`
+}
+
+type RetentionSettings struct {
+	// Audit is the age of the deleted audit log entries, 0 keeps them forever
+	Audit Duration `json:"audit"`
+}
+
+type BackupSettings struct {
+	// Dir is the backups folder of the config folder when it's empty
+	Dir string `json:"dir"`
+	// Interval of the scheduled backups, 0 disables them
+	Interval       Duration `json:"interval"`
+	Keep           int      `json:"keep"`
+	PassphraseFile string   `json:"passphrase_file"`
+}
+
+type PresenceSettings struct {
+	// LowBattery is the battery percent of the low battery alerts, 0 disables them
+	LowBattery int `json:"low_battery"`
+	// Timeout is the time without locations before a device is not reporting,
+	// 0 disables the alerts
+	Timeout Duration `json:"timeout"`
+	// HomeWifi is the SSID of the home Wi-Fi, the devices that join or leave it
+	// raise alerts
+	HomeWifi string `json:"home_wifi"`
+}
+
+type GeotagSettings struct {
+	// Device is the ID of the device whose locations geotag the photos and
+	// videos, empty disables the geotagging
+	Device string `json:"device"`
+	// Window is the maximum time between the media and the locations
+	Window Duration `json:"window"`
+	// Interval of the geotagging of the new media
+	Interval Duration `json:"interval"`
+}
+
+type AudioSettings struct {
+	// Record records the audio of the ALSA device in WAV files
+	Record bool `json:"record"`
+	// Device is the ALSA device, like default or plughw:1,0. sine records a
+	// tone for the computers without a microphone
+	Device     string `json:"device"`
+	SampleRate int    `json:"sample_rate"`
+	Channels   int    `json:"channels"`
+	// SegmentLength is the duration of the WAV files
+	SegmentLength Duration `json:"segment_length"`
+	// Trigger raises a motion when the sound is louder than Threshold during
+	// Attack, TriggerRecord also starts a video
+	Trigger       bool `json:"trigger"`
+	TriggerRecord bool `json:"trigger_record"`
+	// Level is the rms or peak level compared with Threshold in dBFS
+	Level     string   `json:"level"`
+	Threshold int      `json:"threshold"`
+	Attack    Duration `json:"attack"`
+	// Release is the time below the threshold before the trigger is armed again
+	Release Duration `json:"release"`
+	// LevelLog is the interval of the log of the loudest levels, 0 disables it
+	LevelLog Duration `json:"level_log"`
+	// Mux muxes the recorded audio in the videos with the Muxer, auto, ffmpeg
+	// or mp4box. MuxDryRun logs the videos without changing them
+	Mux       bool   `json:"mux"`
+	Muxer     string `json:"muxer"`
+	MuxDryRun bool   `json:"mux_dry_run"`
+}
+
+type SpeakerSettings struct {
+	// Enabled plays the talks of the users on the ALSA device
+	Enabled bool `json:"enabled"`
+	// Device is the ALSA device, like default or plughw:1,0. loopback writes the
+	// talks in talk.wav of the config folder
+	Device string `json:"device"`
+	// Buffer is the audio received before the playback starts
+	Buffer Duration `json:"buffer"`
+	// Volume is the volume in percent when gopicam starts
+	Volume int `json:"volume"`
+}
+
+type IntegrationsSettings struct {
+	// MotionWebhook receives a POST request when motion is detected
+	MotionWebhook string `json:"motion_webhook"`
+}
+
+// SettingChange is the old and new value of a setting
+type SettingChange struct {
+	Setting string `json:"setting"`
+	Old     string `json:"old"`
+	New     string `json:"new"`
+}
+
+// SettingsRecord is saved in the settings_history bucket every time the
+// settings change, it has all the settings so any version can be restored
+type SettingsRecord struct {
+	Version  int             `json:"version"`
+	User     string          `json:"user"`
+	Comment  string          `json:"comment,omitempty"`
+	Changes  []SettingChange `json:"changes"`
+	Settings Settings        `json:"settings"`
+	Created  time.Time       `json:"created"`
+}
+
+func DefaultSettings() Settings {
+	return Settings{
+		Server:    ServerSettings{Port: 443, CertFile: "server.crt", KeyFile: "server.key"},
+		Sessions:  SessionSettings{IdleTimeout: Duration(72 * time.Hour), Lifetime: Duration(30 * 24 * time.Hour)},
+		Passwords: PasswordSettings{MinLength: 10, Hash: "argon2id", BcryptCost: 12},
+		Motion:    MotionSettings{Record: true, StopDelay: Duration(10 * time.Second)},
+		Retention: RetentionSettings{Audit: Duration(90 * 24 * time.Hour)},
+		Backups:   BackupSettings{Interval: Duration(24 * time.Hour), Keep: 7},
+		Presence:  PresenceSettings{LowBattery: 20, Timeout: Duration(30 * time.Minute)},
+		Geotag:    GeotagSettings{Window: Duration(5 * time.Minute), Interval: Duration(10 * time.Minute)},
+		Audio: AudioSettings{
+			Device:        "default",
+			SampleRate:    16000,
+			Channels:      1,
+			SegmentLength: Duration(5 * time.Minute),
+			TriggerRecord: true,
+			Level:         "rms",
+			Threshold:     -20,
+			Attack:        Duration(100 * time.Millisecond),
+			Release:       Duration(2 * time.Second),
+			Muxer:         "auto",
+		},
+		Speaker: SpeakerSettings{Device: "default", Buffer: Duration(200 * time.Millisecond), Volume: 100},
+	}
+}
+
+func settingError(setting string, message string) error {
+	return errors.New("settings_error: " + setting + " " + message)
+}
+
+// Validate checks every setting, the error has the name of the first invalid setting
+func (settings Settings) Validate() error {
+	switch {
+	case settings.Server.Port < 1 || settings.Server.Port > 65535:
+		return settingError("server.port", "must be between 1 and 65535")
+	case !settings.Server.Insecure && (settings.Server.CertFile == "" || settings.Server.KeyFile == ""):
+		return settingError("server.cert_file", "and server.key_file are required without server.insecure")
+	case settings.Sessions.IdleTimeout < Duration(time.Minute):
+		return settingError("sessions.idle_timeout", "must be at least 1m")
+	case settings.Sessions.Lifetime < settings.Sessions.IdleTimeout:
+		return settingError("sessions.lifetime", "can't be shorter than sessions.idle_timeout")
+	case settings.Passwords.MinLength < 8 || settings.Passwords.MinLength > 128:
+		return settingError("passwords.min_length", "must be between 8 and 128")
+	case settings.Passwords.Hash != "argon2id" && settings.Passwords.Hash != "bcrypt":
+		return settingError("passwords.hash", "must be argon2id or bcrypt")
+	case settings.Passwords.BcryptCost < 10 || settings.Passwords.BcryptCost > 31:
+		return settingError("passwords.bcrypt_cost", "must be between 10 and 31")
+	case settings.Motion.StopDelay < Duration(time.Second) || settings.Motion.StopDelay > Duration(time.Hour):
+		return settingError("motion.stop_delay", "must be between 1s and 1h")
+	case settings.Retention.Audit < 0:
+		return settingError("retention.audit", "can't be negative")
+	case settings.Backups.Interval != 0 && settings.Backups.Interval < Duration(time.Minute):
+		return settingError("backups.interval", "must be 0 or at least 1m")
+	case settings.Backups.Keep < 1:
+		return settingError("backups.keep", "must be at least 1")
+	case settings.Presence.LowBattery < 0 || settings.Presence.LowBattery > 100:
+		return settingError("presence.low_battery", "must be between 0 and 100")
+	case settings.Presence.Timeout != 0 && settings.Presence.Timeout < Duration(time.Minute):
+		return settingError("presence.timeout", "must be 0 or at least 1m")
+	case settings.Geotag.Window < Duration(time.Second) || settings.Geotag.Window > Duration(24*time.Hour):
+		return settingError("geotag.window", "must be between 1s and 24h")
+	case settings.Geotag.Interval < Duration(time.Minute):
+		return settingError("geotag.interval", "must be at least 1m")
+	case settings.Audio.Record && settings.Audio.Device == "":
+		return settingError("audio.device", "is required with audio.record")
+	case settings.Audio.SampleRate < 8000 || settings.Audio.SampleRate > 192000:
+		return settingError("audio.sample_rate", "must be between 8000 and 192000")
+	case settings.Audio.Channels < 1 || settings.Audio.Channels > 2:
+		return settingError("audio.channels", "must be 1 or 2")
+	case settings.Audio.SegmentLength < Duration(10*time.Second) || settings.Audio.SegmentLength > Duration(time.Hour):
+		return settingError("audio.segment_length", "must be between 10s and 1h")
+	case settings.Audio.Level != "rms" && settings.Audio.Level != "peak":
+		return settingError("audio.level", "must be rms or peak")
+	case settings.Audio.Threshold < -96 || settings.Audio.Threshold > 0:
+		return settingError("audio.threshold", "must be between -96 and 0")
+	case settings.Audio.Attack < 0 || settings.Audio.Attack > Duration(10*time.Second):
+		return settingError("audio.attack", "must be between 0s and 10s")
+	case settings.Audio.Release < 0 || settings.Audio.Release > Duration(time.Hour):
+		return settingError("audio.release", "must be between 0s and 1h")
+	case settings.Audio.LevelLog != 0 && settings.Audio.LevelLog < Duration(time.Second):
+		return settingError("audio.level_log", "must be 0 or at least 1s")
+	case settings.Audio.Muxer != "auto" && settings.Audio.Muxer != "ffmpeg" && settings.Audio.Muxer != "mp4box":
+		return settingError("audio.muxer", "must be auto, ffmpeg or mp4box")
+	case settings.Speaker.Enabled && settings.Speaker.Device == "":
+		return settingError("speaker.device", "is required with speaker.enabled")
+	case settings.Speaker.Buffer < 0 || settings.Speaker.Buffer > Duration(5*time.Second):
+		return settingError("speaker.buffer", "must be between 0s and 5s")
+	case settings.Speaker.Volume < 0 || settings.Speaker.Volume > 100:
+		return settingError("speaker.volume", "must be between 0 and 100")
+	}
+
+	if settings.Integrations.MotionWebhook != "" {
+		webhook, err := url.Parse(settings.Integrations.MotionWebhook)
+		if err != nil || (webhook.Scheme != "http" && webhook.Scheme != "https") || webhook.Host == "" {
+			return settingError("integrations.motion_webhook", "must be a http or https URL")
+		}
+	}
+
+	return nil
+}
+
+// settingFields returns the fields of the settings by name, the names are the
+// JSON keys of the group and the field joined by a dot, like server.port
+func (settings *Settings) settingFields() (names []string, fields map[string]reflect.Value) {
+	fields = make(map[string]reflect.Value)
+
+	groups := reflect.ValueOf(settings).Elem()
+
+	for i := 0; i < groups.NumField(); i++ {
+		group := groups.Field(i)
+		groupName := jsonName(groups.Type().Field(i))
+
+		for j := 0; j < group.NumField(); j++ {
+			name := groupName + "." + jsonName(group.Type().Field(j))
+
+			names = append(names, name)
+			fields[name] = group.Field(j)
+		}
+	}
+
+	return
+}
+
+func jsonName(field reflect.StructField) string {
+	return strings.Split(field.Tag.Get("json"), ",")[0]
+}
+
+// Get returns the value of a setting as text
+func (settings Settings) Get(name string) (string, error) {
+	_, fields := settings.settingFields()
+
+	field, ok := fields[name]
+	if !ok {
+		return "", settingError(name, "doesn't exist")
+	}
+
+	return fmt.Sprint(field.Interface()), nil
+}
+
+// Set parses the value of a setting, the durations use the format of
+// time.ParseDuration. The settings are not validated
+func (settings *Settings) Set(name string, value string) error {
+	_, fields := settings.settingFields()
+
+	field, ok := fields[name]
+	if !ok {
+		return settingError(name, "doesn't exist")
+	}
+
+	switch field.Interface().(type) {
+	case Duration:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return settingError(name, "must be a duration like 72h")
+		}
+
+		field.SetInt(int64(parsed))
+	case int:
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return settingError(name, "must be an integer")
+		}
+
+		field.SetInt(int64(parsed))
+	case bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return settingError(name, "must be true or false")
+		}
+
+		field.SetBool(parsed)
+	case string:
+		field.SetString(value)
+	}
+
+	return nil
+}
+
+// EnvName is the name of the environment variable of a setting
+func EnvName(name string) string {
+	return settingsEnvPrefix + strings.ToUpper(strings.ReplaceAll(name, ".", "_"))
+}
+
+// ApplyEnv sets the settings that have an environment variable, lookup is
+// usually os.LookupEnv. It returns the names of the settings that were set
+func (settings *Settings) ApplyEnv(lookup func(key string) (string, bool)) (applied []string, err error) {
+	names, _ := settings.settingFields()
+
+	for _, name := range names {
+		value, ok := lookup(EnvName(name))
+		if !ok {
+			continue
+		}
+
+		err = settings.Set(name, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", EnvName(name), err)
+		}
+
+		applied = append(applied, name)
+	}
+
+	return
+}
+
+// Diff returns the settings that are different in other
+func (settings Settings) Diff(other Settings) (changes []SettingChange) {
+	names, fields := settings.settingFields()
+	_, otherFields := other.settingFields()
+
+	for _, name := range names {
+		old, new := fmt.Sprint(fields[name].Interface()), fmt.Sprint(otherFields[name].Interface())
+
+		if old != new {
+			changes = append(changes, SettingChange{Setting: name, Old: old, New: new})
+		}
+	}
+
+	return
+}
+
+// readSettings returns the saved settings, the missing fields have the default value
+func readSettings(tx Tx) (settings Settings, err error) {
+	settings = DefaultSettings()
+
+	value := tx.Bucket([]byte("configuration")).Get([]byte(settingsKey))
+	if value == nil {
+		return
+	}
+
+	err = json.Unmarshal(value, &settings)
+	if err != nil {
+		err = fmt.Errorf("corrupt_settings_error: %s", err)
+	}
+
+	return
+}
+
+// GetSettings returns the saved settings and their version, the version is 0
+// before the first change
+func (boltdb *DB) GetSettings() (settings Settings, version int, err error) {
+	err = boltdb.Store.View(func(tx Tx) error {
+		settings, err = readSettings(tx)
+		if err != nil {
+			return err
+		}
+
+		// the DB commands read the settings before the DB is migrated
+		history := tx.Bucket([]byte("settings_history"))
+		if history == nil {
+			return nil
+		}
+
+		if k, _ := history.Cursor().Last(); k != nil {
+			version = btoi(k)
+		}
+
+		return nil
+	})
+
+	return
+}
+
+// SaveSettings validates and saves the settings with a new version in the
+// history. The settings must be different from the saved ones
+func (boltdb *DB) SaveSettings(settings Settings, user string, comment string) (record SettingsRecord, err error) {
+	err = settings.Validate()
+	if err != nil {
+		return
+	}
+
+	err = boltdb.Store.Update(func(tx Tx) error {
+		current, err := readSettings(tx)
+		if err != nil {
+			return err
+		}
+
+		changes := current.Diff(settings)
+		if len(changes) == 0 {
+			return errors.New("settings_error: the settings didn't change")
+		}
+
+		history := tx.Bucket([]byte("settings_history"))
+
+		record = SettingsRecord{Version: 1, User: user, Comment: comment, Changes: changes, Settings: settings, Created: time.Now().UTC()}
+
+		if k, _ := history.Cursor().Last(); k != nil {
+			record.Version = btoi(k) + 1
+		}
+
+		recordJSON, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+
+		err = history.Put(itob(record.Version), recordJSON)
+		if err != nil {
+			return err
+		}
+
+		settingsJSON, err := json.Marshal(settings)
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket([]byte("configuration")).Put([]byte(settingsKey), settingsJSON)
+	})
+
+	return
+}
+
+// GetSettingsHistory returns the changes of the settings, the newest first
+func (boltdb *DB) GetSettingsHistory(limit int) (records []SettingsRecord, err error) {
+	err = boltdb.Store.View(func(tx Tx) error {
+		c := tx.Bucket([]byte("settings_history")).Cursor()
+
+		for k, v := c.Last(); k != nil && len(records) < limit; k, v = c.Prev() {
+			var record SettingsRecord
+
+			err := json.Unmarshal(v, &record)
+			if err != nil {
+				return fmt.Errorf("corrupt_settings_error: version %d: %s", btoi(k), err)
+			}
+
+			records = append(records, record)
+		}
+
+		return nil
+	})
+
+	return
+}
+
+// RollbackSettings saves the settings of a previous version as a new version
+func (boltdb *DB) RollbackSettings(version int, user string) (record SettingsRecord, err error) {
+	var previous SettingsRecord
+
+	err = boltdb.Store.View(func(tx Tx) error {
+		v := tx.Bucket([]byte("settings_history")).Get(itob(version))
+		if v == nil {
+			return errors.New("settings_error: the version " + strconv.Itoa(version) + " doesn't exist")
+		}
+
+		return json.Unmarshal(v, &previous)
+	})
+	if err != nil {
+		return
+	}
+
+	return boltdb.SaveSettings(previous.Settings, user, "rollback to version "+strconv.Itoa(version))
+}
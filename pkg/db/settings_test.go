@@ -0,0 +1,235 @@
+package db
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSettingsValidate(t *testing.T) {
+	err := DefaultSettings().Validate()
+	if err != nil {
+		t.Fatalf("want valid default settings; got %v", err)
+	}
+
+	tests := []struct {
+		setting string
+		value   string
+	}{
+		{"server.port", "0"},
+		{"server.port", "70000"},
+		{"server.cert_file", ""},
+		{"sessions.idle_timeout", "30s"},
+		{"sessions.lifetime", "1h"},
+		{"passwords.min_length", "4"},
+		{"passwords.hash", "md5"},
+		{"passwords.bcrypt_cost", "40"},
+		{"motion.stop_delay", "0s"},
+		{"retention.audit", "-1h"},
+		{"backups.interval", "10s"},
+		{"backups.keep", "0"},
+		{"audio.sample_rate", "4000"},
+		{"audio.channels", "3"},
+		{"audio.segment_length", "2h"},
+		{"audio.level", "average"},
+		{"audio.threshold", "6"},
+		{"audio.attack", "-1s"},
+		{"audio.level_log", "100ms"},
+		{"audio.muxer", "vlc"},
+		{"speaker.buffer", "10s"},
+		{"speaker.volume", "150"},
+		{"integrations.motion_webhook", "ftp://example.com/motion"},
+		{"integrations.motion_webhook", "example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.setting+"="+tt.value, func(t *testing.T) {
+			settings := DefaultSettings()
+
+			err := settings.Set(tt.setting, tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = settings.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.setting) {
+				t.Errorf("want error of %s; got %v", tt.setting, err)
+			}
+		})
+	}
+}
+
+func TestSettingsSet(t *testing.T) {
+	settings := DefaultSettings()
+
+	values := map[string]string{
+		"server.port":                 "8443",
+		"server.insecure":             "true",
+		"sessions.idle_timeout":       "1h0m0s",
+		"integrations.motion_webhook": "https://example.com/motion",
+	}
+
+	for setting, value := range values {
+		err := settings.Set(setting, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := settings.Get(setting)
+		if err != nil || got != value {
+			t.Errorf("want %s=%s; got %s %v", setting, value, got, err)
+		}
+	}
+
+	if settings.Server.Port != 8443 || !settings.Server.Insecure || settings.Sessions.IdleTimeout != Duration(time.Hour) {
+		t.Errorf("the settings were not set: %+v", settings)
+	}
+
+	invalid := map[string]string{
+		"server.port":           "https",
+		"server.insecure":       "maybe",
+		"sessions.idle_timeout": "3 days",
+		"server.host":           "localhost",
+	}
+
+	for setting, value := range invalid {
+		if err := settings.Set(setting, value); err == nil {
+			t.Errorf("want error setting %s=%s", setting, value)
+		}
+	}
+}
+
+func TestSettingsApplyEnv(t *testing.T) {
+	env := map[string]string{
+		"GOPICAM_SERVER_PORT":          "8080",
+		"GOPICAM_MOTION_STOP_DELAY":    "30s",
+		"GOPICAM_UNKNOWN_SETTING":      "1",
+		"GOPICAM_PASSWORDS_MIN_LENGTH": "12",
+	}
+
+	lookup := func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+
+	settings := DefaultSettings()
+
+	applied, err := settings.ApplyEnv(lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(applied, ",") != "server.port,passwords.min_length,motion.stop_delay" {
+		t.Errorf("want the settings in order; got %v", applied)
+	}
+
+	if settings.Server.Port != 8080 || settings.Motion.StopDelay != Duration(30*time.Second) || settings.Passwords.MinLength != 12 {
+		t.Errorf("the environment was not applied: %+v", settings)
+	}
+
+	env["GOPICAM_SERVER_PORT"] = "http"
+
+	_, err = settings.ApplyEnv(lookup)
+	if err == nil || !strings.Contains(err.Error(), "GOPICAM_SERVER_PORT") {
+		t.Errorf("want error of GOPICAM_SERVER_PORT; got %v", err)
+	}
+}
+
+func TestSettingsJSON(t *testing.T) {
+	settings := DefaultSettings()
+
+	// the missing settings keep the default value
+	err := json.Unmarshal([]byte(`{"server": {"port": 8443}, "sessions": {"idle_timeout": "1h"}}`), &settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := DefaultSettings().Diff(settings)
+
+	want := []SettingChange{
+		{Setting: "server.port", Old: "443", New: "8443"},
+		{Setting: "sessions.idle_timeout", Old: "72h0m0s", New: "1h0m0s"},
+	}
+
+	if len(changes) != len(want) {
+		t.Fatalf("want %v; got %v", want, changes)
+	}
+
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("want %v; got %v", want[i], changes[i])
+		}
+	}
+
+	err = json.Unmarshal([]byte(`{"sessions": {"idle_timeout": 3600}}`), &settings)
+	if err == nil {
+		t.Errorf("want error decoding a duration without unit")
+	}
+}
+
+func TestSettingsHistory(t *testing.T) {
+	database, teardown := newMemoryTestDB(t)
+	defer teardown()
+
+	err := database.InitDb()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	settings, version, err := database.GetSettings()
+	if err != nil || version != 0 || settings != DefaultSettings() {
+		t.Fatalf("want the default settings without version; got %+v %d %v", settings, version, err)
+	}
+
+	settings.Server.Port = 8443
+
+	record, err := database.SaveSettings(settings, "admin", "new port")
+	if err != nil || record.Version != 1 {
+		t.Fatalf("want version 1; got %+v %v", record, err)
+	}
+
+	_, err = database.SaveSettings(settings, "admin", "")
+	if err == nil {
+		t.Errorf("want error saving the same settings")
+	}
+
+	settings.Server.Port = 0
+
+	_, err = database.SaveSettings(settings, "admin", "")
+	if err == nil {
+		t.Errorf("want error saving invalid settings")
+	}
+
+	settings.Server.Port = 9443
+	settings.Motion.Record = false
+
+	_, err = database.SaveSettings(settings, "operator", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record, err = database.RollbackSettings(1, "admin")
+	if err != nil || record.Version != 3 || len(record.Changes) != 2 {
+		t.Fatalf("want version 3 with 2 changes; got %+v %v", record, err)
+	}
+
+	_, err = database.RollbackSettings(10, "admin")
+	if err == nil {
+		t.Errorf("want error rolling back a missing version")
+	}
+
+	settings, version, err = database.GetSettings()
+	if err != nil || version != 3 || settings.Server.Port != 8443 || !settings.Motion.Record {
+		t.Errorf("want the settings of version 1 in version 3; got %+v %d %v", settings, version, err)
+	}
+
+	history, err := database.GetSettingsHistory(2)
+	if err != nil || len(history) != 2 {
+		t.Fatalf("want 2 records; got %d %v", len(history), err)
+	}
+
+	if history[0].Version != 3 || history[1].Version != 2 || history[1].User != "operator" {
+		t.Errorf("want the newest records first; got %+v", history)
+	}
+}
@@ -0,0 +1,141 @@
+package db
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// DefaultTimeout is the time that Open waits for the lock of the DB file
+const DefaultTimeout = 5 * time.Second
+
+// ErrLocked is returned by Open when the DB file is locked by another process,
+// usually another gopicam instance
+var ErrLocked = errors.New("the DB file is in use by another process")
+
+// Store is the key/value storage of the DB, the items are saved in buckets of
+// sorted keys and they are read and written in transactions. The bbolt store
+// saves them in a file and the memory store is used in the tests
+type Store interface {
+	// View runs fn in a read-only transaction
+	View(fn func(tx Tx) error) error
+	// Update runs fn in a read-write transaction, the changes are rolled back
+	// if fn returns an error
+	Update(fn func(tx Tx) error) error
+	// Path is the path of the DB file, it's empty for the memory stores
+	Path() string
+	Close() error
+}
+
+type Tx interface {
+	// Bucket returns nil if the bucket doesn't exist
+	Bucket(name []byte) Bucket
+	CreateBucket(name []byte) (Bucket, error)
+	CreateBucketIfNotExists(name []byte) (Bucket, error)
+	DeleteBucket(name []byte) error
+	// WriteTo writes a copy of the DB file, it's used by the backups
+	WriteTo(w io.Writer) (int64, error)
+	// Size is the size of the copy of the DB file
+	Size() int64
+}
+
+type Bucket interface {
+	// Get returns nil if the key doesn't exist, the value is only valid
+	// during the transaction
+	Get(key []byte) []byte
+	Put(key []byte, value []byte) error
+	Delete(key []byte) error
+	// ForEach runs fn for every key in order, it stops at the first error
+	ForEach(fn func(k, v []byte) error) error
+	Cursor() Cursor
+}
+
+// Cursor iterates the keys of a bucket in order, the methods return a nil key
+// at the end of the bucket
+type Cursor interface {
+	First() (key []byte, value []byte)
+	Last() (key []byte, value []byte)
+	Next() (key []byte, value []byte)
+	Prev() (key []byte, value []byte)
+	// Seek moves to the first key that is greater than or equal to seek
+	Seek(seek []byte) (key []byte, value []byte)
+}
+
+// boltStore saves the DB in a bbolt file
+type boltStore struct {
+	db *bbolt.DB
+}
+
+// OpenBoltStore opens or creates the bbolt file, it waits up to timeout for
+// the lock of the file and returns ErrLocked after that
+func OpenBoltStore(path string, timeout time.Duration) (Store, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: timeout})
+	if errors.Is(err, bbolt.ErrTimeout) {
+		return nil, ErrLocked
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &boltStore{db: db}, nil
+}
+
+func (store *boltStore) View(fn func(tx Tx) error) error {
+	return store.db.View(func(tx *bbolt.Tx) error {
+		return fn(boltTx{tx})
+	})
+}
+
+func (store *boltStore) Update(fn func(tx Tx) error) error {
+	return store.db.Update(func(tx *bbolt.Tx) error {
+		return fn(boltTx{tx})
+	})
+}
+
+func (store *boltStore) Path() string {
+	return store.db.Path()
+}
+
+func (store *boltStore) Close() error {
+	return store.db.Close()
+}
+
+type boltTx struct {
+	*bbolt.Tx
+}
+
+func (tx boltTx) Bucket(name []byte) Bucket {
+	b := tx.Tx.Bucket(name)
+	if b == nil {
+		return nil
+	}
+
+	return boltBucket{b}
+}
+
+func (tx boltTx) CreateBucket(name []byte) (Bucket, error) {
+	b, err := tx.Tx.CreateBucket(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return boltBucket{b}, nil
+}
+
+func (tx boltTx) CreateBucketIfNotExists(name []byte) (Bucket, error) {
+	b, err := tx.Tx.CreateBucketIfNotExists(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return boltBucket{b}, nil
+}
+
+type boltBucket struct {
+	*bbolt.Bucket
+}
+
+func (b boltBucket) Cursor() Cursor {
+	return b.Bucket.Cursor()
+}
@@ -0,0 +1,283 @@
+package db
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"sync"
+
+	"go.etcd.io/bbolt"
+)
+
+// memoryStore keeps the buckets in memory, it's used by the tests that don't
+// need a DB file. Like bbolt it has one writer at a time and the readers see
+// the last committed buckets: the write transactions copy the buckets before
+// changing them
+type memoryStore struct {
+	writer sync.Mutex
+	lock   sync.Mutex
+
+	buckets map[string]*memoryBucket
+	closed  bool
+}
+
+type memoryBucket struct {
+	keys   []string
+	values map[string][]byte
+}
+
+// NewMemoryStore returns an empty store that is lost when it's closed
+func NewMemoryStore() Store {
+	return &memoryStore{buckets: make(map[string]*memoryBucket)}
+}
+
+// committed returns the buckets of the last committed transaction
+func (store *memoryStore) committed() (map[string]*memoryBucket, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	if store.closed {
+		return nil, bbolt.ErrDatabaseNotOpen
+	}
+
+	return store.buckets, nil
+}
+
+func (store *memoryStore) View(fn func(tx Tx) error) error {
+	buckets, err := store.committed()
+	if err != nil {
+		return err
+	}
+
+	return fn(&memoryTx{buckets: buckets})
+}
+
+func (store *memoryStore) Update(fn func(tx Tx) error) error {
+	store.writer.Lock()
+	defer store.writer.Unlock()
+
+	buckets, err := store.committed()
+	if err != nil {
+		return err
+	}
+
+	tx := &memoryTx{buckets: make(map[string]*memoryBucket, len(buckets)), writable: true, copied: make(map[string]bool)}
+	for name, b := range buckets {
+		tx.buckets[name] = b
+	}
+
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+
+	store.lock.Lock()
+	store.buckets = tx.buckets
+	store.lock.Unlock()
+
+	return nil
+}
+
+func (store *memoryStore) Path() string {
+	return ""
+}
+
+func (store *memoryStore) Close() error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	store.closed = true
+	store.buckets = nil
+
+	return nil
+}
+
+type memoryTx struct {
+	buckets  map[string]*memoryBucket
+	writable bool
+	// copied are the buckets that were copied by the transaction and can be changed
+	copied map[string]bool
+}
+
+func (tx *memoryTx) Bucket(name []byte) Bucket {
+	b, ok := tx.buckets[string(name)]
+	if !ok {
+		return nil
+	}
+
+	if tx.writable && !tx.copied[string(name)] {
+		b = b.copy()
+		tx.buckets[string(name)] = b
+		tx.copied[string(name)] = true
+	}
+
+	return &memoryBucketTx{bucket: b, writable: tx.writable}
+}
+
+func (tx *memoryTx) CreateBucket(name []byte) (Bucket, error) {
+	if !tx.writable {
+		return nil, bbolt.ErrTxNotWritable
+	} else if len(name) == 0 {
+		return nil, bbolt.ErrBucketNameRequired
+	} else if _, ok := tx.buckets[string(name)]; ok {
+		return nil, bbolt.ErrBucketExists
+	}
+
+	tx.buckets[string(name)] = &memoryBucket{values: make(map[string][]byte)}
+	tx.copied[string(name)] = true
+
+	return tx.Bucket(name), nil
+}
+
+func (tx *memoryTx) CreateBucketIfNotExists(name []byte) (Bucket, error) {
+	if b := tx.Bucket(name); b != nil {
+		return b, nil
+	}
+
+	return tx.CreateBucket(name)
+}
+
+func (tx *memoryTx) DeleteBucket(name []byte) error {
+	if !tx.writable {
+		return bbolt.ErrTxNotWritable
+	} else if _, ok := tx.buckets[string(name)]; !ok {
+		return bbolt.ErrBucketNotFound
+	}
+
+	delete(tx.buckets, string(name))
+
+	return nil
+}
+
+func (tx *memoryTx) WriteTo(w io.Writer) (int64, error) {
+	return 0, errors.New("the memory store doesn't have a DB file")
+}
+
+func (tx *memoryTx) Size() int64 {
+	return 0
+}
+
+func (b *memoryBucket) copy() *memoryBucket {
+	copied := &memoryBucket{keys: make([]string, len(b.keys)), values: make(map[string][]byte, len(b.values))}
+
+	copy(copied.keys, b.keys)
+	for k, v := range b.values {
+		copied.values[k] = v
+	}
+
+	return copied
+}
+
+// search returns the position of the first key that is greater than or equal to key
+func (b *memoryBucket) search(key string) int {
+	return sort.SearchStrings(b.keys, key)
+}
+
+type memoryBucketTx struct {
+	bucket   *memoryBucket
+	writable bool
+}
+
+func (b *memoryBucketTx) Get(key []byte) []byte {
+	return b.bucket.values[string(key)]
+}
+
+func (b *memoryBucketTx) Put(key []byte, value []byte) error {
+	if !b.writable {
+		return bbolt.ErrTxNotWritable
+	} else if len(key) == 0 {
+		return bbolt.ErrKeyRequired
+	}
+
+	k := string(key)
+
+	if _, ok := b.bucket.values[k]; !ok {
+		i := b.bucket.search(k)
+
+		b.bucket.keys = append(b.bucket.keys, "")
+		copy(b.bucket.keys[i+1:], b.bucket.keys[i:])
+		b.bucket.keys[i] = k
+	}
+
+	// the callers can reuse the value after the Put
+	b.bucket.values[k] = append([]byte{}, value...)
+
+	return nil
+}
+
+func (b *memoryBucketTx) Delete(key []byte) error {
+	if !b.writable {
+		return bbolt.ErrTxNotWritable
+	}
+
+	k := string(key)
+
+	if _, ok := b.bucket.values[k]; !ok {
+		return nil
+	}
+
+	i := b.bucket.search(k)
+	b.bucket.keys = append(b.bucket.keys[:i], b.bucket.keys[i+1:]...)
+	delete(b.bucket.values, k)
+
+	return nil
+}
+
+func (b *memoryBucketTx) ForEach(fn func(k, v []byte) error) error {
+	c := b.Cursor()
+
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		err := fn(k, v)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *memoryBucketTx) Cursor() Cursor {
+	return &memoryCursor{bucket: b.bucket}
+}
+
+// memoryCursor remembers the current key instead of its position, so the keys
+// that are added or deleted while it iterates don't move it
+type memoryCursor struct {
+	bucket *memoryBucket
+	key    string
+}
+
+func (c *memoryCursor) at(i int) ([]byte, []byte) {
+	if i < 0 || i >= len(c.bucket.keys) {
+		return nil, nil
+	}
+
+	c.key = c.bucket.keys[i]
+
+	return []byte(c.key), c.bucket.values[c.key]
+}
+
+func (c *memoryCursor) First() ([]byte, []byte) {
+	return c.at(0)
+}
+
+func (c *memoryCursor) Last() ([]byte, []byte) {
+	return c.at(len(c.bucket.keys) - 1)
+}
+
+func (c *memoryCursor) Next() ([]byte, []byte) {
+	i := c.bucket.search(c.key)
+	if i < len(c.bucket.keys) && c.bucket.keys[i] == c.key {
+		i++
+	}
+
+	return c.at(i)
+}
+
+func (c *memoryCursor) Prev() ([]byte, []byte) {
+	return c.at(c.bucket.search(c.key) - 1)
+}
+
+func (c *memoryCursor) Seek(seek []byte) ([]byte, []byte) {
+	return c.at(c.bucket.search(string(seek)))
+}
@@ -0,0 +1,234 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// countKeys returns the number of keys of a bucket
+func countKeys(b Bucket) (keys int) {
+	b.ForEach(func(k, v []byte) error {
+		keys++
+		return nil
+	})
+
+	return
+}
+
+// cursorKeys returns the keys of a bucket in order
+func cursorKeys(b Bucket) (keys []string) {
+	c := b.Cursor()
+
+	for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		keys = append(keys, string(k))
+	}
+
+	return
+}
+
+func TestStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) Store{
+		"Bolt": func(t *testing.T) Store {
+			store, err := OpenBoltStore(filepath.Join(t.TempDir(), "store.db"), time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			return store
+		},
+		"Memory": func(t *testing.T) Store {
+			return NewMemoryStore()
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			defer store.Close()
+
+			t.Run("Buckets", func(t *testing.T) {
+				err := store.Update(func(tx Tx) error {
+					if tx.Bucket([]byte("items")) != nil {
+						t.Errorf("want nil bucket")
+					}
+
+					if _, err := tx.CreateBucket([]byte("items")); err != nil {
+						return err
+					}
+
+					if _, err := tx.CreateBucket([]byte("items")); err == nil {
+						t.Errorf("want bucket exists error; got nil")
+					}
+
+					if _, err := tx.CreateBucketIfNotExists([]byte("items")); err != nil {
+						return err
+					}
+
+					if _, err := tx.CreateBucket([]byte("deleted")); err != nil {
+						return err
+					}
+
+					return tx.DeleteBucket([]byte("deleted"))
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				store.View(func(tx Tx) error {
+					if tx.Bucket([]byte("items")) == nil || tx.Bucket([]byte("deleted")) != nil {
+						t.Errorf("wrong buckets")
+					}
+
+					if err := tx.DeleteBucket([]byte("items")); err == nil {
+						t.Errorf("want read-only error; got nil")
+					}
+
+					return nil
+				})
+			})
+
+			t.Run("Keys", func(t *testing.T) {
+				err := store.Update(func(tx Tx) error {
+					b := tx.Bucket([]byte("items"))
+
+					for _, key := range []string{"d", "b", "a", "e", "c"} {
+						if err := b.Put([]byte(key), []byte("value "+key)); err != nil {
+							return err
+						}
+					}
+
+					return b.Delete([]byte("e"))
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				store.View(func(tx Tx) error {
+					b := tx.Bucket([]byte("items"))
+
+					if string(b.Get([]byte("b"))) != "value b" || b.Get([]byte("e")) != nil {
+						t.Errorf("wrong values %s %s", b.Get([]byte("b")), b.Get([]byte("e")))
+					}
+
+					if err := b.Put([]byte("f"), []byte("f")); err == nil {
+						t.Errorf("want read-only error; got nil")
+					}
+
+					if keys := cursorKeys(b); len(keys) != 4 || keys[0] != "a" || keys[3] != "d" {
+						t.Errorf("want sorted keys; got %v", keys)
+					}
+
+					if countKeys(b) != 4 {
+						t.Errorf("want 4 keys; got %d", countKeys(b))
+					}
+
+					c := b.Cursor()
+
+					if k, _ := c.Last(); string(k) != "d" {
+						t.Errorf("want last key d; got %s", k)
+					}
+
+					if k, _ := c.Prev(); string(k) != "c" {
+						t.Errorf("want previous key c; got %s", k)
+					}
+
+					if k, v := c.Seek([]byte("bb")); string(k) != "c" || string(v) != "value c" {
+						t.Errorf("want seek key c; got %s %s", k, v)
+					}
+
+					if k, _ := c.Seek([]byte("z")); k != nil {
+						t.Errorf("want nil key after the last key; got %s", k)
+					}
+
+					return nil
+				})
+			})
+
+			t.Run("Delete While Iterating", func(t *testing.T) {
+				err := store.Update(func(tx Tx) error {
+					b := tx.Bucket([]byte("items"))
+					c := b.Cursor()
+
+					for k, _ := c.Seek([]byte("b")); k != nil && string(k) < "d"; k, _ = c.Seek([]byte("b")) {
+						if err := b.Delete(k); err != nil {
+							return err
+						}
+					}
+
+					return nil
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				store.View(func(tx Tx) error {
+					if keys := cursorKeys(tx.Bucket([]byte("items"))); len(keys) != 2 || keys[0] != "a" || keys[1] != "d" {
+						t.Errorf("want keys a and d; got %v", keys)
+					}
+
+					return nil
+				})
+			})
+
+			t.Run("Rollback", func(t *testing.T) {
+				err := store.Update(func(tx Tx) error {
+					tx.Bucket([]byte("items")).Put([]byte("rolled back"), []byte("1"))
+					tx.CreateBucket([]byte("rolled back"))
+
+					// the readers don't see the changes before the commit
+					done := make(chan bool)
+
+					go store.View(func(tx Tx) error {
+						if tx.Bucket([]byte("items")).Get([]byte("rolled back")) != nil {
+							t.Errorf("the reader sees an uncommitted key")
+						}
+
+						done <- true
+
+						return nil
+					})
+
+					<-done
+
+					return errors.New("rollback")
+				})
+				if err == nil || err.Error() != "rollback" {
+					t.Fatalf("want rollback error; got %v", err)
+				}
+
+				store.View(func(tx Tx) error {
+					if tx.Bucket([]byte("items")).Get([]byte("rolled back")) != nil || tx.Bucket([]byte("rolled back")) != nil {
+						t.Errorf("the changes were not rolled back")
+					}
+
+					return nil
+				})
+			})
+		})
+	}
+}
+
+func TestBoltStoreLocked(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "locked.db")
+
+	store, err := OpenBoltStore(dbPath, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	database := &DB{Path: dbPath, Timeout: 100 * time.Millisecond}
+
+	start := time.Now()
+
+	err = database.Open()
+	if !errors.Is(err, ErrLocked) {
+		t.Errorf("want ErrLocked; got %v", err)
+	}
+
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("Open didn't stop waiting after the timeout")
+	}
+}
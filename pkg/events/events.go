@@ -0,0 +1,81 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// subscriberBuffer is the number of events kept for a subscriber that doesn't
+// read them
+const subscriberBuffer = 64
+
+// The types of the events
+const (
+	TypeAlert    = "alert"
+	TypeGeofence = "geofence"
+)
+
+// Event is sent to the subscribers of the event stream, Data is encoded as
+// JSON
+type Event struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data"`
+}
+
+// Broker sends the events to all the subscribers
+type Broker struct {
+	mutex       sync.Mutex
+	subscribers map[chan Event]bool
+}
+
+// NewBroker returns a broker without subscribers
+func NewBroker() *Broker {
+	return &Broker{subscribers: make(map[chan Event]bool)}
+}
+
+// Subscribe returns the channel of the events published after the call and the
+// function that ends the subscription and closes the channel
+func (broker *Broker) Subscribe() (<-chan Event, func()) {
+	subscriber := make(chan Event, subscriberBuffer)
+
+	broker.mutex.Lock()
+	broker.subscribers[subscriber] = true
+	broker.mutex.Unlock()
+
+	var once sync.Once
+
+	return subscriber, func() {
+		once.Do(func() {
+			broker.mutex.Lock()
+			delete(broker.subscribers, subscriber)
+			broker.mutex.Unlock()
+
+			close(subscriber)
+		})
+	}
+}
+
+// Publish sends the event to the subscribers, it doesn't block, the events are
+// dropped for the subscribers that have a full buffer
+func (broker *Broker) Publish(eventType string, data any) {
+	event := Event{Type: eventType, Time: time.Now().UTC(), Data: data}
+
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+
+	for subscriber := range broker.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+		}
+	}
+}
+
+// Subscribers returns the number of subscribers
+func (broker *Broker) Subscribers() int {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+
+	return len(broker.subscribers)
+}
@@ -0,0 +1,51 @@
+package events
+
+import (
+	"testing"
+)
+
+func TestBroker(t *testing.T) {
+	broker := NewBroker()
+
+	first, cancelFirst := broker.Subscribe()
+	second, cancelSecond := broker.Subscribe()
+
+	broker.Publish(TypeAlert, "low battery")
+
+	for _, subscriber := range []<-chan Event{first, second} {
+		event := <-subscriber
+		if event.Type != TypeAlert || event.Data != "low battery" || event.Time.IsZero() {
+			t.Errorf("want the alert event; got %+v", event)
+		}
+	}
+
+	cancelFirst()
+	cancelFirst()
+
+	if _, open := <-first; open {
+		t.Error("want the channel closed after the subscription ends")
+	}
+
+	if broker.Subscribers() != 1 {
+		t.Errorf("want 1 subscriber; got %d", broker.Subscribers())
+	}
+
+	// the events are dropped when the buffer is full
+	for i := 0; i < subscriberBuffer+10; i++ {
+		broker.Publish(TypeGeofence, i)
+	}
+
+	if len(second) != subscriberBuffer {
+		t.Errorf("want %d buffered events; got %d", subscriberBuffer, len(second))
+	}
+
+	if event := <-second; event.Data != 0 {
+		t.Errorf("want the oldest event first; got %v", event.Data)
+	}
+
+	cancelSecond()
+
+	if broker.Subscribers() != 0 {
+		t.Errorf("want no subscribers; got %d", broker.Subscribers())
+	}
+}
@@ -0,0 +1,484 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+	"time"
+)
+
+var ErrNotJPEG = errors.New("exif: not a JPEG file")
+var ErrCorrupt = errors.New("exif: corrupt EXIF data")
+
+// ErrTooLarge is returned when the EXIF data with the GPS tags doesn't fit in
+// the APP1 segment
+var ErrTooLarge = errors.New("exif: the EXIF data is too large")
+
+// exifHeader starts the APP1 segment of the EXIF data
+var exifHeader = []byte("Exif\x00\x00")
+
+// maxSegment is the maximum length of a JPEG segment, with the length itself
+const maxSegment = 0xFFFF
+
+// the JPEG markers
+const (
+	markerSOI  = 0xD8
+	markerEOI  = 0xD9
+	markerSOS  = 0xDA
+	markerAPP0 = 0xE0
+	markerAPP1 = 0xE1
+)
+
+// the tags of the GPS IFD and the tag of its offset in IFD0
+const (
+	tagGPSInfo      = 0x8825
+	tagVersionID    = 0x0000
+	tagLatitudeRef  = 0x0001
+	tagLatitude     = 0x0002
+	tagLongitudeRef = 0x0003
+	tagLongitude    = 0x0004
+	tagTimeStamp    = 0x0007
+	tagDateStamp    = 0x001D
+)
+
+// the types of the values of the IFD entries
+const (
+	typeByte     = 1
+	typeASCII    = 2
+	typeLong     = 4
+	typeRational = 5
+)
+
+var typeSizes = map[uint16]uint32{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+
+// entry is an entry of an IFD, value has the value when it fits in 4 bytes or
+// the offset of the value in the TIFF data
+type entry struct {
+	tag   uint16
+	kind  uint16
+	count uint32
+	value [4]byte
+	// data is the value of the new entries that don't fit in value
+	data []byte
+}
+
+// segment is the position of a JPEG segment, start is the position of the
+// marker and end the position after the segment
+type segment struct {
+	marker byte
+	start  int
+	end    int
+}
+
+// SetGPS returns the JPEG file with the GPS latitude, longitude and time in
+// its EXIF data, the other EXIF tags are kept. The EXIF data is created when
+// the file doesn't have it
+func SetGPS(jpeg []byte, latitude float64, longitude float64, gpsTime time.Time) ([]byte, error) {
+	if math.Abs(latitude) > 90 || math.Abs(longitude) > 180 {
+		return nil, errors.New("exif: invalid coordinates")
+	}
+
+	segments, err := readSegments(jpeg)
+	if err != nil {
+		return nil, err
+	}
+
+	// the new APP1 segment goes after SOI and the JFIF APP0 segments
+	insertAt := 2
+	var old *segment
+
+	for i, seg := range segments {
+		if seg.marker == markerAPP0 && old == nil {
+			insertAt = seg.end
+		}
+
+		if seg.marker == markerAPP1 && bytes.HasPrefix(jpeg[seg.start+4:seg.end], exifHeader) {
+			old = &segments[i]
+			break
+		}
+	}
+
+	var tiff []byte
+
+	if old != nil {
+		tiff = append(tiff, jpeg[old.start+4+len(exifHeader):old.end]...)
+	}
+
+	tiff, err = addGPS(tiff, latitude, longitude, gpsTime.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	length := 2 + len(exifHeader) + len(tiff)
+	if length > maxSegment {
+		return nil, ErrTooLarge
+	}
+
+	app1 := []byte{0xFF, markerAPP1, byte(length >> 8), byte(length)}
+	app1 = append(app1, exifHeader...)
+	app1 = append(app1, tiff...)
+
+	start, end := insertAt, insertAt
+	if old != nil {
+		start, end = old.start, old.end
+	}
+
+	result := make([]byte, 0, len(jpeg)+len(app1))
+	result = append(result, jpeg[:start]...)
+	result = append(result, app1...)
+	result = append(result, jpeg[end:]...)
+
+	return result, nil
+}
+
+// GPS returns the GPS latitude and longitude of the EXIF data of the JPEG
+// file, ok is false when it doesn't have them
+func GPS(jpeg []byte) (latitude float64, longitude float64, ok bool, err error) {
+	segments, err := readSegments(jpeg)
+	if err != nil {
+		return
+	}
+
+	for _, seg := range segments {
+		if seg.marker != markerAPP1 || !bytes.HasPrefix(jpeg[seg.start+4:seg.end], exifHeader) {
+			continue
+		}
+
+		tiff := jpeg[seg.start+4+len(exifHeader) : seg.end]
+
+		order, ifd0, err := readHeader(tiff)
+		if err != nil {
+			return 0, 0, false, err
+		}
+
+		entries, _, err := readIFD(tiff, order, ifd0)
+		if err != nil {
+			return 0, 0, false, err
+		}
+
+		gpsEntry, found := findEntry(entries, tagGPSInfo)
+		if !found {
+			return 0, 0, false, nil
+		}
+
+		gpsEntries, _, err := readIFD(tiff, order, order.Uint32(gpsEntry.value[:]))
+		if err != nil {
+			return 0, 0, false, err
+		}
+
+		latitude, okLatitude, err := readCoordinate(tiff, order, gpsEntries, tagLatitudeRef, tagLatitude, 'S')
+		if err != nil {
+			return 0, 0, false, err
+		}
+
+		longitude, okLongitude, err := readCoordinate(tiff, order, gpsEntries, tagLongitudeRef, tagLongitude, 'W')
+		if err != nil {
+			return 0, 0, false, err
+		}
+
+		return latitude, longitude, okLatitude && okLongitude, nil
+	}
+
+	return
+}
+
+// readSegments returns the segments of the JPEG file before the image data
+func readSegments(jpeg []byte) (segments []segment, err error) {
+	if len(jpeg) < 4 || jpeg[0] != 0xFF || jpeg[1] != markerSOI {
+		return nil, ErrNotJPEG
+	}
+
+	position := 2
+
+	for position+4 <= len(jpeg) {
+		if jpeg[position] != 0xFF {
+			return nil, ErrNotJPEG
+		}
+
+		marker := jpeg[position+1]
+
+		// fill bytes before the marker
+		if marker == 0xFF {
+			position++
+			continue
+		}
+
+		if marker == markerSOS || marker == markerEOI {
+			return
+		}
+
+		length := int(binary.BigEndian.Uint16(jpeg[position+2:]))
+		if length < 2 || position+2+length > len(jpeg) {
+			return nil, ErrNotJPEG
+		}
+
+		segments = append(segments, segment{marker: marker, start: position, end: position + 2 + length})
+		position += 2 + length
+	}
+
+	return nil, ErrNotJPEG
+}
+
+func readHeader(tiff []byte) (order binary.ByteOrder, ifd0 uint32, err error) {
+	if len(tiff) < 8 {
+		return nil, 0, ErrCorrupt
+	}
+
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, 0, ErrCorrupt
+	}
+
+	if order.Uint16(tiff[2:]) != 42 {
+		return nil, 0, ErrCorrupt
+	}
+
+	return order, order.Uint32(tiff[4:]), nil
+}
+
+// readIFD returns the entries of the IFD at the offset and the offset of the
+// next IFD
+func readIFD(tiff []byte, order binary.ByteOrder, offset uint32) (entries []entry, next uint32, err error) {
+	if uint64(offset)+2 > uint64(len(tiff)) {
+		return nil, 0, ErrCorrupt
+	}
+
+	count := uint64(order.Uint16(tiff[offset:]))
+	end := uint64(offset) + 2 + count*12
+
+	if end+4 > uint64(len(tiff)) {
+		return nil, 0, ErrCorrupt
+	}
+
+	for i := uint64(0); i < count; i++ {
+		position := uint64(offset) + 2 + i*12
+
+		var e entry
+		e.tag = order.Uint16(tiff[position:])
+		e.kind = order.Uint16(tiff[position+2:])
+		e.count = order.Uint32(tiff[position+4:])
+		copy(e.value[:], tiff[position+8:position+12])
+
+		entries = append(entries, e)
+	}
+
+	return entries, order.Uint32(tiff[end:]), nil
+}
+
+func findEntry(entries []entry, tag uint16) (entry, bool) {
+	for _, e := range entries {
+		if e.tag == tag {
+			return e, true
+		}
+	}
+
+	return entry{}, false
+}
+
+// entryData returns the value of the entry, inline or at its offset
+func entryData(tiff []byte, order binary.ByteOrder, e entry) ([]byte, error) {
+	size, ok := typeSizes[e.kind]
+	if !ok {
+		return nil, ErrCorrupt
+	}
+
+	length := uint64(size) * uint64(e.count)
+	if length <= 4 {
+		return e.value[:length], nil
+	}
+
+	offset := uint64(order.Uint32(e.value[:]))
+	if offset+length > uint64(len(tiff)) {
+		return nil, ErrCorrupt
+	}
+
+	return tiff[offset : offset+length], nil
+}
+
+// readCoordinate returns the coordinate of the degrees, minutes and seconds
+// rationals, it's negative when the reference is negativeRef
+func readCoordinate(tiff []byte, order binary.ByteOrder, entries []entry, refTag uint16, valueTag uint16, negativeRef byte) (float64, bool, error) {
+	refEntry, okRef := findEntry(entries, refTag)
+	valueEntry, okValue := findEntry(entries, valueTag)
+
+	if !okRef || !okValue {
+		return 0, false, nil
+	}
+
+	if valueEntry.kind != typeRational || valueEntry.count != 3 || refEntry.kind != typeASCII {
+		return 0, false, ErrCorrupt
+	}
+
+	ref, err := entryData(tiff, order, refEntry)
+	if err != nil {
+		return 0, false, err
+	}
+
+	data, err := entryData(tiff, order, valueEntry)
+	if err != nil {
+		return 0, false, err
+	}
+
+	coordinate := 0.0
+
+	for i, unit := range []float64{1, 60, 3600} {
+		numerator := order.Uint32(data[i*8:])
+		denominator := order.Uint32(data[i*8+4:])
+
+		if denominator == 0 {
+			return 0, false, ErrCorrupt
+		}
+
+		coordinate += float64(numerator) / float64(denominator) / unit
+	}
+
+	if len(ref) > 0 && ref[0] == negativeRef {
+		coordinate = -coordinate
+	}
+
+	return coordinate, true, nil
+}
+
+// addGPS appends a copy of IFD0 with the offset of a new GPS IFD and the GPS
+// IFD to the TIFF data, the header points to the new IFD0. The values of the
+// entries of IFD0 are kept in their offsets, the old IFD0 and GPS IFD aren't
+// used anymore. An empty TIFF data is created with an empty IFD0
+func addGPS(tiff []byte, latitude float64, longitude float64, gpsTime time.Time) ([]byte, error) {
+	if len(tiff) == 0 {
+		tiff = []byte{'I', 'I', 42, 0, 8, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	}
+
+	order, ifd0, err := readHeader(tiff)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, next, err := readIFD(tiff, order, ifd0)
+	if err != nil {
+		return nil, err
+	}
+
+	// the IFDs start at even offsets
+	if len(tiff)%2 == 1 {
+		tiff = append(tiff, 0)
+	}
+
+	// the entries of IFD0 without the old GPS IFD, plus the new one
+	var ifd0Entries []entry
+	for _, e := range entries {
+		if e.tag != tagGPSInfo {
+			ifd0Entries = append(ifd0Entries, e)
+		}
+	}
+
+	ifd0Entries = append(ifd0Entries, entry{tag: tagGPSInfo, kind: typeLong, count: 1})
+
+	newIFD0 := uint32(len(tiff))
+	gpsIFD := newIFD0 + ifdSize(len(ifd0Entries))
+
+	for i := range ifd0Entries {
+		if ifd0Entries[i].tag == tagGPSInfo {
+			order.PutUint32(ifd0Entries[i].value[:], gpsIFD)
+		}
+	}
+
+	latitudeRef, longitudeRef := "N\x00", "E\x00"
+	if latitude < 0 {
+		latitudeRef = "S\x00"
+	}
+
+	if longitude < 0 {
+		longitudeRef = "W\x00"
+	}
+
+	gpsEntries := []entry{
+		{tag: tagVersionID, kind: typeByte, count: 4, data: []byte{2, 3, 0, 0}},
+		{tag: tagLatitudeRef, kind: typeASCII, count: 2, data: []byte(latitudeRef)},
+		{tag: tagLatitude, kind: typeRational, count: 3, data: degreesRationals(order, latitude)},
+		{tag: tagLongitudeRef, kind: typeASCII, count: 2, data: []byte(longitudeRef)},
+		{tag: tagLongitude, kind: typeRational, count: 3, data: degreesRationals(order, longitude)},
+		{tag: tagTimeStamp, kind: typeRational, count: 3, data: rationals(order, [][2]uint32{{uint32(gpsTime.Hour()), 1}, {uint32(gpsTime.Minute()), 1}, {uint32(gpsTime.Second()), 1}})},
+		{tag: tagDateStamp, kind: typeASCII, count: 11, data: []byte(gpsTime.Format("2006:01:02") + "\x00")},
+	}
+
+	tiff = writeIFD(tiff, order, ifd0Entries, next)
+	tiff = writeIFD(tiff, order, gpsEntries, 0)
+
+	order.PutUint32(tiff[4:], newIFD0)
+
+	return tiff, nil
+}
+
+func ifdSize(entries int) uint32 {
+	return uint32(2 + entries*12 + 4)
+}
+
+// writeIFD appends the IFD sorted by tag and the values of its new entries
+// that don't fit in 4 bytes
+func writeIFD(tiff []byte, order binary.ByteOrder, entries []entry, next uint32) []byte {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].tag < entries[j].tag })
+
+	dataOffset := uint32(len(tiff)) + ifdSize(len(entries))
+
+	var data []byte
+
+	ifd := make([]byte, ifdSize(len(entries)))
+	order.PutUint16(ifd, uint16(len(entries)))
+
+	for i, e := range entries {
+		position := 2 + i*12
+
+		order.PutUint16(ifd[position:], e.tag)
+		order.PutUint16(ifd[position+2:], e.kind)
+		order.PutUint32(ifd[position+4:], e.count)
+
+		switch {
+		case e.data == nil:
+			copy(ifd[position+8:], e.value[:])
+		case len(e.data) <= 4:
+			copy(ifd[position+8:], e.data)
+		default:
+			order.PutUint32(ifd[position+8:], dataOffset+uint32(len(data)))
+
+			data = append(data, e.data...)
+			if len(data)%2 == 1 {
+				data = append(data, 0)
+			}
+		}
+	}
+
+	order.PutUint32(ifd[len(ifd)-4:], next)
+
+	tiff = append(tiff, ifd...)
+
+	return append(tiff, data...)
+}
+
+func rationals(order binary.ByteOrder, values [][2]uint32) []byte {
+	data := make([]byte, len(values)*8)
+
+	for i, value := range values {
+		order.PutUint32(data[i*8:], value[0])
+		order.PutUint32(data[i*8+4:], value[1])
+	}
+
+	return data
+}
+
+// degreesRationals returns the degrees, minutes and seconds of the coordinate,
+// the seconds have 4 decimals, about 3 mm
+func degreesRationals(order binary.ByteOrder, coordinate float64) []byte {
+	total := uint64(math.Round(math.Abs(coordinate) * 3600 * 10000))
+
+	degrees := total / (3600 * 10000)
+	minutes := total % (3600 * 10000) / (60 * 10000)
+	seconds := total % (60 * 10000)
+
+	return rationals(order, [][2]uint32{{uint32(degrees), 1}, {uint32(minutes), 1}, {uint32(seconds), 10000}})
+}
@@ -0,0 +1,196 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/jpeg"
+	"math"
+	"testing"
+	"time"
+)
+
+var gpsTime = time.Date(2024, 5, 1, 14, 30, 15, 0, time.UTC)
+
+func testJPEG(t *testing.T) []byte {
+	t.Helper()
+
+	var buffer bytes.Buffer
+
+	err := jpeg.Encode(&buffer, image.NewGray(image.Rect(0, 0, 16, 16)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buffer.Bytes()
+}
+
+// cameraJPEG returns a JPEG with a JFIF APP0 segment and big endian EXIF data
+// with the Make tag, like the photos of the cameras
+func cameraJPEG(t *testing.T) []byte {
+	t.Helper()
+
+	plain := testJPEG(t)
+
+	order := binary.BigEndian
+	tiff := []byte{'M', 'M', 0, 42, 0, 0, 0, 8}
+
+	ifd := make([]byte, ifdSize(1))
+	order.PutUint16(ifd, 1)
+	order.PutUint16(ifd[2:], 0x010F)
+	order.PutUint16(ifd[4:], typeASCII)
+	order.PutUint32(ifd[6:], 10)
+	order.PutUint32(ifd[10:], uint32(len(tiff)+len(ifd)))
+
+	tiff = append(tiff, ifd...)
+	tiff = append(tiff, "Raspberry\x00"...)
+
+	app0 := []byte{0xFF, markerAPP0, 0, 16, 'J', 'F', 'I', 'F', 0, 1, 1, 0, 0, 1, 0, 1, 0, 0}
+
+	length := 2 + len(exifHeader) + len(tiff)
+	app1 := append([]byte{0xFF, markerAPP1, byte(length >> 8), byte(length)}, exifHeader...)
+	app1 = append(app1, tiff...)
+
+	result := append([]byte{}, plain[:2]...)
+	result = append(result, app0...)
+	result = append(result, app1...)
+
+	return append(result, plain[2:]...)
+}
+
+func TestSetGPS(t *testing.T) {
+	tests := []struct {
+		name      string
+		jpeg      func(t *testing.T) []byte
+		latitude  float64
+		longitude float64
+	}{
+		{"Without EXIF", testJPEG, 52.5200066, 13.404954},
+		{"With EXIF", cameraJPEG, 52.5200066, 13.404954},
+		{"South West", testJPEG, -33.8567844, -70.6482668},
+		{"Limits", cameraJPEG, -90, 180},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := tt.jpeg(t)
+
+			tagged, err := SetGPS(original, tt.latitude, tt.longitude, gpsTime)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			checkGPS(t, tagged, tt.latitude, tt.longitude)
+
+			// the GPS tags are replaced
+			tagged, err = SetGPS(tagged, 1, 2, gpsTime)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			checkGPS(t, tagged, 1, 2)
+
+			if _, err := jpeg.Decode(bytes.NewReader(tagged)); err != nil {
+				t.Errorf("want a valid JPEG; got %v", err)
+			}
+
+			if !bytes.HasSuffix(tagged, original[len(original)-100:]) {
+				t.Error("want the image data unchanged")
+			}
+		})
+	}
+}
+
+func checkGPS(t *testing.T, tagged []byte, wantLatitude float64, wantLongitude float64) {
+	t.Helper()
+
+	latitude, longitude, ok, err := GPS(tagged)
+	if err != nil || !ok {
+		t.Fatalf("want the GPS tags; got %v %v", ok, err)
+	}
+
+	// the seconds have 4 decimals
+	if math.Abs(latitude-wantLatitude) > 1e-7 || math.Abs(longitude-wantLongitude) > 1e-7 {
+		t.Errorf("want %v,%v; got %v,%v", wantLatitude, wantLongitude, latitude, longitude)
+	}
+}
+
+func TestSetGPSKeepsTags(t *testing.T) {
+	tagged, err := SetGPS(cameraJPEG(t), 52.52, 13.405, gpsTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	segments, err := readSegments(tagged)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(segments) < 2 || segments[0].marker != markerAPP0 || segments[1].marker != markerAPP1 {
+		t.Fatalf("want the APP0 segment and then the EXIF data; got %+v", segments)
+	}
+
+	tiff := tagged[segments[1].start+4+len(exifHeader) : segments[1].end]
+
+	order, ifd0, err := readHeader(tiff)
+	if err != nil || order != binary.BigEndian {
+		t.Fatalf("want the big endian EXIF data; got %v %v", order, err)
+	}
+
+	entries, _, err := readIFD(tiff, order, ifd0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	makeEntry, ok := findEntry(entries, 0x010F)
+	if !ok {
+		t.Fatal("want the Make tag")
+	}
+
+	value, err := entryData(tiff, order, makeEntry)
+	if err != nil || string(value) != "Raspberry\x00" {
+		t.Errorf("want the Make Raspberry; got %q %v", value, err)
+	}
+
+	gpsEntry, _ := findEntry(entries, tagGPSInfo)
+
+	gpsEntries, _, err := readIFD(tiff, order, order.Uint32(gpsEntry.value[:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	date, _ := findEntry(gpsEntries, tagDateStamp)
+
+	value, err = entryData(tiff, order, date)
+	if err != nil || string(value) != "2024:05:01\x00" {
+		t.Errorf("want the date 2024:05:01; got %q %v", value, err)
+	}
+}
+
+func TestGPSErrors(t *testing.T) {
+	if _, err := SetGPS([]byte("GIF89a"), 0, 0, gpsTime); !errors.Is(err, ErrNotJPEG) {
+		t.Errorf("want ErrNotJPEG; got %v", err)
+	}
+
+	if _, err := SetGPS(testJPEG(t), 91, 0, gpsTime); err == nil {
+		t.Error("want an error of the invalid latitude")
+	}
+
+	if _, _, ok, err := GPS(testJPEG(t)); ok || err != nil {
+		t.Errorf("want no GPS tags; got %v %v", ok, err)
+	}
+
+	// the offset of IFD0 is after the end of the EXIF data
+	corrupt := cameraJPEG(t)
+	segments, _ := readSegments(corrupt)
+	binary.BigEndian.PutUint32(corrupt[segments[1].start+4+len(exifHeader)+4:], 5000)
+
+	if _, _, _, err := GPS(corrupt); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("want ErrCorrupt; got %v", err)
+	}
+
+	if _, err := SetGPS(corrupt, 0, 0, gpsTime); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("want ErrCorrupt; got %v", err)
+	}
+}
@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jempe/gopicam/pkg/db"
+)
+
+const defaultAuditLimit = 100
+const maxAuditLimit = 1000
+
+type auditContextKey struct{}
+
+// auditRecord collects the details of the request that the handlers know, like
+// the command sent to raspimjpeg
+type auditRecord struct {
+	command string
+	result  string
+	// user and authMethod are set by the handlers that don't use the session,
+	// like the requests signed by the devices
+	user       string
+	authMethod string
+}
+
+type AuditListResponse struct {
+	Audit        []db.Audit `json:"audit"`
+	TotalResults *int64     `json:"total_results,omitempty"`
+	NextCursor   string     `json:"next_cursor,omitempty"`
+	Status       string     `json:"status"`
+}
+
+// statusRecorder keeps the status code of the response for the audit log
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (recorder *statusRecorder) WriteHeader(status int) {
+	recorder.status = status
+	recorder.ResponseWriter.WriteHeader(status)
+}
+
+func (recorder *statusRecorder) Unwrap() http.ResponseWriter {
+	return recorder.ResponseWriter
+}
+
+// AuditLog saves the API requests that change the state of the camera or the
+// accounts in the audit bucket. It must be wrapped by the LoadAndSave handler of
+// the session manager and wrap the CSRFProtect handler so rejected requests are logged too
+func (srv *Server) AuditLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the trackers send the locations with GET requests, the requests of the
+		// devices are always logged
+		readOnly := r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions
+		if (readOnly && !csrfExemptPaths[r.URL.Path]) || !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// the session is destroyed on logout, get the user before running the handler
+		username := srv.Sessions.GetString(r.Context(), "username")
+
+		record := &auditRecord{}
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), auditContextKey{}, record)))
+
+		if username == "" {
+			username = srv.Sessions.GetString(r.Context(), "username")
+		}
+
+		authMethod := "session"
+		if record.authMethod != "" {
+			username, authMethod = record.user, record.authMethod
+		}
+
+		result := strconv.Itoa(recorder.status) + " " + http.StatusText(recorder.status)
+		if record.result != "" {
+			result += ": " + record.result
+		}
+
+		_, err := srv.Db.InsertAudit(db.Audit{
+			User:       truncate(username, 100),
+			AuthMethod: authMethod,
+			IP:         remoteIP(r),
+			Method:     r.Method,
+			Endpoint:   truncate(r.URL.Path, 2083),
+			Command:    truncate(record.command, 200),
+			Result:     truncate(result, 2083),
+		}, []string{})
+		if err != nil {
+			srv.LogError.Println("Audit:", err)
+		}
+	})
+}
+
+// auditCommand saves the command sent to raspimjpeg in the audit entry of the request
+func auditCommand(r *http.Request, command string) {
+	if record, ok := r.Context().Value(auditContextKey{}).(*auditRecord); ok {
+		record.command = command
+	}
+}
+
+// auditDevice saves the device that signed the request in the audit entry
+func auditDevice(r *http.Request, device db.Device) {
+	if record, ok := r.Context().Value(auditContextKey{}).(*auditRecord); ok {
+		record.user = device.Name
+		record.authMethod = "device"
+	}
+}
+
+// auditResult adds details about the result to the audit entry of the request
+func auditResult(r *http.Request, result string) {
+	if record, ok := r.Context().Value(auditContextKey{}).(*auditRecord); ok {
+		record.result = result
+	}
+}
+
+// PruneAuditLog deletes the audit entries older than the retention time every
+// hour, a zero retention keeps the entries forever
+func (srv *Server) PruneAuditLog(retention time.Duration) {
+	if retention <= 0 {
+		return
+	}
+
+	for {
+		rowsAffected, err := srv.Db.DeleteAuditsBefore(time.Now().UTC().Add(-retention))
+		if err != nil {
+			srv.LogError.Println("Audit:", err)
+		} else if rowsAffected > 0 {
+			srv.LogInfo.Println("Audit: deleted", rowsAffected, "entries older than", retention)
+		}
+
+		time.Sleep(time.Hour)
+	}
+}
+
+// handler to query the audit log, it accepts the parameters filter=<expression>
+// or filter=<Field>:<Comparison>:<Value> (repeated), operator=AND|OR, sort=<Field>,
+// direction=ASC|DESC, offset, limit and format=json|csv. The cursor parameter
+// replaces the offset, it's empty for the first page and then the next_cursor
+// of the previous response, the cursor pages don't include the total results
+func (srv *Server) AuditHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		setSecureHeaders(w, "json")
+		returnCode405(w, r)
+		return
+	}
+
+	if _, ok := srv.currentSession(r); !ok {
+		setSecureHeaders(w, "json")
+		returnCode401(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+
+	filters, err := parseFilterParams(query["filter"], query.Get("operator"))
+	if err != nil {
+		setSecureHeaders(w, "json")
+		returnCode400(w, r)
+		return
+	}
+
+	sortBy := db.SortBy{Field: "Created", Direction: "DESC"}
+	if query.Get("sort") != "" {
+		sortBy.Field = query.Get("sort")
+	}
+	if query.Get("direction") != "" {
+		sortBy.Direction = strings.ToUpper(query.Get("direction"))
+	}
+
+	offset, _ := strconv.Atoi(query.Get("offset"))
+
+	limit, limitErr := strconv.Atoi(query.Get("limit"))
+	if limitErr != nil || limit <= 0 {
+		limit = defaultAuditLimit
+	}
+
+	csvFormat := query.Get("format") == "csv"
+
+	// the CSV export returns all the results
+	if !csvFormat && limit > maxAuditLimit {
+		limit = maxAuditLimit
+	} else if csvFormat && limitErr != nil {
+		limit = math.MaxInt32
+	}
+
+	var response AuditListResponse
+
+	_, cursorPagination := query["cursor"]
+
+	if cursorPagination && !csvFormat {
+		response.Audit, response.NextCursor, err = srv.Db.GetAuditPage(query.Get("cursor"), limit, filters, []string{}, sortBy)
+	} else {
+		var totalResults int64
+
+		response.Audit, totalResults, err = srv.Db.GetAuditList(offset, limit, filters, []string{}, sortBy)
+		response.TotalResults = &totalResults
+	}
+
+	if err != nil {
+		srv.LogError.Println(err)
+		setSecureHeaders(w, "json")
+		returnCode400(w, r)
+		return
+	}
+
+	if csvFormat {
+		srv.writeAuditCSV(w, response.Audit)
+		return
+	}
+
+	setSecureHeaders(w, "json")
+
+	response.Status = "success"
+	if response.Audit == nil {
+		response.Audit = []db.Audit{}
+	}
+
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		srv.LogError.Println(err)
+	}
+
+	fmt.Fprintln(w, string(responseJSON))
+}
+
+func (srv *Server) writeAuditCSV(w http.ResponseWriter, auditList []db.Audit) {
+	w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	w.Header().Set("Content-Type", "text/csv;charset=utf-8")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"gopicam-audit-"+time.Now().UTC().Format("20060102-150405")+".csv\"")
+
+	writer := csv.NewWriter(w)
+
+	writer.Write([]string{"id", "created", "user", "auth_method", "ip", "method", "endpoint", "command", "result"})
+
+	for _, audit := range auditList {
+		writer.Write([]string{audit.ID, audit.Created.Format(time.RFC3339), audit.User, audit.AuthMethod, audit.IP, audit.Method, audit.Endpoint, audit.Command, audit.Result})
+	}
+
+	writer.Flush()
+
+	if err := writer.Error(); err != nil {
+		srv.LogError.Println(err)
+	}
+}
+
+// filterParamRegexp matches the filter parameters with the <Field>:<Comparison>:<Value> format
+var filterParamRegexp = regexp.MustCompile(`^(\w+):(LIKE|NOT LIKE|=|!=|>|>=|<|<=):(.*)$`)
+
+// parseFilterParams converts the filter parameters to the Filters of the DB and
+// combines them with the operator. A parameter is a filter expression like
+// created>2024-01-01 AND method IN (POST,DELETE), see db.ParseFilter, or a
+// single condition with the format <Field>:<Comparison>:<Value>
+func parseFilterParams(filterParams []string, operator string) (filters db.Filters, err error) {
+	filters.Operator = "AND"
+
+	if operator != "" {
+		filters.Operator = strings.ToUpper(operator)
+	}
+
+	if filters.Operator != "AND" && filters.Operator != "OR" {
+		err = errors.New("invalid filter operator " + operator)
+		return
+	}
+
+	for _, filterParam := range filterParams {
+		if filterParts := filterParamRegexp.FindStringSubmatch(filterParam); filterParts != nil {
+			filters.Conditions = append(filters.Conditions, db.Condition{Field: filterParts[1], Comparison: filterParts[2], Value: filterParts[3]})
+			continue
+		}
+
+		var paramFilters db.Filters
+
+		paramFilters, err = db.ParseFilter(filterParam)
+		if err != nil {
+			return
+		}
+
+		if len(paramFilters.Conditions) == 1 && len(paramFilters.Groups) == 0 {
+			filters.Conditions = append(filters.Conditions, paramFilters.Conditions[0])
+		} else if len(paramFilters.Conditions) > 0 || len(paramFilters.Groups) > 0 {
+			filters.Groups = append(filters.Groups, paramFilters)
+		}
+	}
+
+	return
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/jempe/gopicam/pkg/backup"
+)
+
+// handler to download a backup of the DBs, it accepts the form values gzip=1 to
+// compress the backup and passphrase to encrypt it. It's a POST request so the
+// passphrase isn't saved in the URL and the download is in the audit log
+func (srv *Server) BackupHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		setSecureHeaders(w, "json")
+		returnCode405(w, r)
+		return
+	}
+
+	if _, ok := srv.currentSession(r); !ok {
+		setSecureHeaders(w, "json")
+		returnCode401(w, r)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		setSecureHeaders(w, "json")
+		returnCode400(w, r)
+		return
+	}
+
+	options := backup.Options{
+		Compress:   r.PostForm.Get("gzip") == "1" || r.PostForm.Get("gzip") == "true",
+		Passphrase: r.PostForm.Get("passphrase"),
+	}
+
+	fileName := backup.FileName(time.Now(), options)
+
+	w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+fileName+"\"")
+
+	// the headers are already sent when the copy fails, the error is only logged
+	err = backup.Write(w, srv.BackupFiles, options)
+	if err != nil {
+		srv.LogError.Println("Backup:", err)
+		auditResult(r, "backup error")
+		return
+	}
+
+	auditResult(r, fileName)
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const csrfHeader = "X-CSRF-Token"
+const csrfFormField = "csrf_token"
+const csrfSessionKey = "csrf_token"
+
+// paths that are called before the user has a session, they are only
+// protected by the Origin and Referer checks so one of the headers is required
+var csrfTokenExemptPaths = map[string]bool{
+	"/api/login": true,
+	"/api/setup": true,
+}
+
+// paths of the requests signed by the devices, they don't come from a browser
+// and don't have a session
+var csrfExemptPaths = map[string]bool{
+	"/api/ingest":           true,
+	"/api/ingest/owntracks": true,
+	"/api/ingest/osmand":    true,
+}
+
+type CSRFResponse struct {
+	Token  string `json:"csrf_token"`
+	Status string `json:"status"`
+}
+
+// CSRFProtect rejects the state changing requests that come from other sites,
+// it checks the Origin or Referer header and the synchronizer token saved in the
+// session. It must be wrapped by the LoadAndSave handler of the session manager
+func (srv *Server) CSRFProtect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions || csrfExemptPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !sameOrigin(r) {
+			srv.LogError.Println("CSRF: cross origin request rejected", r.Method, r.URL.Path, r.Header.Get("Origin"), r.Header.Get("Referer"))
+			setSecureHeaders(w, "json")
+			returnCode403(w, r)
+			return
+		}
+
+		if csrfTokenExemptPaths[r.URL.Path] {
+			if r.Header.Get("Origin") == "" && r.Header.Get("Referer") == "" {
+				srv.LogError.Println("CSRF: request without origin rejected", r.Method, r.URL.Path)
+				setSecureHeaders(w, "json")
+				returnCode403(w, r)
+				return
+			}
+		} else if !srv.validCSRFToken(r) {
+			srv.LogError.Println("CSRF: invalid token", r.Method, r.URL.Path)
+			setSecureHeaders(w, "json")
+			returnCode403(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handler that returns the CSRF token of the session, the token has to be sent
+// in the X-CSRF-Token header of POST and DELETE requests
+func (srv *Server) CSRFTokenHandler(w http.ResponseWriter, r *http.Request) {
+	setSecureHeaders(w, "json")
+
+	if r.Method != http.MethodGet {
+		returnCode405(w, r)
+		return
+	}
+
+	token, err := srv.csrfToken(r)
+	if err != nil {
+		srv.LogError.Println(err)
+		returnCode500(w, r)
+		return
+	}
+
+	responseJSON, err := json.Marshal(CSRFResponse{Token: token, Status: "success"})
+	if err != nil {
+		srv.LogError.Println(err)
+	}
+
+	fmt.Fprintln(w, string(responseJSON))
+}
+
+// csrfToken returns the token of the session and creates it if it doesn't exist
+func (srv *Server) csrfToken(r *http.Request) (string, error) {
+	token := srv.Sessions.GetString(r.Context(), csrfSessionKey)
+
+	if token != "" {
+		return token, nil
+	}
+
+	return srv.renewCSRFToken(r)
+}
+
+// renewCSRFToken replaces the token of the session, it's called when the user logs in
+func (srv *Server) renewCSRFToken(r *http.Request) (string, error) {
+	tokenBytes := make([]byte, 32)
+
+	_, err := rand.Read(tokenBytes)
+	if err != nil {
+		return "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(tokenBytes)
+
+	srv.Sessions.Put(r.Context(), csrfSessionKey, token)
+
+	return token, nil
+}
+
+func (srv *Server) validCSRFToken(r *http.Request) bool {
+	sessionToken := srv.Sessions.GetString(r.Context(), csrfSessionKey)
+	if sessionToken == "" {
+		return false
+	}
+
+	requestToken := r.Header.Get(csrfHeader)
+	if requestToken == "" {
+		requestToken = r.PostFormValue(csrfFormField)
+	}
+
+	return subtle.ConstantTimeCompare([]byte(sessionToken), []byte(requestToken)) == 1
+}
+
+// sameOrigin checks that the Origin header, or the Referer when the browser
+// doesn't send the Origin, points to the host of the request. Requests without
+// both headers don't come from a browser and rely on the token check, except
+// the token exempt paths that reject them
+func sameOrigin(r *http.Request) bool {
+	source := r.Header.Get("Origin")
+	if source == "" {
+		source = r.Header.Get("Referer")
+	}
+
+	if source == "" {
+		return true
+	}
+
+	sourceURL, err := url.Parse(source)
+	if err != nil {
+		return false
+	}
+
+	return sourceURL.Host == r.Host
+}
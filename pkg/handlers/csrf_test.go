@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestSameOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		origin  string
+		referer string
+		want    bool
+	}{
+		{name: "Same Origin", origin: "https://camera.local", want: true},
+		{name: "Other Origin", origin: "https://evil.example", want: false},
+		{name: "Same Referer", referer: "https://camera.local/index.html", want: true},
+		{name: "Other Referer", referer: "https://evil.example/index.html", want: false},
+		{name: "Origin Before Referer", origin: "https://evil.example", referer: "https://camera.local/", want: false},
+		{name: "Invalid Origin", origin: "://camera.local", want: false},
+		{name: "No Headers", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "https://camera.local/api/camera/photo/take", nil)
+
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+
+			if tt.referer != "" {
+				r.Header.Set("Referer", tt.referer)
+			}
+
+			if got := sameOrigin(r); got != tt.want {
+				t.Errorf("want %v; got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCSRFProtect(t *testing.T) {
+	srv := newTestServer(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/csrf", srv.CSRFTokenHandler)
+	mux.HandleFunc("/api/login", srv.LoginHandler)
+	mux.HandleFunc("/api/camera/photo/take", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	c := newTestClient(t, srv.Sessions.LoadAndSave(srv.CSRFProtect(mux)))
+
+	status, body := c.do(http.MethodGet, "/api/csrf", nil, nil)
+	if status != http.StatusOK {
+		t.Fatalf("want the CSRF token; got %d %s", status, body)
+	}
+
+	var response CSRFResponse
+
+	err := json.Unmarshal([]byte(body), &response)
+	if err != nil || response.Token == "" {
+		t.Fatalf("want the CSRF token; got %s %v", body, err)
+	}
+
+	withToken := c.sameOriginHeader()
+	withToken.Set(csrfHeader, response.Token)
+
+	crossOrigin := http.Header{"Origin": {"https://evil.example"}, csrfHeader: {response.Token}}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		header http.Header
+		want   int
+	}{
+		{name: "Safe Method", method: http.MethodGet, path: "/api/camera/photo/take", want: http.StatusOK},
+		{name: "Token", method: http.MethodPost, path: "/api/camera/photo/take", header: withToken, want: http.StatusOK},
+		{name: "No Token", method: http.MethodPost, path: "/api/camera/photo/take", header: c.sameOriginHeader(), want: http.StatusForbidden},
+		{name: "Wrong Token", method: http.MethodPost, path: "/api/camera/photo/take", header: http.Header{"Origin": {c.server.URL}, csrfHeader: {"wrong"}}, want: http.StatusForbidden},
+		{name: "Cross Origin", method: http.MethodPost, path: "/api/camera/photo/take", header: crossOrigin, want: http.StatusForbidden},
+		{name: "Exempt Path", method: http.MethodPost, path: "/api/login", header: c.sameOriginHeader(), want: http.StatusOK},
+		{name: "Exempt Path Cross Origin", method: http.MethodPost, path: "/api/login", header: http.Header{"Origin": {"https://evil.example"}}, want: http.StatusForbidden},
+		{name: "Exempt Path Without Origin", method: http.MethodPost, path: "/api/login", want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := c.do(tt.method, tt.path, url.Values{"username": {"admin1"}, "password": {testPassword}}, tt.header)
+			if status != tt.want {
+				t.Errorf("want %d; got %d %s", tt.want, status, body)
+			}
+		})
+	}
+}
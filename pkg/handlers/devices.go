@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jempe/gopicam/pkg/db"
+)
+
+// maxDevices is the maximum number of devices in the list
+const maxDevices = 1000
+
+// DeviceResponse is a device without its secret, the secret is only returned
+// when the device is enrolled
+type DeviceResponse struct {
+	ID      string    `json:"id"`
+	Key     string    `json:"key"`
+	Name    string    `json:"name"`
+	Secret  string    `json:"secret,omitempty"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+type DeviceListResponse struct {
+	Devices []DeviceResponse `json:"devices"`
+	Status  string           `json:"status"`
+}
+
+type DeviceEnrollResponse struct {
+	Device DeviceResponse `json:"device"`
+	Status string         `json:"status"`
+}
+
+// handler of the devices that send their locations to /api/ingest, GET lists
+// them, POST enrolls a device with the form value name and DELETE ?id= removes
+// one. The response of POST has the key and the secret of the device, the
+// secret isn't returned again
+func (srv *Server) DevicesHandler(w http.ResponseWriter, r *http.Request) {
+	setSecureHeaders(w, "json")
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		returnCode405(w, r)
+		return
+	}
+
+	if _, ok := srv.currentSession(r); !ok {
+		returnCode401(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		err := r.ParseForm()
+		if err != nil {
+			returnCode400(w, r)
+			return
+		}
+
+		device, err := srv.Db.EnrollDevice(r.PostForm.Get("name"))
+		if err != nil {
+			srv.LogError.Println(err)
+			auditResult(r, truncate(err.Error(), 200))
+			returnCode400(w, r)
+			return
+		}
+
+		srv.LogInfo.Println("Device enrolled", device.Name, device.Key)
+		auditResult(r, "enrolled device "+device.ID+" "+device.Name)
+
+		response := DeviceEnrollResponse{Device: newDeviceResponse(device), Status: "success"}
+		response.Device.Secret = device.Secret
+
+		responseJSON, err := json.Marshal(response)
+		if err != nil {
+			srv.LogError.Println(err)
+		}
+
+		fmt.Fprintln(w, string(responseJSON))
+	case http.MethodDelete:
+		deviceID := r.URL.Query().Get("id")
+
+		device, err := srv.Db.GetDevice(deviceID)
+		if err != nil {
+			returnCode404(w, r)
+			return
+		}
+
+		_, err = srv.Db.DeleteDevice(device.ID)
+		if err != nil {
+			srv.LogError.Println(err)
+			returnCode500(w, r)
+			return
+		}
+
+		srv.LogInfo.Println("Device removed", device.Name, device.Key)
+		auditResult(r, "removed device "+device.ID+" "+device.Name)
+
+		fmt.Fprintln(w, "{\"status\": \"success\"}")
+	default:
+		devices, _, err := srv.Db.GetDeviceList(0, maxDevices, db.Filters{Operator: "AND"}, []string{}, db.SortBy{Field: "Name", Direction: "ASC"})
+		if err != nil {
+			srv.LogError.Println(err)
+			returnCode500(w, r)
+			return
+		}
+
+		response := DeviceListResponse{Devices: []DeviceResponse{}, Status: "success"}
+
+		for _, device := range devices {
+			response.Devices = append(response.Devices, newDeviceResponse(device))
+		}
+
+		responseJSON, err := json.Marshal(response)
+		if err != nil {
+			srv.LogError.Println(err)
+		}
+
+		fmt.Fprintln(w, string(responseJSON))
+	}
+}
+
+func newDeviceResponse(device db.Device) DeviceResponse {
+	return DeviceResponse{
+		ID:      device.ID,
+		Key:     device.Key,
+		Name:    device.Name,
+		Created: device.Created,
+		Updated: device.Updated,
+	}
+}
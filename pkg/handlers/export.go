@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jempe/gopicam/pkg/db"
+)
+
+// maxImportSize is the maximum size of the files of the imports
+const maxImportSize = 64 << 20
+
+type ImportResponse struct {
+	db.ImportResult
+	Status string `json:"status"`
+}
+
+// responseTracker records if the response has been written, the errors of the
+// exports can only be returned before the first write
+type responseTracker struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (tracker *responseTracker) Write(p []byte) (int, error) {
+	tracker.written = true
+	return tracker.ResponseWriter.Write(p)
+}
+
+// handler to download the items of a bucket, the URL is /api/export/<bucket> and
+// it accepts the parameters format=jsonl|csv and filter=<expression> with
+// operator=AND|OR like the audit log
+func (srv *Server) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		setSecureHeaders(w, "json")
+		returnCode405(w, r)
+		return
+	}
+
+	if _, ok := srv.currentSession(r); !ok {
+		setSecureHeaders(w, "json")
+		returnCode401(w, r)
+		return
+	}
+
+	bucket := strings.TrimPrefix(r.URL.Path, "/api/export/")
+
+	repo, err := srv.Db.Exportable(bucket)
+	if err != nil {
+		setSecureHeaders(w, "json")
+		returnCode404(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+
+	format := query.Get("format")
+	if format == "" {
+		format = db.FormatJSONL
+	}
+
+	filters, err := parseFilterParams(query["filter"], query.Get("operator"))
+	if err != nil || (format != db.FormatJSONL && format != db.FormatCSV) {
+		setSecureHeaders(w, "json")
+		returnCode400(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	if format == db.FormatCSV {
+		w.Header().Set("Content-Type", "text/csv;charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	w.Header().Set("Content-Disposition", "attachment; filename=\"gopicam-"+bucket+"-"+time.Now().UTC().Format("20060102-150405")+"."+format+"\"")
+
+	tracker := &responseTracker{ResponseWriter: w}
+
+	_, err = repo.Export(tracker, format, filters)
+	if err != nil {
+		srv.LogError.Println(err)
+
+		if !tracker.written {
+			setSecureHeaders(w, "json")
+			w.Header().Del("Content-Disposition")
+			returnCode400(w, r)
+		}
+	}
+}
+
+// handler to import a JSON Lines or CSV file to a bucket, the URL is
+// /api/import/<bucket> and the body is the file. It accepts the parameters
+// format=jsonl|csv and dry_run=1 to only validate the rows. The response has the
+// number of imported rows and the errors of the invalid rows
+func (srv *Server) ImportHandler(w http.ResponseWriter, r *http.Request) {
+	setSecureHeaders(w, "json")
+
+	if r.Method != http.MethodPost {
+		returnCode405(w, r)
+		return
+	}
+
+	if _, ok := srv.currentSession(r); !ok {
+		returnCode401(w, r)
+		return
+	}
+
+	bucket := strings.TrimPrefix(r.URL.Path, "/api/import/")
+
+	repo, err := srv.Db.Exportable(bucket)
+	if err != nil {
+		returnCode404(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+
+	format := query.Get("format")
+	if format == "" {
+		format = db.FormatJSONL
+	}
+
+	dryRun := query.Get("dry_run") == "1" || query.Get("dry_run") == "true"
+
+	result, err := repo.Import(http.MaxBytesReader(w, r.Body, maxImportSize), format, dryRun)
+	if err != nil {
+		srv.LogError.Println(err)
+		auditResult(r, truncate(err.Error(), 200))
+		returnCode400(w, r)
+		return
+	}
+
+	if dryRun {
+		auditResult(r, fmt.Sprintf("dry run of %s: %d valid rows, %d errors", bucket, result.Imported, result.Failed))
+	} else {
+		auditResult(r, fmt.Sprintf("imported %d %s, %d errors", result.Imported, bucket, result.Failed))
+	}
+
+	response := ImportResponse{ImportResult: result, Status: "success"}
+	if response.Errors == nil {
+		response.Errors = []db.ImportError{}
+	}
+
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		srv.LogError.Println(err)
+	}
+
+	fmt.Fprintln(w, string(responseJSON))
+}
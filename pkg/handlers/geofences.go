@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/jempe/gopicam/pkg/db"
+)
+
+// maxGeofenceSize is the maximum size of the body of a geofence
+const maxGeofenceSize = 16 << 10
+
+// maxGeofences is the maximum number of geofences in the list
+const maxGeofences = 1000
+
+const defaultGeofenceEventsLimit = 100
+const maxGeofenceEventsLimit = 1000
+
+// GeofenceResponse is a geofence with the states of the devices that sent
+// locations since it was created
+type GeofenceResponse struct {
+	db.Geofence
+	States []db.GeofenceState `json:"states"`
+}
+
+type GeofenceListResponse struct {
+	Geofences []GeofenceResponse `json:"geofences"`
+	Status    string             `json:"status"`
+}
+
+type GeofenceSaveResponse struct {
+	Geofence db.Geofence `json:"geofence"`
+	Status   string      `json:"status"`
+}
+
+// GeofenceErrorResponse has the error of the invalid geofences
+type GeofenceErrorResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+}
+
+type GeofenceEventsResponse struct {
+	Events     []db.GeofenceEvent `json:"events"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+	Status     string             `json:"status"`
+}
+
+// handler of the geofences, GET lists them with the states of the devices,
+// POST creates a geofence and PUT ?id= replaces one, the body is the JSON
+// geofence. DELETE ?id= removes one with its events
+func (srv *Server) GeofencesHandler(w http.ResponseWriter, r *http.Request) {
+	setSecureHeaders(w, "json")
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost && r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		returnCode405(w, r)
+		return
+	}
+
+	if _, ok := srv.currentSession(r); !ok {
+		returnCode401(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost, http.MethodPut:
+		var geofence db.Geofence
+
+		decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxGeofenceSize))
+		decoder.DisallowUnknownFields()
+
+		err := decoder.Decode(&geofence)
+		if err != nil {
+			srv.writeGeofenceError(w, r, err.Error())
+			return
+		}
+
+		if r.Method == http.MethodPost {
+			geofence.ID = ""
+			geofence.ID, err = srv.Db.InsertGeofence(geofence, []string{})
+		} else {
+			var saved db.Geofence
+
+			saved, err = srv.Db.GetGeofence(r.URL.Query().Get("id"))
+			if err != nil {
+				returnCode404(w, r)
+				return
+			}
+
+			geofence.ID, geofence.Created = saved.ID, saved.Created
+			_, err = srv.Db.UpdateGeofence(geofence, []string{})
+		}
+
+		if err != nil {
+			srv.writeGeofenceError(w, r, err.Error())
+			return
+		}
+
+		geofence, err = srv.Db.GetGeofence(geofence.ID)
+		if err != nil {
+			srv.LogError.Println(err)
+			returnCode500(w, r)
+			return
+		}
+
+		srv.LogInfo.Println("Geofence saved", geofence.ID, geofence.Name)
+		auditResult(r, "saved geofence "+geofence.ID+" "+geofence.Name)
+
+		responseJSON, err := json.Marshal(GeofenceSaveResponse{Geofence: geofence, Status: "success"})
+		if err != nil {
+			srv.LogError.Println(err)
+		}
+
+		fmt.Fprintln(w, string(responseJSON))
+	case http.MethodDelete:
+		geofence, err := srv.Db.GetGeofence(r.URL.Query().Get("id"))
+		if err != nil {
+			returnCode404(w, r)
+			return
+		}
+
+		_, err = srv.Db.DeleteGeofence(geofence.ID)
+		if err != nil {
+			srv.LogError.Println(err)
+			returnCode500(w, r)
+			return
+		}
+
+		srv.LogInfo.Println("Geofence removed", geofence.ID, geofence.Name)
+		auditResult(r, "removed geofence "+geofence.ID+" "+geofence.Name)
+
+		fmt.Fprintln(w, "{\"status\": \"success\"}")
+	default:
+		geofences, _, err := srv.Db.GetGeofenceList(0, maxGeofences, db.Filters{Operator: "AND"}, []string{}, db.SortBy{Field: "Name", Direction: "ASC"})
+		if err != nil {
+			srv.LogError.Println(err)
+			returnCode500(w, r)
+			return
+		}
+
+		response := GeofenceListResponse{Geofences: []GeofenceResponse{}, Status: "success"}
+
+		for _, geofence := range geofences {
+			states, err := srv.Db.GetGeofenceStates(geofence.ID)
+			if err != nil {
+				srv.LogError.Println(err)
+				returnCode500(w, r)
+				return
+			}
+
+			if states == nil {
+				states = []db.GeofenceState{}
+			}
+
+			response.Geofences = append(response.Geofences, GeofenceResponse{Geofence: geofence, States: states})
+		}
+
+		responseJSON, err := json.Marshal(response)
+		if err != nil {
+			srv.LogError.Println(err)
+		}
+
+		fmt.Fprintln(w, string(responseJSON))
+	}
+}
+
+// handler of the enter and exit events of the geofences, the newest first. It
+// accepts the parameters geofence and device to filter them, limit and cursor,
+// which is empty for the first page and then the next_cursor of the previous
+// response
+func (srv *Server) GeofenceEventsHandler(w http.ResponseWriter, r *http.Request) {
+	setSecureHeaders(w, "json")
+
+	if r.Method != http.MethodGet {
+		returnCode405(w, r)
+		return
+	}
+
+	if _, ok := srv.currentSession(r); !ok {
+		returnCode401(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+
+	limit := defaultGeofenceEventsLimit
+
+	if value := query.Get("limit"); value != "" {
+		var err error
+
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			returnCode400(w, r)
+			return
+		}
+	}
+
+	if limit > maxGeofenceEventsLimit {
+		limit = maxGeofenceEventsLimit
+	}
+
+	filters := db.Filters{Operator: "AND"}
+
+	for _, field := range []string{"geofence", "device"} {
+		if value := query.Get(field); value != "" {
+			filters.Conditions = append(filters.Conditions, db.Condition{Field: field, Comparison: "=", Value: value})
+		}
+	}
+
+	events, nextCursor, err := srv.Db.GetGeofenceEventPage(query.Get("cursor"), limit, filters, []string{}, db.SortBy{Field: "Created", Direction: "DESC"})
+	if err != nil {
+		returnCode400(w, r)
+		return
+	}
+
+	response := GeofenceEventsResponse{Events: events, NextCursor: nextCursor, Status: "success"}
+	if response.Events == nil {
+		response.Events = []db.GeofenceEvent{}
+	}
+
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		srv.LogError.Println(err)
+	}
+
+	fmt.Fprintln(w, string(responseJSON))
+}
+
+func (srv *Server) writeGeofenceError(w http.ResponseWriter, r *http.Request, message string) {
+	auditResult(r, truncate(message, 200))
+
+	responseJSON, err := json.Marshal(GeofenceErrorResponse{Status: "error", Error: message})
+	if err != nil {
+		srv.LogError.Println(err)
+	}
+
+	w.WriteHeader(http.StatusBadRequest)
+	fmt.Fprintln(w, string(responseJSON))
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jempe/gopicam/pkg/auth"
+	"github.com/jempe/gopicam/pkg/db"
+)
+
+// maxIngestSize is the maximum size of the body of the signed requests, the
+// body is saved in the Data field of the requests
+const maxIngestSize = 2083
+
+// maxClockSkew is the maximum difference between the timestamp of a signed
+// request and the time of the server, the older requests are rejected as stale
+const maxClockSkew = 5 * time.Minute
+
+// validNonce allows the nonces that can't change the signed message, like the
+// nonces with new lines
+var validNonce = regexp.MustCompile(`^[A-Za-z0-9_-]{8,64}$`)
+
+type IngestResponse struct {
+	RequestID string `json:"request_id"`
+	Status    string `json:"status"`
+}
+
+// handler of the requests of the enrolled devices, they are signed with the
+// secret of the device, see auth.SignRequest. The X-Gopicam-Key,
+// X-Gopicam-Timestamp (unix time), X-Gopicam-Nonce and X-Gopicam-Signature
+// headers are required. The body is saved in the requests bucket before it's
+// verified, the stale, replayed and malformed requests are rejected and kept
+// without device
+func (srv *Server) IngestHandler(w http.ResponseWriter, r *http.Request) {
+	setSecureHeaders(w, "json")
+
+	if r.Method != http.MethodPost {
+		returnCode405(w, r)
+		return
+	}
+
+	// the devices don't have a session, the rejected requests are saved in the
+	// audit log without user
+	auditDevice(r, db.Device{})
+
+	body, readErr := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIngestSize))
+
+	// the request is saved before it's verified, the rejected requests are kept
+	// without device
+	requestID, err := srv.saveDeviceRequest(r, body)
+	if err != nil {
+		srv.LogError.Println(err)
+		returnCode500(w, r)
+		return
+	}
+
+	if readErr != nil {
+		auditResult(r, "the body of the request "+requestID+" is too large")
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		w.Write([]byte("{\"status\": \"error\"}"))
+		return
+	}
+
+	device, err := srv.verifyDeviceRequest(r, body)
+	if err != nil {
+		srv.LogError.Println("Ingest:", err, remoteIP(r))
+		auditResult(r, "request "+requestID+": "+err.Error())
+		returnCode401(w, r)
+		return
+	}
+
+	auditDevice(r, device)
+
+	err = srv.setRequestDevice(requestID, device)
+	if err != nil {
+		srv.LogError.Println(err)
+		returnCode500(w, r)
+		return
+	}
+
+	if !utf8.Valid(body) {
+		auditResult(r, "the body of the request "+requestID+" isn't UTF-8")
+		returnCode400(w, r)
+		return
+	}
+
+	auditResult(r, "request "+requestID)
+
+	responseJSON, err := json.Marshal(IngestResponse{RequestID: requestID, Status: "success"})
+	if err != nil {
+		srv.LogError.Println(err)
+	}
+
+	fmt.Fprintln(w, string(responseJSON))
+}
+
+// verifyDeviceRequest returns the device that signed the request, the nonce of
+// the request is saved so it can't be replayed
+func (srv *Server) verifyDeviceRequest(r *http.Request, body []byte) (device db.Device, err error) {
+	key := r.Header.Get(auth.HeaderDeviceKey)
+	nonce := r.Header.Get(auth.HeaderNonce)
+	signature := r.Header.Get(auth.HeaderSignature)
+
+	if key == "" || signature == "" {
+		return device, errors.New("the request isn't signed")
+	}
+
+	if !validNonce.MatchString(nonce) {
+		return device, errors.New("invalid nonce")
+	}
+
+	timestamp, err := strconv.ParseInt(r.Header.Get(auth.HeaderTimestamp), 10, 64)
+	if err != nil {
+		return device, errors.New("invalid timestamp")
+	}
+
+	now := time.Now()
+
+	requestTime := time.Unix(timestamp, 0)
+	if requestTime.Before(now.Add(-maxClockSkew)) || requestTime.After(now.Add(maxClockSkew)) {
+		return device, errors.New("stale request of " + requestTime.UTC().Format(time.RFC3339))
+	}
+
+	device, err = srv.Db.GetDeviceByKey(key)
+	if err != nil {
+		return device, errors.New("unknown device key " + truncate(key, 100))
+	}
+
+	// the imported devices don't have a secret
+	if device.Secret == "" || !auth.VerifySignature(device.Secret, signature, timestamp, nonce, body) {
+		return device, errors.New("invalid signature of the device " + device.ID)
+	}
+
+	err = srv.Db.UseNonce(device.ID, timestamp, nonce, now.Add(-maxClockSkew))
+	if errors.Is(err, db.ErrNonceUsed) {
+		return device, errors.New("replayed request of the device " + device.ID)
+	}
+
+	return
+}
+
+// authenticateDevice returns the device of the requests signed like the
+// IngestHandler requests or of the requests with the HTTP basic authentication
+// of the trackers that can't sign them, the user is the key of the device and
+// the password is its secret. The basic authentication doesn't protect against
+// replays, the trackers resend the locations anyway and they are deduplicated
+func (srv *Server) authenticateDevice(r *http.Request, data []byte) (device db.Device, err error) {
+	key, secret, ok := r.BasicAuth()
+	if !ok {
+		return srv.verifyDeviceRequest(r, data)
+	}
+
+	device, err = srv.Db.GetDeviceByKey(key)
+	if err != nil {
+		return device, errors.New("unknown device key " + truncate(key, 100))
+	}
+
+	if device.Secret == "" || subtle.ConstantTimeCompare([]byte(device.Secret), []byte(secret)) != 1 {
+		return device, errors.New("invalid secret of the device " + device.ID)
+	}
+
+	return
+}
+
+// saveDeviceRequest saves the data sent by a device in the requests bucket, the
+// device is set by setRequestDevice when the request is verified
+func (srv *Server) saveDeviceRequest(r *http.Request, data []byte) (string, error) {
+	return srv.Db.InsertRequest(db.Request{Data: string(data), IP: remoteIP(r)}, []string{})
+}
+
+// setRequestDevice saves the device of a verified request
+func (srv *Server) setRequestDevice(requestID string, device db.Device) error {
+	_, err := srv.Db.UpdateRequest(db.Request{ID: requestID, FromDevice: device.ID}, []string{"FromDevice"})
+
+	return err
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jempe/gopicam/pkg/auth"
+	"github.com/jempe/gopicam/pkg/db"
+)
+
+// signedHeader returns the headers of a request of the device signed at the time
+func signedHeader(device db.Device, requestTime time.Time, nonce string, body string) http.Header {
+	timestamp := requestTime.Unix()
+
+	return http.Header{
+		auth.HeaderDeviceKey: {device.Key},
+		auth.HeaderTimestamp: {strconv.FormatInt(timestamp, 10)},
+		auth.HeaderNonce:     {nonce},
+		auth.HeaderSignature: {auth.SignRequest(device.Secret, timestamp, nonce, []byte(body))},
+	}
+}
+
+func TestIngestHandler(t *testing.T) {
+	srv := newTestServer(t)
+
+	device, err := srv.Db.EnrollDevice("phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestClient(t, http.HandlerFunc(srv.IngestHandler))
+
+	form := url.Values{"battery": {"80"}}
+	body := form.Encode()
+
+	wrongSecret := device
+	wrongSecret.Secret = "wrong secret"
+
+	tests := []struct {
+		name       string
+		header     http.Header
+		wantStatus int
+		wantDevice string
+	}{
+		{name: "Valid", header: signedHeader(device, time.Now(), "nonce-valid-1", body), wantStatus: http.StatusOK, wantDevice: device.ID},
+		{name: "Replayed Nonce", header: signedHeader(device, time.Now(), "nonce-valid-1", body), wantStatus: http.StatusUnauthorized},
+		{name: "Stale", header: signedHeader(device, time.Now().Add(-10*time.Minute), "nonce-stale-1", body), wantStatus: http.StatusUnauthorized},
+		{name: "Future", header: signedHeader(device, time.Now().Add(10*time.Minute), "nonce-future-1", body), wantStatus: http.StatusUnauthorized},
+		{name: "Invalid Nonce", header: signedHeader(device, time.Now(), "short", body), wantStatus: http.StatusUnauthorized},
+		{name: "Wrong Secret", header: signedHeader(wrongSecret, time.Now(), "nonce-wrong-1", body), wantStatus: http.StatusUnauthorized},
+		{name: "Unsigned", header: http.Header{}, wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, _ := c.do(http.MethodPost, "/api/ingest", form, tt.header)
+			if status != tt.wantStatus {
+				t.Fatalf("want status %d; got %d", tt.wantStatus, status)
+			}
+
+			// every request is saved, the rejected requests without device
+			requests, _, err := srv.Db.GetRequestList(0, 1, db.Filters{Operator: "AND"}, []string{}, db.SortBy{Field: "Created", Direction: "DESC"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(requests) != 1 || requests[0].Data != body {
+				t.Fatalf("want saved request; got %v", requests)
+			}
+
+			if requests[0].FromDevice != tt.wantDevice {
+				t.Errorf("want device %q; got %q", tt.wantDevice, requests[0].FromDevice)
+			}
+		})
+	}
+
+	_, total, err := srv.Db.GetRequestList(0, 1, db.Filters{Operator: "AND"}, []string{}, db.SortBy{Field: "Created", Direction: "DESC"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if total != int64(len(tests)) {
+		t.Errorf("want %d saved requests; got %d", len(tests), total)
+	}
+}
@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jempe/gopicam/pkg/db"
+	"github.com/jempe/gopicam/pkg/tracker"
+)
+
+// maxStatusDevices is the maximum number of devices in the status view
+const maxStatusDevices = 1000
+
+// batteryTrendPeriod is the period of the locations of the battery trend,
+// before the last location of the device
+const batteryTrendPeriod = 6 * time.Hour
+
+const defaultAlertsLimit = 100
+const maxAlertsLimit = 1000
+
+// eventsHeartbeat is the interval of the comments sent to keep the event stream
+// open through the proxies, the session is checked again at every heartbeat
+const eventsHeartbeat = 30 * time.Second
+
+// StatusLocation is the last known position of a device
+type StatusLocation struct {
+	ID        string    `json:"id"`
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	Accuracy  int       `json:"accuracy"`
+	Time      time.Time `json:"time"`
+}
+
+// DeviceStatus is the status of a device, the fields of the state are empty
+// for the devices that didn't send locations. BatteryTrend is the change of
+// the battery in percent per hour in the last hours, it's null when it's
+// unknown
+type DeviceStatus struct {
+	Device       DeviceResponse  `json:"device"`
+	LastSeen     *time.Time      `json:"last_seen"`
+	Reporting    bool            `json:"reporting"`
+	Location     *StatusLocation `json:"location"`
+	Battery      int             `json:"battery"`
+	BatteryTrend *float64        `json:"battery_trend"`
+	LowBattery   bool            `json:"low_battery"`
+	Wifi         string          `json:"wifi"`
+	AtHome       bool            `json:"at_home"`
+}
+
+type DeviceStatusResponse struct {
+	Devices []DeviceStatus `json:"devices"`
+	Status  string         `json:"status"`
+}
+
+type AlertsResponse struct {
+	Alerts     []db.Alert `json:"alerts"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+	Status     string     `json:"status"`
+}
+
+// handler of the status of the devices, the last time they sent a location,
+// their last known position, battery and trend and the SSID of their Wi-Fi
+func (srv *Server) DeviceStatusHandler(w http.ResponseWriter, r *http.Request) {
+	setSecureHeaders(w, "json")
+
+	if r.Method != http.MethodGet {
+		returnCode405(w, r)
+		return
+	}
+
+	if _, ok := srv.currentSession(r); !ok {
+		returnCode401(w, r)
+		return
+	}
+
+	devices, _, err := srv.Db.GetDeviceList(0, maxStatusDevices, db.Filters{Operator: "AND"}, []string{}, db.SortBy{Field: "Name", Direction: "ASC"})
+	if err != nil {
+		srv.LogError.Println(err)
+		returnCode500(w, r)
+		return
+	}
+
+	response := DeviceStatusResponse{Devices: []DeviceStatus{}, Status: "success"}
+
+	for _, device := range devices {
+		status, err := srv.deviceStatus(device)
+		if err != nil {
+			srv.LogError.Println(err)
+			returnCode500(w, r)
+			return
+		}
+
+		response.Devices = append(response.Devices, status)
+	}
+
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		srv.LogError.Println(err)
+	}
+
+	fmt.Fprintln(w, string(responseJSON))
+}
+
+// deviceStatus returns the status of the device from its saved state
+func (srv *Server) deviceStatus(device db.Device) (status DeviceStatus, err error) {
+	status.Device = newDeviceResponse(device)
+
+	state, ok, err := srv.Db.GetDeviceState(device.ID)
+	if err != nil || !ok {
+		return
+	}
+
+	presence := srv.Settings.Presence
+
+	status.LastSeen = &state.LastSeen
+	status.Reporting = !state.NotReporting && (presence.Timeout <= 0 || time.Since(state.LastSeen) < time.Duration(presence.Timeout))
+	status.Battery = state.Battery
+	status.LowBattery = state.LowBattery
+	status.Wifi = state.Wifi
+	status.AtHome = presence.HomeWifi != "" && state.Wifi == presence.HomeWifi
+
+	// the location may have been deleted by the retention
+	if location, err := srv.Db.GetLocation(state.Location); err == nil {
+		latitude, longitude := location.Coordinates()
+
+		status.Location = &StatusLocation{
+			ID:        location.ID,
+			Latitude:  latitude,
+			Longitude: longitude,
+			Accuracy:  location.Accuracy,
+			Time:      time.Unix(location.DeviceTime, 0).UTC(),
+		}
+	}
+
+	filters := db.Filters{
+		Operator: "AND",
+		Conditions: []db.Condition{
+			{Field: "Device", Comparison: "=", Value: device.ID},
+			{Field: "DeviceTime", Comparison: "BETWEEN", Value: []int64{state.DeviceTime - int64(batteryTrendPeriod/time.Second), state.DeviceTime}},
+		},
+	}
+
+	locations, _, err := srv.Db.GetLocationList(0, maxTrackLocations, filters, []string{"Battery", "DeviceTime"}, db.SortBy{Field: "DeviceTime", Direction: "ASC"})
+	if err != nil {
+		return
+	}
+
+	if trend, ok := tracker.BatteryTrend(locations); ok {
+		status.BatteryTrend = &trend
+	}
+
+	return
+}
+
+// handler of the alerts of the devices, the newest first. It accepts the
+// parameters device and type to filter them, limit and cursor, which is empty
+// for the first page and then the next_cursor of the previous response
+func (srv *Server) AlertsHandler(w http.ResponseWriter, r *http.Request) {
+	setSecureHeaders(w, "json")
+
+	if r.Method != http.MethodGet {
+		returnCode405(w, r)
+		return
+	}
+
+	if _, ok := srv.currentSession(r); !ok {
+		returnCode401(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+
+	limit := defaultAlertsLimit
+
+	if value := query.Get("limit"); value != "" {
+		var err error
+
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			returnCode400(w, r)
+			return
+		}
+	}
+
+	if limit > maxAlertsLimit {
+		limit = maxAlertsLimit
+	}
+
+	filters := db.Filters{Operator: "AND"}
+
+	for _, field := range []string{"device", "type"} {
+		if value := query.Get(field); value != "" {
+			filters.Conditions = append(filters.Conditions, db.Condition{Field: field, Comparison: "=", Value: value})
+		}
+	}
+
+	alerts, nextCursor, err := srv.Db.GetAlertPage(query.Get("cursor"), limit, filters, []string{}, db.SortBy{Field: "Created", Direction: "DESC"})
+	if err != nil {
+		returnCode400(w, r)
+		return
+	}
+
+	response := AlertsResponse{Alerts: alerts, NextCursor: nextCursor, Status: "success"}
+	if response.Alerts == nil {
+		response.Alerts = []db.Alert{}
+	}
+
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		srv.LogError.Println(err)
+	}
+
+	fmt.Fprintln(w, string(responseJSON))
+}
+
+// handler of the event stream, the server-sent events of the alerts and the
+// geofences. The session middleware buffers the responses so this handler is
+// served outside of it and loads the session itself
+func (srv *Server) EventsHandler(w http.ResponseWriter, r *http.Request) {
+	setSecureHeaders(w, "json")
+
+	if r.Method != http.MethodGet {
+		returnCode405(w, r)
+		return
+	}
+
+	if srv.Events == nil {
+		returnCode404(w, r)
+		return
+	}
+
+	r, err := srv.loadSession(r)
+	if err != nil {
+		srv.LogError.Println(err)
+		returnCode500(w, r)
+		return
+	}
+
+	if _, ok := srv.currentSession(r); !ok {
+		returnCode401(w, r)
+		return
+	}
+
+	subscription, cancel := srv.Events.Subscribe()
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	controller := http.NewResponseController(w)
+
+	// the first comment sends the headers
+	fmt.Fprint(w, ": connected\n\n")
+
+	err = controller.Flush()
+	if err != nil {
+		srv.LogError.Println(err)
+		return
+	}
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			// the session may have been revoked or expired
+			if _, ok := srv.currentSession(r); !ok {
+				return
+			}
+
+			fmt.Fprint(w, ": heartbeat\n\n")
+		case event := <-subscription:
+			eventJSON, err := json.Marshal(event)
+			if err != nil {
+				srv.LogError.Println(err)
+				continue
+			}
+
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, eventJSON)
+		}
+
+		err = controller.Flush()
+		if err != nil {
+			return
+		}
+	}
+}
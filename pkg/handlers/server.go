@@ -4,21 +4,42 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 
 	"github.com/alexedwards/scs/v2"
-	"golang.org/x/crypto/bcrypt"
-
+	"github.com/jempe/gopicam/pkg/audio"
+	"github.com/jempe/gopicam/pkg/auth"
+	"github.com/jempe/gopicam/pkg/backup"
 	"github.com/jempe/gopicam/pkg/camera"
 	"github.com/jempe/gopicam/pkg/db"
+	"github.com/jempe/gopicam/pkg/events"
+	"github.com/jempe/gopicam/pkg/tracker"
+	"github.com/jempe/gopicam/pkg/validator"
 )
 
 type Server struct {
-	Db            *db.DB
-	Sessions      *scs.SessionManager
-	LogError      *log.Logger
-	LogInfo       *log.Logger
-	CamController *camera.CamController
+	Db             *db.DB
+	Sessions       *scs.SessionManager
+	LogError       *log.Logger
+	LogInfo        *log.Logger
+	CamController  *camera.CamController
+	PasswordPolicy validator.PasswordPolicy
+	HashPolicy     auth.HashPolicy
+	BackupFiles    []backup.File
+	// Settings are the settings gopicam is running with
+	Settings db.Settings
+	// SettingsOverrides has the settings that are set by a flag or an
+	// environment variable, the value is the flag or the variable
+	SettingsOverrides map[string]string
+	// Geofencer evaluates the locations of the devices in the geofences
+	Geofencer *tracker.Geofencer
+	// Monitor raises the alerts of the devices
+	Monitor *tracker.Monitor
+	// Events sends the alerts and the geofence events to the event stream
+	Events *events.Broker
+	// Speaker plays the talks of the users, it's nil without speaker
+	Speaker *audio.Speaker
 }
 
 type PreviewResponse struct {
@@ -56,26 +77,32 @@ func (srv *Server) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		srv.LogError.Println(err)
 	}
 
-	if r.PostForm.Get("username") == string(srv.Db.GetConfigValue("username")) {
-
+	user, err := srv.Db.GetUserByUsername(r.PostForm.Get("username"))
+	if err != nil {
+		// compare with a dummy hash so the response time is the same
+		auth.CheckPassword(nil, r.PostForm.Get("password"))
+	} else if auth.CheckPassword(user.Password, r.PostForm.Get("password")) {
 		srv.LogInfo.Println("Login User Found")
 
-		err = bcrypt.CompareHashAndPassword(srv.Db.GetConfigValue("password"), []byte(r.PostForm.Get("password")))
+		// upgrade the hashes created with weaker settings while we know the password
+		if srv.HashPolicy.NeedsRehash(user.Password) {
+			srv.rehashPassword(user.Username, r.PostForm.Get("password"))
+		}
 
-		if err == nil {
-			// prepare successful response
-			response["access"] = "granted"
+		csrfToken, err := srv.startSession(r, user.Username)
+		if err != nil {
+			srv.LogError.Println(err)
+			returnCode500(w, r)
+			return
+		}
 
-			// Renew the session token...
-			err = srv.Sessions.RenewToken(r.Context())
-			if err != nil {
-				returnCode500(w, r)
-				return
-			}
+		// prepare successful response
+		response["access"] = "granted"
+		response["csrf_token"] = csrfToken
+	}
 
-			// Save the username in the session
-			srv.Sessions.Put(r.Context(), "username", r.PostForm.Get("username"))
-		}
+	if response["access"] != "granted" {
+		auditResult(r, "access denied for "+truncate(r.PostForm.Get("username"), 100))
 	}
 
 	responseJSON, err := json.Marshal(response)
@@ -95,7 +122,7 @@ func (srv *Server) PreviewHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if srv.Sessions.GetString(r.Context(), "username") != string(srv.Db.GetConfigValue("username")) {
+	if _, ok := srv.currentSession(r); !ok {
 		returnCode401(w, r)
 		return
 	}
@@ -131,12 +158,12 @@ func (srv *Server) PreviewHandler(w http.ResponseWriter, r *http.Request) {
 func (srv *Server) CameraCommandHandler(w http.ResponseWriter, r *http.Request) {
 	setSecureHeaders(w, "json")
 
-	if r.Method != http.MethodGet {
+	if r.Method != http.MethodPost {
 		returnCode405(w, r)
 		return
 	}
 
-	if srv.Sessions.GetString(r.Context(), "username") != string(srv.Db.GetConfigValue("username")) {
+	if _, ok := srv.currentSession(r); !ok {
 		returnCode401(w, r)
 		return
 	}
@@ -159,6 +186,8 @@ func (srv *Server) CameraCommandHandler(w http.ResponseWriter, r *http.Request)
 	raspiMJPEGCommand, ok := pathCommands[r.URL.Path]
 
 	if ok {
+		auditCommand(r, raspiMJPEGCommand)
+
 		srv.CamController.SendCommand(raspiMJPEGCommand)
 
 		response["status"] = "success"
@@ -174,6 +203,42 @@ func (srv *Server) CameraCommandHandler(w http.ResponseWriter, r *http.Request)
 	fmt.Fprintln(w, string(responseJSON))
 }
 
+// rehashPassword saves the password with the current hash policy
+func (srv *Server) rehashPassword(username string, password string) {
+	hashedPassword, err := srv.HashPolicy.Hash(password)
+	if err != nil {
+		srv.LogError.Println(err)
+		return
+	}
+
+	_, err = srv.Db.UpdateUserPassword(username, hashedPassword)
+	if err != nil {
+		srv.LogError.Println(err)
+		return
+	}
+
+	srv.LogInfo.Println("Password hash of", username, "upgraded to", srv.HashPolicy.Algorithm)
+}
+
+// remoteIP returns the IP address of the client without the port
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// truncate limits the length of a string
+func truncate(value string, maxLength int) string {
+	if len(value) > maxLength {
+		return value[:maxLength]
+	}
+
+	return value
+}
+
 func returnCode400(w http.ResponseWriter, r *http.Request) {
 	// see http://golang.org/pkg/net/http/#pkg-constants
 	w.WriteHeader(http.StatusBadRequest)
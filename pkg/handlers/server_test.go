@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexedwards/scs/v2"
+
+	"github.com/jempe/gopicam/pkg/auth"
+	"github.com/jempe/gopicam/pkg/db"
+	"github.com/jempe/gopicam/pkg/validator"
+)
+
+const testPassword = "k8#pQz!vW2rT"
+
+// newTestServer returns a server with an empty DB in a temporary folder
+func newTestServer(t *testing.T) *Server {
+	database := &db.DB{Path: filepath.Join(t.TempDir(), "gopicam.db")}
+
+	err := database.InitDb()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(database.Close)
+
+	logger := log.New(io.Discard, "", 0)
+
+	return &Server{
+		Db:             database,
+		Sessions:       scs.New(),
+		LogError:       logger,
+		LogInfo:        logger,
+		PasswordPolicy: validator.DefaultPasswordPolicy,
+		HashPolicy:     auth.DefaultHashPolicy,
+	}
+}
+
+// testClient sends the requests to the test server and keeps the cookies
+type testClient struct {
+	t      *testing.T
+	server *httptest.Server
+	client *http.Client
+}
+
+func newTestClient(t *testing.T, handler http.Handler) *testClient {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testClient{t: t, server: server, client: &http.Client{Jar: jar}}
+}
+
+// do sends the request and returns the status and the body of the response
+func (c *testClient) do(method string, path string, form url.Values, header http.Header) (int, string) {
+	request, err := http.NewRequest(method, c.server.URL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	for name, values := range header {
+		request.Header[name] = values
+	}
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	return response.StatusCode, string(body)
+}
+
+// sameOriginHeader returns the Origin header of the requests of the web interface
+func (c *testClient) sameOriginHeader() http.Header {
+	return http.Header{"Origin": {c.server.URL}}
+}
@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jempe/gopicam/pkg/db"
+)
+
+type SessionResponse struct {
+	ID        string    `json:"id"`
+	Username  string    `json:"username"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Created   time.Time `json:"created"`
+	LastSeen  time.Time `json:"last_seen"`
+	Current   bool      `json:"current"`
+}
+
+type SessionListResponse struct {
+	Sessions []SessionResponse `json:"sessions"`
+	Status   string            `json:"status"`
+}
+
+// startSession logs in the user and registers the session so it can be listed
+// and revoked, it returns the new CSRF token of the session
+func (srv *Server) startSession(r *http.Request, username string) (csrfToken string, err error) {
+	// Renew the session token...
+	err = srv.Sessions.RenewToken(r.Context())
+	if err != nil {
+		return
+	}
+
+	sessionID, err := srv.Db.InsertSession(db.Session{
+		Username:  username,
+		IP:        remoteIP(r),
+		UserAgent: truncate(r.UserAgent(), 512),
+	})
+	if err != nil {
+		return
+	}
+
+	// Save the username in the session
+	srv.Sessions.Put(r.Context(), "username", username)
+	srv.Sessions.Put(r.Context(), "session_id", sessionID)
+
+	return srv.renewCSRFToken(r)
+}
+
+// currentSession returns the registered session of the logged in user, ok is
+// false when the user isn't logged in or the session has been revoked
+func (srv *Server) currentSession(r *http.Request) (session db.Session, ok bool) {
+	username := srv.Sessions.GetString(r.Context(), "username")
+
+	if username == "" {
+		return
+	}
+
+	// the account may have been deleted from the command line
+	if _, err := srv.Db.GetUserByUsername(username); err != nil {
+		return
+	}
+
+	session, err := srv.Db.GetSession(srv.Sessions.GetString(r.Context(), "session_id"))
+	if err != nil || session.Username != username {
+		return
+	}
+
+	if session.Expired(time.Now().UTC(), srv.Sessions.IdleTimeout, srv.Sessions.Lifetime) {
+		return
+	}
+
+	// the preview is requested every second, update last seen only once per minute
+	if time.Since(session.LastSeen) > time.Minute || session.IP != remoteIP(r) {
+		err = srv.Db.TouchSession(session.ID, remoteIP(r))
+		if err != nil {
+			srv.LogError.Println(err)
+		}
+	}
+
+	return session, true
+}
+
+// loadSession loads the session of the cookie in the context of the request,
+// it's used by the handlers that keep the connection open and aren't wrapped
+// by the LoadAndSave handler of the session manager
+func (srv *Server) loadSession(r *http.Request) (*http.Request, error) {
+	var token string
+	if cookie, err := r.Cookie(srv.Sessions.Cookie.Name); err == nil {
+		token = cookie.Value
+	}
+
+	ctx, err := srv.Sessions.Load(r.Context(), token)
+	if err != nil {
+		return r, err
+	}
+
+	return r.WithContext(ctx), nil
+}
+
+// handler to log out the current session
+func (srv *Server) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	setSecureHeaders(w, "json")
+
+	if r.Method != http.MethodPost {
+		returnCode405(w, r)
+		return
+	}
+
+	sessionID := srv.Sessions.GetString(r.Context(), "session_id")
+	if sessionID != "" {
+		_, err := srv.Db.DeleteSession(sessionID)
+		if err != nil {
+			srv.LogError.Println(err)
+		}
+	}
+
+	err := srv.Sessions.Destroy(r.Context())
+	if err != nil {
+		srv.LogError.Println(err)
+		returnCode500(w, r)
+		return
+	}
+
+	fmt.Fprintln(w, "{\"status\": \"success\"}")
+}
+
+// handler to list the active sessions (GET) and revoke one of them (DELETE ?id=)
+func (srv *Server) SessionsHandler(w http.ResponseWriter, r *http.Request) {
+	setSecureHeaders(w, "json")
+
+	if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+		returnCode405(w, r)
+		return
+	}
+
+	currentSession, ok := srv.currentSession(r)
+	if !ok {
+		returnCode401(w, r)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		sessionID := r.URL.Query().Get("id")
+
+		session, err := srv.Db.GetSession(sessionID)
+		if err != nil || session.Username != currentSession.Username {
+			returnCode404(w, r)
+			return
+		}
+
+		_, err = srv.Db.DeleteSession(session.ID)
+		if err != nil {
+			srv.LogError.Println(err)
+			returnCode500(w, r)
+			return
+		}
+
+		if session.ID == currentSession.ID {
+			err = srv.Sessions.Destroy(r.Context())
+			if err != nil {
+				srv.LogError.Println(err)
+			}
+		}
+
+		srv.LogInfo.Println("Session revoked", session.ID)
+
+		fmt.Fprintln(w, "{\"status\": \"success\"}")
+		return
+	}
+
+	// remove the sessions that expired before listing them
+	_, err := srv.Db.DeleteExpiredSessions(srv.Sessions.IdleTimeout, srv.Sessions.Lifetime)
+	if err != nil {
+		srv.LogError.Println(err)
+	}
+
+	sessionList, err := srv.Db.GetSessionList(currentSession.Username)
+	if err != nil {
+		srv.LogError.Println(err)
+		returnCode500(w, r)
+		return
+	}
+
+	response := SessionListResponse{Sessions: []SessionResponse{}, Status: "success"}
+
+	for _, session := range sessionList {
+		response.Sessions = append(response.Sessions, newSessionResponse(session, currentSession.ID))
+	}
+
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		srv.LogError.Println(err)
+	}
+
+	fmt.Fprintln(w, string(responseJSON))
+}
+
+// handler to log out everywhere, it revokes all the sessions of the user including the current one
+func (srv *Server) RevokeAllSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	setSecureHeaders(w, "json")
+
+	if r.Method != http.MethodPost {
+		returnCode405(w, r)
+		return
+	}
+
+	currentSession, ok := srv.currentSession(r)
+	if !ok {
+		returnCode401(w, r)
+		return
+	}
+
+	rowsAffected, err := srv.Db.DeleteUserSessions(currentSession.Username)
+	if err != nil {
+		srv.LogError.Println(err)
+		returnCode500(w, r)
+		return
+	}
+
+	err = srv.Sessions.Destroy(r.Context())
+	if err != nil {
+		srv.LogError.Println(err)
+	}
+
+	srv.LogInfo.Println("Revoked", rowsAffected, "sessions of", currentSession.Username)
+
+	fmt.Fprintln(w, "{\"status\": \"success\"}")
+}
+
+func newSessionResponse(session db.Session, currentSessionID string) SessionResponse {
+	return SessionResponse{
+		ID:        session.ID,
+		Username:  session.Username,
+		IP:        session.IP,
+		UserAgent: session.UserAgent,
+		Created:   session.Created,
+		LastSeen:  session.LastSeen,
+		Current:   session.ID == currentSessionID,
+	}
+}
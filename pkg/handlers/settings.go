@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jempe/gopicam/pkg/db"
+)
+
+// maxSettingsSize is the maximum size of the body of a change of the settings
+const maxSettingsSize = 64 << 10
+
+type SettingsResponse struct {
+	// Settings are the saved settings, Effective are the settings gopicam is
+	// running with, they have the overrides of the flags and the environment
+	// variables and the changes saved after gopicam started
+	Settings  db.Settings       `json:"settings"`
+	Effective db.Settings       `json:"effective"`
+	Overrides map[string]string `json:"overrides"`
+	Version   int               `json:"version"`
+	// RestartRequired is true when the saved settings are different from the effective settings
+	RestartRequired bool               `json:"restart_required"`
+	Changes         []db.SettingChange `json:"changes,omitempty"`
+	Status          string             `json:"status"`
+}
+
+// SettingsErrorResponse has the error of the invalid changes of the settings
+type SettingsErrorResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+}
+
+type SettingsHistoryResponse struct {
+	History []db.SettingsRecord `json:"history"`
+	Status  string              `json:"status"`
+}
+
+// handler of the settings, GET returns the saved and the effective settings and
+// PUT saves a change, the body is a JSON object with the changed settings like
+// {"server": {"port": 8443}} and the optional comment of the history in the
+// comment parameter. The changes are applied when gopicam restarts
+func (srv *Server) SettingsHandler(w http.ResponseWriter, r *http.Request) {
+	setSecureHeaders(w, "json")
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPut {
+		returnCode405(w, r)
+		return
+	}
+
+	session, ok := srv.currentSession(r)
+	if !ok {
+		returnCode401(w, r)
+		return
+	}
+
+	settings, version, err := srv.Db.GetSettings()
+	if err != nil {
+		srv.LogError.Println(err)
+		returnCode500(w, r)
+		return
+	}
+
+	response := SettingsResponse{Status: "success"}
+
+	if r.Method == http.MethodPut {
+		// the settings that are not in the body keep their saved value
+		decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSettingsSize))
+		decoder.DisallowUnknownFields()
+
+		err = decoder.Decode(&settings)
+		if err != nil {
+			srv.writeSettingsError(w, r, err.Error())
+			return
+		}
+
+		record, err := srv.Db.SaveSettings(settings, session.Username, r.URL.Query().Get("comment"))
+		if err != nil {
+			srv.writeSettingsError(w, r, err.Error())
+			return
+		}
+
+		srv.LogInfo.Println("Settings version", record.Version, "saved by", session.Username)
+		auditResult(r, settingsAuditResult(record))
+
+		version, response.Changes = record.Version, record.Changes
+	}
+
+	srv.writeSettings(w, response, settings, version)
+}
+
+// handler of the history of the settings, the newest changes first. It accepts
+// the parameter limit, 50 by default
+func (srv *Server) SettingsHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	setSecureHeaders(w, "json")
+
+	if r.Method != http.MethodGet {
+		returnCode405(w, r)
+		return
+	}
+
+	if _, ok := srv.currentSession(r); !ok {
+		returnCode401(w, r)
+		return
+	}
+
+	limit := 50
+
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			returnCode400(w, r)
+			return
+		}
+	}
+
+	history, err := srv.Db.GetSettingsHistory(limit)
+	if err != nil {
+		srv.LogError.Println(err)
+		returnCode500(w, r)
+		return
+	}
+
+	response := SettingsHistoryResponse{History: history, Status: "success"}
+	if response.History == nil {
+		response.History = []db.SettingsRecord{}
+	}
+
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		srv.LogError.Println(err)
+	}
+
+	fmt.Fprintln(w, string(responseJSON))
+}
+
+// handler to roll back the settings, it saves the settings of the version of
+// the form value version as a new version
+func (srv *Server) SettingsRollbackHandler(w http.ResponseWriter, r *http.Request) {
+	setSecureHeaders(w, "json")
+
+	if r.Method != http.MethodPost {
+		returnCode405(w, r)
+		return
+	}
+
+	session, ok := srv.currentSession(r)
+	if !ok {
+		returnCode401(w, r)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		returnCode400(w, r)
+		return
+	}
+
+	version, err := strconv.Atoi(r.PostForm.Get("version"))
+	if err != nil || version < 1 {
+		returnCode400(w, r)
+		return
+	}
+
+	record, err := srv.Db.RollbackSettings(version, session.Username)
+	if err != nil {
+		srv.writeSettingsError(w, r, err.Error())
+		return
+	}
+
+	srv.LogInfo.Println("Settings rolled back to version", version, "by", session.Username)
+	auditResult(r, settingsAuditResult(record))
+
+	srv.writeSettings(w, SettingsResponse{Changes: record.Changes, Status: "success"}, record.Settings, record.Version)
+}
+
+func (srv *Server) writeSettings(w http.ResponseWriter, response SettingsResponse, settings db.Settings, version int) {
+	response.Settings, response.Effective, response.Version = settings, srv.Settings, version
+
+	response.Overrides = srv.SettingsOverrides
+	if response.Overrides == nil {
+		response.Overrides = map[string]string{}
+	}
+
+	// the overrides of the effective settings don't require a restart
+	for _, change := range srv.Settings.Diff(settings) {
+		if _, ok := response.Overrides[change.Setting]; !ok {
+			response.RestartRequired = true
+		}
+	}
+
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		srv.LogError.Println(err)
+	}
+
+	fmt.Fprintln(w, string(responseJSON))
+}
+
+func (srv *Server) writeSettingsError(w http.ResponseWriter, r *http.Request, message string) {
+	auditResult(r, truncate(message, 200))
+
+	responseJSON, err := json.Marshal(SettingsErrorResponse{Status: "error", Error: message})
+	if err != nil {
+		srv.LogError.Println(err)
+	}
+
+	w.WriteHeader(http.StatusBadRequest)
+	fmt.Fprintln(w, string(responseJSON))
+}
+
+// settingsAuditResult lists the changed settings, the values aren't saved in
+// the audit log because the history has them
+func settingsAuditResult(record db.SettingsRecord) string {
+	var settings []string
+
+	for _, change := range record.Changes {
+		settings = append(settings, change.Setting)
+	}
+
+	return truncate(fmt.Sprintf("settings version %d: %s", record.Version, strings.Join(settings, ", ")), 200)
+}
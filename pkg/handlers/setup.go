@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/jempe/gopicam/pkg/db"
+	"github.com/jempe/gopicam/pkg/validator"
+)
+
+type SetupResponse struct {
+	SetupRequired bool   `json:"setup_required"`
+	Status        string `json:"status"`
+	Error         string `json:"error,omitempty"`
+	Field         string `json:"field,omitempty"`
+	CSRFToken     string `json:"csrf_token,omitempty"`
+}
+
+// handler of the first run setup, GET returns if the admin account has to be
+// created and POST creates it. The setup is locked after the first account is created
+func (srv *Server) SetupHandler(w http.ResponseWriter, r *http.Request) {
+	setSecureHeaders(w, "json")
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		returnCode405(w, r)
+		return
+	}
+
+	response := SetupResponse{SetupRequired: srv.Db.SetupRequired(), Status: "success"}
+
+	if r.Method == http.MethodPost {
+		if !response.SetupRequired {
+			returnCode403(w, r)
+			return
+		}
+
+		err := r.ParseForm()
+		if err != nil {
+			srv.LogError.Println(err)
+			returnCode400(w, r)
+			return
+		}
+
+		username := r.PostForm.Get("username")
+		password := r.PostForm.Get("password")
+
+		validUsername, usernameErr := validator.ValidateUsername(username, db.MinUsernameLength, db.MaxUsernameLength)
+		if !validUsername {
+			srv.writeSetupError(w, "username", usernameErr.Error())
+			return
+		}
+
+		if password != r.PostForm.Get("password_confirmation") {
+			srv.writeSetupError(w, "password_confirmation", "error:password_confirmation")
+			return
+		}
+
+		validPassword, passwordErr := validator.ValidatePassword(password, username, srv.PasswordPolicy)
+		if !validPassword {
+			srv.writeSetupError(w, "password", passwordErr.Error())
+			return
+		}
+
+		hashedPassword, err := srv.HashPolicy.Hash(password)
+		if err != nil {
+			srv.LogError.Println(err)
+			returnCode500(w, r)
+			return
+		}
+
+		_, err = srv.Db.InsertFirstUser(db.User{Username: username, Password: hashedPassword})
+		if err == db.ErrSetupCompleted {
+			returnCode403(w, r)
+			return
+		} else if err != nil {
+			srv.writeSetupError(w, "username", err.Error())
+			return
+		}
+
+		srv.LogInfo.Println("Admin account created from the setup page:", username)
+
+		response.CSRFToken, err = srv.startSession(r, username)
+		if err != nil {
+			srv.LogError.Println(err)
+			returnCode500(w, r)
+			return
+		}
+
+		response.SetupRequired = false
+	}
+
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		srv.LogError.Println(err)
+	}
+
+	fmt.Fprintln(w, string(responseJSON))
+}
+
+func (srv *Server) writeSetupError(w http.ResponseWriter, field string, message string) {
+	responseJSON, err := json.Marshal(SetupResponse{SetupRequired: true, Status: "error", Error: message, Field: field})
+	if err != nil {
+		srv.LogError.Println(err)
+	}
+
+	w.WriteHeader(http.StatusBadRequest)
+	fmt.Fprintln(w, string(responseJSON))
+}
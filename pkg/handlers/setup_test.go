@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestSetupLockout(t *testing.T) {
+	srv := newTestServer(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/setup", srv.SetupHandler)
+
+	c := newTestClient(t, srv.Sessions.LoadAndSave(srv.CSRFProtect(mux)))
+
+	setup := func(username string) (int, SetupResponse) {
+		status, body := c.do(http.MethodPost, "/api/setup", url.Values{
+			"username":              {username},
+			"password":              {testPassword},
+			"password_confirmation": {testPassword},
+		}, c.sameOriginHeader())
+
+		var response SetupResponse
+		json.Unmarshal([]byte(body), &response)
+
+		return status, response
+	}
+
+	status, response := setup("admin1")
+	if status != http.StatusOK || response.Status != "success" || response.SetupRequired || response.CSRFToken == "" {
+		t.Fatalf("want the first account created; got %d %+v", status, response)
+	}
+
+	status, _ = setup("admin2")
+	if status != http.StatusForbidden {
+		t.Errorf("want 403 after the setup; got %d", status)
+	}
+
+	// the setup stays locked when the accounts are removed from the command line
+	_, err := srv.Db.DeleteUser("admin1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, _ = setup("admin3")
+	if status != http.StatusForbidden {
+		t.Errorf("want 403 without accounts after the setup; got %d", status)
+	}
+
+	if _, err := srv.Db.GetUserByUsername("admin3"); err == nil {
+		t.Errorf("want no account created after the setup")
+	}
+}
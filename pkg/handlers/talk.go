@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jempe/gopicam/pkg/audio"
+	"github.com/jempe/gopicam/pkg/db"
+	"github.com/jempe/gopicam/pkg/websocket"
+)
+
+// talkIdleTimeout is the time without messages before the talk is closed
+const talkIdleTimeout = 30 * time.Second
+
+// talkSessionCheck is the interval of the checks of the session during a talk
+const talkSessionCheck = 30 * time.Second
+
+// maxTalkMessage is the maximum size of the audio messages of the browser
+const maxTalkMessage = 256 * 1024
+
+const defaultTalkSampleRate = 16000
+
+type SpeakerResponse struct {
+	Volume  int    `json:"volume"`
+	Muted   bool   `json:"muted"`
+	Talking bool   `json:"talking"`
+	Status  string `json:"status"`
+}
+
+type TalkErrorResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+}
+
+// handler of the WebSocket that plays the audio of the browser on the speaker
+// of the camera. The binary messages are PCM chunks, or Opus in WebM or Ogg
+// with codec=opus
+func (srv *Server) TalkHandler(w http.ResponseWriter, r *http.Request) {
+	setSecureHeaders(w, "json")
+
+	if r.Method != http.MethodGet {
+		returnCode405(w, r)
+		return
+	}
+
+	if srv.Speaker == nil {
+		returnCode404(w, r)
+		return
+	}
+
+	r, err := srv.loadSession(r)
+	if err != nil {
+		srv.LogError.Println(err)
+		returnCode500(w, r)
+		return
+	}
+
+	session, ok := srv.currentSession(r)
+	if !ok {
+		returnCode401(w, r)
+		return
+	}
+
+	// the browsers don't apply the same origin policy to the WebSockets
+	if !sameOrigin(r) {
+		srv.LogError.Println("Talk: cross origin request rejected", r.Header.Get("Origin"))
+		returnCode403(w, r)
+		return
+	}
+
+	codec := r.URL.Query().Get("codec")
+	if codec == "" {
+		codec = "pcm"
+	}
+
+	format := audio.Format{SampleRate: defaultTalkSampleRate, Channels: 1}
+
+	if rate := r.URL.Query().Get("rate"); rate != "" {
+		format.SampleRate, err = strconv.Atoi(rate)
+		if err != nil || format.SampleRate < 8000 || format.SampleRate > 48000 {
+			srv.writeTalkError(w, http.StatusBadRequest, "rate must be between 8000 and 48000")
+			return
+		}
+	}
+
+	if channels := r.URL.Query().Get("channels"); channels != "" {
+		format.Channels, err = strconv.Atoi(channels)
+		if err != nil || format.Channels < 1 || format.Channels > 2 {
+			srv.writeTalkError(w, http.StatusBadRequest, "channels must be 1 or 2")
+			return
+		}
+	}
+
+	if codec != "pcm" && codec != "opus" {
+		srv.writeTalkError(w, http.StatusBadRequest, "codec must be pcm or opus")
+		return
+	}
+
+	// the speaker is only acquired for valid handshakes
+	if err := websocket.CheckHandshake(r); err != nil {
+		returnCode400(w, r)
+		return
+	}
+
+	talk, err := srv.Speaker.Talk(format)
+	if errors.Is(err, audio.ErrSpeakerBusy) {
+		srv.writeTalkError(w, http.StatusConflict, "the speaker is used by another user")
+		return
+	} else if err != nil {
+		srv.LogError.Println("Talk:", err)
+		returnCode500(w, r)
+		return
+	}
+
+	var writer io.WriteCloser = talk
+
+	if codec == "opus" {
+		writer, err = audio.DecodeOpus(talk, format)
+		if err != nil {
+			talk.Close()
+
+			if errors.Is(err, audio.ErrNoDecoder) {
+				srv.writeTalkError(w, http.StatusBadRequest, "opus isn't supported, send pcm")
+				return
+			}
+
+			srv.LogError.Println("Talk:", err)
+			returnCode500(w, r)
+			return
+		}
+	}
+
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		writer.Close()
+		talk.Close()
+
+		if errors.Is(err, websocket.ErrBadHandshake) {
+			returnCode400(w, r)
+		} else {
+			srv.LogError.Println("Talk:", err)
+		}
+
+		return
+	}
+
+	conn.MaxMessageSize = maxTalkMessage
+
+	srv.LogInfo.Println("Talk: started by", session.Username)
+
+	status, err := json.Marshal(srv.speakerResponse())
+	if err == nil {
+		err = conn.WriteMessage(websocket.TextMessage, status)
+	}
+
+	lastCheck := time.Now()
+
+	for err == nil {
+		conn.SetReadDeadline(time.Now().Add(talkIdleTimeout))
+
+		var opcode int
+		var data []byte
+
+		opcode, data, err = conn.ReadMessage()
+		if err != nil {
+			break
+		}
+
+		// the session may have been revoked or expired
+		if time.Since(lastCheck) > talkSessionCheck {
+			if _, ok := srv.currentSession(r); !ok {
+				conn.CloseWithCode(websocket.ClosePolicyViolation, "session ended")
+				break
+			}
+
+			lastCheck = time.Now()
+		}
+
+		if opcode != websocket.BinaryMessage {
+			continue
+		}
+
+		_, err = writer.Write(data)
+		if err != nil {
+			srv.LogError.Println("Talk:", err)
+			conn.CloseWithCode(websocket.CloseInternalError, "playback error")
+		}
+	}
+
+	conn.Close()
+
+	if codec == "opus" {
+		if err := writer.Close(); err != nil {
+			srv.LogError.Println("Talk:", err)
+		}
+	}
+
+	if err := talk.Close(); err != nil {
+		srv.LogError.Println("Talk:", err)
+	}
+
+	received, dropped := talk.Received()
+
+	result := fmt.Sprintf("101 Switching Protocols: %s received, %s dropped", received.Round(100*time.Millisecond), dropped.Round(100*time.Millisecond))
+
+	srv.LogInfo.Println("Talk: ended by", session.Username, result)
+
+	// the WebSocket isn't wrapped by the audit log
+	_, err = srv.Db.InsertAudit(db.Audit{
+		User:       truncate(session.Username, 100),
+		AuthMethod: "session",
+		IP:         remoteIP(r),
+		Method:     r.Method,
+		Endpoint:   r.URL.Path,
+		Command:    "talk " + codec,
+		Result:     result,
+	}, []string{})
+	if err != nil {
+		srv.LogError.Println("Audit:", err)
+	}
+}
+
+func (srv *Server) writeTalkError(w http.ResponseWriter, status int, message string) {
+	responseJSON, err := json.Marshal(TalkErrorResponse{Status: "error", Error: message})
+	if err != nil {
+		srv.LogError.Println(err)
+	}
+
+	w.WriteHeader(status)
+	fmt.Fprintln(w, string(responseJSON))
+}
+
+func (srv *Server) speakerResponse() SpeakerResponse {
+	return SpeakerResponse{
+		Volume:  srv.Speaker.Volume(),
+		Muted:   srv.Speaker.Muted(),
+		Talking: srv.Speaker.Talking(),
+		Status:  "success",
+	}
+}
+
+// handler that returns the volume of the speaker and changes it with the form
+// values volume and muted
+func (srv *Server) SpeakerHandler(w http.ResponseWriter, r *http.Request) {
+	setSecureHeaders(w, "json")
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		returnCode405(w, r)
+		return
+	}
+
+	if _, ok := srv.currentSession(r); !ok {
+		returnCode401(w, r)
+		return
+	}
+
+	if srv.Speaker == nil {
+		returnCode404(w, r)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		volume := srv.Speaker.Volume()
+		muted := srv.Speaker.Muted()
+
+		var err error
+
+		if value := r.PostFormValue("volume"); value != "" {
+			volume, err = strconv.Atoi(value)
+			if err != nil || volume < 0 || volume > 100 {
+				auditResult(r, "invalid volume")
+				srv.writeTalkError(w, http.StatusBadRequest, "volume must be between 0 and 100")
+				return
+			}
+		}
+
+		if value := r.PostFormValue("muted"); value != "" {
+			muted, err = strconv.ParseBool(value)
+			if err != nil {
+				auditResult(r, "invalid muted")
+				srv.writeTalkError(w, http.StatusBadRequest, "muted must be true or false")
+				return
+			}
+		}
+
+		auditCommand(r, "volume "+strconv.Itoa(volume)+" muted "+strconv.FormatBool(muted))
+
+		srv.Speaker.SetVolume(volume)
+		srv.Speaker.SetMuted(muted)
+	}
+
+	responseJSON, err := json.Marshal(srv.speakerResponse())
+	if err != nil {
+		srv.LogError.Println(err)
+	}
+
+	fmt.Fprintln(w, string(responseJSON))
+}
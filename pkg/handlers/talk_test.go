@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jempe/gopicam/pkg/audio"
+)
+
+func TestTalkHandler(t *testing.T) {
+	srv := newTestServer(t)
+
+	// the sink fails, so a talk started before the checks returns 500
+	var sinkCalls atomic.Int32
+
+	srv.Speaker = audio.NewSpeaker(func(audio.Format) (io.WriteCloser, error) {
+		sinkCalls.Add(1)
+		return nil, errors.New("the speaker can't be used in the tests")
+	}, time.Second, 100)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/setup", srv.SetupHandler)
+
+	rootMux := http.NewServeMux()
+	rootMux.HandleFunc("/api/talk", srv.TalkHandler)
+	rootMux.Handle("/", srv.Sessions.LoadAndSave(srv.CSRFProtect(mux)))
+
+	c := newTestClient(t, rootMux)
+
+	status, body := c.do(http.MethodPost, "/api/setup", url.Values{
+		"username":              {"admin1"},
+		"password":              {testPassword},
+		"password_confirmation": {testPassword},
+	}, c.sameOriginHeader())
+	if status != http.StatusOK {
+		t.Fatalf("want the first account created; got %d %s", status, body)
+	}
+
+	anonymous := newTestClient(t, rootMux)
+
+	handshake := func(origin string) http.Header {
+		return http.Header{
+			"Origin":                {origin},
+			"Connection":            {"Upgrade"},
+			"Upgrade":               {"websocket"},
+			"Sec-Websocket-Version": {"13"},
+			"Sec-Websocket-Key":     {"dGhlIHNhbXBsZSBub25jZQ=="},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		client *testClient
+		path   string
+		header http.Header
+		want   int
+	}{
+		{name: "Without Session", client: anonymous, path: "/api/talk", header: handshake(anonymous.server.URL), want: http.StatusUnauthorized},
+		{name: "Cross Origin", client: c, path: "/api/talk", header: handshake("https://evil.example"), want: http.StatusForbidden},
+		{name: "Bad Handshake", client: c, path: "/api/talk", header: c.sameOriginHeader(), want: http.StatusBadRequest},
+		{name: "Bad Codec", client: c, path: "/api/talk?codec=mp3", header: handshake(c.server.URL), want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := tt.client.do(http.MethodGet, tt.path, nil, tt.header)
+			if status != tt.want {
+				t.Errorf("want %d; got %d %s", tt.want, status, body)
+			}
+
+			// the speaker is only acquired by the valid handshakes
+			if calls := sinkCalls.Load(); calls != 0 {
+				t.Errorf("want the speaker not acquired; got %d playbacks", calls)
+			}
+		})
+	}
+
+	status, _ = c.do(http.MethodGet, "/api/talk", nil, handshake(c.server.URL))
+	if status != http.StatusInternalServerError || sinkCalls.Load() != 1 {
+		t.Errorf("want the speaker acquired by the valid handshake; got %d with %d playbacks", status, sinkCalls.Load())
+	}
+}
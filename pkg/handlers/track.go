@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jempe/gopicam/pkg/db"
+	"github.com/jempe/gopicam/pkg/tracker"
+)
+
+// defaultTrackTolerance is the default tolerance in meters of the simplification
+// of the tracks, about the accuracy of the GPS of the phones
+const defaultTrackTolerance = 5
+
+// defaultTrackPeriod is the period of the tracks without from parameter
+const defaultTrackPeriod = 24 * time.Hour
+
+// maxTrackLocations is the maximum number of locations read for a track, the
+// tracks with more locations must be split with from and to
+const maxTrackLocations = 100000
+
+type TrackPoint struct {
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	Altitude  int       `json:"altitude"`
+	Time      time.Time `json:"time"`
+}
+
+type TrackResponse struct {
+	Device    DeviceResponse `json:"device"`
+	From      time.Time      `json:"from"`
+	To        time.Time      `json:"to"`
+	Tolerance float64        `json:"tolerance"`
+	Locations int            `json:"locations"`
+	Track     []TrackPoint   `json:"track"`
+	Status    string         `json:"status"`
+}
+
+// handler of the track of a device, the URL is /api/devices/{id}/track. The
+// locations between from and to, unix times or RFC 3339 times that default to
+// the last 24 hours, are simplified with the tolerance parameter in meters,
+// tolerance=0 returns all of them. The format parameter json, gpx, geojson or
+// kml selects the response, the last three are downloads
+func (srv *Server) TrackHandler(w http.ResponseWriter, r *http.Request) {
+	setSecureHeaders(w, "json")
+
+	if r.Method != http.MethodGet {
+		returnCode405(w, r)
+		return
+	}
+
+	if _, ok := srv.currentSession(r); !ok {
+		returnCode401(w, r)
+		return
+	}
+
+	device, err := srv.Db.GetDevice(r.PathValue("id"))
+	if err != nil {
+		returnCode404(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+
+	to := time.Now().UTC()
+	if value := query.Get("to"); value != "" {
+		to, err = parseTrackTime(value)
+		if err != nil {
+			returnCode400(w, r)
+			return
+		}
+	}
+
+	from := to.Add(-defaultTrackPeriod)
+	if value := query.Get("from"); value != "" {
+		from, err = parseTrackTime(value)
+		if err != nil {
+			returnCode400(w, r)
+			return
+		}
+	}
+
+	tolerance := float64(defaultTrackTolerance)
+	if value := query.Get("tolerance"); value != "" {
+		tolerance, err = strconv.ParseFloat(value, 64)
+		if err != nil || !(tolerance >= 0) {
+			returnCode400(w, r)
+			return
+		}
+	}
+
+	format := query.Get("format")
+	if format == "" {
+		format = "json"
+	}
+
+	if _, ok := tracker.ContentTypes[format]; !ok && format != "json" {
+		returnCode400(w, r)
+		return
+	}
+
+	if from.After(to) {
+		returnCode400(w, r)
+		return
+	}
+
+	filters := db.Filters{
+		Operator: "AND",
+		Conditions: []db.Condition{
+			{Field: "Device", Comparison: "=", Value: device.ID},
+			{Field: "DeviceTime", Comparison: "BETWEEN", Value: []int64{from.Unix(), to.Unix()}},
+		},
+	}
+
+	locations, total, err := srv.Db.GetLocationList(0, maxTrackLocations, filters, []string{}, db.SortBy{Field: "DeviceTime", Direction: "ASC"})
+	if err != nil {
+		srv.LogError.Println(err)
+		returnCode500(w, r)
+		return
+	}
+
+	if total > maxTrackLocations {
+		returnCode400(w, r)
+		return
+	}
+
+	simplified := tracker.Simplify(locations, tolerance)
+
+	if format != "json" {
+		w.Header().Set("Content-Type", tracker.ContentTypes[format])
+		w.Header().Set("Content-Disposition", "attachment; filename=\"gopicam-track-"+device.ID+"-"+from.UTC().Format("20060102-150405")+"."+format+"\"")
+
+		err = tracker.WriteTrack(w, format, device.Name, simplified)
+		if err != nil {
+			srv.LogError.Println(err)
+		}
+
+		return
+	}
+
+	response := TrackResponse{
+		Device:    newDeviceResponse(device),
+		From:      from,
+		To:        to,
+		Tolerance: tolerance,
+		Locations: len(locations),
+		Track:     []TrackPoint{},
+		Status:    "success",
+	}
+
+	for _, location := range simplified {
+		latitude, longitude := location.Coordinates()
+
+		response.Track = append(response.Track, TrackPoint{
+			Latitude:  latitude,
+			Longitude: longitude,
+			Altitude:  location.Altitude,
+			Time:      time.Unix(location.DeviceTime, 0).UTC(),
+		})
+	}
+
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		srv.LogError.Println(err)
+	}
+
+	fmt.Fprintln(w, string(responseJSON))
+}
+
+// parseTrackTime parses the from and to parameters of the tracks, unix times in
+// seconds or RFC 3339 times
+func parseTrackTime(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,223 @@
+package tracker
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jempe/gopicam/pkg/db"
+)
+
+// formats of the track downloads
+const (
+	FormatGPX     = "gpx"
+	FormatGeoJSON = "geojson"
+	FormatKML     = "kml"
+)
+
+// ContentTypes are the content types of the track formats
+var ContentTypes = map[string]string{
+	FormatGPX:     "application/gpx+xml",
+	FormatGeoJSON: "application/geo+json",
+	FormatKML:     "application/vnd.google-earth.kml+xml",
+}
+
+// WriteTrack writes the locations of a track, sorted by time, in a GPX 1.1,
+// GeoJSON or KML document. The name is the name of the track
+func WriteTrack(w io.Writer, format string, name string, locations []db.Location) error {
+	switch format {
+	case FormatGPX:
+		return writeGPX(w, name, locations)
+	case FormatGeoJSON:
+		return writeGeoJSON(w, name, locations)
+	case FormatKML:
+		return writeKML(w, name, locations)
+	}
+
+	return errors.New("unknown track format " + format)
+}
+
+type gpxDocument struct {
+	XMLName xml.Name `xml:"http://www.topografix.com/GPX/1/1 gpx"`
+	Version string   `xml:"version,attr"`
+	Creator string   `xml:"creator,attr"`
+	Track   gpxTrack `xml:"trk"`
+}
+
+type gpxTrack struct {
+	Name    string     `xml:"name"`
+	Segment gpxSegment `xml:"trkseg"`
+}
+
+type gpxSegment struct {
+	Points []gpxPoint `xml:"trkpt"`
+}
+
+type gpxPoint struct {
+	Latitude  string `xml:"lat,attr"`
+	Longitude string `xml:"lon,attr"`
+	Elevation int    `xml:"ele"`
+	Time      string `xml:"time"`
+}
+
+func writeGPX(w io.Writer, name string, locations []db.Location) error {
+	document := gpxDocument{Version: "1.1", Creator: "gopicam", Track: gpxTrack{Name: name}}
+
+	for _, location := range locations {
+		latitude, longitude := formatCoordinates(location)
+
+		document.Track.Segment.Points = append(document.Track.Segment.Points, gpxPoint{
+			Latitude:  latitude,
+			Longitude: longitude,
+			Elevation: location.Altitude,
+			Time:      formatTime(location.DeviceTime),
+		})
+	}
+
+	return writeXML(w, document)
+}
+
+type geoJSONCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+type geoJSONFeature struct {
+	Type       string            `json:"type"`
+	Geometry   geoJSONGeometry   `json:"geometry"`
+	Properties geoJSONProperties `json:"properties"`
+}
+
+// geoJSONGeometry is a LineString, or a Point when the track has one location
+type geoJSONGeometry struct {
+	Type        string `json:"type"`
+	Coordinates any    `json:"coordinates"`
+}
+
+// geoJSONProperties has the times of the coordinates like the coordTimes of
+// the GPX tracks converted by togeojson
+type geoJSONProperties struct {
+	Name       string   `json:"name"`
+	CoordTimes []string `json:"coordTimes"`
+}
+
+func writeGeoJSON(w io.Writer, name string, locations []db.Location) error {
+	collection := geoJSONCollection{Type: "FeatureCollection", Features: []geoJSONFeature{}}
+
+	if len(locations) > 0 {
+		positions := [][3]float64{}
+		properties := geoJSONProperties{Name: name}
+
+		for _, location := range locations {
+			latitude, longitude := location.Coordinates()
+
+			// the positions of GeoJSON are longitude, latitude, altitude
+			positions = append(positions, [3]float64{longitude, latitude, float64(location.Altitude)})
+			properties.CoordTimes = append(properties.CoordTimes, formatTime(location.DeviceTime))
+		}
+
+		geometry := geoJSONGeometry{Type: "LineString", Coordinates: positions}
+		if len(positions) == 1 {
+			geometry = geoJSONGeometry{Type: "Point", Coordinates: positions[0]}
+		}
+
+		collection.Features = append(collection.Features, geoJSONFeature{Type: "Feature", Geometry: geometry, Properties: properties})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(collection)
+}
+
+type kmlDocument struct {
+	XMLName  xml.Name `xml:"http://www.opengis.net/kml/2.2 kml"`
+	Document kmlTrack `xml:"Document"`
+}
+
+type kmlTrack struct {
+	Name      string        `xml:"name"`
+	Placemark *kmlPlacemark `xml:"Placemark,omitempty"`
+}
+
+type kmlPlacemark struct {
+	Name       string         `xml:"name"`
+	TimeSpan   kmlTimeSpan    `xml:"TimeSpan"`
+	LineString *kmlCoordinate `xml:"LineString,omitempty"`
+	Point      *kmlCoordinate `xml:"Point,omitempty"`
+}
+
+type kmlTimeSpan struct {
+	Begin string `xml:"begin"`
+	End   string `xml:"end"`
+}
+
+type kmlCoordinate struct {
+	AltitudeMode string `xml:"altitudeMode"`
+	Coordinates  string `xml:"coordinates"`
+}
+
+func writeKML(w io.Writer, name string, locations []db.Location) error {
+	document := kmlDocument{Document: kmlTrack{Name: name}}
+
+	if len(locations) > 0 {
+		coordinates := []string{}
+
+		for _, location := range locations {
+			latitude, longitude := formatCoordinates(location)
+
+			// the coordinates of KML are longitude,latitude,altitude
+			coordinates = append(coordinates, longitude+","+latitude+","+strconv.Itoa(location.Altitude))
+		}
+
+		placemark := &kmlPlacemark{
+			Name:     name,
+			TimeSpan: kmlTimeSpan{Begin: formatTime(locations[0].DeviceTime), End: formatTime(locations[len(locations)-1].DeviceTime)},
+		}
+
+		coordinate := &kmlCoordinate{AltitudeMode: "absolute", Coordinates: strings.Join(coordinates, " ")}
+		if len(coordinates) == 1 {
+			placemark.Point = coordinate
+		} else {
+			placemark.LineString = coordinate
+		}
+
+		document.Document.Placemark = placemark
+	}
+
+	return writeXML(w, document)
+}
+
+func writeXML(w io.Writer, document any) error {
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	err = encoder.Encode(document)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// formatCoordinates returns the coordinates in degrees without exponent, the
+// XML formats don't accept it
+func formatCoordinates(location db.Location) (latitude string, longitude string) {
+	lat, lon := location.Coordinates()
+
+	return strconv.FormatFloat(lat, 'f', -1, 64), strconv.FormatFloat(lon, 'f', -1, 64)
+}
+
+func formatTime(deviceTime int64) string {
+	return time.Unix(deviceTime, 0).UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,196 @@
+package tracker
+
+import (
+	"log"
+	"math"
+	"sync"
+
+	"github.com/jempe/gopicam/pkg/db"
+)
+
+// maxGeofences is the maximum number of geofences checked for each location
+const maxGeofences = 1000
+
+// Distance returns the distance in meters between the location and the border
+// of the geofence, it's negative when the location is inside the geofence
+func Distance(geofence db.Geofence, location db.Location) (float64, error) {
+	err := geofence.Check()
+	if err != nil {
+		return 0, err
+	}
+
+	if geofence.Shape == db.ShapeCircle {
+		x, y := project(location, db.Location{Latitude: geofence.Latitude, Longitude: geofence.Longitude})
+
+		return math.Hypot(x, y) - float64(geofence.Radius), nil
+	}
+
+	vertices, err := geofence.Vertices()
+	if err != nil {
+		return 0, err
+	}
+
+	latitude, longitude := location.Coordinates()
+
+	// the vertices are projected around the location, which is the origin
+	points := make([][2]float64, len(vertices))
+	for i, vertex := range vertices {
+		points[i][0], points[i][1] = projectDegrees(vertex[0], vertex[1], latitude, longitude)
+	}
+
+	distance := math.Inf(1)
+	inside := false
+
+	for i := range points {
+		start, end := points[i], points[(i+1)%len(points)]
+
+		distance = math.Min(distance, planeDistance(0, 0, start[0], start[1], end[0], end[1]))
+
+		// the ray from the origin to the east crosses the edge
+		if (start[1] > 0) != (end[1] > 0) && start[0]+(0-start[1])*(end[0]-start[0])/(end[1]-start[1]) > 0 {
+			inside = !inside
+		}
+	}
+
+	if inside {
+		return -distance, nil
+	}
+
+	return distance, nil
+}
+
+// Transition returns the state of the device in the geofence after the
+// location and the event of the transition, enter, exit or empty when the
+// device stays inside or outside. The locations older than the state and the
+// locations less accurate than the MaxAccuracy of the geofence don't change
+// the state. A device without state enters the geofence with its first
+// location inside it
+func Transition(geofence db.Geofence, state db.GeofenceState, location db.Location) (next db.GeofenceState, event string, err error) {
+	next = state
+
+	if geofence.MaxAccuracy > 0 && location.Accuracy > geofence.MaxAccuracy {
+		return
+	}
+
+	known := state.Device != ""
+	if known && location.DeviceTime < state.DeviceTime {
+		return
+	}
+
+	distance, err := Distance(geofence, location)
+	if err != nil {
+		return
+	}
+
+	next = db.GeofenceState{Geofence: geofence.ID, Device: location.Device, Inside: state.Inside, Location: location.ID, DeviceTime: location.DeviceTime}
+
+	// the hysteresis keeps the devices that move around the border inside
+	if next.Inside && distance > float64(geofence.Hysteresis) {
+		next.Inside = false
+		event = db.EventExit
+	} else if !next.Inside && distance <= 0 {
+		next.Inside = true
+		event = db.EventEnter
+	}
+
+	return
+}
+
+// Geofencer evaluates the locations of the devices in the geofences, it saves
+// their states and events and sends the commands of the geofences when the
+// first device enters or the last device exits
+type Geofencer struct {
+	Db *db.DB
+	// SendCommand sends the commands to the camera
+	SendCommand func(command string)
+	// Notify receives the events after they are saved
+	Notify  func(event db.GeofenceEvent)
+	LogInfo *log.Logger
+	// the states are read and saved by one location at a time
+	mutex sync.Mutex
+}
+
+// Update evaluates a saved location in the geofences that track its device and
+// returns the events
+func (geofencer *Geofencer) Update(location db.Location) (events []db.GeofenceEvent, err error) {
+	geofencer.mutex.Lock()
+	defer geofencer.mutex.Unlock()
+
+	geofences, _, err := geofencer.Db.GetGeofenceList(0, maxGeofences, db.Filters{Operator: "AND"}, []string{}, db.SortBy{Field: "Created", Direction: "ASC"})
+	if err != nil {
+		return
+	}
+
+	for _, geofence := range geofences {
+		if !geofence.Tracks(location.Device) {
+			continue
+		}
+
+		states, err := geofencer.Db.GetGeofenceStates(geofence.ID)
+		if err != nil {
+			return events, err
+		}
+
+		var state db.GeofenceState
+
+		occupied := 0
+
+		for _, saved := range states {
+			if saved.Device == location.Device {
+				state = saved
+			} else if saved.Inside && geofence.Tracks(saved.Device) {
+				occupied++
+			}
+		}
+
+		next, eventName, err := Transition(geofence, state, location)
+		if err != nil {
+			return events, err
+		}
+
+		if next == state {
+			continue
+		}
+
+		err = geofencer.Db.SaveGeofenceState(next)
+		if err != nil {
+			return events, err
+		}
+
+		if eventName == "" {
+			continue
+		}
+
+		event := db.GeofenceEvent{Geofence: geofence.ID, Device: location.Device, Event: eventName, Location: location.ID, DeviceTime: location.DeviceTime}
+
+		// the other devices inside keep the geofence occupied
+		if occupied == 0 {
+			if eventName == db.EventEnter {
+				event.Command = geofence.EnterCommand
+			} else {
+				event.Command = geofence.ExitCommand
+			}
+		}
+
+		event.ID, err = geofencer.Db.InsertGeofenceEvent(event, []string{})
+		if err != nil {
+			return events, err
+		}
+
+		if geofencer.LogInfo != nil {
+			geofencer.LogInfo.Println("Geofence:", location.Device, eventName, geofence.Name, event.Command)
+		}
+
+		if event.Command != "" && geofencer.SendCommand != nil {
+			geofencer.SendCommand(event.Command)
+		}
+
+		if geofencer.Notify != nil {
+			geofencer.Notify(event)
+		}
+
+		events = append(events, event)
+	}
+
+	return
+}
@@ -0,0 +1,199 @@
+package tracker
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/jempe/gopicam/pkg/db"
+)
+
+// metersPerDegree is the length of a degree of latitude
+const metersPerDegree = earthRadius * math.Pi / 180
+
+var home = db.Geofence{
+	ID:           "home",
+	Name:         "Home",
+	Shape:        db.ShapeCircle,
+	Latitude:     db.ScaleCoordinate(52.52),
+	Longitude:    db.ScaleCoordinate(13.405),
+	Radius:       100,
+	Hysteresis:   30,
+	MaxAccuracy:  50,
+	EnterCommand: "md 0",
+	ExitCommand:  "md 1",
+}
+
+// north returns a location of the device the meters north of the center of home
+func north(device string, meters float64, deviceTime int64, accuracy int) db.Location {
+	return db.Location{
+		Device:     device,
+		Latitude:   db.ScaleCoordinate(52.52 + meters/metersPerDegree),
+		Longitude:  home.Longitude,
+		Accuracy:   accuracy,
+		DeviceTime: deviceTime,
+	}
+}
+
+func TestDistance(t *testing.T) {
+	square := db.Geofence{Name: "Block", Shape: db.ShapePolygon, Polygon: "52.519,13.404 52.521,13.404 52.521,13.406 52.519,13.406"}
+
+	tests := []struct {
+		name     string
+		geofence db.Geofence
+		location db.Location
+		want     float64
+	}{
+		{"Circle Center", home, north("phone", 0, 0, 0), -100},
+		{"Circle Inside", home, north("phone", 60, 0, 0), -40},
+		{"Circle Outside", home, north("phone", 250, 0, 0), 150},
+		// the east and west sides are the closest to the center
+		{"Polygon Center", square, north("phone", 0, 0, 0), -0.001 * metersPerDegree * math.Cos(52.52*math.Pi/180)},
+		{"Polygon Inside", square, north("phone", 100, 0, 0), -(0.001*metersPerDegree - 100)},
+		{"Polygon Outside", square, north("phone", 200, 0, 0), 200 - 0.001*metersPerDegree},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			distance, err := Distance(tt.geofence, tt.location)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if math.Abs(distance-tt.want) > 0.5 {
+				t.Errorf("want %.1f m; got %.1f m", tt.want, distance)
+			}
+		})
+	}
+
+	// the concave vertex of the L doesn't make the notch inside
+	l := db.Geofence{Name: "L", Shape: db.ShapePolygon, Polygon: "52.519,13.404 52.523,13.404 52.523,13.405 52.52,13.405 52.52,13.408 52.519,13.408"}
+	if distance, _ := Distance(l, db.Location{Latitude: db.ScaleCoordinate(52.522), Longitude: db.ScaleCoordinate(13.407)}); distance <= 0 {
+		t.Errorf("want the notch of the L outside; got %.1f m", distance)
+	}
+
+	if _, err := Distance(db.Geofence{Name: "Line", Shape: db.ShapePolygon, Polygon: "52.519,13.404 52.521,13.404"}, north("phone", 0, 0, 0)); err == nil {
+		t.Error("want error with a polygon of two vertices")
+	}
+}
+
+func TestTransition(t *testing.T) {
+	// a phone leaves home, waits near the border and comes back
+	track := []struct {
+		name     string
+		location db.Location
+		inside   bool
+		event    string
+	}{
+		{"First Location Outside", north("phone", 300, 100, 10), false, ""},
+		{"Arrives", north("phone", 90, 200, 10), true, db.EventEnter},
+		{"Stays", north("phone", 0, 300, 10), true, ""},
+		{"Border Inside The Hysteresis", north("phone", 120, 400, 10), true, ""},
+		{"Inaccurate Location Outside", north("phone", 500, 500, 80), true, ""},
+		{"Leaves", north("phone", 140, 600, 10), false, db.EventExit},
+		{"Old Location Inside", north("phone", 0, 550, 10), false, ""},
+		{"Border Outside", north("phone", 101, 700, 10), false, ""},
+		{"Comes Back", north("phone", 99, 800, 10), true, db.EventEnter},
+	}
+
+	var state db.GeofenceState
+
+	for _, step := range track {
+		next, event, err := Transition(home, state, step.location)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if next.Inside != step.inside || event != step.event {
+			t.Errorf("%s: want inside %t and event %q; got %t and %q", step.name, step.inside, step.event, next.Inside, event)
+		}
+
+		state = next
+	}
+
+	if state.DeviceTime != 800 || state.Device != "phone" || state.Geofence != "home" {
+		t.Errorf("want the state of the last location; got %+v", state)
+	}
+
+	// the first location inside enters the geofence
+	if _, event, _ := Transition(home, db.GeofenceState{}, north("tablet", 0, 100, 0)); event != db.EventEnter {
+		t.Errorf("want enter with the first location inside; got %q", event)
+	}
+}
+
+func TestGeofencer(t *testing.T) {
+	database := &db.DB{Store: db.NewMemoryStore()}
+	defer database.Close()
+
+	err := database.InitDb()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	geofence := home
+	geofence.ID = ""
+	geofence.Devices = "alice,bob"
+
+	geofence.ID, err = database.InsertGeofence(geofence, []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var commands []string
+
+	geofencer := &Geofencer{Db: database, SendCommand: func(command string) { commands = append(commands, command) }}
+
+	// both phones are at home, they leave one after the other and alice comes
+	// back. The camera of a visitor doesn't change anything
+	track := []db.Location{
+		north("alice", 0, 100, 10),
+		north("bob", 10, 110, 10),
+		north("visitor", 500, 120, 10),
+		north("alice", 1000, 200, 10),
+		north("visitor", 0, 210, 10),
+		north("bob", 2000, 300, 10),
+		north("alice", 0, 400, 10),
+	}
+
+	var events []string
+
+	for _, location := range track {
+		updateEvents, err := geofencer.Update(location)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, event := range updateEvents {
+			events = append(events, event.Device+" "+event.Event)
+		}
+	}
+
+	wantEvents := []string{"alice enter", "bob enter", "alice exit", "bob exit", "alice enter"}
+	if !reflect.DeepEqual(events, wantEvents) {
+		t.Errorf("want events %v; got %v", wantEvents, events)
+	}
+
+	wantCommands := []string{"md 0", "md 1", "md 0"}
+	if !reflect.DeepEqual(commands, wantCommands) {
+		t.Errorf("want commands %v; got %v", wantCommands, commands)
+	}
+
+	saved, total, err := database.GetGeofenceEventList(0, 10, db.Filters{Operator: "AND", Conditions: []db.Condition{{Field: "Geofence", Comparison: "=", Value: geofence.ID}}}, []string{}, db.SortBy{Field: "DeviceTime", Direction: "ASC"})
+	if err != nil || total != 5 {
+		t.Fatalf("want 5 saved events; got %d %v", total, err)
+	}
+
+	if saved[3].Device != "bob" || saved[3].Command != "md 1" {
+		t.Errorf("want the exit of bob with md 1; got %+v", saved[3])
+	}
+
+	_, err = database.DeleteGeofence(geofence.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	states, err := database.GetGeofenceStates(geofence.ID)
+	if err != nil || len(states) != 0 {
+		t.Errorf("want the states deleted with the geofence; got %+v %v", states, err)
+	}
+}
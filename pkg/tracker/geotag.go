@@ -0,0 +1,307 @@
+package tracker
+
+import (
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jempe/gopicam/pkg/db"
+	"github.com/jempe/gopicam/pkg/exif"
+)
+
+// geotagPage is the number of media read at a time by the geotagging
+const geotagPage = 100
+
+// Geotagger saves the location of the photos and videos, interpolated from the
+// locations of a device around their DeviceTime
+type Geotagger struct {
+	Db *db.DB
+	// Device is the ID of the device whose locations geotag the media
+	Device string
+	// Window is the maximum time between the media and the locations
+	Window time.Duration
+	// MediaFolder has the files of the media, the GPS tags are written in the
+	// EXIF data of the JPEG photos. The files aren't changed when it's empty
+	MediaFolder string
+	LogInfo     *log.Logger
+	LogError    *log.Logger
+}
+
+// Locate returns the location of the device at the unix time deviceTime, it's
+// interpolated between the locations before and after it or it's the nearest
+// location when there's only one in the window. ok is false without locations
+// in the window
+func (geotagger *Geotagger) Locate(deviceTime int64) (latitude int, longitude int, geotag string, ok bool, err error) {
+	window := int64(geotagger.Window / time.Second)
+
+	nearest := func(from int64, to int64, direction string) (location db.Location, found bool, err error) {
+		filters := db.Filters{
+			Operator: "AND",
+			Conditions: []db.Condition{
+				{Field: "Device", Comparison: "=", Value: geotagger.Device},
+				{Field: "DeviceTime", Comparison: "BETWEEN", Value: []int64{from, to}},
+			},
+		}
+
+		locations, _, err := geotagger.Db.GetLocationList(0, 1, filters, []string{}, db.SortBy{Field: "DeviceTime", Direction: direction})
+		if err != nil || len(locations) == 0 {
+			return
+		}
+
+		return locations[0], true, nil
+	}
+
+	before, okBefore, err := nearest(deviceTime-window, deviceTime, "DESC")
+	if err != nil {
+		return
+	}
+
+	after, okAfter, err := nearest(deviceTime, deviceTime+window, "ASC")
+	if err != nil {
+		return
+	}
+
+	switch {
+	case okBefore && okAfter && before.DeviceTime < deviceTime && after.DeviceTime > deviceTime:
+		latitude, longitude = Interpolate(before, after, deviceTime)
+		return latitude, longitude, db.GeotagInterpolated, true, nil
+	case okBefore && (!okAfter || deviceTime-before.DeviceTime <= after.DeviceTime-deviceTime):
+		return before.Latitude, before.Longitude, db.GeotagNearest, true, nil
+	case okAfter:
+		return after.Latitude, after.Longitude, db.GeotagNearest, true, nil
+	}
+
+	return
+}
+
+// Interpolate returns the coordinates between the locations at the unix time
+// deviceTime, moving at constant speed from before to after. The longitudes
+// are interpolated through the antimeridian when it's shorter
+func Interpolate(before db.Location, after db.Location, deviceTime int64) (latitude int, longitude int) {
+	if after.DeviceTime == before.DeviceTime {
+		return before.Latitude, before.Longitude
+	}
+
+	fraction := float64(deviceTime-before.DeviceTime) / float64(after.DeviceTime-before.DeviceTime)
+
+	latitude = before.Latitude + int(math.Round(float64(after.Latitude-before.Latitude)*fraction))
+
+	turn := db.ScaleCoordinate(360)
+
+	difference := after.Longitude - before.Longitude
+	if difference > turn/2 {
+		difference -= turn
+	} else if difference < -turn/2 {
+		difference += turn
+	}
+
+	longitude = before.Longitude + int(math.Round(float64(difference)*fraction))
+	if longitude > turn/2 {
+		longitude -= turn
+	} else if longitude < -turn/2 {
+		longitude += turn
+	}
+
+	return
+}
+
+// Tag geotags the photos and videos without geotag that are older than the
+// window at the time now, so the locations after them were received. The media
+// without locations in the window get the GeotagNone geotag so they aren't
+// looked up again
+func (geotagger *Geotagger) Tag(now time.Time) (photos int, videos int, err error) {
+	if geotagger.Device == "" {
+		return
+	}
+
+	untagged := db.Filters{
+		Operator: "AND",
+		Conditions: []db.Condition{
+			{Field: "Geotag", Comparison: "=", Value: ""},
+			{Field: "DeviceTime", Comparison: "<=", Value: now.Add(-geotagger.Window).Unix()},
+		},
+	}
+
+	sortBy := db.SortBy{Field: "DeviceTime", Direction: "ASC"}
+
+	cursor := ""
+
+	for {
+		var page []db.Photo
+
+		page, cursor, err = geotagger.Db.GetPhotoPage(cursor, geotagPage, untagged, []string{}, sortBy)
+		if err != nil {
+			return
+		}
+
+		for _, photo := range page {
+			var tagged bool
+
+			tagged, err = geotagger.tagPhoto(photo)
+			if err != nil {
+				return
+			}
+
+			if tagged {
+				photos++
+			}
+		}
+
+		if cursor == "" {
+			break
+		}
+	}
+
+	for {
+		var page []db.Video
+
+		page, cursor, err = geotagger.Db.GetVideoPage(cursor, geotagPage, untagged, []string{}, sortBy)
+		if err != nil {
+			return
+		}
+
+		for _, video := range page {
+			var ok bool
+
+			video.Latitude, video.Longitude, video.Geotag, ok, err = geotagger.Locate(video.DeviceTime)
+			if err != nil {
+				return
+			}
+
+			if !ok {
+				video.Geotag = db.GeotagNone
+			}
+
+			_, err = geotagger.Db.UpdateVideo(video, []string{"Latitude", "Longitude", "Geotag"})
+			if err != nil {
+				return
+			}
+
+			if ok {
+				videos++
+			}
+		}
+
+		if cursor == "" {
+			break
+		}
+	}
+
+	return
+}
+
+// tagPhoto saves the location of the photo and writes it in its EXIF data
+// when it's a JPEG file. The files that can't be changed are logged, the
+// location is saved in the record anyway
+func (geotagger *Geotagger) tagPhoto(photo db.Photo) (tagged bool, err error) {
+	var ok bool
+
+	photo.Latitude, photo.Longitude, photo.Geotag, ok, err = geotagger.Locate(photo.DeviceTime)
+	if err != nil {
+		return
+	}
+
+	if !ok {
+		photo.Geotag = db.GeotagNone
+
+		_, err = geotagger.Db.UpdatePhoto(photo, []string{"Geotag"})
+
+		return
+	}
+
+	fields := []string{"Latitude", "Longitude", "Geotag"}
+
+	fileType := strings.ToLower(photo.FileType)
+
+	if geotagger.MediaFolder != "" && (fileType == "jpg" || fileType == "jpeg") {
+		size, fileErr := geotagger.writeGPS(photo)
+		if fileErr != nil {
+			if geotagger.LogError != nil {
+				geotagger.LogError.Println("Geotag:", photo.FileName(), fileErr)
+			}
+		} else {
+			photo.Size = size
+			fields = append(fields, "Size")
+		}
+	}
+
+	_, err = geotagger.Db.UpdatePhoto(photo, fields)
+	if err != nil {
+		return
+	}
+
+	return true, nil
+}
+
+// writeGPS writes the location of the photo in the EXIF data of its file and
+// returns the new size of the file
+func (geotagger *Geotagger) writeGPS(photo db.Photo) (int, error) {
+	filePath := filepath.Join(geotagger.MediaFolder, photo.FileName())
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return 0, err
+	}
+
+	latitude, longitude := db.Location{Latitude: photo.Latitude, Longitude: photo.Longitude}.Coordinates()
+
+	data, err = exif.SetGPS(data, latitude, longitude, time.Unix(photo.DeviceTime, 0))
+	if err != nil {
+		return 0, err
+	}
+
+	// the new file replaces the photo at once so it's never half written
+	tempFile, err := os.CreateTemp(geotagger.MediaFolder, ".geotag-*")
+	if err != nil {
+		return 0, err
+	}
+
+	defer os.Remove(tempFile.Name())
+
+	_, err = tempFile.Write(data)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	info, err := os.Stat(filePath)
+	if err == nil {
+		err = os.Chmod(tempFile.Name(), info.Mode().Perm())
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	err = os.Rename(tempFile.Name(), filePath)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(data), nil
+}
+
+// Run geotags the new media every interval until the program exits, it
+// doesn't run without device
+func (geotagger *Geotagger) Run(interval time.Duration) {
+	if geotagger.Device == "" || interval <= 0 {
+		return
+	}
+
+	for {
+		photos, videos, err := geotagger.Tag(time.Now().UTC())
+		if err != nil && geotagger.LogError != nil {
+			geotagger.LogError.Println("Geotag:", err)
+		} else if photos+videos > 0 && geotagger.LogInfo != nil {
+			geotagger.LogInfo.Println("Geotag:", photos, "photos and", videos, "videos geotagged")
+		}
+
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,188 @@
+package tracker
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jempe/gopicam/pkg/db"
+	"github.com/jempe/gopicam/pkg/exif"
+)
+
+func TestInterpolate(t *testing.T) {
+	at := func(latitude float64, longitude float64, deviceTime int64) db.Location {
+		return db.Location{Latitude: db.ScaleCoordinate(latitude), Longitude: db.ScaleCoordinate(longitude), DeviceTime: deviceTime}
+	}
+
+	tests := []struct {
+		name          string
+		before        db.Location
+		after         db.Location
+		deviceTime    int64
+		wantLatitude  float64
+		wantLongitude float64
+	}{
+		{"Middle", at(52, 13, 100), at(53, 14, 200), 150, 52.5, 13.5},
+		{"Quarter", at(52, 13, 100), at(53, 14, 200), 125, 52.25, 13.25},
+		{"Same Time", at(52, 13, 100), at(53, 14, 100), 100, 52, 13},
+		{"Antimeridian", at(-17, 179, 100), at(-18, -179, 200), 175, -17.75, -179.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			latitude, longitude := Interpolate(tt.before, tt.after, tt.deviceTime)
+
+			if latitude != db.ScaleCoordinate(tt.wantLatitude) || longitude != db.ScaleCoordinate(tt.wantLongitude) {
+				t.Errorf("want %v,%v; got %v,%v", tt.wantLatitude, tt.wantLongitude, float64(latitude)/db.CoordinateScale, float64(longitude)/db.CoordinateScale)
+			}
+		})
+	}
+}
+
+func TestGeotagger(t *testing.T) {
+	database := &db.DB{Store: db.NewMemoryStore()}
+	defer database.Close()
+
+	err := database.InitDb()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mediaFolder := t.TempDir()
+
+	const start = 1700000000
+
+	// the dashcam drives north at 10 m/s, the phone of the passenger reports
+	// its location every minute
+	for i := int64(0); i <= 10; i++ {
+		_, err = database.InsertLocation(north("phone", float64(i*600), start+i*60, 10), []string{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// another phone that isn't used for the geotags
+	_, err = database.InsertLocation(north("other", 5000, start+30, 10), []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var photoData bytes.Buffer
+
+	err = jpeg.Encode(&photoData, image.NewGray(image.Rect(0, 0, 8, 8)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	photos := map[string]struct {
+		deviceTime int64
+		geotag     string
+		meters     float64
+	}{
+		"middle":   {start + 90, db.GeotagInterpolated, 900},
+		"exact":    {start + 120, db.GeotagNearest, 1200},
+		"after":    {start + 600 + 100, db.GeotagNearest, 6000},
+		"far away": {start + 600 + 1000, db.GeotagNone, 0},
+		"recent":   {start + 3000, "", 0},
+	}
+
+	ids := map[string]string{}
+
+	for name, photo := range photos {
+		ids[name], err = database.InsertPhoto(db.Photo{FileType: "jpg", Size: photoData.Len(), DeviceTime: photo.deviceTime}, []string{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = os.WriteFile(filepath.Join(mediaFolder, ids[name]+".jpg"), photoData.Bytes(), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	videoID, err := database.InsertVideo(db.Video{FileType: "mp4", DeviceTime: start + 30}, []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	geotagger := &Geotagger{Db: database, Device: "phone", Window: 5 * time.Minute, MediaFolder: mediaFolder}
+
+	now := time.Unix(start+3000, 0)
+
+	taggedPhotos, taggedVideos, err := geotagger.Tag(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if taggedPhotos != 3 || taggedVideos != 1 {
+		t.Errorf("want 3 photos and 1 video geotagged; got %d and %d", taggedPhotos, taggedVideos)
+	}
+
+	for name, want := range photos {
+		photo, err := database.GetPhoto(ids[name])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if photo.Geotag != want.geotag {
+			t.Errorf("%s: want geotag %q; got %q", name, want.geotag, photo.Geotag)
+		}
+
+		data, err := os.ReadFile(filepath.Join(mediaFolder, photo.FileName()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if photo.Size != len(data) {
+			t.Errorf("%s: want the size %d of the file; got %d", name, len(data), photo.Size)
+		}
+
+		located := want.geotag != "" && want.geotag != db.GeotagNone
+
+		latitude, longitude, ok, err := exif.GPS(data)
+		if err != nil || ok != located {
+			t.Fatalf("%s: want GPS tags %v; got %v %v", name, located, ok, err)
+		}
+
+		if !located {
+			continue
+		}
+
+		wantLocation := north("phone", want.meters, 0, 0)
+
+		if photo.Latitude != wantLocation.Latitude || photo.Longitude != wantLocation.Longitude {
+			t.Errorf("%s: want %d,%d; got %d,%d", name, wantLocation.Latitude, wantLocation.Longitude, photo.Latitude, photo.Longitude)
+		}
+
+		wantLatitude, wantLongitude := wantLocation.Coordinates()
+		if math.Abs(latitude-wantLatitude) > 1e-6 || math.Abs(longitude-wantLongitude) > 1e-6 {
+			t.Errorf("%s: want EXIF %v,%v; got %v,%v", name, wantLatitude, wantLongitude, latitude, longitude)
+		}
+	}
+
+	video, err := database.GetVideo(videoID)
+	if err != nil || video.Geotag != db.GeotagInterpolated || video.Latitude != north("phone", 300, 0, 0).Latitude {
+		t.Errorf("want the video geotagged 300 m north; got %+v %v", video, err)
+	}
+
+	// the geotagged media and the media without locations aren't looked up
+	// again, even when a late location would geotag them
+	_, err = database.InsertLocation(north("phone", 0, start+600+1000, 10), []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	taggedPhotos, taggedVideos, err = geotagger.Tag(now)
+	if err != nil || taggedPhotos+taggedVideos != 0 {
+		t.Errorf("want no media geotagged again; got %d %d %v", taggedPhotos, taggedVideos, err)
+	}
+
+	if photo, err := database.GetPhoto(ids["far away"]); err != nil || photo.Geotag != db.GeotagNone {
+		t.Errorf("want the photo without locations not looked up again; got %+v %v", photo, err)
+	}
+}
@@ -0,0 +1,128 @@
+package tracker
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jempe/gopicam/pkg/db"
+)
+
+// The parameters of the OsmAnd protocol of Traccar and their aliases used by
+// GPSLogger and the other trackers that send the location in the URL
+var (
+	latitudeParams  = []string{"lat", "latitude"}
+	longitudeParams = []string{"lon", "lng", "longitude"}
+	timeParams      = []string{"timestamp", "time", "tst"}
+	accuracyParams  = []string{"accuracy", "acc"}
+	altitudeParams  = []string{"altitude", "alt"}
+	bearingParams   = []string{"bearing", "heading", "dir"}
+	batteryParams   = []string{"batt", "battery"}
+	wifiParams      = []string{"wifi", "ssid"}
+)
+
+// ParseQuery returns the location of the parameters of the OsmAnd protocol,
+// sent by the OsmAnd plugin, the Traccar client and the custom URLs of
+// GPSLogger. The time is a unix time in seconds or milliseconds or a RFC 3339
+// time, received is used when it's missing. The speed parameter is in knots
+// like the OsmAnd protocol and spd in m/s like GPSLogger. The DeviceIndex is
+// the index parameter or the time of the location
+func ParseQuery(values url.Values, received time.Time) (location db.Location, err error) {
+	latitude, err := floatParam(values, latitudeParams)
+	if err == nil {
+		var longitude float64
+
+		longitude, err = floatParam(values, longitudeParams)
+		if err == nil {
+			err = setCoordinates(&location, latitude, longitude)
+		}
+	}
+
+	if err != nil {
+		return
+	}
+
+	location.DeviceTime = received.Unix()
+
+	if value, name := param(values, timeParams); name != "" {
+		location.DeviceTime, err = parseTime(value)
+		if err != nil {
+			return
+		}
+	}
+
+	location.DeviceIndex = int(location.DeviceTime)
+
+	if value, name := param(values, []string{"index"}); name != "" {
+		location.DeviceIndex, err = strconv.Atoi(value)
+		if err != nil {
+			return location, fmt.Errorf("invalid index %q", value)
+		}
+	}
+
+	measures := []struct {
+		target *int
+		params []string
+		scale  float64
+	}{
+		{&location.Accuracy, accuracyParams, 1},
+		{&location.Altitude, altitudeParams, 1},
+		{&location.Speed, []string{"speed"}, knotsToKmh},
+		{&location.Speed, []string{"spd"}, msToKmh},
+		{&location.Battery, batteryParams, 1},
+	}
+
+	for _, measure := range measures {
+		if _, name := param(values, measure.params); name == "" {
+			continue
+		}
+
+		value, err := floatParam(values, measure.params)
+		if err != nil {
+			return location, err
+		}
+
+		*measure.target = round(value * measure.scale)
+	}
+
+	if _, name := param(values, bearingParams); name != "" {
+		value, err := floatParam(values, bearingParams)
+		if err != nil {
+			return location, err
+		}
+
+		location.BearingTo = bearing(value)
+	}
+
+	location.Wifi, _ = param(values, wifiParams)
+
+	return
+}
+
+// param returns the value of the first parameter that is set, the name is
+// empty when none of them is set
+func param(values url.Values, names []string) (value string, name string) {
+	for _, name := range names {
+		if value := strings.TrimSpace(values.Get(name)); value != "" {
+			return value, name
+		}
+	}
+
+	return "", ""
+}
+
+func floatParam(values url.Values, names []string) (float64, error) {
+	value, name := param(values, names)
+	if name == "" {
+		return 0, fmt.Errorf("the %s parameter is required", names[0])
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", name, value)
+	}
+
+	return number, nil
+}
@@ -0,0 +1,72 @@
+package tracker
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/jempe/gopicam/pkg/db"
+)
+
+// ownTracksMessage is the JSON payload of the HTTP mode of OwnTracks, see
+// https://owntracks.org/booklet/tech/json/. The messages of the other types
+// don't have the fields of the location
+type ownTracksMessage struct {
+	Type      string   `json:"_type"`
+	Latitude  *float64 `json:"lat"`
+	Longitude *float64 `json:"lon"`
+	// Accuracy and Altitude in meters
+	Accuracy float64 `json:"acc"`
+	Altitude float64 `json:"alt"`
+	// Velocity in km/h
+	Velocity float64 `json:"vel"`
+	Battery  float64 `json:"batt"`
+	// Course over ground in degrees
+	Course    float64 `json:"cog"`
+	Timestamp int64   `json:"tst"`
+	SSID      string  `json:"SSID"`
+}
+
+// ParseOwnTracks returns the location of an OwnTracks message, the messages
+// that aren't locations return ErrIgnored. OwnTracks doesn't number the
+// locations, the DeviceIndex is the time of the location
+func ParseOwnTracks(data []byte) (location db.Location, err error) {
+	var message ownTracksMessage
+
+	err = json.Unmarshal(data, &message)
+	if err != nil {
+		return location, errors.New("invalid OwnTracks message: " + err.Error())
+	}
+
+	if message.Type != "location" {
+		return location, ErrIgnored
+	}
+
+	if message.Latitude == nil || message.Longitude == nil || message.Timestamp <= 0 {
+		return location, errors.New("the OwnTracks location doesn't have lat, lon or tst")
+	}
+
+	err = setCoordinates(&location, *message.Latitude, *message.Longitude)
+	if err != nil {
+		return
+	}
+
+	location.Accuracy = round(message.Accuracy)
+	location.Altitude = round(message.Altitude)
+	location.Battery = round(message.Battery)
+
+	// iOS sends -1 when the speed and the course are unknown
+	if message.Velocity > 0 {
+		location.Speed = round(message.Velocity)
+	}
+
+	if message.Course > 0 {
+		location.BearingTo = bearing(message.Course)
+	}
+
+	location.DeviceTime = message.Timestamp
+	location.DeviceIndex = int(message.Timestamp)
+	location.Wifi = strings.TrimSpace(message.SSID)
+
+	return
+}
@@ -0,0 +1,246 @@
+package tracker
+
+import (
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jempe/gopicam/pkg/db"
+)
+
+// batteryRecovery is the battery percent above the threshold that ends the low
+// battery alert, it keeps the battery that oscillates around the threshold
+// from raising alerts
+const batteryRecovery = 5
+
+// checkInterval is the interval between the checks of the devices that are not
+// reporting
+const checkInterval = time.Minute
+
+// Monitor keeps the last known state of the devices and raises the alerts of
+// low battery, devices not reporting and devices joining or leaving the home
+// Wi-Fi
+type Monitor struct {
+	Db       *db.DB
+	Settings db.PresenceSettings
+	// Notify receives the alerts after they are saved
+	Notify   func(alert db.Alert)
+	LogInfo  *log.Logger
+	LogError *log.Logger
+	// the states are read and saved by one location at a time
+	mutex sync.Mutex
+}
+
+// Update saves the state of the device after a location received at the
+// received time and returns the alerts it raised. The locations older than the
+// state only update the last seen time
+func (monitor *Monitor) Update(location db.Location, received time.Time) (alerts []db.Alert, err error) {
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+
+	state, known, err := monitor.Db.GetDeviceState(location.Device)
+	if err != nil {
+		return
+	}
+
+	state.Device = location.Device
+	state.LastSeen = received.UTC()
+
+	if state.NotReporting {
+		state.NotReporting = false
+		alerts = append(alerts, db.Alert{Type: db.AlertReporting, Message: monitor.deviceName(location.Device) + " is reporting again"})
+	}
+
+	if !known || location.DeviceTime >= state.DeviceTime {
+		alerts = append(alerts, monitor.transitions(&state, known, location)...)
+
+		state.Location = location.ID
+		state.DeviceTime = location.DeviceTime
+		state.Wifi = location.Wifi
+
+		// the trackers that don't know the battery send 0
+		if location.Battery > 0 {
+			state.Battery = location.Battery
+		}
+	}
+
+	err = monitor.Db.SaveDeviceState(state)
+	if err != nil {
+		return
+	}
+
+	for i := range alerts {
+		alerts[i].Device = location.Device
+		alerts[i].Location = location.ID
+		alerts[i].Battery = location.Battery
+		alerts[i].Wifi = location.Wifi
+	}
+
+	return alerts, monitor.raise(alerts)
+}
+
+// transitions returns the battery and Wi-Fi alerts of the location and updates
+// the flags of the state
+func (monitor *Monitor) transitions(state *db.DeviceState, known bool, location db.Location) (alerts []db.Alert) {
+	name := monitor.deviceName(location.Device)
+	threshold := monitor.Settings.LowBattery
+
+	if threshold > 0 && location.Battery > 0 {
+		if location.Battery <= threshold && !state.LowBattery {
+			state.LowBattery = true
+			alerts = append(alerts, db.Alert{Type: db.AlertLowBattery, Message: "battery of " + name + " at " + strconv.Itoa(location.Battery) + "%"})
+		} else if location.Battery > threshold+batteryRecovery {
+			state.LowBattery = false
+		}
+	}
+
+	homeWifi := monitor.Settings.HomeWifi
+	if homeWifi == "" {
+		return
+	}
+
+	wasHome := known && state.Wifi == homeWifi
+	isHome := location.Wifi == homeWifi
+
+	if isHome && !wasHome {
+		alerts = append(alerts, db.Alert{Type: db.AlertWifiJoin, Message: name + " joined " + homeWifi})
+	} else if wasHome && !isHome {
+		alerts = append(alerts, db.Alert{Type: db.AlertWifiLeave, Message: name + " left " + homeWifi})
+	}
+
+	return
+}
+
+// Check raises the alerts of the devices that didn't send locations for the
+// timeout of the settings, once until they report again
+func (monitor *Monitor) Check(now time.Time) (alerts []db.Alert, err error) {
+	if monitor.Settings.Timeout <= 0 {
+		return
+	}
+
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+
+	states, err := monitor.Db.GetDeviceStates()
+	if err != nil {
+		return
+	}
+
+	timeout := time.Duration(monitor.Settings.Timeout)
+
+	for _, state := range states {
+		if state.NotReporting || now.Sub(state.LastSeen) < timeout {
+			continue
+		}
+
+		state.NotReporting = true
+
+		err = monitor.Db.SaveDeviceState(state)
+		if err != nil {
+			return
+		}
+
+		alerts = append(alerts, db.Alert{
+			Device:  state.Device,
+			Type:    db.AlertNotReporting,
+			Message: monitor.deviceName(state.Device) + " is not reporting since " + state.LastSeen.Format(time.RFC3339),
+			Battery: state.Battery,
+			Wifi:    state.Wifi,
+		})
+	}
+
+	return alerts, monitor.raise(alerts)
+}
+
+// Run checks the devices that are not reporting every minute until the
+// program exits, a zero timeout disables the checks
+func (monitor *Monitor) Run() {
+	if monitor.Settings.Timeout <= 0 {
+		return
+	}
+
+	for {
+		time.Sleep(checkInterval)
+
+		_, err := monitor.Check(time.Now().UTC())
+		if err != nil && monitor.LogError != nil {
+			monitor.LogError.Println("Presence:", err)
+		}
+	}
+}
+
+// raise saves the alerts and sends them to Notify
+func (monitor *Monitor) raise(alerts []db.Alert) error {
+	for i := range alerts {
+		var err error
+
+		alerts[i].ID, err = monitor.Db.InsertAlert(alerts[i], []string{})
+		if err != nil {
+			return err
+		}
+
+		alert, err := monitor.Db.Alerts().Get(alerts[i].ID)
+		if err == nil {
+			alerts[i] = alert
+		}
+
+		if monitor.LogInfo != nil {
+			monitor.LogInfo.Println("Presence:", alerts[i].Type, alerts[i].Message)
+		}
+
+		if monitor.Notify != nil {
+			monitor.Notify(alerts[i])
+		}
+	}
+
+	return nil
+}
+
+// deviceName returns the name of the device for the messages of the alerts,
+// or its ID when it can't be read
+func (monitor *Monitor) deviceName(deviceID string) string {
+	device, err := monitor.Db.GetDevice(deviceID)
+	if err != nil || device.Name == "" {
+		return deviceID
+	}
+
+	return device.Name
+}
+
+// BatteryTrend returns the change of the battery in percent per hour, the
+// slope of the least squares line of the battery of the locations by their
+// DeviceTime. It returns false without two locations with known battery at
+// different times
+func BatteryTrend(locations []db.Location) (float64, bool) {
+	var count, sumX, sumY, sumXX, sumXY float64
+
+	// the times are relative to the first location to keep the sums small
+	var origin int64
+
+	for _, location := range locations {
+		if location.Battery <= 0 {
+			continue
+		}
+
+		if count == 0 {
+			origin = location.DeviceTime
+		}
+
+		x := float64(location.DeviceTime-origin) / 3600
+		y := float64(location.Battery)
+
+		count++
+		sumX += x
+		sumY += y
+		sumXX += x * x
+		sumXY += x * y
+	}
+
+	denominator := count*sumXX - sumX*sumX
+	if count < 2 || denominator == 0 {
+		return 0, false
+	}
+
+	return (count*sumXY - sumX*sumY) / denominator, true
+}
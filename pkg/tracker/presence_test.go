@@ -0,0 +1,146 @@
+package tracker
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jempe/gopicam/pkg/db"
+)
+
+func TestMonitor(t *testing.T) {
+	database := &db.DB{Store: db.NewMemoryStore()}
+	defer database.Close()
+
+	err := database.InitDb()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var notified []string
+
+	monitor := &Monitor{
+		Db:       database,
+		Settings: db.PresenceSettings{LowBattery: 20, Timeout: db.Duration(30 * time.Minute), HomeWifi: "home"},
+		Notify:   func(alert db.Alert) { notified = append(notified, alert.Type) },
+	}
+
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+
+	// the phone starts at home, leaves with the battery going down, stops
+	// reporting, comes back and is charged
+	track := []struct {
+		battery int
+		wifi    string
+		minutes int
+		want    []string
+	}{
+		{80, "home", 0, []string{db.AlertWifiJoin}},
+		{70, "home", 10, nil},
+		{50, "", 20, []string{db.AlertWifiLeave}},
+		{20, "cafe", 30, []string{db.AlertLowBattery}},
+		{18, "cafe", 40, nil},
+		// the battery oscillates around the threshold
+		{22, "", 50, nil},
+		{19, "", 60, nil},
+		{15, "home", 120, []string{db.AlertReporting, db.AlertWifiJoin}},
+		{30, "home", 130, nil},
+		{19, "home", 140, []string{db.AlertLowBattery}},
+		// the trackers without battery send 0
+		{0, "home", 150, nil},
+	}
+
+	for i, step := range track {
+		received := start.Add(time.Duration(step.minutes) * time.Minute)
+
+		if step.minutes == 120 {
+			alerts, err := monitor.Check(received)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(alerts) != 1 || alerts[0].Type != db.AlertNotReporting || alerts[0].Device != "phone" {
+				t.Fatalf("want the not reporting alert of the phone; got %+v", alerts)
+			}
+
+			// the alert is raised once
+			alerts, _ = monitor.Check(received)
+			if len(alerts) != 0 {
+				t.Errorf("want no new alerts; got %+v", alerts)
+			}
+		}
+
+		location := db.Location{ID: "location" + string(rune('a'+i)), Device: "phone", Battery: step.battery, Wifi: step.wifi, DeviceTime: received.Unix()}
+
+		alerts, err := monitor.Update(location, received)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var types []string
+		for _, alert := range alerts {
+			types = append(types, alert.Type)
+
+			if alert.ID == "" || alert.Location != location.ID || alert.Created.IsZero() {
+				t.Errorf("want the saved alert of the location %s; got %+v", location.ID, alert)
+			}
+		}
+
+		if !reflect.DeepEqual(types, step.want) {
+			t.Errorf("location %d: want alerts %v; got %v", i, step.want, types)
+		}
+	}
+
+	// an old location sent again only updates the last seen time
+	received := start.Add(4 * time.Hour)
+
+	alerts, err := monitor.Update(db.Location{ID: "old", Device: "phone", Battery: 90, Wifi: "cafe", DeviceTime: start.Unix()}, received)
+	if err != nil || len(alerts) != 0 {
+		t.Errorf("want no alerts of the old location; got %+v %v", alerts, err)
+	}
+
+	state, ok, err := database.GetDeviceState("phone")
+	if err != nil || !ok {
+		t.Fatalf("want the state of the phone; got %v %v", ok, err)
+	}
+
+	want := db.DeviceState{Device: "phone", LastSeen: received, Location: "locationk", DeviceTime: start.Add(150 * time.Minute).Unix(), Battery: 19, Wifi: "home", LowBattery: true}
+	if !reflect.DeepEqual(state, want) {
+		t.Errorf("want state %+v; got %+v", want, state)
+	}
+
+	_, total, err := database.GetAlertList(0, 100, db.Filters{Operator: "AND"}, []string{}, db.SortBy{Field: "Created", Direction: "ASC"})
+	if err != nil || total != int64(len(notified)) || total != 7 {
+		t.Errorf("want 7 saved and notified alerts; got %d %d %v", total, len(notified), err)
+	}
+}
+
+func TestBatteryTrend(t *testing.T) {
+	location := func(battery int, minutes int64) db.Location {
+		return db.Location{Battery: battery, DeviceTime: 1700000000 + minutes*60}
+	}
+
+	tests := []struct {
+		name      string
+		locations []db.Location
+		want      float64
+		ok        bool
+	}{
+		{"Empty", nil, 0, false},
+		{"One Location", []db.Location{location(50, 0)}, 0, false},
+		{"Same Time", []db.Location{location(50, 0), location(40, 0)}, 0, false},
+		{"Discharging", []db.Location{location(80, 0), location(75, 30), location(70, 60), location(65, 90)}, -10, true},
+		{"Charging", []db.Location{location(20, 0), location(0, 15), location(40, 30)}, 40, true},
+		{"Noisy", []db.Location{location(50, 0), location(52, 60), location(48, 120), location(50, 180)}, -0.4, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trend, ok := BatteryTrend(tt.locations)
+			if ok != tt.ok || math.Abs(trend-tt.want) > 1e-9 {
+				t.Errorf("want %v %v; got %v %v", tt.want, tt.ok, trend, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package tracker
+
+import (
+	"math"
+
+	"github.com/jempe/gopicam/pkg/db"
+)
+
+// earthRadius is the mean radius of the earth in meters
+const earthRadius = 6371008.8
+
+// Simplify removes the locations of the track that are closer than tolerance
+// meters to the line between the locations kept around them, with the
+// Douglas-Peucker algorithm. The first and the last locations are always kept,
+// a zero tolerance keeps all the locations
+func Simplify(locations []db.Location, tolerance float64) []db.Location {
+	if tolerance <= 0 || len(locations) < 3 {
+		return locations
+	}
+
+	keep := make([]bool, len(locations))
+	keep[0] = true
+	keep[len(locations)-1] = true
+
+	// the long tracks would need a deep recursion, the segments to check are
+	// kept in a stack
+	stack := [][2]int{{0, len(locations) - 1}}
+
+	for len(stack) > 0 {
+		first, last := stack[len(stack)-1][0], stack[len(stack)-1][1]
+		stack = stack[:len(stack)-1]
+
+		farthest := -1
+		maxDistance := tolerance
+
+		for i := first + 1; i < last; i++ {
+			distance := segmentDistance(locations[i], locations[first], locations[last])
+			if distance > maxDistance {
+				farthest = i
+				maxDistance = distance
+			}
+		}
+
+		if farthest == -1 {
+			continue
+		}
+
+		keep[farthest] = true
+		stack = append(stack, [2]int{first, farthest}, [2]int{farthest, last})
+	}
+
+	simplified := make([]db.Location, 0, len(locations))
+
+	for i, location := range locations {
+		if keep[i] {
+			simplified = append(simplified, location)
+		}
+	}
+
+	return simplified
+}
+
+// segmentDistance returns the distance in meters between the location and the
+// segment from start to end. The coordinates are projected on a plane tangent
+// at the start of the segment, the error is small for the distances between
+// the locations of a track
+func segmentDistance(location db.Location, start db.Location, end db.Location) float64 {
+	x, y := project(location, start)
+	endX, endY := project(end, start)
+
+	return planeDistance(x, y, 0, 0, endX, endY)
+}
+
+// planeDistance returns the distance between the point x, y and the segment
+// from startX, startY to endX, endY of the plane
+func planeDistance(x float64, y float64, startX float64, startY float64, endX float64, endY float64) float64 {
+	x, y, endX, endY = x-startX, y-startY, endX-startX, endY-startY
+
+	length := endX*endX + endY*endY
+	if length == 0 {
+		return math.Hypot(x, y)
+	}
+
+	// position of the closest point of the segment, between 0 (start) and 1 (end)
+	t := math.Max(0, math.Min(1, (x*endX+y*endY)/length))
+
+	return math.Hypot(x-t*endX, y-t*endY)
+}
+
+// project returns the position of the location in meters east and north of the
+// origin, with an equirectangular projection
+func project(location db.Location, origin db.Location) (x float64, y float64) {
+	latitude, longitude := location.Coordinates()
+	originLatitude, originLongitude := origin.Coordinates()
+
+	return projectDegrees(latitude, longitude, originLatitude, originLongitude)
+}
+
+func projectDegrees(latitude float64, longitude float64, originLatitude float64, originLongitude float64) (x float64, y float64) {
+	// the difference of the longitudes across the antimeridian
+	deltaLongitude := math.Remainder(longitude-originLongitude, 360)
+
+	x = deltaLongitude * math.Pi / 180 * earthRadius * math.Cos(originLatitude*math.Pi/180)
+	y = (latitude - originLatitude) * math.Pi / 180 * earthRadius
+
+	return
+}
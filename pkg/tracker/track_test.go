@@ -0,0 +1,122 @@
+package tracker
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jempe/gopicam/pkg/db"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+func location(latitude float64, longitude float64, altitude int, deviceTime int64) db.Location {
+	return db.Location{Latitude: db.ScaleCoordinate(latitude), Longitude: db.ScaleCoordinate(longitude), Altitude: altitude, DeviceTime: deviceTime}
+}
+
+// a walk in Berlin, north along a street and then east
+var walk = []db.Location{
+	location(52.5200083, 13.4049541, 42, 1700000000),
+	location(52.5209, 13.40496, 43, 1700000060),
+	location(52.5218, 13.4049, 43, 1700000120),
+	location(52.5227, 13.40495, 44, 1700000180),
+	location(52.52271, 13.4064, 44, 1700000240),
+	location(52.5227, 13.4079, 45, 1700000300),
+}
+
+func TestSimplify(t *testing.T) {
+	tests := []struct {
+		name      string
+		tolerance float64
+		want      []db.Location
+	}{
+		{"Without Tolerance", 0, walk},
+		{"Tolerance 5 m", 5, []db.Location{walk[0], walk[3], walk[5]}},
+		{"Tolerance 3 m", 3, []db.Location{walk[0], walk[2], walk[3], walk[5]}},
+		{"Tolerance 1 km", 1000, []db.Location{walk[0], walk[5]}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			simplified := Simplify(walk, tt.tolerance)
+
+			if len(simplified) != len(tt.want) {
+				t.Fatalf("want %d locations; got %d", len(tt.want), len(simplified))
+			}
+
+			for i := range simplified {
+				if simplified[i] != tt.want[i] {
+					t.Errorf("want %+v at %d; got %+v", tt.want[i], i, simplified[i])
+				}
+			}
+		})
+	}
+
+	// the locations that come back to the start are kept
+	loop := []db.Location{walk[0], walk[3], walk[5], walk[0]}
+	if simplified := Simplify(loop, 5); len(simplified) != len(loop) {
+		t.Errorf("want the %d locations of the loop; got %d", len(loop), len(simplified))
+	}
+}
+
+func TestSegmentDistance(t *testing.T) {
+	// 0.001 degrees of latitude are about 111 m
+	distance := segmentDistance(location(0.001, 0.0005, 0, 0), location(0, 0, 0, 0), location(0, 0.001, 0, 0))
+	if distance < 111 || distance > 111.4 {
+		t.Errorf("want about 111.2 m; got %f", distance)
+	}
+
+	// the segment across the antimeridian is short
+	distance = segmentDistance(location(0.001, 180, 0, 0), location(0, 179.9995, 0, 0), location(0, -179.9995, 0, 0))
+	if distance < 111 || distance > 111.4 {
+		t.Errorf("want about 111.2 m across the antimeridian; got %f", distance)
+	}
+}
+
+func TestWriteTrack(t *testing.T) {
+	tracks := []struct {
+		name      string
+		locations []db.Location
+	}{
+		{"track", walk},
+		{"point", walk[:1]},
+		{"empty", []db.Location{}},
+	}
+
+	for _, format := range []string{FormatGPX, FormatGeoJSON, FormatKML} {
+		for _, track := range tracks {
+			golden := filepath.Join("testdata", track.name+"."+format)
+
+			t.Run(golden, func(t *testing.T) {
+				var buffer bytes.Buffer
+
+				err := WriteTrack(&buffer, format, "Alice's <phone> & co", track.locations)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if *update {
+					err = os.WriteFile(golden, buffer.Bytes(), 0644)
+					if err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				want, err := os.ReadFile(golden)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !bytes.Equal(buffer.Bytes(), want) {
+					t.Errorf("want\n%s\ngot\n%s", want, buffer.Bytes())
+				}
+			})
+		}
+	}
+
+	if err := WriteTrack(&bytes.Buffer{}, "csv", "", walk); err == nil {
+		t.Error("want error writing an unknown format")
+	}
+}
@@ -0,0 +1,72 @@
+package tracker
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/jempe/gopicam/pkg/db"
+)
+
+// ErrIgnored is returned for the messages that don't have a location, like the
+// OwnTracks transitions and waypoints, they are saved in the requests but not
+// in the locations
+var ErrIgnored = errors.New("the message doesn't have a location")
+
+// knotsToKmh converts the speed of the OsmAnd protocol
+const knotsToKmh = 1.852
+
+// msToKmh converts the speed of GPSLogger
+const msToKmh = 3.6
+
+// setCoordinates checks the latitude and longitude and sets them in the location
+func setCoordinates(location *db.Location, latitude float64, longitude float64) error {
+	if math.IsNaN(latitude) || latitude < -90 || latitude > 90 {
+		return fmt.Errorf("invalid latitude %v", latitude)
+	}
+
+	if math.IsNaN(longitude) || longitude < -180 || longitude > 180 {
+		return fmt.Errorf("invalid longitude %v", longitude)
+	}
+
+	location.Latitude = db.ScaleCoordinate(latitude)
+	location.Longitude = db.ScaleCoordinate(longitude)
+
+	return nil
+}
+
+// parseTime parses the unix time in seconds or milliseconds or the RFC 3339
+// times sent by the trackers
+func parseTime(value string) (int64, error) {
+	if number, err := strconv.ParseFloat(value, 64); err == nil {
+		// the times after 2286 in seconds are milliseconds
+		if number > 1e10 {
+			number /= 1000
+		}
+
+		return int64(number), nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+
+	return parsed.Unix(), nil
+}
+
+// round converts the measures to the integers of the locations
+func round(value float64) int {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0
+	}
+
+	return int(math.Round(value))
+}
+
+// bearing returns the bearing between 0 and 359 degrees
+func bearing(degrees float64) int {
+	return (round(degrees)%360 + 360) % 360
+}
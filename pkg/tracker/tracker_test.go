@@ -0,0 +1,146 @@
+package tracker
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jempe/gopicam/pkg/db"
+)
+
+// the payloads in testdata were captured from the apps, the IDs and the
+// positions were replaced
+func readSample(t *testing.T, name string) []byte {
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func TestParseOwnTracks(t *testing.T) {
+	tests := []struct {
+		sample string
+		want   db.Location
+	}{
+		{
+			sample: "owntracks-android.json",
+			want:   db.Location{Latitude: 525200083, Longitude: 134049541, Accuracy: 14, Altitude: 42, Speed: 5, Battery: 73, BearingTo: 271, DeviceTime: 1700000000, DeviceIndex: 1700000000, Wifi: "home-net"},
+		},
+		{
+			sample: "owntracks-ios.json",
+			want:   db.Location{Latitude: -338567844, Longitude: 1512131080, Accuracy: 65, Altitude: 38, Battery: 41, DeviceTime: 1700000120, DeviceIndex: 1700000120},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sample, func(t *testing.T) {
+			location, err := ParseOwnTracks(readSample(t, tt.sample))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if location != tt.want {
+				t.Errorf("want %+v; got %+v", tt.want, location)
+			}
+		})
+	}
+
+	for _, sample := range []string{"owntracks-transition.json", "owntracks-lwt.json"} {
+		t.Run(sample, func(t *testing.T) {
+			_, err := ParseOwnTracks(readSample(t, sample))
+			if !errors.Is(err, ErrIgnored) {
+				t.Errorf("want ErrIgnored; got %v", err)
+			}
+		})
+	}
+
+	invalid := []string{
+		`{"_type":"location","lat":52.5,"tst":1700000000}`,
+		`{"_type":"location","lat":52.5,"lon":13.4}`,
+		`{"_type":"location","lat":95,"lon":13.4,"tst":1700000000}`,
+		`{"_type":"location","lat":"52.5","lon":13.4,"tst":1700000000}`,
+		`lat=52.5&lon=13.4`,
+	}
+
+	for _, data := range invalid {
+		if _, err := ParseOwnTracks([]byte(data)); err == nil || errors.Is(err, ErrIgnored) {
+			t.Errorf("want error parsing %s; got %v", data, err)
+		}
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	received := time.Unix(1700000999, 0)
+
+	tests := []struct {
+		sample string
+		want   db.Location
+	}{
+		{
+			sample: "traccar-client.txt",
+			want:   db.Location{Latitude: 525200083, Longitude: 134049541, Accuracy: 14, Altitude: 42, Speed: 5, Battery: 73, BearingTo: 271, DeviceTime: 1700000000, DeviceIndex: 1700000000},
+		},
+		{
+			sample: "osmand.txt",
+			want:   db.Location{Latitude: 525200083, Longitude: 134049541, Altitude: 42, DeviceTime: 1700000000, DeviceIndex: 1700000000},
+		},
+		{
+			sample: "gpslogger.txt",
+			want:   db.Location{Latitude: 525200083, Longitude: 134049541, Accuracy: 14, Altitude: 42, Speed: 5, Battery: 73, BearingTo: 271, DeviceTime: 1700000000, DeviceIndex: 1700000000, Wifi: "home-net"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sample, func(t *testing.T) {
+			values, err := url.ParseQuery(strings.TrimSpace(string(readSample(t, tt.sample))))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			location, err := ParseQuery(values, received)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if location != tt.want {
+				t.Errorf("want %+v; got %+v", tt.want, location)
+			}
+		})
+	}
+
+	t.Run("Without Time", func(t *testing.T) {
+		location, err := ParseQuery(url.Values{"lat": {"1.5"}, "lng": {"-2.25"}, "index": {"42"}}, received)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := db.Location{Latitude: 15000000, Longitude: -22500000, DeviceTime: 1700000999, DeviceIndex: 42}
+		if location != want {
+			t.Errorf("want %+v; got %+v", want, location)
+		}
+	})
+
+	invalid := []string{
+		"lon=13.4",
+		"lat=52.5",
+		"lat=52.5&lon=190",
+		"lat=NaN&lon=13.4",
+		"lat=52.5&lon=13.4&timestamp=yesterday",
+		"lat=52.5&lon=13.4&speed=fast",
+		"lat=52.5&lon=13.4&index=1.5",
+	}
+
+	for _, query := range invalid {
+		values, _ := url.ParseQuery(query)
+
+		if _, err := ParseQuery(values, received); err == nil {
+			t.Errorf("want error parsing %s", query)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package validator
+
+import (
+	_ "embed"
+	"errors"
+	"strings"
+)
+
+const commonPasswordError string = "error:common_password_error"
+const passwordContainsUsernameError string = "error:password_username_error"
+
+// list of breached and common passwords, one lowercase password per line
+//
+//go:embed common_passwords.txt
+var commonPasswordsList string
+
+var commonPasswords map[string]bool
+
+// PasswordPolicy defines the rules that the passwords of the user accounts must follow
+type PasswordPolicy struct {
+	MinLength      int
+	MaxLength      int
+	RejectCommon   bool
+	RejectUsername bool
+}
+
+// DefaultPasswordPolicy is used when the policy isn't configured
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength:      10,
+	MaxLength:      128,
+	RejectCommon:   true,
+	RejectUsername: true,
+}
+
+func init() {
+	commonPasswords = make(map[string]bool)
+
+	for _, commonPassword := range strings.Split(commonPasswordsList, "\n") {
+		commonPassword = strings.TrimSpace(commonPassword)
+
+		if commonPassword != "" {
+			commonPasswords[commonPassword] = true
+		}
+	}
+}
+
+// Validate Password Length and make sure it isn't a common password and doesn't contain the username
+func ValidatePassword(password string, username string, policy PasswordPolicy) (valid bool, err error) {
+	validMinLength, errMinLength := MinLength(password, policy.MinLength)
+	if !validMinLength {
+		err = errMinLength
+		return
+	}
+
+	if policy.MaxLength > 0 {
+		validMaxLength, errMaxLength := MaxLength(password, policy.MaxLength)
+		if !validMaxLength {
+			err = errMaxLength
+			return
+		}
+	}
+
+	if policy.RejectCommon {
+		validCommon, errCommon := NotCommonPassword(password)
+		if !validCommon {
+			err = errCommon
+			return
+		}
+	}
+
+	if policy.RejectUsername && username != "" {
+		if strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+			err = errors.New(passwordContainsUsernameError)
+			return
+		}
+	}
+
+	valid = true
+
+	return
+}
+
+// Check that the password isn't in the list of breached and common passwords
+func NotCommonPassword(password string) (bool, error) {
+	if commonPasswords[strings.ToLower(password)] {
+		return false, errors.New(commonPasswordError)
+	}
+
+	return true, nil
+}
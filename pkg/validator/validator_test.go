@@ -346,3 +346,87 @@ func TestEmail(t *testing.T) {
 		})
 	}
 }
+
+func TestValidatePassword(t *testing.T) {
+	tests := []struct {
+		name       string
+		password   string
+		username   string
+		policy     PasswordPolicy
+		want       bool
+		wantNilErr bool
+	}{
+		{
+			name:       "Empty",
+			password:   "",
+			username:   "username",
+			policy:     DefaultPasswordPolicy,
+			want:       false,
+			wantNilErr: false,
+		},
+		{
+			name:       "Short",
+			password:   "k8#pQz",
+			username:   "username",
+			policy:     DefaultPasswordPolicy,
+			want:       false,
+			wantNilErr: false,
+		},
+		{
+			name:       "Common",
+			password:   "Password123",
+			username:   "username",
+			policy:     DefaultPasswordPolicy,
+			want:       false,
+			wantNilErr: false,
+		},
+		{
+			name:       "Common Allowed",
+			password:   "password123",
+			username:   "username",
+			policy:     PasswordPolicy{MinLength: 8},
+			want:       true,
+			wantNilErr: true,
+		},
+		{
+			name:       "Contains Username",
+			password:   "my-UserName-is-long",
+			username:   "username",
+			policy:     DefaultPasswordPolicy,
+			want:       false,
+			wantNilErr: false,
+		},
+		{
+			name:       "Long",
+			password:   "correct horse battery staple",
+			username:   "username",
+			policy:     PasswordPolicy{MinLength: 10, MaxLength: 20},
+			want:       false,
+			wantNilErr: false,
+		},
+		{
+			name:       "Valid",
+			password:   "correct horse battery staple",
+			username:   "username",
+			policy:     DefaultPasswordPolicy,
+			want:       true,
+			wantNilErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valid, passwordError := ValidatePassword(tt.password, tt.username, tt.policy)
+
+			if valid != tt.want {
+				t.Errorf("want %t; got %t", tt.want, valid)
+			}
+			if passwordError != nil && tt.wantNilErr {
+				t.Errorf("want nil error; got error")
+			}
+			if passwordError == nil && !tt.wantNilErr {
+				t.Errorf("want error; got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,373 @@
+// Package websocket is the server side of the WebSocket protocol of RFC 6455,
+// enough for the browsers that stream to the camera
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// The opcodes of the frames
+const (
+	continuationFrame = 0
+	TextMessage       = 1
+	BinaryMessage     = 2
+	CloseMessage      = 8
+	PingMessage       = 9
+	PongMessage       = 10
+)
+
+// The status codes of the close messages
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseInvalidData     = 1007
+	ClosePolicyViolation = 1008
+	CloseTooBig          = 1009
+	CloseInternalError   = 1011
+)
+
+// DefaultMaxMessageSize is the maximum size of the messages received when
+// MaxMessageSize is 0
+const DefaultMaxMessageSize = 1 << 20
+
+// acceptGUID is added to the key of the handshake, see section 1.3 of RFC 6455
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxControlSize is the maximum size of the payload of the control frames
+const maxControlSize = 125
+
+var ErrBadHandshake = errors.New("websocket: bad handshake")
+var ErrTooBig = errors.New("websocket: message too big")
+var ErrProtocol = errors.New("websocket: protocol error")
+
+// CloseError is returned by ReadMessage when the client closes the connection
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (err *CloseError) Error() string {
+	message := "websocket: closed with status " + strconv.Itoa(err.Code)
+	if err.Reason != "" {
+		message += ": " + err.Reason
+	}
+
+	return message
+}
+
+// Conn is a WebSocket connection, ReadMessage must be called from a single
+// goroutine, the messages can be written from any goroutine
+type Conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	// MaxMessageSize is the maximum size of the messages received
+	MaxMessageSize int64
+
+	writeMutex sync.Mutex
+	closeSent  bool
+}
+
+// CheckHandshake returns ErrBadHandshake when the request isn't a WebSocket
+// handshake, the handlers use it to reject the request before they acquire
+// the resources of the connection
+func CheckHandshake(r *http.Request) error {
+	if r.Method != http.MethodGet ||
+		!headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") ||
+		r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return ErrBadHandshake
+	}
+
+	nonce, err := base64.StdEncoding.DecodeString(r.Header.Get("Sec-WebSocket-Key"))
+	if err != nil || len(nonce) != 16 {
+		return ErrBadHandshake
+	}
+
+	return nil
+}
+
+// Upgrade checks the handshake of the request and switches the connection to
+// the WebSocket protocol. It returns ErrBadHandshake without writing a
+// response when the request isn't a WebSocket handshake
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	err := CheckHandshake(r)
+	if err != nil {
+		return nil, err
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+
+	conn, buffer, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	// the deadlines of the server don't apply to the hijacked connection
+	conn.SetDeadline(time.Time{})
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + AcceptKey(key) + "\r\n\r\n"
+
+	_, err = buffer.WriteString(response)
+	if err == nil {
+		err = buffer.Flush()
+	}
+
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &Conn{conn: conn, reader: buffer.Reader}, nil
+}
+
+// AcceptKey returns the Sec-WebSocket-Accept header of the key of the handshake
+func AcceptKey(key string) string {
+	hash := sha1.Sum([]byte(key + acceptGUID))
+
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// headerContains checks if the comma separated values of the header have the
+// token, ignoring the case
+func headerContains(header http.Header, name string, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ReadMessage returns the next text or binary message, the pings are answered
+// and the pongs are skipped. It returns a *CloseError when the client closes
+// the connection, the close is answered
+func (c *Conn) ReadMessage() (opcode int, data []byte, err error) {
+	maxSize := c.MaxMessageSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxMessageSize
+	}
+
+	for {
+		fin, frameOpcode, payload, err := c.readFrame(maxSize - int64(len(data)))
+		if err != nil {
+			return 0, nil, c.fail(err)
+		}
+
+		switch frameOpcode {
+		case PingMessage:
+			err = c.WriteMessage(PongMessage, payload)
+			if err != nil {
+				return 0, nil, err
+			}
+
+			continue
+		case PongMessage:
+			continue
+		case CloseMessage:
+			return 0, nil, c.closed(payload)
+		case TextMessage, BinaryMessage:
+			if opcode != 0 {
+				return 0, nil, c.fail(ErrProtocol)
+			}
+
+			opcode = frameOpcode
+		case continuationFrame:
+			if opcode == 0 {
+				return 0, nil, c.fail(ErrProtocol)
+			}
+		default:
+			return 0, nil, c.fail(ErrProtocol)
+		}
+
+		data = append(data, payload...)
+
+		if !fin {
+			continue
+		}
+
+		if opcode == TextMessage && !utf8.Valid(data) {
+			c.CloseWithCode(CloseInvalidData, "invalid UTF-8")
+			return 0, nil, ErrProtocol
+		}
+
+		return opcode, data, nil
+	}
+}
+
+// readFrame reads a frame of the client, the payload of the data frames can't
+// be larger than maxSize
+func (c *Conn) readFrame(maxSize int64) (fin bool, opcode int, payload []byte, err error) {
+	header := make([]byte, 2)
+
+	_, err = io.ReadFull(c.reader, header)
+	if err != nil {
+		return
+	}
+
+	fin = header[0]&0x80 != 0
+	opcode = int(header[0] & 0x0f)
+
+	// the extensions aren't negotiated and the clients must mask the frames
+	if header[0]&0x70 != 0 || header[1]&0x80 == 0 {
+		return false, 0, nil, ErrProtocol
+	}
+
+	size := int64(header[1] & 0x7f)
+
+	switch size {
+	case 126:
+		extended := make([]byte, 2)
+
+		_, err = io.ReadFull(c.reader, extended)
+		size = int64(binary.BigEndian.Uint16(extended))
+	case 127:
+		extended := make([]byte, 8)
+
+		_, err = io.ReadFull(c.reader, extended)
+		size = int64(binary.BigEndian.Uint64(extended))
+	}
+
+	if err != nil {
+		return
+	}
+
+	if opcode >= CloseMessage {
+		if !fin || size > maxControlSize {
+			return false, 0, nil, ErrProtocol
+		}
+	} else if size < 0 || size > maxSize {
+		return false, 0, nil, ErrTooBig
+	}
+
+	mask := make([]byte, 4)
+
+	_, err = io.ReadFull(c.reader, mask)
+	if err != nil {
+		return
+	}
+
+	payload = make([]byte, size)
+
+	_, err = io.ReadFull(c.reader, payload)
+	if err != nil {
+		return
+	}
+
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return
+}
+
+// fail closes the connection with the status of the error
+func (c *Conn) fail(err error) error {
+	switch {
+	case errors.Is(err, ErrTooBig):
+		c.CloseWithCode(CloseTooBig, "message too big")
+	case errors.Is(err, ErrProtocol):
+		c.CloseWithCode(CloseProtocolError, "protocol error")
+	}
+
+	return err
+}
+
+// closed answers the close message of the client and returns its status
+func (c *Conn) closed(payload []byte) error {
+	closeErr := &CloseError{}
+
+	if len(payload) >= 2 {
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Reason = string(payload[2:])
+	}
+
+	code := closeErr.Code
+	if code == 0 {
+		code = CloseNormal
+	}
+
+	c.CloseWithCode(code, "")
+
+	return closeErr
+}
+
+// WriteMessage writes a message in a single frame
+func (c *Conn) WriteMessage(opcode int, data []byte) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	if c.closeSent {
+		return net.ErrClosed
+	}
+
+	return c.writeFrame(opcode, data)
+}
+
+func (c *Conn) writeFrame(opcode int, data []byte) error {
+	header := []byte{0x80 | byte(opcode)}
+
+	switch {
+	case len(data) < 126:
+		header = append(header, byte(len(data)))
+	case len(data) <= 0xffff:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(len(data)))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(len(data)))
+	}
+
+	_, err := c.conn.Write(append(header, data...))
+
+	return err
+}
+
+// CloseWithCode sends a close message with the status code and the reason and
+// closes the connection
+func (c *Conn) CloseWithCode(code int, reason string) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	if !c.closeSent {
+		c.closeSent = true
+
+		payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+		payload = append(payload, reason[:min(len(reason), maxControlSize-2)]...)
+
+		// the connection is closed anyway when the client is gone
+		c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+		c.writeFrame(CloseMessage, payload)
+	}
+
+	return c.conn.Close()
+}
+
+// Close closes the connection normally
+func (c *Conn) Close() error {
+	return c.CloseWithCode(CloseNormal, "")
+}
+
+// SetReadDeadline sets the time the next message must be received before
+func (c *Conn) SetReadDeadline(deadline time.Time) error {
+	return c.conn.SetReadDeadline(deadline)
+}
@@ -0,0 +1,321 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testClient is the client side of the connection, it writes masked frames
+type testClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func dial(t *testing.T, server *httptest.Server) *testClient {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+
+	request := "GET /talk HTTP/1.1\r\nHost: camera\r\nUpgrade: websocket\r\nConnection: keep-alive, Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + key + "\r\nSec-WebSocket-Version: 13\r\n\r\n"
+
+	_, err = conn.Write([]byte(request))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader := bufio.NewReader(conn)
+
+	response, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.StatusCode != http.StatusSwitchingProtocols || response.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("want the handshake accepted; got %s %v", response.Status, response.Header)
+	}
+
+	return &testClient{conn: conn, reader: reader}
+}
+
+func (client *testClient) writeFrame(t *testing.T, fin bool, opcode int, payload []byte, masked bool) {
+	t.Helper()
+
+	first := byte(opcode)
+	if fin {
+		first |= 0x80
+	}
+
+	frame := []byte{first}
+
+	maskBit := byte(0)
+	if masked {
+		maskBit = 0x80
+	}
+
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, maskBit|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+
+	data := append([]byte(nil), payload...)
+
+	if masked {
+		mask := []byte{0x12, 0x34, 0x56, 0x78}
+		frame = append(frame, mask...)
+
+		for i := range data {
+			data[i] ^= mask[i%4]
+		}
+	}
+
+	_, err := client.conn.Write(append(frame, data...))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (client *testClient) readFrame(t *testing.T) (opcode int, payload []byte) {
+	t.Helper()
+
+	header := make([]byte, 2)
+
+	_, err := io.ReadFull(client.reader, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if header[0]&0x80 == 0 || header[1]&0x80 != 0 {
+		t.Fatalf("want a final unmasked frame; got %x", header)
+	}
+
+	size := int(header[1] & 0x7f)
+
+	switch size {
+	case 126:
+		extended := make([]byte, 2)
+		io.ReadFull(client.reader, extended)
+		size = int(binary.BigEndian.Uint16(extended))
+	case 127:
+		extended := make([]byte, 8)
+		io.ReadFull(client.reader, extended)
+		size = int(binary.BigEndian.Uint64(extended))
+	}
+
+	payload = make([]byte, size)
+
+	_, err = io.ReadFull(client.reader, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return int(header[0] & 0x0f), payload
+}
+
+func (client *testClient) readClose(t *testing.T) int {
+	t.Helper()
+
+	opcode, payload := client.readFrame(t)
+	if opcode != CloseMessage || len(payload) < 2 {
+		t.Fatalf("want a close message; got %d %q", opcode, payload)
+	}
+
+	return int(binary.BigEndian.Uint16(payload))
+}
+
+// echoServer echoes the messages and sends the error of ReadMessage
+func echoServer(t *testing.T, maxMessageSize int64) (*httptest.Server, chan error) {
+	errs := make(chan error, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		defer conn.Close()
+
+		conn.MaxMessageSize = maxMessageSize
+
+		for {
+			opcode, data, err := conn.ReadMessage()
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			err = conn.WriteMessage(opcode, data)
+			if err != nil {
+				errs <- err
+				return
+			}
+		}
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server, errs
+}
+
+func TestEcho(t *testing.T) {
+	server, errs := echoServer(t, 0)
+
+	client := dial(t, server)
+
+	client.writeFrame(t, true, TextMessage, []byte("hello"), true)
+
+	if opcode, payload := client.readFrame(t); opcode != TextMessage || string(payload) != "hello" {
+		t.Errorf("want the text echoed; got %d %q", opcode, payload)
+	}
+
+	// a fragmented binary message with a ping between the fragments
+	audio := bytes.Repeat([]byte{1, 2, 3, 4}, 20000)
+
+	client.writeFrame(t, false, BinaryMessage, audio[:100], true)
+	client.writeFrame(t, true, PingMessage, []byte("ping"), true)
+	client.writeFrame(t, true, continuationFrame, audio[100:], true)
+
+	if opcode, payload := client.readFrame(t); opcode != PongMessage || string(payload) != "ping" {
+		t.Errorf("want the pong; got %d %q", opcode, payload)
+	}
+
+	if opcode, payload := client.readFrame(t); opcode != BinaryMessage || !bytes.Equal(payload, audio) {
+		t.Errorf("want the binary message echoed; got %d %d bytes", opcode, len(payload))
+	}
+
+	client.writeFrame(t, true, CloseMessage, binary.BigEndian.AppendUint16(nil, CloseGoingAway), true)
+
+	if code := client.readClose(t); code != CloseGoingAway {
+		t.Errorf("want the close answered with %d; got %d", CloseGoingAway, code)
+	}
+
+	var closeErr *CloseError
+	if err := <-errs; !errors.As(err, &closeErr) || closeErr.Code != CloseGoingAway {
+		t.Errorf("want CloseError; got %v", err)
+	}
+}
+
+func TestProtocolErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		write    func(t *testing.T, client *testClient)
+		wantCode int
+		wantErr  error
+	}{
+		{
+			name: "Unmasked",
+			write: func(t *testing.T, client *testClient) {
+				client.writeFrame(t, true, BinaryMessage, []byte{1}, false)
+			},
+			wantCode: CloseProtocolError,
+			wantErr:  ErrProtocol,
+		},
+		{
+			name: "Too Big",
+			write: func(t *testing.T, client *testClient) {
+				client.writeFrame(t, false, BinaryMessage, make([]byte, 600), true)
+				client.writeFrame(t, true, continuationFrame, make([]byte, 600), true)
+			},
+			wantCode: CloseTooBig,
+			wantErr:  ErrTooBig,
+		},
+		{
+			name: "Continuation Without Message",
+			write: func(t *testing.T, client *testClient) {
+				client.writeFrame(t, true, continuationFrame, []byte{1}, true)
+			},
+			wantCode: CloseProtocolError,
+			wantErr:  ErrProtocol,
+		},
+		{
+			name: "Invalid UTF-8",
+			write: func(t *testing.T, client *testClient) {
+				client.writeFrame(t, true, TextMessage, []byte{0xff, 0xfe}, true)
+			},
+			wantCode: CloseInvalidData,
+			wantErr:  ErrProtocol,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, errs := echoServer(t, 1000)
+
+			client := dial(t, server)
+
+			tt.write(t, client)
+
+			if code := client.readClose(t); code != tt.wantCode {
+				t.Errorf("want close status %d; got %d", tt.wantCode, code)
+			}
+
+			if err := <-errs; !errors.Is(err, tt.wantErr) {
+				t.Errorf("want %v; got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestBadHandshake(t *testing.T) {
+	server, _ := echoServer(t, 0)
+
+	response, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("want 400 without the upgrade headers; got %s", response.Status)
+	}
+
+	request, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Upgrade", "websocket")
+	request.Header.Set("Sec-WebSocket-Version", "13")
+	request.Header.Set("Sec-WebSocket-Key", strings.Repeat("a", 10))
+
+	response, err = http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("want 400 with an invalid key; got %s", response.Status)
+	}
+
+	if err := CheckHandshake(request); !errors.Is(err, ErrBadHandshake) {
+		t.Errorf("want ErrBadHandshake with an invalid key; got %v", err)
+	}
+
+	request.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+
+	if err := CheckHandshake(request); err != nil {
+		t.Errorf("want a valid handshake; got %v", err)
+	}
+}